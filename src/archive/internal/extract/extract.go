@@ -0,0 +1,359 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package extract implements the extraction of archive entries into a
+// directory, shared by archive/tar and archive/zip.
+//
+// All files are created below the root directory. Entry names are
+// cleaned and must be local, and symbolic links are never followed
+// when creating files: a path whose parent is a symbolic link is
+// rejected. Symbolic links in the archive are created last, and only
+// if their targets stay within the root.
+package extract
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Options configure an Extractor.
+type Options struct {
+	Prefix          string // prefix for error messages, such as "archive/tar"
+	ErrInsecurePath error  // returned for names and links that are not local
+
+	MaxFiles int   // maximum number of entries, or 0 for no limit
+	MaxSize  int64 // maximum total size of files, or 0 for no limit
+}
+
+// An Extractor creates files below a root directory.
+type Extractor struct {
+	root string
+	opts Options
+
+	files int   // number of entries so far
+	size  int64 // total size of files so far
+
+	dirs  map[string]bool   // directories known to be real, keyed by relative path
+	metas []dirMeta         // directories whose mode and time are set by Close
+	links map[string]string // symbolic links to create in Close, keyed by relative path
+}
+
+type dirMeta struct {
+	path  string
+	mode  fs.FileMode
+	mtime time.Time
+}
+
+// New returns an Extractor that creates files below root,
+// creating root if necessary.
+func New(root string, opts Options) (*Extractor, error) {
+	if err := os.MkdirAll(root, 0o777); err != nil {
+		return nil, err
+	}
+	return &Extractor{
+		root:  root,
+		opts:  opts,
+		dirs:  map[string]bool{".": true},
+		links: make(map[string]string),
+	}, nil
+}
+
+// CheckLimits reports an error if an archive with the given number
+// of entries and total size exceeds the limits.
+func (x *Extractor) CheckLimits(files int, size int64) error {
+	if x.opts.MaxFiles > 0 && files > x.opts.MaxFiles {
+		return fmt.Errorf("%s: archive has more than %d entries", x.opts.Prefix, x.opts.MaxFiles)
+	}
+	if x.opts.MaxSize > 0 && size > x.opts.MaxSize {
+		return fmt.Errorf("%s: archive is larger than %d bytes", x.opts.Prefix, x.opts.MaxSize)
+	}
+	return nil
+}
+
+// add counts an entry of the given size against the limits.
+func (x *Extractor) add(size int64) error {
+	x.files++
+	if size < 0 || size > math.MaxInt64-x.size {
+		x.size = math.MaxInt64
+	} else {
+		x.size += size
+	}
+	return x.CheckLimits(x.files, x.size)
+}
+
+// Skip counts an entry that is not extracted, such as a device file,
+// against the limits.
+func (x *Extractor) Skip() error {
+	return x.add(0)
+}
+
+func (x *Extractor) insecure(name string) error {
+	return fmt.Errorf("%w: %q", x.opts.ErrInsecurePath, name)
+}
+
+// localize converts the archive entry name to a local path,
+// relative to the root.
+func (x *Extractor) localize(name string) (string, error) {
+	rel, err := filepath.Localize(path.Clean(name))
+	if err != nil {
+		return "", x.insecure(name)
+	}
+	return rel, nil
+}
+
+// mkdirAll creates the directory dir, relative to the root, and any
+// missing parents. Each existing component must be a directory,
+// not a symbolic link.
+func (x *Extractor) mkdirAll(dir string) error {
+	if x.dirs[dir] {
+		return nil
+	}
+	if err := x.mkdirAll(filepath.Dir(dir)); err != nil {
+		return err
+	}
+	p := filepath.Join(x.root, dir)
+	fi, err := os.Lstat(p)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		if err := os.Mkdir(p, 0o777); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case fi.Mode()&fs.ModeSymlink != 0:
+		return x.insecure(filepath.ToSlash(dir))
+	case !fi.IsDir():
+		return &fs.PathError{Op: "mkdir", Path: p, Err: fs.ErrExist}
+	}
+	x.dirs[dir] = true
+	return nil
+}
+
+// prepare returns the path for the non-directory entry name,
+// creating its parent directories and removing any existing file
+// of that name.
+func (x *Extractor) prepare(name string) (string, error) {
+	rel, err := x.localize(name)
+	if err != nil {
+		return "", err
+	}
+	if rel == "." {
+		return "", x.insecure(name)
+	}
+	if err := x.mkdirAll(filepath.Dir(rel)); err != nil {
+		return "", err
+	}
+	p := filepath.Join(x.root, rel)
+	if err := x.remove(p); err != nil {
+		return "", err
+	}
+	delete(x.links, rel)
+	return p, nil
+}
+
+// remove removes the file at p, unless it is a directory.
+func (x *Extractor) remove(p string) error {
+	fi, err := os.Lstat(p)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	case fi.IsDir():
+		return &fs.PathError{Op: "create", Path: p, Err: fs.ErrExist}
+	}
+	return os.Remove(p)
+}
+
+// File creates the regular file name, of the given size, and calls
+// write to fill it. It then sets the permissions and modification
+// time of the file; a zero mtime leaves the time unchanged.
+func (x *Extractor) File(name string, size int64, mode fs.FileMode, mtime time.Time, write func(*os.File) error) error {
+	if err := x.add(size); err != nil {
+		return err
+	}
+	p, err := x.prepare(name)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(p, mode.Perm()); err != nil {
+		return err
+	}
+	return os.Chtimes(p, time.Time{}, mtime)
+}
+
+// Dir creates the directory name. Its permissions and modification
+// time are set by Close, after the files in it have been created.
+func (x *Extractor) Dir(name string, mode fs.FileMode, mtime time.Time) error {
+	if err := x.add(0); err != nil {
+		return err
+	}
+	rel, err := x.localize(name)
+	if err != nil {
+		return err
+	}
+	if rel == "." {
+		return nil // Leave the root alone.
+	}
+	if err := x.mkdirAll(rel); err != nil {
+		return err
+	}
+	x.metas = append(x.metas, dirMeta{filepath.Join(x.root, rel), mode.Perm(), mtime})
+	return nil
+}
+
+// Link creates name as a hard link to the regular file target,
+// an earlier entry of the archive.
+func (x *Extractor) Link(name, target string) error {
+	if err := x.add(0); err != nil {
+		return err
+	}
+	trel, err := x.localize(target)
+	if err != nil {
+		return err
+	}
+	if err := x.mkdirAll(filepath.Dir(trel)); err != nil {
+		return err
+	}
+	tp := filepath.Join(x.root, trel)
+	fi, err := os.Lstat(tp)
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("%s: hard link %q to non-regular file %q", x.opts.Prefix, name, target)
+	}
+	if rel, err := x.localize(name); err == nil && rel == trel {
+		return fmt.Errorf("%s: hard link %q to itself", x.opts.Prefix, name)
+	}
+	p, err := x.prepare(name)
+	if err != nil {
+		return err
+	}
+	return os.Link(tp, p)
+}
+
+// Symlink records that name is a symbolic link to target.
+// The link is created by Close.
+func (x *Extractor) Symlink(name, target string) error {
+	if err := x.add(0); err != nil {
+		return err
+	}
+	rel, err := x.localize(name)
+	if err != nil {
+		return err
+	}
+	if rel == "." || !x.localLink(rel, target) {
+		return x.insecure(name)
+	}
+	x.links[rel] = target
+	return nil
+}
+
+// localLink reports whether a symbolic link at rel to target resolves
+// to a path within the root.
+//
+// The parents of rel are real directories, so leading ".." elements
+// of target can be resolved lexically. A ".." after any other element
+// is rejected, since it would be resolved relative to wherever a
+// symbolic link in the target points. The remaining elements may only
+// pass through symbolic links created by the Extractor, whose targets
+// are checked in the same way.
+func (x *Extractor) localLink(rel, target string) bool {
+	if target == "" || filepath.IsAbs(target) || filepath.VolumeName(target) != "" ||
+		strings.HasPrefix(target, "/") || strings.HasPrefix(target, `\`) {
+		return false
+	}
+	var elems []string
+	if dir := filepath.Dir(rel); dir != "." {
+		elems = strings.Split(filepath.ToSlash(dir), "/")
+	}
+	descending := false
+	for _, e := range strings.Split(filepath.ToSlash(target), "/") {
+		switch e {
+		case "", ".":
+		case "..":
+			if descending || len(elems) == 0 {
+				return false
+			}
+			elems = elems[:len(elems)-1]
+		default:
+			descending = true
+			elems = append(elems, e)
+			p, err := filepath.Localize(path.Join(elems...))
+			if err != nil {
+				return false
+			}
+			if _, ok := x.links[p]; ok {
+				continue
+			}
+			fi, err := os.Lstat(filepath.Join(x.root, p))
+			if err == nil && fi.Mode()&fs.ModeSymlink != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Close creates the symbolic links and sets the permissions and
+// modification times of directories.
+func (x *Extractor) Close() error {
+	rels := make([]string, 0, len(x.links))
+	for rel := range x.links {
+		rels = append(rels, rel)
+	}
+	slices.Sort(rels)
+	for _, rel := range rels {
+		target := x.links[rel]
+		if !x.localLink(rel, target) {
+			return x.insecure(filepath.ToSlash(rel))
+		}
+		if err := x.mkdirAll(filepath.Dir(rel)); err != nil {
+			return err
+		}
+		p := filepath.Join(x.root, rel)
+		if err := x.remove(p); err != nil {
+			return err
+		}
+		if err := os.Symlink(filepath.FromSlash(target), p); err != nil {
+			return err
+		}
+	}
+
+	// Set the innermost directories first, since changing
+	// a directory's permissions may prevent access to its contents.
+	slices.SortStableFunc(x.metas, func(a, b dirMeta) int {
+		return strings.Compare(a.path, b.path)
+	})
+	for i := len(x.metas) - 1; i >= 0; i-- {
+		m := x.metas[i]
+		if err := os.Chmod(m.path, m.mode); err != nil {
+			return err
+		}
+		if err := os.Chtimes(m.path, time.Time{}, m.mtime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tar
+
+import (
+	"archive/internal/extract"
+	"io"
+	"os"
+)
+
+// ExtractOptions limit the resources used by [Reader.Extract].
+// The zero value imposes no limits.
+type ExtractOptions struct {
+	// MaxFiles is the maximum number of entries to read, including
+	// the skipped ones, or 0 for no limit.
+	MaxFiles int
+
+	// MaxSize is the maximum total size, in bytes, of the files
+	// to extract, or 0 for no limit.
+	MaxSize int64
+}
+
+// Extract extracts the remaining entries of the archive into the
+// directory dst, creating it if necessary. A nil opts is equivalent
+// to a zero ExtractOptions.
+//
+// All files are created within dst. Extract returns an error wrapping
+// [ErrInsecurePath] for an entry whose name is absolute or escapes dst,
+// and for a symbolic link whose target is absolute or escapes dst.
+// Symbolic links are never followed when creating files, so an entry
+// whose parent directory is a symbolic link is also rejected; symbolic
+// links are created after all other entries. Hard links must refer to
+// a regular file extracted earlier.
+//
+// Existing files are replaced, but existing directories are not.
+// The permission bits and modification times of files and directories
+// are preserved; the set-user-ID, set-group-ID and sticky bits, and
+// the owners, are not. Holes in sparse files are preserved. Character
+// and block devices and FIFOs are skipped, but count toward
+// MaxFiles.
+//
+// If an entry exceeds a limit in opts, Extract returns an error
+// before creating it.
+func (tr *Reader) Extract(dst string, opts *ExtractOptions) error {
+	var o ExtractOptions
+	if opts != nil {
+		o = *opts
+	}
+	x, err := extract.New(dst, extract.Options{
+		Prefix:          "archive/tar",
+		ErrInsecurePath: ErrInsecurePath,
+		MaxFiles:        o.MaxFiles,
+		MaxSize:         o.MaxSize,
+	})
+	if err != nil {
+		return err
+	}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		mode := hdr.FileInfo().Mode()
+		switch hdr.Typeflag {
+		case TypeReg, TypeGNUSparse:
+			err = x.File(hdr.Name, hdr.Size, mode, hdr.ModTime, func(f *os.File) error {
+				_, err := tr.WriteTo(f)
+				return err
+			})
+		case TypeDir:
+			err = x.Dir(hdr.Name, mode, hdr.ModTime)
+		case TypeSymlink:
+			err = x.Symlink(hdr.Name, hdr.Linkname)
+		case TypeLink:
+			err = x.Link(hdr.Name, hdr.Linkname)
+		default:
+			err = x.Skip()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return x.Close()
+}
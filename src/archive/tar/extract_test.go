@@ -0,0 +1,211 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tar
+
+import (
+	"bytes"
+	"errors"
+	"internal/testenv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// makeTar returns an archive holding hdrs. The content of each
+// regular file is its name.
+func makeTar(t *testing.T, hdrs ...*Header) *Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := NewWriter(&buf)
+	for _, hdr := range hdrs {
+		if hdr.Typeflag == TypeReg {
+			hdr.Size = int64(len(hdr.Name))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == TypeReg {
+			tw.Write([]byte(hdr.Name))
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return NewReader(&buf)
+}
+
+func TestExtract(t *testing.T) {
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	tr := makeTar(t,
+		&Header{Typeflag: TypeDir, Name: "./", Mode: 0o755},
+		&Header{Typeflag: TypeDir, Name: "ro/", Mode: 0o555, ModTime: mtime},
+		&Header{Typeflag: TypeReg, Name: "ro/file", Mode: 0o640, ModTime: mtime},
+		&Header{Typeflag: TypeReg, Name: "implicit/dir/exec", Mode: 0o4755, ModTime: mtime},
+		&Header{Typeflag: TypeReg, Name: "replaced", Mode: 0o644},
+		&Header{Typeflag: TypeReg, Name: "replaced", Mode: 0o600},
+		&Header{Typeflag: TypeLink, Name: "hardlink", Linkname: "ro/file"},
+		&Header{Typeflag: TypeChar, Name: "dev", Devmajor: 1, Devminor: 3},
+	)
+	dst := filepath.Join(t.TempDir(), "dst")
+	if err := tr.Extract(dst, nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(filepath.Join(dst, "ro"), 0o755) })
+
+	for _, f := range []struct {
+		name    string
+		content string
+		mode    fs.FileMode
+		mtime   time.Time
+	}{
+		{"ro", "", fs.ModeDir | 0o555, mtime},
+		{"ro/file", "ro/file", 0o640, mtime},
+		{"implicit/dir/exec", "implicit/dir/exec", 0o755, mtime},
+		{"replaced", "replaced", 0o600, time.Time{}},
+		{"hardlink", "ro/file", 0o640, mtime},
+	} {
+		p := filepath.Join(dst, f.name)
+		fi, err := os.Lstat(p)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if runtime.GOOS != "windows" && fi.Mode() != f.mode {
+			t.Errorf("%s: mode = %v, want %v", f.name, fi.Mode(), f.mode)
+		}
+		if !f.mtime.IsZero() && !fi.ModTime().Equal(f.mtime) {
+			t.Errorf("%s: mtime = %v, want %v", f.name, fi.ModTime(), f.mtime)
+		}
+		if fi.Mode().IsRegular() {
+			b, err := os.ReadFile(p)
+			if err != nil || string(b) != f.content {
+				t.Errorf("%s: content = %q, %v; want %q", f.name, b, err, f.content)
+			}
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(dst, "dev")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("device was extracted: %v", err)
+	}
+}
+
+func TestExtractSymlink(t *testing.T) {
+	testenv.MustHaveSymlink(t)
+
+	tr := makeTar(t,
+		&Header{Typeflag: TypeSymlink, Name: "dir/link", Linkname: "../file"},
+		&Header{Typeflag: TypeSymlink, Name: "dir/self", Linkname: "./link"},
+		&Header{Typeflag: TypeReg, Name: "file", Mode: 0o644},
+	)
+	dst := t.TempDir()
+	if err := tr.Extract(dst, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"dir/link", "dir/self"} {
+		b, err := os.ReadFile(filepath.Join(dst, name))
+		if err != nil || string(b) != "file" {
+			t.Errorf("reading through %s: %q, %v; want %q", name, b, err, "file")
+		}
+	}
+}
+
+func TestExtractInsecure(t *testing.T) {
+	tests := []struct {
+		name    string
+		symlink bool
+		hdrs    []*Header
+	}{{
+		name: "dotdot",
+		hdrs: []*Header{{Typeflag: TypeReg, Name: "a/../../evil"}},
+	}, {
+		name: "absolute",
+		hdrs: []*Header{{Typeflag: TypeReg, Name: "/evil"}},
+	}, {
+		name: "hardlink",
+		hdrs: []*Header{{Typeflag: TypeLink, Name: "link", Linkname: "../evil"}},
+	}, {
+		name: "symlink dotdot",
+		hdrs: []*Header{{Typeflag: TypeSymlink, Name: "a/link", Linkname: "../../evil"}},
+	}, {
+		name: "symlink absolute",
+		hdrs: []*Header{{Typeflag: TypeSymlink, Name: "link", Linkname: "/evil"}},
+	}, {
+		name:    "symlink through symlink",
+		symlink: true,
+		hdrs: []*Header{
+			{Typeflag: TypeSymlink, Name: "c", Linkname: "."},
+			{Typeflag: TypeSymlink, Name: "a", Linkname: "c/../evil"},
+		},
+	}, {
+		name:    "existing symlink",
+		symlink: true,
+		hdrs:    []*Header{{Typeflag: TypeReg, Name: "out/evil"}},
+	}, {
+		name:    "symlink to existing symlink",
+		symlink: true,
+		hdrs:    []*Header{{Typeflag: TypeSymlink, Name: "link", Linkname: "out/evil"}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.symlink {
+				testenv.MustHaveSymlink(t)
+			}
+			dir := t.TempDir()
+			dst := filepath.Join(dir, "dst")
+			os.Mkdir(dst, 0o777)
+			os.Mkdir(filepath.Join(dir, "outside"), 0o777)
+			if tt.symlink {
+				if err := os.Symlink("../outside", filepath.Join(dst, "out")); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := makeTar(t, tt.hdrs...).Extract(dst, nil)
+			if !errors.Is(err, ErrInsecurePath) {
+				t.Errorf("Extract: %v, want %v", err, ErrInsecurePath)
+			}
+			for _, p := range []string{"evil", "outside/evil"} {
+				if _, err := os.Lstat(filepath.Join(dir, p)); err == nil {
+					t.Errorf("%s was created", p)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractLimits(t *testing.T) {
+	hdrs := func() []*Header {
+		return []*Header{
+			{Typeflag: TypeDir, Name: "dir/"},
+			{Typeflag: TypeFifo, Name: "dir/fifo"},
+			{Typeflag: TypeReg, Name: "dir/a"},
+			{Typeflag: TypeReg, Name: "dir/b"},
+		}
+	}
+	tests := []struct {
+		opts ExtractOptions
+		ok   bool
+	}{
+		{ExtractOptions{}, true},
+		{ExtractOptions{MaxFiles: 4}, true},
+		// Skipped entries count toward the limit.
+		{ExtractOptions{MaxFiles: 3}, false},
+		{ExtractOptions{MaxSize: 10}, true},
+		{ExtractOptions{MaxSize: 9}, false},
+	}
+	for _, tt := range tests {
+		dst := t.TempDir()
+		err := makeTar(t, hdrs()...).Extract(dst, &tt.opts)
+		if (err == nil) != tt.ok {
+			t.Errorf("Extract with %+v: %v", tt.opts, err)
+		}
+		if !tt.ok {
+			if _, err := os.Stat(filepath.Join(dst, "dir/b")); err == nil {
+				t.Errorf("Extract with %+v created the file over the limit", tt.opts)
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tar
+
+import (
+	"os"
+	"strings"
+)
+
+// Keywords for POSIX ACLs in a PAX extended header, as written by star,
+// GNU tar and bsdtar. The values use the short text form of ACLs,
+// such as "user::rw-,group::r--,other::r--".
+const (
+	paxSchilyACLAccess  = "SCHILY.acl.access"
+	paxSchilyACLDefault = "SCHILY.acl.default"
+)
+
+// sysFileHeader, if non-nil, adds the extended attributes, ACLs and
+// sparse holes of f to h.
+var sysFileHeader func(f *os.File, h *Header) error
+
+// sysSetXattrs, if non-nil, sets the extended attributes and ACLs
+// recorded in the PAX records of h on f.
+var sysSetXattrs func(f *os.File, h *Header) error
+
+// FileHeader returns a [Header] for the open file f, as [FileInfoHeader]
+// does for the result of f.Stat. On Linux, it also records:
+//
+//   - the extended attributes of f as "SCHILY.xattr." PAX records,
+//   - the POSIX ACLs of f as "SCHILY.acl.access" and "SCHILY.acl.default"
+//     PAX records, in the form used by star, GNU tar and bsdtar,
+//   - the holes in f, if it is a regular file, in [Header.SparseHoles],
+//     found with lseek's SEEK_DATA and SEEK_HOLE.
+//
+// The link argument is used as in FileInfoHeader.
+//
+// Passing f to [Writer.ReadFrom] after writing the header skips the
+// holes in f instead of reading them.
+func FileHeader(f *os.File, link string) (*Header, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	h, err := FileInfoHeader(fi, link)
+	if err != nil {
+		return nil, err
+	}
+	if sysFileHeader != nil {
+		if err := sysFileHeader(f, h); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// ExtractFile writes the remaining content of the current file to f,
+// which is typically a newly created file. Holes in a sparse file are
+// skipped rather than written, so that f is sparse too on file systems
+// that support it. On Linux, ExtractFile then sets the extended
+// attributes and POSIX ACLs recorded in the PAX records of the
+// [Header] returned by the last call to [Reader.Next].
+//
+// ExtractFile does not set the mode, owner or times of f. Since setting
+// the mode of a file changes its access ACL, the mode should be set
+// before calling ExtractFile.
+//
+// For a directory, f may be the open directory; no data is written.
+func (tr *Reader) ExtractFile(f *os.File) error {
+	if _, err := tr.WriteTo(f); err != nil {
+		return err
+	}
+	if sysSetXattrs != nil && tr.hdr != nil {
+		return sysSetXattrs(f, tr.hdr)
+	}
+	return nil
+}
+
+// xattrRecords returns the extended attributes recorded in the
+// PAX records of h, keyed by attribute name.
+func xattrRecords(h *Header) map[string]string {
+	var xattrs map[string]string
+	for k, v := range h.PAXRecords {
+		if name, ok := strings.CutPrefix(k, paxSchilyXattr); ok {
+			if xattrs == nil {
+				xattrs = make(map[string]string)
+			}
+			xattrs[name] = v
+		}
+	}
+	return xattrs
+}
@@ -0,0 +1,384 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tar
+
+import (
+	"encoding/binary"
+	"errors"
+	"internal/syscall/unix"
+	"io"
+	"os"
+	"os/user"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+func init() {
+	sysFileHeader = fileHeaderLinux
+	sysSetXattrs = setXattrsLinux
+}
+
+const (
+	// Extended attributes holding POSIX ACLs.
+	xattrACLAccess  = "system.posix_acl_access"
+	xattrACLDefault = "system.posix_acl_default"
+
+	// Whence values for lseek.
+	seekData = 3
+	seekHole = 4
+)
+
+func fileHeaderLinux(f *os.File, h *Header) error {
+	xattrs, err := listXattrs(f)
+	if err != nil {
+		return err
+	}
+	for name, v := range xattrs {
+		key := paxSchilyXattr + name
+		switch name {
+		case xattrACLAccess, xattrACLDefault:
+			text, err := aclToText([]byte(v))
+			if err != nil {
+				return err
+			}
+			key, v = paxSchilyACLAccess, text
+			if name == xattrACLDefault {
+				key = paxSchilyACLDefault
+			}
+		}
+		if h.PAXRecords == nil {
+			h.PAXRecords = make(map[string]string)
+		}
+		h.PAXRecords[key] = v
+	}
+	if h.Typeflag == TypeReg {
+		if h.SparseHoles, err = detectSparseHoles(f, h.Size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listXattrs returns the extended attributes of f.
+func listXattrs(f *os.File) (map[string]string, error) {
+	var xattrs map[string]string
+	err := control(f, func(fd int) error {
+		var names []byte
+		for {
+			n, err := unix.Flistxattr(fd, nil)
+			if err != nil {
+				return ignoreNotSupported(err)
+			}
+			names = make([]byte, n)
+			n, err = unix.Flistxattr(fd, names)
+			if err == syscall.ERANGE {
+				continue // The list grew; try again.
+			}
+			if err != nil {
+				return err
+			}
+			names = names[:n]
+			break
+		}
+		for _, name := range strings.Split(string(names), "\x00") {
+			if name == "" {
+				continue
+			}
+			v, err := getXattr(fd, name)
+			if err == syscall.ENODATA {
+				continue // Removed since it was listed.
+			}
+			if err != nil {
+				return &os.PathError{Op: "getxattr", Path: f.Name(), Err: err}
+			}
+			if xattrs == nil {
+				xattrs = make(map[string]string)
+			}
+			xattrs[name] = string(v)
+		}
+		return nil
+	})
+	return xattrs, err
+}
+
+func getXattr(fd int, name string) ([]byte, error) {
+	for {
+		n, err := unix.Fgetxattr(fd, name, nil)
+		if err != nil {
+			return nil, err
+		}
+		v := make([]byte, n)
+		n, err = unix.Fgetxattr(fd, name, v)
+		if err == syscall.ERANGE {
+			continue // The value grew; try again.
+		}
+		return v[:n], err
+	}
+}
+
+func setXattrsLinux(f *os.File, h *Header) error {
+	xattrs := xattrRecords(h)
+	for key, name := range map[string]string{paxSchilyACLAccess: xattrACLAccess, paxSchilyACLDefault: xattrACLDefault} {
+		text, ok := h.PAXRecords[key]
+		if !ok {
+			continue
+		}
+		acl, err := aclFromText(text)
+		if err != nil {
+			return err
+		}
+		if xattrs == nil {
+			xattrs = make(map[string]string)
+		}
+		xattrs[name] = string(acl)
+	}
+	if len(xattrs) == 0 {
+		return nil
+	}
+
+	// Set the attributes in a deterministic order.
+	names := make([]string, 0, len(xattrs))
+	for name := range xattrs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return control(f, func(fd int) error {
+		var errs []error
+		for _, name := range names {
+			if err := unix.Fsetxattr(fd, name, []byte(xattrs[name]), 0); err != nil {
+				errs = append(errs, &os.PathError{Op: "setxattr " + name, Path: f.Name(), Err: err})
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// control calls fn with the file descriptor of f.
+func control(f *os.File, fn func(fd int) error) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var ferr error
+	if err := rc.Control(func(fd uintptr) { ferr = fn(int(fd)) }); err != nil {
+		return err
+	}
+	return ferr
+}
+
+// ignoreNotSupported returns nil if err reports that the file system
+// does not support extended attributes.
+func ignoreNotSupported(err error) error {
+	if err == syscall.ENOTSUP || err == syscall.EOPNOTSUPP {
+		return nil
+	}
+	return err
+}
+
+// detectSparseHoles returns the holes in the first size bytes of f.
+// It returns nil if f has no holes, or if the file system cannot report them.
+// The offset of f is preserved.
+func detectSparseHoles(f *os.File, size int64) ([]SparseEntry, error) {
+	pos, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Seek(pos, io.SeekStart)
+
+	var sph []SparseEntry
+	for off := int64(0); off < size; {
+		data, err := f.Seek(off, seekData)
+		switch {
+		case errors.Is(err, syscall.ENXIO):
+			data = size // No data after off.
+		case errors.Is(err, syscall.EINVAL):
+			return nil, nil // SEEK_DATA not supported.
+		case err != nil:
+			return nil, err
+		}
+		data = min(data, size)
+		if data > off {
+			sph = append(sph, SparseEntry{Offset: off, Length: data - off})
+		}
+		if data == size {
+			break
+		}
+		if off, err = f.Seek(data, seekHole); err != nil {
+			return nil, err
+		}
+	}
+	return sph, nil
+}
+
+// Linux POSIX ACL extended attribute format, from <linux/posix_acl_xattr.h>.
+const (
+	aclXattrVersion = 2
+	aclUndefinedID  = 1<<32 - 1
+
+	aclUserObj  = 0x01
+	aclUser     = 0x02
+	aclGroupObj = 0x04
+	aclGroup    = 0x08
+	aclMask     = 0x10
+	aclOther    = 0x20
+)
+
+var aclTagNames = []struct {
+	tag   uint16
+	name  string
+	short string
+}{
+	{aclUserObj, "user", "u"},
+	{aclUser, "user", "u"},
+	{aclGroupObj, "group", "g"},
+	{aclGroup, "group", "g"},
+	{aclMask, "mask", "m"},
+	{aclOther, "other", "o"},
+}
+
+var errACL = errors.New("archive/tar: invalid ACL")
+
+// aclToText converts an ACL in the extended attribute format to its
+// short text form, using numeric user and group IDs.
+func aclToText(b []byte) (string, error) {
+	if len(b) < 4 || binary.LittleEndian.Uint32(b) != aclXattrVersion || (len(b)-4)%8 != 0 {
+		return "", errACL
+	}
+	var entries []string
+	for b = b[4:]; len(b) > 0; b = b[8:] {
+		tag := binary.LittleEndian.Uint16(b)
+		perm := binary.LittleEndian.Uint16(b[2:])
+		id := binary.LittleEndian.Uint32(b[4:])
+		var e []byte
+		for _, t := range aclTagNames {
+			if t.tag == tag {
+				e = append(e, t.name...)
+				break
+			}
+		}
+		if e == nil {
+			return "", errACL
+		}
+		e = append(e, ':')
+		if tag == aclUser || tag == aclGroup {
+			e = strconv.AppendUint(e, uint64(id), 10)
+		}
+		e = append(e, ':')
+		for i, c := range "rwx" {
+			if perm&(4>>i) != 0 {
+				e = append(e, byte(c))
+			} else {
+				e = append(e, '-')
+			}
+		}
+		entries = append(entries, string(e))
+	}
+	return strings.Join(entries, ","), nil
+}
+
+// aclFromText converts an ACL in text form to the extended attribute format.
+// It accepts entries separated by commas or newlines, abbreviated tag names,
+// and user and group names, optionally followed by a numeric ID as written
+// by star.
+func aclFromText(text string) ([]byte, error) {
+	type entry struct {
+		tag  uint16
+		perm uint16
+		id   uint32
+	}
+	var entries []entry
+	for _, f := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == '\n' }) {
+		f = strings.TrimSpace(f)
+		if f == "" || f[0] == '#' {
+			continue
+		}
+		parts := strings.Split(f, ":")
+		if len(parts) < 3 || len(parts) > 4 {
+			return nil, errACL
+		}
+		name, qual, perms := parts[0], parts[1], parts[2]
+		e := entry{id: aclUndefinedID}
+		for _, t := range aclTagNames {
+			if name != t.name && name != t.short {
+				continue
+			}
+			// The tags come in pairs: the owner entry first,
+			// then the named entry.
+			if e.tag = t.tag; qual != "" && (t.tag == aclUserObj || t.tag == aclGroupObj) {
+				e.tag <<= 1
+			}
+			break
+		}
+		switch {
+		case e.tag == 0, qual != "" && e.tag != aclUser && e.tag != aclGroup:
+			return nil, errACL
+		case qual != "":
+			if len(parts) == 4 {
+				qual = parts[3]
+			}
+			id, err := aclID(qual, e.tag == aclUser)
+			if err != nil {
+				return nil, err
+			}
+			e.id = id
+		}
+		for _, c := range perms {
+			switch c {
+			case 'r':
+				e.perm |= 4
+			case 'w':
+				e.perm |= 2
+			case 'x':
+				e.perm |= 1
+			case '-':
+			default:
+				return nil, errACL
+			}
+		}
+		entries = append(entries, e)
+	}
+	slices.SortFunc(entries, func(a, b entry) int {
+		if a.tag != b.tag {
+			return int(a.tag) - int(b.tag)
+		}
+		return int(int64(a.id) - int64(b.id))
+	})
+
+	b := binary.LittleEndian.AppendUint32(nil, aclXattrVersion)
+	for _, e := range entries {
+		b = binary.LittleEndian.AppendUint16(b, e.tag)
+		b = binary.LittleEndian.AppendUint16(b, e.perm)
+		b = binary.LittleEndian.AppendUint32(b, e.id)
+	}
+	return b, nil
+}
+
+// aclID returns the numeric ID of the named user or group.
+func aclID(s string, isUser bool) (uint32, error) {
+	if id, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return uint32(id), nil
+	}
+	var id string
+	if isUser {
+		u, err := user.Lookup(s)
+		if err != nil {
+			return 0, err
+		}
+		id = u.Uid
+	} else {
+		g, err := user.LookupGroup(s)
+		if err != nil {
+			return 0, err
+		}
+		id = g.Gid
+	}
+	n, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return 0, errACL
+	}
+	return uint32(n), nil
+}
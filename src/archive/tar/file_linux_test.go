@@ -0,0 +1,152 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tar
+
+import (
+	"bytes"
+	"internal/syscall/unix"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+func TestACLText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"user::rw-,group::r--,other::r--", "user::rw-,group::r--,other::r--"},
+		{"u::rwx,g::r-x,o::---,u:1000:rw-,g:50:r--,m::rwx", "user::rwx,user:1000:rw-,group::r-x,group:50:r--,mask::rwx,other::---"},
+		{"user::rw-\nuser:lisa:r--:1003\ngroup::r--\nmask::r--\nother::r--\n", "user::rw-,user:1003:r--,group::r--,mask::r--,other::r--"},
+	}
+	for _, tt := range tests {
+		b, err := aclFromText(tt.in)
+		if err != nil {
+			t.Errorf("aclFromText(%q): %v", tt.in, err)
+			continue
+		}
+		got, err := aclToText(b)
+		if err != nil || got != tt.want {
+			t.Errorf("aclToText(aclFromText(%q)) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, in := range []string{"user", "user::rwz", "mask:1:rwx", "other:x:r--", "bogus::rwx"} {
+		if _, err := aclFromText(in); err == nil {
+			t.Errorf("aclFromText(%q) succeeded", in)
+		}
+	}
+	for _, in := range [][]byte{nil, {1, 0, 0, 0}, {2, 0, 0, 0, 1}} {
+		if _, err := aclToText(in); err == nil {
+			t.Errorf("aclToText(%x) succeeded", in)
+		}
+	}
+}
+
+func TestFileHeaderSparse(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "sparse")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	const size = 8 << 20
+	if err := f.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.Repeat([]byte("data"), 1024)
+	for _, off := range []int64{1 << 20, 5 << 20} {
+		if _, err := f.WriteAt(data, off); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := unix.Fsetxattr(int(f.Fd()), "user.test", []byte("value"), 0); err != nil {
+		if err == syscall.ENOTSUP || err == syscall.EPERM {
+			t.Skipf("file system does not support user xattrs: %v", err)
+		}
+		t.Fatal(err)
+	}
+
+	hdr, err := FileHeader(f, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hdr.SparseHoles) == 0 {
+		t.Skip("file system does not report holes")
+	}
+	if got := hdr.PAXRecords["SCHILY.xattr.user.test"]; got != "value" {
+		t.Errorf("xattr record = %q, want %q", got, "value")
+	}
+	holes := hdr.SparseHoles
+
+	var buf bytes.Buffer
+	tw := NewWriter(&buf)
+	if err := tw.WriteHeader(hdr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.ReadFrom(f); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() > size/4 {
+		t.Errorf("archive is %d bytes; holes were not skipped", buf.Len())
+	}
+
+	tr := NewReader(&buf)
+	got, err := tr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.SparseHoles, holes) {
+		t.Errorf("SparseHoles = %v, want %v", got.SparseHoles, holes)
+	}
+	out, err := os.Create(filepath.Join(dir, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	if err := tr.ExtractFile(out); err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	have, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(have, want) {
+		t.Error("extracted file has the wrong content")
+	}
+	// The final byte of a trailing hole is written to set the file size,
+	// so only the start of the last hole is expected to match.
+	sph, err := detectSparseHoles(out, size)
+	if err != nil || len(sph) != len(holes) ||
+		!reflect.DeepEqual(sph[:len(sph)-1], holes[:len(holes)-1]) ||
+		sph[len(sph)-1].Offset != holes[len(holes)-1].Offset {
+		t.Errorf("extracted file has holes %v, %v; want %v", sph, err, holes)
+	}
+	v := make([]byte, 16)
+	n, err := unix.Fgetxattr(int(out.Fd()), "user.test", v)
+	if err != nil || string(v[:n]) != "value" {
+		t.Errorf("extracted xattr = %q, %v; want %q", v[:n], err, "value")
+	}
+
+	// The offset of f is unchanged by FileHeader.
+	f.Seek(10, io.SeekStart)
+	if _, err := FileHeader(f, ""); err != nil {
+		t.Fatal(err)
+	}
+	if off, _ := f.Seek(0, io.SeekCurrent); off != 10 {
+		t.Errorf("offset after FileHeader = %d, want 10", off)
+	}
+}
@@ -21,6 +21,7 @@ type Reader struct {
 	pad  int64      // Amount of padding (ignored) after current file entry
 	curr fileReader // Reader for current file entry
 	blk  block      // Buffer to use as temporary local storage
+	hdr  *Header    // Header of current file entry, for ExtractFile
 
 	// err is a persistent error.
 	// It is only the responsibility of every exported method of Reader to
@@ -57,6 +58,7 @@ func (tr *Reader) Next() (*Header, error) {
 	}
 	hdr, err := tr.next()
 	tr.err = err
+	tr.hdr = hdr
 	if err == nil && !filepath.IsLocal(hdr.Name) {
 		if tarinsecurepath.Value() == "0" {
 			tarinsecurepath.IncNonDefault()
@@ -208,6 +210,7 @@ func (tr *Reader) handleSparseFile(hdr *Header, rawHdr *block) error {
 		}
 		sph := invertSparseEntries(spd, hdr.Size)
 		tr.curr = &sparseFileReader{tr.curr, sph, 0}
+		hdr.SparseHoles = append([]SparseEntry{}, sph...)
 	}
 	return err
 }
@@ -501,7 +504,7 @@ func (tr *Reader) readOldGNUSparseMap(hdr *Header, blk *block) (sparseDatas, err
 			if p.err != nil {
 				return nil, p.err
 			}
-			spd = append(spd, sparseEntry{Offset: offset, Length: length})
+			spd = append(spd, SparseEntry{Offset: offset, Length: length})
 		}
 
 		if s.isExtended()[0] > 0 {
@@ -581,7 +584,7 @@ func readGNUSparseMap1x0(r io.Reader) (sparseDatas, error) {
 		if err1 != nil || err2 != nil {
 			return nil, ErrHeader
 		}
-		spd = append(spd, sparseEntry{Offset: offset, Length: length})
+		spd = append(spd, SparseEntry{Offset: offset, Length: length})
 	}
 	return spd, nil
 }
@@ -615,7 +618,7 @@ func readGNUSparseMap0x1(paxHdrs map[string]string) (sparseDatas, error) {
 		if err1 != nil || err2 != nil {
 			return nil, ErrHeader
 		}
-		spd = append(spd, sparseEntry{Offset: offset, Length: length})
+		spd = append(spd, SparseEntry{Offset: offset, Length: length})
 		sparseMap = sparseMap[2:]
 	}
 	return spd, nil
@@ -642,17 +645,14 @@ func (tr *Reader) Read(b []byte) (int, error) {
 	return n, err
 }
 
-// writeTo writes the content of the current file to w.
+// WriteTo writes the content of the current file to w.
 // The bytes written matches the number of remaining bytes in the current file.
 //
-// If the current file is sparse and w is an io.WriteSeeker,
-// then writeTo uses Seek to skip past holes defined in Header.SparseHoles,
+// If the current file is sparse and w is an [io.WriteSeeker],
+// then WriteTo uses Seek to skip past holes defined in [Header.SparseHoles],
 // assuming that skipped regions are filled with NULs.
 // This always writes the last byte to ensure w is the right size.
-//
-// TODO(dsnet): Re-export this when adding sparse file support.
-// See https://golang.org/issue/22735
-func (tr *Reader) writeTo(w io.Writer) (int64, error) {
+func (tr *Reader) WriteTo(w io.Writer) (int64, error) {
 	if tr.err != nil {
 		return 0, tr.err
 	}
@@ -21,6 +21,17 @@ import (
 	"time"
 )
 
+// sparseFormatsHoles are the holes of the sparse files in
+// testdata/sparse-formats.tar, which have data at every odd offset
+// up to 189, followed by a hole of 10 bytes.
+var sparseFormatsHoles = func() []SparseEntry {
+	var sph []SparseEntry
+	for off := int64(0); off < 190; off += 2 {
+		sph = append(sph, SparseEntry{Offset: off, Length: 1})
+	}
+	return append(sph, SparseEntry{Offset: 190, Length: 10})
+}()
+
 func TestReader(t *testing.T) {
 	vectors := []struct {
 		file    string    // Test input file
@@ -59,19 +70,20 @@ func TestReader(t *testing.T) {
 	}, {
 		file: "testdata/sparse-formats.tar",
 		headers: []*Header{{
-			Name:     "sparse-gnu",
-			Mode:     420,
-			Uid:      1000,
-			Gid:      1000,
-			Size:     200,
-			ModTime:  time.Unix(1392395740, 0),
-			Typeflag: 0x53,
-			Linkname: "",
-			Uname:    "david",
-			Gname:    "david",
-			Devmajor: 0,
-			Devminor: 0,
-			Format:   FormatGNU,
+			Name:        "sparse-gnu",
+			Mode:        420,
+			Uid:         1000,
+			Gid:         1000,
+			Size:        200,
+			ModTime:     time.Unix(1392395740, 0),
+			Typeflag:    0x53,
+			Linkname:    "",
+			Uname:       "david",
+			Gname:       "david",
+			Devmajor:    0,
+			Devminor:    0,
+			SparseHoles: sparseFormatsHoles,
+			Format:      FormatGNU,
 		}, {
 			Name:     "sparse-posix-0.0",
 			Mode:     420,
@@ -90,7 +102,8 @@ func TestReader(t *testing.T) {
 				"GNU.sparse.numblocks": "95",
 				"GNU.sparse.map":       "1,1,3,1,5,1,7,1,9,1,11,1,13,1,15,1,17,1,19,1,21,1,23,1,25,1,27,1,29,1,31,1,33,1,35,1,37,1,39,1,41,1,43,1,45,1,47,1,49,1,51,1,53,1,55,1,57,1,59,1,61,1,63,1,65,1,67,1,69,1,71,1,73,1,75,1,77,1,79,1,81,1,83,1,85,1,87,1,89,1,91,1,93,1,95,1,97,1,99,1,101,1,103,1,105,1,107,1,109,1,111,1,113,1,115,1,117,1,119,1,121,1,123,1,125,1,127,1,129,1,131,1,133,1,135,1,137,1,139,1,141,1,143,1,145,1,147,1,149,1,151,1,153,1,155,1,157,1,159,1,161,1,163,1,165,1,167,1,169,1,171,1,173,1,175,1,177,1,179,1,181,1,183,1,185,1,187,1,189,1",
 			},
-			Format: FormatPAX,
+			SparseHoles: sparseFormatsHoles,
+			Format:      FormatPAX,
 		}, {
 			Name:     "sparse-posix-0.1",
 			Mode:     420,
@@ -110,7 +123,8 @@ func TestReader(t *testing.T) {
 				"GNU.sparse.map":       "1,1,3,1,5,1,7,1,9,1,11,1,13,1,15,1,17,1,19,1,21,1,23,1,25,1,27,1,29,1,31,1,33,1,35,1,37,1,39,1,41,1,43,1,45,1,47,1,49,1,51,1,53,1,55,1,57,1,59,1,61,1,63,1,65,1,67,1,69,1,71,1,73,1,75,1,77,1,79,1,81,1,83,1,85,1,87,1,89,1,91,1,93,1,95,1,97,1,99,1,101,1,103,1,105,1,107,1,109,1,111,1,113,1,115,1,117,1,119,1,121,1,123,1,125,1,127,1,129,1,131,1,133,1,135,1,137,1,139,1,141,1,143,1,145,1,147,1,149,1,151,1,153,1,155,1,157,1,159,1,161,1,163,1,165,1,167,1,169,1,171,1,173,1,175,1,177,1,179,1,181,1,183,1,185,1,187,1,189,1",
 				"GNU.sparse.name":      "sparse-posix-0.1",
 			},
-			Format: FormatPAX,
+			SparseHoles: sparseFormatsHoles,
+			Format:      FormatPAX,
 		}, {
 			Name:     "sparse-posix-1.0",
 			Mode:     420,
@@ -130,7 +144,8 @@ func TestReader(t *testing.T) {
 				"GNU.sparse.realsize": "200",
 				"GNU.sparse.name":     "sparse-posix-1.0",
 			},
-			Format: FormatPAX,
+			SparseHoles: sparseFormatsHoles,
+			Format:      FormatPAX,
 		}, {
 			Name:     "end",
 			Mode:     420,
@@ -430,18 +445,19 @@ func TestReader(t *testing.T) {
 			ChangeTime: time.Unix(1441973436, 0),
 			Format:     FormatGNU,
 		}, {
-			Name:       "test2/sparse",
-			Mode:       33188,
-			Uid:        1000,
-			Gid:        1000,
-			Size:       536870912,
-			ModTime:    time.Unix(1441973427, 0),
-			Typeflag:   'S',
-			Uname:      "rawr",
-			Gname:      "dsnet",
-			AccessTime: time.Unix(1441991948, 0),
-			ChangeTime: time.Unix(1441973436, 0),
-			Format:     FormatGNU,
+			Name:        "test2/sparse",
+			Mode:        33188,
+			Uid:         1000,
+			Gid:         1000,
+			Size:        536870912,
+			ModTime:     time.Unix(1441973427, 0),
+			Typeflag:    'S',
+			Uname:       "rawr",
+			Gname:       "dsnet",
+			AccessTime:  time.Unix(1441991948, 0),
+			ChangeTime:  time.Unix(1441973436, 0),
+			SparseHoles: []SparseEntry{{Offset: 0, Length: 536870912}},
+			Format:      FormatGNU,
 		}},
 	}, {
 		// Matches the behavior of GNU and BSD tar utilities.
@@ -558,21 +574,23 @@ func TestReader(t *testing.T) {
 		// Generated by Go, works on BSD tar v3.1.2 and GNU tar v.1.27.1.
 		file: "testdata/gnu-nil-sparse-data.tar",
 		headers: []*Header{{
-			Name:     "sparse.db",
-			Typeflag: TypeGNUSparse,
-			Size:     1000,
-			ModTime:  time.Unix(0, 0),
-			Format:   FormatGNU,
+			Name:        "sparse.db",
+			Typeflag:    TypeGNUSparse,
+			Size:        1000,
+			ModTime:     time.Unix(0, 0),
+			SparseHoles: []SparseEntry{{Offset: 1000, Length: 0}},
+			Format:      FormatGNU,
 		}},
 	}, {
 		// Generated by Go, works on BSD tar v3.1.2 and GNU tar v.1.27.1.
 		file: "testdata/gnu-nil-sparse-hole.tar",
 		headers: []*Header{{
-			Name:     "sparse.db",
-			Typeflag: TypeGNUSparse,
-			Size:     1000,
-			ModTime:  time.Unix(0, 0),
-			Format:   FormatGNU,
+			Name:        "sparse.db",
+			Typeflag:    TypeGNUSparse,
+			Size:        1000,
+			ModTime:     time.Unix(0, 0),
+			SparseHoles: []SparseEntry{{Offset: 0, Length: 1000}},
+			Format:      FormatGNU,
 		}},
 	}, {
 		// Generated by Go, works on BSD tar v3.1.2 and GNU tar v.1.27.1.
@@ -589,7 +607,8 @@ func TestReader(t *testing.T) {
 				"GNU.sparse.realsize": "1000",
 				"GNU.sparse.name":     "sparse.db",
 			},
-			Format: FormatPAX,
+			SparseHoles: []SparseEntry{{Offset: 1000, Length: 0}},
+			Format:      FormatPAX,
 		}},
 	}, {
 		// Generated by Go, works on BSD tar v3.1.2 and GNU tar v.1.27.1.
@@ -606,7 +625,8 @@ func TestReader(t *testing.T) {
 				"GNU.sparse.realsize": "1000",
 				"GNU.sparse.name":     "sparse.db",
 			},
-			Format: FormatPAX,
+			SparseHoles: []SparseEntry{{Offset: 0, Length: 1000}},
+			Format:      FormatPAX,
 		}},
 	}, {
 		file: "testdata/trailing-slash.tar",
@@ -871,7 +891,7 @@ func TestReadTruncation(t *testing.T) {
 				}
 				cnt++
 				if s2 == "manual" {
-					if _, err = tr.writeTo(io.Discard); err != nil {
+					if _, err = tr.WriteTo(io.Discard); err != nil {
 						break
 					}
 				}
@@ -1059,7 +1079,7 @@ func TestReadOldGNUSparseMap(t *testing.T) {
 		return out
 	}
 
-	makeSparseStrings := func(sp []sparseEntry) (out []string) {
+	makeSparseStrings := func(sp []SparseEntry) (out []string) {
 		var f formatter
 		for _, s := range sp {
 			var b [24]byte
@@ -1313,7 +1333,7 @@ func TestReadGNUSparsePAXHeaders(t *testing.T) {
 		inputHdrs: map[string]string{paxGNUSparseMajor: "1", paxGNUSparseMinor: "0"},
 		wantMap: func() (spd sparseDatas) {
 			for i := 0; i < 100; i++ {
-				spd = append(spd, sparseEntry{int64(i) << 30, 512})
+				spd = append(spd, SparseEntry{int64(i) << 30, 512})
 			}
 			return spd
 		}(),
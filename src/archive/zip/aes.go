@@ -0,0 +1,242 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zip
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"errors"
+	"hash"
+	"internal/pbkdf2"
+	"io"
+)
+
+// WinZip AES encryption, as described in
+// https://www.winzip.com/en/support/aes-encryption/.
+//
+// An encrypted file is stored with method winzipAESMethod, and an
+// aesExtraID extra field that records the key size and the actual
+// compression method. Its data consists of a salt, a password
+// verification value, the encrypted compressed data, and an
+// authentication code computed over the encrypted data.
+
+// Encryption is the WinZip AES encryption applied to a file.
+type Encryption uint8
+
+// Encryption methods.
+const (
+	NoEncryption Encryption = 0
+	AES128       Encryption = 1 // AES with a 128-bit key
+	AES192       Encryption = 2 // AES with a 192-bit key
+	AES256       Encryption = 3 // AES with a 256-bit key
+)
+
+// ErrPassword is returned when opening an encrypted file without
+// a password, or with the wrong password.
+var ErrPassword = errors.New("zip: invalid password")
+
+const (
+	winzipAESMethod = 99
+	aesExtraID      = 0x9901 // WinZip AES encryption
+
+	// Versions of the format. AE-2 does not store the CRC-32 of
+	// the file, which would reveal information about small files.
+	aesVersion1 = 1
+	aesVersion2 = 2
+
+	aesVerifierLen = 2
+	aesMACLen      = 10
+	aesIterations  = 1000
+)
+
+// keyLen returns the AES key length for e, or 0 if e is not valid.
+func (e Encryption) keyLen() int {
+	switch e {
+	case AES128:
+		return 16
+	case AES192:
+		return 24
+	case AES256:
+		return 32
+	}
+	return 0
+}
+
+// saltLen returns the length of the salt for e.
+func (e Encryption) saltLen() int {
+	return e.keyLen() / 2
+}
+
+// aesOverhead returns the number of bytes that encryption with e
+// adds to the compressed data.
+func (e Encryption) aesOverhead() int {
+	return e.saltLen() + aesVerifierLen + aesMACLen
+}
+
+// aesKeys derives the encryption key, the authentication key and the
+// password verification value from the password and salt.
+func aesKeys(e Encryption, password string, salt []byte) (key, macKey, verifier []byte) {
+	n := e.keyLen()
+	dk := pbkdf2.Key(sha1.New, []byte(password), salt, aesIterations, 2*n+aesVerifierLen)
+	return dk[:n], dk[n : 2*n], dk[2*n:]
+}
+
+// aesCTR is AES in counter mode with the little-endian counter,
+// starting at 1, that WinZip uses. It differs from [cipher.NewCTR],
+// which increments the counter as a big-endian number.
+type aesCTR struct {
+	block cipher.Block
+	ctr   [aes.BlockSize]byte
+	ks    [aes.BlockSize]byte
+	used  int // bytes of ks already used
+}
+
+func newAESCTR(key []byte) *aesCTR {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic("zip: " + err.Error()) // key length is always valid
+	}
+	return &aesCTR{block: block, used: aes.BlockSize}
+}
+
+func (c *aesCTR) XORKeyStream(dst, src []byte) {
+	for len(src) > 0 {
+		if c.used == len(c.ks) {
+			for i := range c.ctr {
+				c.ctr[i]++
+				if c.ctr[i] != 0 {
+					break
+				}
+			}
+			c.block.Encrypt(c.ks[:], c.ctr[:])
+			c.used = 0
+		}
+		n := subtle.XORBytes(dst, src, c.ks[c.used:])
+		c.used += n
+		dst, src = dst[n:], src[n:]
+	}
+}
+
+// aesReader decrypts the data of an encrypted file.
+type aesReader struct {
+	r   *io.SectionReader // the encrypted data, without salt, verifier and MAC
+	ctr *aesCTR
+	mac hash.Hash
+	// want is the stored authentication code.
+	want [aesMACLen]byte
+}
+
+// newAESReader returns a reader that decrypts the data in r, which holds
+// the complete stored data of a file encrypted with e.
+func newAESReader(r *io.SectionReader, e Encryption, password string) (*aesReader, error) {
+	saltLen := int64(e.saltLen())
+	hdr := make([]byte, saltLen+aesVerifierLen)
+	dataLen := r.Size() - int64(e.aesOverhead())
+	if dataLen < 0 {
+		return nil, ErrFormat
+	}
+	if _, err := r.ReadAt(hdr, 0); err != nil {
+		return nil, err
+	}
+	key, macKey, verifier := aesKeys(e, password, hdr[:saltLen])
+	if subtle.ConstantTimeCompare(verifier, hdr[saltLen:]) != 1 {
+		return nil, ErrPassword
+	}
+	ar := &aesReader{
+		r:   io.NewSectionReader(r, int64(len(hdr)), dataLen),
+		ctr: newAESCTR(key),
+		mac: hmac.New(sha1.New, macKey),
+	}
+	if _, err := r.ReadAt(ar.want[:], int64(len(hdr))+dataLen); err != nil {
+		return nil, err
+	}
+	return ar, nil
+}
+
+func (ar *aesReader) Read(p []byte) (int, error) {
+	n, err := ar.r.Read(p)
+	ar.mac.Write(p[:n])
+	ar.ctr.XORKeyStream(p[:n], p[:n])
+	return n, err
+}
+
+// verify reads any encrypted data that the decompressor did not
+// consume and checks the authentication code.
+func (ar *aesReader) verify() error {
+	if _, err := io.Copy(io.Discard, ar); err != nil {
+		return err
+	}
+	if !hmac.Equal(ar.mac.Sum(nil)[:aesMACLen], ar.want[:]) {
+		return ErrChecksum
+	}
+	return nil
+}
+
+// aesWriter encrypts the data of a file.
+type aesWriter struct {
+	w   io.Writer
+	ctr *aesCTR
+	mac hash.Hash
+	buf []byte
+}
+
+// newAESWriter writes the salt and password verification value for
+// a file encrypted with e to w, and returns a writer that encrypts
+// the file data.
+func newAESWriter(w io.Writer, e Encryption, password string) (*aesWriter, error) {
+	salt := make([]byte, e.saltLen())
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key, macKey, verifier := aesKeys(e, password, salt)
+	if _, err := w.Write(append(salt, verifier...)); err != nil {
+		return nil, err
+	}
+	return &aesWriter{
+		w:   w,
+		ctr: newAESCTR(key),
+		mac: hmac.New(sha1.New, macKey),
+	}, nil
+}
+
+func (aw *aesWriter) Write(p []byte) (int, error) {
+	aw.buf = append(aw.buf[:0], p...)
+	aw.ctr.XORKeyStream(aw.buf, aw.buf)
+	aw.mac.Write(aw.buf)
+	return aw.w.Write(aw.buf)
+}
+
+// Close writes the authentication code.
+func (aw *aesWriter) Close() error {
+	_, err := aw.w.Write(aw.mac.Sum(nil)[:aesMACLen])
+	return err
+}
+
+// aesExtra returns the extra field describing a file encrypted with e
+// whose data is compressed with method.
+func aesExtra(e Encryption, method uint16) []byte {
+	var buf [11]byte
+	b := writeBuf(buf[:])
+	b.uint16(aesExtraID)
+	b.uint16(7) // size
+	b.uint16(aesVersion2)
+	b.uint8('A')
+	b.uint8('E')
+	b.uint8(uint8(e))
+	b.uint16(method)
+	return buf[:]
+}
+
+// wireMethod returns the method recorded in the file headers for h.
+func (h *FileHeader) wireMethod() uint16 {
+	if h.Encryption != NoEncryption {
+		return winzipAESMethod
+	}
+	return h.Method
+}
@@ -0,0 +1,188 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zip
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func password(pw string) func(*FileHeader) (string, error) {
+	return func(*FileHeader) (string, error) { return pw, nil }
+}
+
+func TestReaderWinZipAES(t *testing.T) {
+	// Created by bsdtar --options zip:encryption=aes256 --passphrase golang.
+	r, err := OpenReader("testdata/winzip-aes.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var nums strings.Builder
+	for i := 1; i <= 200; i++ {
+		fmt.Fprintf(&nums, "%d\n", i)
+	}
+	want := map[string]string{
+		"small.txt": "hello, world\n",
+		"nums.txt":  nums.String(),
+	}
+
+	for _, f := range r.File {
+		if f.Encryption != AES256 || f.Method != Deflate {
+			t.Errorf("%s: Encryption = %d, Method = %d; want %d, %d", f.Name, f.Encryption, f.Method, AES256, Deflate)
+		}
+		if _, err := f.Open(); err != ErrPassword {
+			t.Errorf("%s: Open without password: %v, want %v", f.Name, err, ErrPassword)
+		}
+	}
+
+	r.SetPasswordFunc(password("wrong"))
+	for _, f := range r.File {
+		if _, err := f.Open(); err != ErrPassword {
+			t.Errorf("%s: Open with wrong password: %v, want %v", f.Name, err, ErrPassword)
+		}
+	}
+
+	r.SetPasswordFunc(password("golang"))
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		got, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		if string(got) != want[f.Name] {
+			t.Errorf("%s: got %q, want %q", f.Name, got, want[f.Name])
+		}
+	}
+}
+
+func TestWriterWinZipAES(t *testing.T) {
+	large := bytes.Repeat([]byte("The quick brown fox jumps over the lazy dog.\n"), 10000)
+	files := []struct {
+		name       string
+		method     uint16
+		encryption Encryption
+		data       []byte
+	}{
+		{"empty", Deflate, AES128, nil},
+		{"small", Store, AES192, []byte("hello")},
+		{"large", Deflate, AES256, large},
+		{"plain", Deflate, NoEncryption, []byte("not encrypted")},
+		{"dir/", Store, AES256, nil},
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if _, err := w.CreateHeader(&FileHeader{Name: "x", Encryption: AES128}); err != ErrPassword {
+		t.Errorf("CreateHeader without password: %v, want %v", err, ErrPassword)
+	}
+	w = NewWriter(&buf)
+	w.SetPasswordFunc(func(fh *FileHeader) (string, error) { return "pw-" + fh.Name, nil })
+	for _, f := range files {
+		fw, err := w.CreateHeader(&FileHeader{Name: f.name, Method: f.method, Encryption: f.encryption})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(f.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("quick brown")) || bytes.Contains(buf.Bytes(), []byte("hello")) {
+		t.Error("encrypted data appears in the archive")
+	}
+
+	check := func(t *testing.T, b []byte) {
+		t.Helper()
+		r, err := NewReader(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.SetPasswordFunc(func(fh *FileHeader) (string, error) { return "pw-" + fh.Name, nil })
+		for i, f := range r.File {
+			want := files[i]
+			if want.name == "dir/" {
+				want.encryption = NoEncryption
+			}
+			if f.Name != want.name || f.Method != want.method || f.Encryption != want.encryption {
+				t.Errorf("file %d = %q, method %d, encryption %d; want %q, %d, %d",
+					i, f.Name, f.Method, f.Encryption, want.name, want.method, want.encryption)
+			}
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatalf("%s: %v", f.Name, err)
+			}
+			got, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatalf("%s: %v", f.Name, err)
+			}
+			if !bytes.Equal(got, want.data) {
+				t.Errorf("%s: wrong content", f.Name)
+			}
+		}
+	}
+	check(t, buf.Bytes())
+
+	// Copying the encrypted files keeps them encrypted.
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf2 bytes.Buffer
+	w = NewWriter(&buf2)
+	for _, f := range r.File {
+		if err := w.Copy(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	check(t, buf2.Bytes())
+}
+
+func TestWinZipAESCorrupt(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.SetPasswordFunc(password("secret"))
+	fw, err := w.CreateHeader(&FileHeader{Name: "f", Method: Store, Encryption: AES256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(fw, "some data to protect")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+
+	r, err := NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	off, err := r.File[0].DataOffset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Flip a bit in the encrypted data, after the salt and verifier.
+	b[off+int64(AES256.saltLen())+aesVerifierLen] ^= 1
+
+	r.SetPasswordFunc(password("secret"))
+	rc, err := r.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(rc); err != ErrChecksum {
+		t.Errorf("reading corrupted file: %v, want %v", err, ErrChecksum)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zip
+
+import (
+	"archive/internal/extract"
+	"errors"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"strings"
+)
+
+// ExtractOptions limit the resources used by [Reader.Extract].
+// The zero value imposes no limits.
+type ExtractOptions struct {
+	// MaxFiles is the maximum number of entries to extract,
+	// or 0 for no limit.
+	MaxFiles int
+
+	// MaxSize is the maximum total uncompressed size, in bytes,
+	// of the files to extract, or 0 for no limit.
+	MaxSize int64
+}
+
+// maxSymlinkSize is the maximum length of a symbolic link target.
+const maxSymlinkSize = 4096
+
+// Extract extracts the files in the archive into the directory dst,
+// creating it if necessary. A nil opts is equivalent to a zero
+// ExtractOptions.
+//
+// All files are created within dst. Extract returns an error wrapping
+// [ErrInsecurePath] for a file whose name is absolute or escapes dst,
+// and for a symbolic link whose target is absolute or escapes dst.
+// Symbolic links are never followed when creating files, so a file
+// whose parent directory is a symbolic link is also rejected; symbolic
+// links are created after all other files.
+//
+// Existing files are replaced, but existing directories are not.
+// The permission bits and modification times of files and directories
+// are preserved; the set-user-ID, set-group-ID and sticky bits are not.
+// Other special files are skipped.
+//
+// The limits in opts are checked against the number of files and the
+// uncompressed sizes recorded in the archive before anything is
+// extracted. A file that decompresses to more than its recorded size
+// is reported as [ErrFormat].
+func (r *Reader) Extract(dst string, opts *ExtractOptions) error {
+	var o ExtractOptions
+	if opts != nil {
+		o = *opts
+	}
+	x, err := extract.New(dst, extract.Options{
+		Prefix:          "zip",
+		ErrInsecurePath: ErrInsecurePath,
+		MaxFiles:        o.MaxFiles,
+		MaxSize:         o.MaxSize,
+	})
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	for _, f := range r.File {
+		size = addSize(size, f.UncompressedSize64)
+	}
+	if err := x.CheckLimits(len(r.File), size); err != nil {
+		return err
+	}
+
+	for _, f := range r.File {
+		if err := extractFile(x, f); err != nil {
+			return err
+		}
+	}
+	return x.Close()
+}
+
+// addSize returns size+n, saturating at math.MaxInt64.
+func addSize(size int64, n uint64) int64 {
+	if n > uint64(math.MaxInt64-size) {
+		return math.MaxInt64
+	}
+	return size + int64(n)
+}
+
+func extractFile(x *extract.Extractor, f *File) error {
+	mode := f.Mode()
+	switch {
+	case strings.HasSuffix(f.Name, "/") || mode.IsDir():
+		return x.Dir(f.Name, mode, f.Modified)
+	case mode&fs.ModeSymlink != 0:
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		target, err := io.ReadAll(io.LimitReader(rc, maxSymlinkSize+1))
+		if err != nil {
+			return err
+		}
+		if len(target) > maxSymlinkSize {
+			return errors.New("zip: symbolic link target too long")
+		}
+		return x.Symlink(f.Name, string(target))
+	case mode.IsRegular():
+		return x.File(f.Name, addSize(0, f.UncompressedSize64), mode, f.Modified, func(w *os.File) error {
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			defer rc.Close()
+			_, err = io.Copy(w, rc)
+			return err
+		})
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zip
+
+import (
+	"bytes"
+	"errors"
+	"internal/testenv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+type extractEntry struct {
+	name    string
+	mode    fs.FileMode
+	content string
+}
+
+// makeZip returns an archive holding the entries.
+func makeZip(t *testing.T, mtime time.Time, entries ...extractEntry) *Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, e := range entries {
+		fh := &FileHeader{Name: e.name, Method: Deflate, Modified: mtime}
+		fh.SetMode(e.mode)
+		fw, err := w.CreateHeader(fh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(e.content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestExtract(t *testing.T) {
+	mtime := time.Date(2020, 1, 2, 3, 4, 6, 0, time.UTC)
+	r := makeZip(t, mtime,
+		extractEntry{"dir/", fs.ModeDir | 0o750, ""},
+		extractEntry{"dir/file", 0o640, "hello"},
+		extractEntry{"implicit/exec", 0o755, "#!/bin/sh\n"},
+		extractEntry{"pipe", fs.ModeNamedPipe | 0o644, ""},
+	)
+	dst := t.TempDir()
+	if err := r.Extract(dst, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []struct {
+		name    string
+		mode    fs.FileMode
+		content string
+	}{
+		{"dir", fs.ModeDir | 0o750, ""},
+		{"dir/file", 0o640, "hello"},
+		{"implicit/exec", 0o755, "#!/bin/sh\n"},
+	} {
+		p := filepath.Join(dst, f.name)
+		fi, err := os.Lstat(p)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if runtime.GOOS != "windows" && fi.Mode() != f.mode {
+			t.Errorf("%s: mode = %v, want %v", f.name, fi.Mode(), f.mode)
+		}
+		if !fi.ModTime().Equal(mtime) {
+			t.Errorf("%s: mtime = %v, want %v", f.name, fi.ModTime(), mtime)
+		}
+		if fi.Mode().IsRegular() {
+			b, err := os.ReadFile(p)
+			if err != nil || string(b) != f.content {
+				t.Errorf("%s: content = %q, %v; want %q", f.name, b, err, f.content)
+			}
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(dst, "pipe")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("named pipe was extracted: %v", err)
+	}
+}
+
+func TestExtractSymlink(t *testing.T) {
+	testenv.MustHaveSymlink(t)
+
+	r := makeZip(t, time.Now(),
+		extractEntry{"dir/link", fs.ModeSymlink | 0o777, "../file"},
+		extractEntry{"file", 0o644, "content"},
+	)
+	dst := t.TempDir()
+	if err := r.Extract(dst, nil); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(dst, "dir/link"))
+	if err != nil || string(b) != "content" {
+		t.Errorf("reading through link: %q, %v; want %q", b, err, "content")
+	}
+
+	r = makeZip(t, time.Now(), extractEntry{"link", fs.ModeSymlink | 0o777, "../outside"})
+	if err := r.Extract(t.TempDir(), nil); !errors.Is(err, ErrInsecurePath) {
+		t.Errorf("extracting escaping link: %v, want %v", err, ErrInsecurePath)
+	}
+}
+
+func TestExtractInsecure(t *testing.T) {
+	for _, name := range []string{"../evil", "/evil", "a/../../evil", `\evil`, "a/./../../evil"} {
+		r := makeZip(t, time.Now(), extractEntry{name, 0o644, "evil"})
+		dir := t.TempDir()
+		err := r.Extract(filepath.Join(dir, "dst"), nil)
+		if runtime.GOOS != "windows" && name == `\evil` {
+			// Backslash is an ordinary character.
+			if err != nil {
+				t.Errorf("Extract(%q): %v", name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrInsecurePath) {
+			t.Errorf("Extract(%q): %v, want %v", name, err, ErrInsecurePath)
+		}
+		if _, err := os.Lstat(filepath.Join(dir, "evil")); err == nil {
+			t.Errorf("Extract(%q) created file outside the destination", name)
+		}
+	}
+}
+
+func TestExtractLimits(t *testing.T) {
+	r := makeZip(t, time.Now(),
+		extractEntry{"a", 0o644, "12345"},
+		extractEntry{"b", 0o644, "67890"},
+	)
+	tests := []struct {
+		opts ExtractOptions
+		ok   bool
+	}{
+		{ExtractOptions{}, true},
+		{ExtractOptions{MaxFiles: 2, MaxSize: 10}, true},
+		{ExtractOptions{MaxFiles: 1}, false},
+		{ExtractOptions{MaxSize: 9}, false},
+	}
+	for _, tt := range tests {
+		dst := t.TempDir()
+		err := r.Extract(dst, &tt.opts)
+		if (err == nil) != tt.ok {
+			t.Errorf("Extract with %+v: %v", tt.opts, err)
+		}
+		if !tt.ok {
+			// The limits are checked before anything is extracted.
+			if _, err := os.Lstat(filepath.Join(dst, "a")); err == nil {
+				t.Errorf("Extract with %+v created a file", tt.opts)
+			}
+		}
+	}
+
+	// A file that decompresses to more than its recorded size is rejected.
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	fw, err := w.CreateRaw(&FileHeader{Name: "bomb", Method: Store, UncompressedSize64: 4, CompressedSize64: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte("12345678"))
+	w.Close()
+	r, err = NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Extract(t.TempDir(), nil); err != ErrFormat {
+		t.Errorf("Extract of file larger than its recorded size: %v, want %v", err, ErrFormat)
+	}
+}
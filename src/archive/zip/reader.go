@@ -37,6 +37,7 @@ type Reader struct {
 	File          []*File
 	Comment       string
 	decompressors map[uint16]Decompressor
+	passwordFunc  func(*FileHeader) (string, error)
 
 	// Some JAR files are zip files with a prefix that is a bash script.
 	// The baseOffset field is the start of the zip file proper.
@@ -63,6 +64,7 @@ type File struct {
 	zipr         io.ReaderAt
 	headerOffset int64 // includes overall ZIP archive baseOffset
 	zip64        bool  // zip64 extended information extra field presence
+	aesVersion   uint16
 }
 
 // OpenReader will open the Zip file specified by name and return a ReadCloser.
@@ -186,6 +188,13 @@ func (r *Reader) RegisterDecompressor(method uint16, dcomp Decompressor) {
 	r.decompressors[method] = dcomp
 }
 
+// SetPasswordFunc sets the function that returns the password
+// for an encrypted file when [File.Open] is called for it.
+// If it is not set, opening an encrypted file returns [ErrPassword].
+func (r *Reader) SetPasswordFunc(fn func(*FileHeader) (string, error)) {
+	r.passwordFunc = fn
+}
+
 func (r *Reader) decompressor(method uint16) Decompressor {
 	dcomp := r.decompressors[method]
 	if dcomp == nil {
@@ -237,11 +246,32 @@ func (f *File) Open() (io.ReadCloser, error) {
 	}
 	size := int64(f.CompressedSize64)
 	r := io.NewSectionReader(f.zipr, f.headerOffset+bodyOffset, size)
+	if f.Flags&0x1 != 0 && f.Encryption == NoEncryption {
+		// Traditional PKWARE encryption is not supported.
+		return nil, ErrAlgorithm
+	}
 	dcomp := f.zip.decompressor(f.Method)
 	if dcomp == nil {
 		return nil, ErrAlgorithm
 	}
-	var rc io.ReadCloser = dcomp(r)
+	var (
+		ar *aesReader
+		dr io.Reader = r
+	)
+	if f.Encryption != NoEncryption {
+		if f.zip.passwordFunc == nil {
+			return nil, ErrPassword
+		}
+		password, err := f.zip.passwordFunc(&f.FileHeader)
+		if err != nil {
+			return nil, err
+		}
+		if ar, err = newAESReader(r, f.Encryption, password); err != nil {
+			return nil, err
+		}
+		dr = ar
+	}
+	var rc io.ReadCloser = dcomp(dr)
 	var desr io.Reader
 	if f.hasDataDescriptor() {
 		desr = io.NewSectionReader(f.zipr, f.headerOffset+bodyOffset+size, dataDescriptorLen)
@@ -251,6 +281,7 @@ func (f *File) Open() (io.ReadCloser, error) {
 		hash: crc32.NewIEEE(),
 		f:    f,
 		desr: desr,
+		aes:  ar,
 	}
 	return rc, nil
 }
@@ -283,8 +314,9 @@ type checksumReader struct {
 	hash  hash.Hash32
 	nread uint64 // number of bytes read so far
 	f     *File
-	desr  io.Reader // if non-nil, where to read the data descriptor
-	err   error     // sticky error
+	desr  io.Reader  // if non-nil, where to read the data descriptor
+	aes   *aesReader // if non-nil, the decrypting reader to verify at EOF
+	err   error      // sticky error
 }
 
 func (r *checksumReader) Stat() (fs.FileInfo, error) {
@@ -308,6 +340,15 @@ func (r *checksumReader) Read(b []byte) (n int, err error) {
 		if r.nread != r.f.UncompressedSize64 {
 			return 0, io.ErrUnexpectedEOF
 		}
+		// AE-2 encrypted files store no CRC-32;
+		// the authentication code is checked instead.
+		checkCRC := r.f.aesVersion != aesVersion2
+		if r.aes != nil {
+			if err1 := r.aes.verify(); err1 != nil {
+				r.err = err1
+				return n, err1
+			}
+		}
 		if r.desr != nil {
 			if err1 := readDataDescriptor(r.desr, r.f); err1 != nil {
 				if err1 == io.EOF {
@@ -315,14 +356,14 @@ func (r *checksumReader) Read(b []byte) (n int, err error) {
 				} else {
 					err = err1
 				}
-			} else if r.hash.Sum32() != r.f.CRC32 {
+			} else if checkCRC && r.hash.Sum32() != r.f.CRC32 {
 				err = ErrChecksum
 			}
 		} else {
 			// If there's not a data descriptor, we still compare
 			// the CRC32 of what we've read against the file header
 			// or TOC's CRC32, if it seems like it was set.
-			if r.f.CRC32 != 0 && r.hash.Sum32() != r.f.CRC32 {
+			if checkCRC && r.f.CRC32 != 0 && r.hash.Sum32() != r.f.CRC32 {
 				err = ErrChecksum
 			}
 		}
@@ -451,6 +492,19 @@ parseExtras:
 				}
 				f.headerOffset = int64(fieldBuf.uint64())
 			}
+		case aesExtraID:
+			if f.Method != winzipAESMethod || len(fieldBuf) < 7 {
+				continue parseExtras
+			}
+			version := fieldBuf.uint16()
+			fieldBuf.uint16() // vendor ID, "AE"
+			e := Encryption(fieldBuf.uint8())
+			if e.keyLen() == 0 || (version != aesVersion1 && version != aesVersion2) {
+				continue parseExtras
+			}
+			f.Encryption = e
+			f.aesVersion = version
+			f.Method = fieldBuf.uint16()
 		case ntfsExtraID:
 			if len(fieldBuf) < 4 {
 				continue parseExtras
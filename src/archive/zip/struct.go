@@ -58,6 +58,7 @@ const (
 	// Version numbers.
 	zipVersion20 = 20 // 2.0
 	zipVersion45 = 45 // 4.5 (reads and writes zip64 archives)
+	zipVersion51 = 51 // 5.1 (reads and writes WinZip AES encryption)
 
 	// Limits for non zip64 files.
 	uint16max = (1 << 16) - 1
@@ -112,6 +113,16 @@ type FileHeader struct {
 	// Method is the compression method. If zero, Store is used.
 	Method uint16
 
+	// Encryption is the WinZip AES encryption of the file.
+	//
+	// When reading, it is set from the file's AES extra field, and
+	// Method is the compression method used before encryption.
+	//
+	// When writing with [Writer.CreateHeader], a file is encrypted if
+	// Encryption is not NoEncryption, using the password returned by
+	// the function passed to [Writer.SetPasswordFunc].
+	Encryption Encryption
+
 	// Modified is the modified time of the file.
 	//
 	// When reading, an extended timestamp is preferred over the legacy MS-DOS
@@ -30,6 +30,13 @@ type Writer struct {
 	compressors map[uint16]Compressor
 	comment     string
 
+	passwordFunc func(*FileHeader) (string, error)
+
+	// truncate, if non-nil, is called by Close with the final size
+	// of an archive opened by NewAppender.
+	truncate func(size int64) error
+	origSize int64
+
 	// testHookCloseSizeOffset if non-nil is called with the size
 	// of offset of the central directory at Close.
 	testHookCloseSizeOffset func(size, offset uint64)
@@ -46,6 +53,77 @@ func NewWriter(w io.Writer) *Writer {
 	return &Writer{cw: &countWriter{w: bufio.NewWriter(w)}}
 }
 
+// NewAppender returns a new [Writer] that adds files to the existing
+// zip archive of the given size, which it reads from r and updates
+// through w. Typically r and w are the same [*os.File].
+//
+// The files already in the archive are kept in place, along with the
+// archive comment. New files are written over the old central directory,
+// and [Writer.Close] writes a new central directory listing both the
+// existing and the new files. Until Close returns, the archive in w
+// is not valid.
+//
+// If the updated archive is shorter than the original, as happens when
+// [Writer.SetComment] shortens the comment, Close truncates w
+// using its Truncate method. If w has no Truncate method, Close returns
+// an error instead.
+func NewAppender(r io.ReaderAt, w io.WriterAt, size int64) (*Writer, error) {
+	if size < 0 {
+		return nil, errors.New("zip: size cannot be negative")
+	}
+	end, baseOffset, err := readDirectoryEnd(r, size)
+	if err != nil {
+		return nil, err
+	}
+	zr := new(Reader)
+	// The names of existing files are not used by the Writer,
+	// so ErrInsecurePath does not matter here.
+	if err := zr.init(r, size); err != nil && err != ErrInsecurePath {
+		return nil, err
+	}
+
+	start := baseOffset + int64(end.directoryOffset)
+	zw := &Writer{
+		cw:       &countWriter{w: bufio.NewWriter(io.NewOffsetWriter(w, start)), count: start},
+		dir:      make([]*header, 0, len(zr.File)),
+		comment:  zr.Comment,
+		origSize: size,
+	}
+	if t, ok := w.(interface{ Truncate(int64) error }); ok {
+		zw.truncate = t.Truncate
+	}
+	for _, f := range zr.File {
+		fh := f.FileHeader
+		// Close adds a zip64 extra field if the file needs one.
+		fh.Extra = removeExtra(fh.Extra, zip64ExtraID)
+		zw.dir = append(zw.dir, &header{FileHeader: &fh, offset: uint64(f.headerOffset), raw: true})
+	}
+	return zw, nil
+}
+
+// removeExtra returns a copy of the extra fields in extra,
+// without the fields with the given tag.
+func removeExtra(extra []byte, tag uint16) []byte {
+	var out []byte
+	for b := readBuf(extra); len(b) >= 4; {
+		fieldTag := b.uint16()
+		fieldSize := int(b.uint16())
+		if len(b) < fieldSize {
+			break
+		}
+		fieldBuf := b.sub(fieldSize)
+		if fieldTag != tag {
+			var hdr [4]byte
+			wb := writeBuf(hdr[:])
+			wb.uint16(fieldTag)
+			wb.uint16(uint16(fieldSize))
+			out = append(out, hdr[:]...)
+			out = append(out, fieldBuf...)
+		}
+	}
+	return out
+}
+
 // SetOffset sets the offset of the beginning of the zip data within the
 // underlying writer. It should be used when the zip data is appended to an
 // existing file, such as a binary executable.
@@ -63,6 +141,13 @@ func (w *Writer) Flush() error {
 	return w.cw.w.(*bufio.Writer).Flush()
 }
 
+// SetPasswordFunc sets the function that returns the password for
+// files created by [Writer.CreateHeader] whose Encryption field
+// is not NoEncryption.
+func (w *Writer) SetPasswordFunc(fn func(*FileHeader) (string, error)) {
+	w.passwordFunc = fn
+}
+
 // SetComment sets the end-of-central-directory comment field.
 // It can only be called before [Writer.Close].
 func (w *Writer) SetComment(comment string) error {
@@ -96,7 +181,7 @@ func (w *Writer) Close() error {
 		b.uint16(h.CreatorVersion)
 		b.uint16(h.ReaderVersion)
 		b.uint16(h.Flags)
-		b.uint16(h.Method)
+		b.uint16(h.wireMethod())
 		b.uint16(h.ModifiedTime)
 		b.uint16(h.ModifiedDate)
 		b.uint32(h.CRC32)
@@ -204,7 +289,16 @@ func (w *Writer) Close() error {
 		return err
 	}
 
-	return w.cw.w.(*bufio.Writer).Flush()
+	if err := w.cw.w.(*bufio.Writer).Flush(); err != nil {
+		return err
+	}
+	if w.origSize > w.cw.count {
+		if w.truncate == nil {
+			return errors.New("zip: appended archive is shorter than the original and cannot be truncated")
+		}
+		return w.truncate(w.cw.count)
+	}
+	return nil
 }
 
 // Create adds a file to the zip file using the provided name.
@@ -346,6 +440,7 @@ func (w *Writer) CreateHeader(fh *FileHeader) (io.Writer, error) {
 		// even when compressing an empty string.
 		fh.Method = Store
 		fh.Flags &^= 0x8 // we will not write a data descriptor
+		fh.Encryption = NoEncryption
 
 		// Explicitly clear sizes as they have no meaning for directories.
 		fh.CompressedSize = 0
@@ -366,19 +461,50 @@ func (w *Writer) CreateHeader(fh *FileHeader) (io.Writer, error) {
 		if comp == nil {
 			return nil, ErrAlgorithm
 		}
+		fw.header = h
+		ow = fw
+	}
+
+	var password string
+	if fh.Encryption != NoEncryption {
+		if fh.Encryption.keyLen() == 0 {
+			return nil, errors.New("zip: invalid FileHeader.Encryption")
+		}
+		if w.passwordFunc == nil {
+			return nil, ErrPassword
+		}
 		var err error
-		fw.comp, err = comp(fw.compCount)
-		if err != nil {
+		if password, err = w.passwordFunc(fh); err != nil {
 			return nil, err
 		}
-		fw.rawCount = &countWriter{w: fw.comp}
-		fw.header = h
-		ow = fw
+		fh.Flags |= 0x1
+		fh.ReaderVersion = zipVersion51
+		fh.Extra = append(removeExtra(fh.Extra, aesExtraID), aesExtra(fh.Encryption, fh.Method)...)
 	}
+
 	w.dir = append(w.dir, h)
 	if err := writeHeader(w.cw, h); err != nil {
 		return nil, err
 	}
+	if fw != nil {
+		// The encryption header follows the file header, so the
+		// compressor can only be created now.
+		var cw io.Writer = fw.compCount
+		if fh.Encryption != NoEncryption {
+			aw, err := newAESWriter(fw.compCount, fh.Encryption, password)
+			if err != nil {
+				return nil, err
+			}
+			fw.enc = aw
+			cw = aw
+		}
+		var err error
+		fw.comp, err = w.compressor(fh.Method)(cw)
+		if err != nil {
+			return nil, err
+		}
+		fw.rawCount = &countWriter{w: fw.comp}
+	}
 	// If we're creating a directory, fw is nil.
 	w.last = fw
 	return ow, nil
@@ -398,7 +524,7 @@ func writeHeader(w io.Writer, h *header) error {
 	b.uint32(uint32(fileHeaderSignature))
 	b.uint16(h.ReaderVersion)
 	b.uint16(h.Flags)
-	b.uint16(h.Method)
+	b.uint16(h.wireMethod())
 	b.uint16(h.ModifiedTime)
 	b.uint16(h.ModifiedDate)
 	// In raw mode (caller does the compression), the values are either
@@ -558,6 +684,7 @@ type fileWriter struct {
 	comp      io.WriteCloser
 	compCount *countWriter
 	crc32     hash.Hash32
+	enc       *aesWriter // if non-nil, encrypts the compressed data
 	closed    bool
 }
 
@@ -583,17 +710,25 @@ func (w *fileWriter) close() error {
 	if err := w.comp.Close(); err != nil {
 		return err
 	}
+	if w.enc != nil {
+		if err := w.enc.Close(); err != nil {
+			return err
+		}
+	}
 
 	// update FileHeader
 	fh := w.header.FileHeader
 	fh.CRC32 = w.crc32.Sum32()
+	if w.enc != nil {
+		fh.CRC32 = 0 // AE-2 does not store the CRC-32
+	}
 	fh.CompressedSize64 = uint64(w.compCount.count)
 	fh.UncompressedSize64 = uint64(w.rawCount.count)
 
 	if fh.isZip64() {
 		fh.CompressedSize = uint32max
 		fh.UncompressedSize = uint32max
-		fh.ReaderVersion = zipVersion45 // requires 4.5 - File uses ZIP64 format extensions
+		fh.ReaderVersion = max(fh.ReaderVersion, zipVersion45) // requires 4.5 - File uses ZIP64 format extensions
 	} else {
 		fh.CompressedSize = uint32(fh.CompressedSize64)
 		fh.UncompressedSize = uint32(fh.UncompressedSize64)
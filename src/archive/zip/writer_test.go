@@ -671,3 +671,113 @@ func TestIssue61875(t *testing.T) {
 		t.Errorf("expected error, got nil")
 	}
 }
+
+// memFile is an in-memory file for testing NewAppender.
+type memFile struct {
+	b []byte
+}
+
+func (f *memFile) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(f.b)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.b[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) WriteAt(p []byte, off int64) (int, error) {
+	if end := int(off) + len(p); end > len(f.b) {
+		f.b = append(f.b, make([]byte, end-len(f.b))...)
+	}
+	return copy(f.b[off:], p), nil
+}
+
+func (f *memFile) Truncate(size int64) error {
+	f.b = f.b[:size]
+	return nil
+}
+
+func TestAppender(t *testing.T) {
+	largeData := make([]byte, 1<<17)
+	if _, err := rand.Read(largeData); err != nil {
+		t.Fatal("rand.Read failed:", err)
+	}
+	writeTests[1].Data = largeData
+	defer func() {
+		writeTests[1].Data = nil
+	}()
+
+	// Write the first half of the tests, with a prefix before the archive.
+	buf := new(bytes.Buffer)
+	buf.WriteString("#!/bin/sh\nexit 0\n")
+	w := NewWriter(buf)
+	w.SetOffset(int64(buf.Len()))
+	half := len(writeTests) / 2
+	for _, wt := range writeTests[:half] {
+		testCreate(t, w, &wt)
+	}
+	w.SetComment("the comment")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	orig := append([]byte(nil), buf.Bytes()...)
+
+	// Append the rest.
+	f := &memFile{b: buf.Bytes()}
+	w, err := NewAppender(f, f, int64(len(f.b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, wt := range writeTests[half:] {
+		testCreate(t, w, &wt)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(bytes.NewReader(f.b), int64(len(f.b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.File) != len(writeTests) {
+		t.Fatalf("got %d files, want %d", len(r.File), len(writeTests))
+	}
+	for i, wt := range writeTests {
+		testReadFile(t, r.File[i], &wt)
+	}
+	if r.Comment != "the comment" {
+		t.Errorf("Comment = %q, want %q", r.Comment, "the comment")
+	}
+
+	// The existing entries must not have been rewritten.
+	if off := r.File[half].headerOffset; !bytes.Equal(f.b[:off], orig[:off]) {
+		t.Error("existing entries were modified")
+	}
+
+	// Shortening the comment truncates the file.
+	size := int64(len(f.b))
+	w, err = NewAppender(f, f, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetComment("")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if want := size - int64(len("the comment")); int64(len(f.b)) != want {
+		t.Errorf("size after shortening comment = %d, want %d", len(f.b), want)
+	}
+	r, err = NewReader(bytes.NewReader(f.b), int64(len(f.b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.File) != len(writeTests) || r.Comment != "" {
+		t.Errorf("got %d files and comment %q, want %d files and no comment", len(r.File), r.Comment, len(writeTests))
+	}
+	for i, wt := range writeTests {
+		testReadFile(t, r.File[i], &wt)
+	}
+}
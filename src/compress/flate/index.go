@@ -0,0 +1,317 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flate
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"sort"
+)
+
+// An Index records checkpoints in a DEFLATE stream, from which
+// decompression can start without decompressing the data before them.
+// Each checkpoint holds the last 32 KiB of uncompressed data before it,
+// so an index takes up about 32 KiB per checkpoint.
+//
+// Checkpoints can only be placed at the start of a DEFLATE block, so the
+// distance between them also depends on how the stream was compressed.
+//
+// An Index is created by [BuildIndex] or an [Indexer], and used by
+// an [IndexedReader]. It may be saved with [Index.MarshalBinary].
+type Index struct {
+	size   int64        // total uncompressed size
+	points []indexPoint // in increasing order of out
+}
+
+// An indexPoint is a checkpoint in a DEFLATE stream.
+type indexPoint struct {
+	in     int64  // bit offset in the compressed data of the start of a block
+	out    int64  // offset in the uncompressed data of the start of the block
+	window []byte // uncompressed data before out, up to maxMatchOffset bytes
+}
+
+// Size returns the size of the uncompressed data.
+func (x *Index) Size() int64 {
+	return x.size
+}
+
+// An Indexer decompresses a DEFLATE stream and builds an [Index] for it
+// as the data is read.
+type Indexer struct {
+	f     decompressor
+	span  int64
+	out   int64 // uncompressed bytes returned so far
+	index Index
+	done  bool
+}
+
+// NewIndexer returns a new [Indexer] that reads a DEFLATE stream from r.
+// It records a checkpoint at the start of the first block that begins
+// at least span bytes of uncompressed data after the previous checkpoint.
+// If r does not also implement [io.ByteReader], the Indexer may read
+// more data than necessary from r.
+func NewIndexer(r io.Reader, span int64) *Indexer {
+	fixedHuffmanDecoderInit()
+
+	ix := &Indexer{span: span}
+	f := &ix.f
+	f.makeReader(r)
+	f.bits = new([maxNumLit + maxNumDist]int)
+	f.codebits = new([numCodes]int)
+	f.step = (*decompressor).nextBlock
+	f.dict.init(maxMatchOffset, nil)
+	f.onBlock = ix.checkpoint
+	return ix
+}
+
+// checkpoint is called at the start of each block.
+func (ix *Indexer) checkpoint(f *decompressor) {
+	out := ix.out + int64(f.dict.availRead())
+	if n := len(ix.index.points); n > 0 && out-ix.index.points[n-1].out < ix.span {
+		return
+	}
+	ix.index.points = append(ix.index.points, indexPoint{
+		in:     f.roffset*8 - int64(f.nb),
+		out:    out,
+		window: f.dict.window(),
+	})
+}
+
+// Read reads uncompressed data, recording checkpoints as it goes.
+func (ix *Indexer) Read(p []byte) (int, error) {
+	n, err := ix.f.Read(p)
+	ix.out += int64(n)
+	if err == io.EOF {
+		ix.done = true
+		ix.index.size = ix.out
+	}
+	return n, err
+}
+
+// Index returns the index of the stream.
+// It returns nil until Read has returned [io.EOF].
+func (ix *Indexer) Index() *Index {
+	if !ix.done {
+		return nil
+	}
+	return &ix.index
+}
+
+// BuildIndex reads the DEFLATE stream from r to its end and returns
+// an index whose checkpoints are at least span bytes of uncompressed
+// data apart. See [NewIndexer] for details.
+func BuildIndex(r io.Reader, span int64) (*Index, error) {
+	ix := NewIndexer(r, span)
+	if _, err := io.Copy(io.Discard, ix); err != nil {
+		return nil, err
+	}
+	return ix.Index(), nil
+}
+
+// window returns a copy of the history, oldest byte first.
+func (dd *dictDecoder) window() []byte {
+	if !dd.full {
+		return append([]byte(nil), dd.hist[:dd.wrPos]...)
+	}
+	w := make([]byte, 0, len(dd.hist))
+	w = append(w, dd.hist[dd.wrPos:]...)
+	return append(w, dd.hist[:dd.wrPos]...)
+}
+
+// point returns the last checkpoint at or before the uncompressed offset off.
+func (x *Index) point(off int64) *indexPoint {
+	i := sort.Search(len(x.points), func(i int) bool { return x.points[i].out > off })
+	return &x.points[i-1]
+}
+
+// newDecompressor returns a decompressor that reads the compressed data
+// in r starting at checkpoint p.
+func (x *Index) newDecompressor(r io.ReaderAt, p *indexPoint) (*decompressor, error) {
+	off := p.in / 8
+	f := NewReaderDict(io.NewSectionReader(r, off, math.MaxInt64-off), p.window).(*decompressor)
+	f.roffset = off
+	if k := uint(p.in % 8); k != 0 {
+		if err := f.moreBits(); err != nil {
+			return nil, err
+		}
+		f.b >>= k
+		f.nb -= k
+	}
+	return f, nil
+}
+
+const indexMagic = "DEFLATE index\x00"
+
+// MarshalBinary encodes the index in a binary form
+// that can be decoded by [Index.UnmarshalBinary].
+func (x *Index) MarshalBinary() ([]byte, error) {
+	b := []byte(indexMagic)
+	b = binary.AppendUvarint(b, uint64(x.size))
+	b = binary.AppendUvarint(b, uint64(len(x.points)))
+	var last indexPoint
+	for _, p := range x.points {
+		b = binary.AppendUvarint(b, uint64(p.in-last.in))
+		b = binary.AppendUvarint(b, uint64(p.out-last.out))
+		b = binary.AppendUvarint(b, uint64(len(p.window)))
+		b = append(b, p.window...)
+		last = p
+	}
+	return b, nil
+}
+
+var errIndexFormat = errors.New("flate: invalid index data")
+
+// UnmarshalBinary decodes an index encoded by [Index.MarshalBinary].
+func (x *Index) UnmarshalBinary(data []byte) error {
+	b, ok := bytes.CutPrefix(data, []byte(indexMagic))
+	if !ok {
+		return errIndexFormat
+	}
+	next := func() uint64 {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			ok = false
+			return 0
+		}
+		b = b[n:]
+		return v
+	}
+	size := next()
+	n := next()
+	if !ok || size > math.MaxInt64 || n > uint64(len(b)) {
+		return errIndexFormat
+	}
+	points := make([]indexPoint, 0, n)
+	var last indexPoint
+	for i := uint64(0); i < n; i++ {
+		p := indexPoint{
+			in:  last.in + int64(next()),
+			out: last.out + int64(next()),
+		}
+		wlen := next()
+		if !ok || wlen > maxMatchOffset || wlen > uint64(len(b)) ||
+			p.in < last.in || p.out < last.out || p.out > int64(size) || (i == 0 && p.out != 0) {
+			return errIndexFormat
+		}
+		p.window = append([]byte(nil), b[:wlen]...)
+		b = b[wlen:]
+		points = append(points, p)
+		last = p
+	}
+	if len(b) != 0 || len(points) == 0 {
+		return errIndexFormat
+	}
+	x.size = int64(size)
+	x.points = points
+	return nil
+}
+
+// An IndexedReader provides random access to the uncompressed data of
+// a DEFLATE stream, using an [Index] to avoid decompressing the stream
+// from the start. It implements [io.Reader], [io.ReaderAt], and [io.Seeker].
+type IndexedReader struct {
+	r     io.ReaderAt
+	index *Index
+	off   int64 // offset for Read
+
+	// The decompressor used by Read, and its position.
+	f    *decompressor
+	fOff int64
+}
+
+// NewIndexedReader returns an [IndexedReader] that reads the DEFLATE
+// stream in r, which must start at offset 0 of r, using the index x.
+func NewIndexedReader(r io.ReaderAt, x *Index) *IndexedReader {
+	return &IndexedReader{r: r, index: x}
+}
+
+// Size returns the size of the uncompressed data.
+func (z *IndexedReader) Size() int64 {
+	return z.index.size
+}
+
+// Read implements [io.Reader].
+func (z *IndexedReader) Read(p []byte) (int, error) {
+	if z.off >= z.index.size {
+		return 0, io.EOF
+	}
+	// Reuse the current decompressor if it is at or before the
+	// current offset, and no checkpoint is closer.
+	pt := z.index.point(z.off)
+	if z.f == nil || z.fOff > z.off || z.fOff < pt.out {
+		f, err := z.index.newDecompressor(z.r, pt)
+		if err != nil {
+			return 0, err
+		}
+		z.f, z.fOff = f, pt.out
+	}
+	if z.fOff < z.off {
+		n, err := io.CopyN(io.Discard, z.f, z.off-z.fOff)
+		z.fOff += n
+		if err != nil {
+			return 0, noEOF(err)
+		}
+	}
+	n, err := z.f.Read(p)
+	z.fOff += int64(n)
+	z.off += int64(n)
+	if err == io.EOF && z.off < z.index.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// ReadAt implements [io.ReaderAt].
+// It may be called concurrently with other calls to ReadAt,
+// but not with Read or Seek.
+func (z *IndexedReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("flate.IndexedReader.ReadAt: negative offset")
+	}
+	if off >= z.index.size {
+		return 0, io.EOF
+	}
+	pt := z.index.point(off)
+	f, err := z.index.newDecompressor(z.r, pt)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := io.CopyN(io.Discard, f, off-pt.out); err != nil {
+		return 0, noEOF(err)
+	}
+	want := len(p)
+	if rem := z.index.size - off; int64(want) > rem {
+		want = int(rem)
+	}
+	n, err := io.ReadFull(f, p[:want])
+	if err != nil {
+		return n, noEOF(err)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Seek implements [io.Seeker].
+func (z *IndexedReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += z.off
+	case io.SeekEnd:
+		offset += z.index.size
+	default:
+		return 0, errors.New("flate.IndexedReader.Seek: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("flate.IndexedReader.Seek: negative position")
+	}
+	z.off = offset
+	return offset, nil
+}
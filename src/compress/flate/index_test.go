@@ -0,0 +1,167 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flate
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// indexTestData returns n bytes of compressible data
+// with long-distance repetitions.
+func indexTestData(n int) []byte {
+	rnd := rand.New(rand.NewSource(1))
+	var buf bytes.Buffer
+	for buf.Len() < n {
+		fmt.Fprintf(&buf, "%d: %x\n", buf.Len(), rnd.Intn(1<<16))
+	}
+	return buf.Bytes()[:n]
+}
+
+func compressForIndex(t *testing.T, data []byte, level int) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Write in pieces with occasional flushes, to get a mix of
+	// block boundaries, including byte-aligned ones.
+	for i := 0; i < len(data); i += 100 << 10 {
+		w.Write(data[i:min(i+100<<10, len(data))])
+		if i%(300<<10) == 0 {
+			w.Flush()
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestIndex(t *testing.T) {
+	data := indexTestData(1 << 20)
+	for _, level := range []int{NoCompression, BestSpeed, DefaultCompression, HuffmanOnly} {
+		t.Run(fmt.Sprint(level), func(t *testing.T) {
+			compressed := compressForIndex(t, data, level)
+			x, err := BuildIndex(bytes.NewReader(compressed), 64<<10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if x.Size() != int64(len(data)) {
+				t.Fatalf("Size = %d, want %d", x.Size(), len(data))
+			}
+			if len(x.points) < 2 {
+				t.Fatalf("index has only %d checkpoints", len(x.points))
+			}
+			for i := 1; i < len(x.points); i++ {
+				if d := x.points[i].out - x.points[i-1].out; d < 64<<10 {
+					t.Errorf("checkpoints %d and %d are %d bytes apart", i-1, i, d)
+				}
+			}
+			testIndexedReader(t, NewIndexedReader(bytes.NewReader(compressed), x), data)
+
+			// Round trip the index through its binary encoding.
+			b, err := x.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			x2 := new(Index)
+			if err := x2.UnmarshalBinary(b); err != nil {
+				t.Fatal(err)
+			}
+			testIndexedReader(t, NewIndexedReader(bytes.NewReader(compressed), x2), data)
+		})
+	}
+}
+
+func testIndexedReader(t *testing.T, z *IndexedReader, data []byte) {
+	t.Helper()
+	rnd := rand.New(rand.NewSource(2))
+	offsets := []int{0, 1, len(data) - 1, len(data) / 2}
+	for i := 0; i < 20; i++ {
+		offsets = append(offsets, rnd.Intn(len(data)))
+	}
+	for _, off := range offsets {
+		n := min(1000, len(data)-off)
+		got := make([]byte, n)
+		if _, err := z.ReadAt(got, int64(off)); err != nil {
+			t.Fatalf("ReadAt(%d): %v", off, err)
+		}
+		if !bytes.Equal(got, data[off:off+n]) {
+			t.Fatalf("ReadAt(%d) returned wrong data", off)
+		}
+
+		if _, err := z.Seek(int64(off), io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadFull(z, got); err != nil {
+			t.Fatalf("Read at %d: %v", off, err)
+		}
+		if !bytes.Equal(got, data[off:off+n]) {
+			t.Fatalf("Read at %d returned wrong data", off)
+		}
+	}
+
+	// Reading past the end.
+	buf := make([]byte, 10)
+	n, err := z.ReadAt(buf, int64(len(data)-5))
+	if n != 5 || err != io.EOF {
+		t.Errorf("ReadAt at end = %d, %v; want 5, EOF", n, err)
+	}
+	if !bytes.Equal(buf[:5], data[len(data)-5:]) {
+		t.Errorf("ReadAt at end returned wrong data")
+	}
+
+	// Reading sequentially from the start.
+	z.Seek(0, io.SeekStart)
+	all, err := io.ReadAll(z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(all, data) {
+		t.Errorf("ReadAll returned wrong data")
+	}
+}
+
+func TestIndexer(t *testing.T) {
+	data := indexTestData(200 << 10)
+	compressed := compressForIndex(t, data, DefaultCompression)
+	ix := NewIndexer(bytes.NewReader(compressed), 32<<10)
+	if ix.Index() != nil {
+		t.Error("Index before EOF is not nil")
+	}
+	got, err := io.ReadAll(ix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("Indexer returned wrong data")
+	}
+	if x := ix.Index(); x == nil || x.Size() != int64(len(data)) {
+		t.Errorf("Index after EOF = %v", x)
+	}
+}
+
+func TestIndexCorrupt(t *testing.T) {
+	data := indexTestData(300 << 10)
+	compressed := compressForIndex(t, data, BestSpeed)
+	x, err := BuildIndex(bytes.NewReader(compressed), 64<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := x.MarshalBinary()
+	for _, bad := range [][]byte{nil, b[:len(b)-1], b[:20], append(b[:len(b):len(b)], 0)} {
+		if err := new(Index).UnmarshalBinary(bad); err == nil {
+			t.Errorf("UnmarshalBinary of %d bytes succeeded", len(bad))
+		}
+	}
+
+	if _, err := BuildIndex(bytes.NewReader(compressed[:len(compressed)/2]), 64<<10); err != io.ErrUnexpectedEOF {
+		t.Errorf("BuildIndex of truncated data: %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
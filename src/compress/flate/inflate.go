@@ -297,9 +297,15 @@ type decompressor struct {
 	hl, hd    *huffmanDecoder
 	copyLen   int
 	copyDist  int
+
+	// If non-nil, onBlock is called at the start of each block.
+	onBlock func(*decompressor)
 }
 
 func (f *decompressor) nextBlock() {
+	if f.onBlock != nil {
+		f.onBlock(f)
+	}
 	for f.nb < 1+2 {
 		if f.err = f.moreBits(); f.err != nil {
 			return
@@ -0,0 +1,123 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gzip
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// An Index allows random access to the uncompressed data of a gzip file
+// through an [flate.IndexedReader]. See [flate.Index] for details.
+type Index struct {
+	offset int64 // offset of the DEFLATE data in the file
+	index  *flate.Index
+}
+
+// BuildIndex reads the gzip file from r and returns an index whose
+// checkpoints are at least span bytes of uncompressed data apart.
+// It verifies the checksum and size in the gzip trailer.
+// Only the first member of a multistream file is indexed;
+// any data after it is not read.
+func BuildIndex(r io.Reader, span int64) (*Index, error) {
+	cr := &countingReader{}
+	if rr, ok := r.(flate.Reader); ok {
+		cr.r = rr
+	} else {
+		cr.r = bufio.NewReader(r)
+	}
+	var z Reader
+	if err := z.Reset(cr); err != nil {
+		return nil, err
+	}
+	offset := cr.n
+
+	ix := flate.NewIndexer(cr, span)
+	digest := crc32.NewIEEE()
+	size, err := io.Copy(digest, ix)
+	if err != nil {
+		return nil, err
+	}
+	var buf [8]byte
+	if _, err := io.ReadFull(cr, buf[:]); err != nil {
+		return nil, noEOF(err)
+	}
+	if le.Uint32(buf[:4]) != digest.Sum32() || le.Uint32(buf[4:]) != uint32(size) {
+		return nil, ErrChecksum
+	}
+	return &Index{offset: offset, index: ix.Index()}, nil
+}
+
+// countingReader counts the bytes read from a flate.Reader.
+type countingReader struct {
+	r flate.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+func (cr *countingReader) ReadByte() (byte, error) {
+	c, err := cr.r.ReadByte()
+	if err == nil {
+		cr.n++
+	}
+	return c, err
+}
+
+// Size returns the size of the uncompressed data.
+func (x *Index) Size() int64 {
+	return x.index.Size()
+}
+
+// NewIndexedReader returns a [flate.IndexedReader] that reads the
+// uncompressed data of the gzip file in r, using the index x.
+func NewIndexedReader(r io.ReaderAt, x *Index) *flate.IndexedReader {
+	return flate.NewIndexedReader(io.NewSectionReader(r, x.offset, math.MaxInt64-x.offset), x.index)
+}
+
+const indexMagic = "gzip index\x00"
+
+// MarshalBinary encodes the index in a binary form
+// that can be decoded by [Index.UnmarshalBinary].
+func (x *Index) MarshalBinary() ([]byte, error) {
+	fb, err := x.index.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	b := []byte(indexMagic)
+	b = binary.AppendUvarint(b, uint64(x.offset))
+	return append(b, fb...), nil
+}
+
+var errIndexFormat = errors.New("gzip: invalid index data")
+
+// UnmarshalBinary decodes an index encoded by [Index.MarshalBinary].
+func (x *Index) UnmarshalBinary(data []byte) error {
+	b, ok := bytes.CutPrefix(data, []byte(indexMagic))
+	if !ok {
+		return errIndexFormat
+	}
+	offset, n := binary.Uvarint(b)
+	if n <= 0 || offset > math.MaxInt64 {
+		return errIndexFormat
+	}
+	fi := new(flate.Index)
+	if err := fi.UnmarshalBinary(b[n:]); err != nil {
+		return err
+	}
+	x.offset = int64(offset)
+	x.index = fi
+	return nil
+}
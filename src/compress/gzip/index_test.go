@@ -0,0 +1,69 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gzip
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	data := parallelTestData(1 << 20)
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Name = "log.txt"
+	w.Comment = "a header with optional fields"
+	w.Write(data)
+	w.Close()
+	compressed := buf.Bytes()
+
+	x, err := BuildIndex(bytes.NewReader(compressed), 100<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x.Size() != int64(len(data)) {
+		t.Fatalf("Size = %d, want %d", x.Size(), len(data))
+	}
+
+	b, err := x.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	x2 := new(Index)
+	if err := x2.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, x := range []*Index{x, x2} {
+		z := NewIndexedReader(bytes.NewReader(compressed), x)
+		for _, off := range []int{0, 12345, 500000, len(data) - 100} {
+			got := make([]byte, 100)
+			if _, err := z.ReadAt(got, int64(off)); err != nil {
+				t.Fatalf("ReadAt(%d): %v", off, err)
+			}
+			if !bytes.Equal(got, data[off:off+100]) {
+				t.Errorf("ReadAt(%d) returned wrong data", off)
+			}
+		}
+		z.Seek(-10, io.SeekEnd)
+		tail, err := io.ReadAll(z)
+		if err != nil || !bytes.Equal(tail, data[len(data)-10:]) {
+			t.Errorf("read %q, %v at end; want %q", tail, err, data[len(data)-10:])
+		}
+	}
+}
+
+func TestIndexChecksum(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Write([]byte("hello, world"))
+	w.Close()
+	compressed := buf.Bytes()
+	compressed[len(compressed)-8] ^= 1
+	if _, err := BuildIndex(bytes.NewReader(compressed), 1<<20); err != ErrChecksum {
+		t.Errorf("BuildIndex with bad checksum: %v, want %v", err, ErrChecksum)
+	}
+}
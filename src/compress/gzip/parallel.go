@@ -0,0 +1,373 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gzip
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"runtime"
+)
+
+const (
+	// defaultBlockSize is the default amount of uncompressed
+	// data compressed by each goroutine of a ParallelWriter.
+	defaultBlockSize = 1 << 20
+
+	// windowSize is the size of the DEFLATE sliding window.
+	// Each block is compressed using the end of the previous
+	// block as a preset dictionary.
+	windowSize = 32 << 10
+)
+
+// A ParallelWriter is an io.WriteCloser that compresses data like
+// a [Writer], but splits the data into blocks that are compressed
+// concurrently on separate goroutines.
+//
+// The output is a standard single-member GZIP stream that any GZIP
+// reader can decompress. Each block is compressed using the end of the
+// previous block as a preset dictionary, so the compression ratio is
+// only slightly worse than that of a Writer at the same level.
+// The blocks are joined by DEFLATE sync flush markers.
+//
+// A ParallelWriter buffers up to the block size times the number of
+// concurrent blocks of uncompressed data, plus their compressed forms.
+type ParallelWriter struct {
+	Header // written at first call to Write, Flush, or Close
+
+	w           io.Writer
+	level       int
+	blockSize   int
+	blocks      int // maximum number of blocks in flight
+	wroteHeader bool
+	closed      bool
+	err         error
+
+	cur     []byte           // uncompressed data of the block being filled
+	dict    []byte           // last windowSize bytes before cur
+	pending []*parallelBlock // blocks being compressed, in order
+	digest  uint32           // CRC-32 of the data written out so far
+	size    uint32           // size of the data written out so far
+}
+
+// A parallelBlock is a block of data being compressed by a goroutine.
+type parallelBlock struct {
+	done   chan struct{} // closed when compression is complete
+	out    bytes.Buffer  // compressed data
+	size   int           // uncompressed size
+	digest uint32        // CRC-32 of the uncompressed data
+	err    error
+}
+
+// NewParallelWriter returns a new [ParallelWriter] that compresses
+// data at the given level and writes it to w.
+// By default, it compresses blocks of 1 MiB on up to
+// [runtime.GOMAXPROCS] goroutines; see [ParallelWriter.SetConcurrency].
+//
+// The compression level can be [DefaultCompression], [NoCompression],
+// [HuffmanOnly] or any integer value between [BestSpeed] and
+// [BestCompression] inclusive. The error returned will be nil if the
+// level is valid.
+//
+// It is the caller's responsibility to call Close on the ParallelWriter
+// when done. Callers that wish to set the fields in ParallelWriter.Header
+// must do so before the first call to Write, Flush, or Close.
+func NewParallelWriter(w io.Writer, level int) (*ParallelWriter, error) {
+	if level < HuffmanOnly || level > BestCompression {
+		return nil, fmt.Errorf("gzip: invalid compression level: %d", level)
+	}
+	z := &ParallelWriter{
+		level:     level,
+		blockSize: defaultBlockSize,
+		blocks:    runtime.GOMAXPROCS(0),
+	}
+	z.Reset(w)
+	return z, nil
+}
+
+// SetConcurrency sets the amount of uncompressed data in each block
+// and the maximum number of blocks compressed at once.
+// The block size must be at least 64 KiB, and blocks must be positive.
+// SetConcurrency must be called before the first call to Write,
+// Flush, or Close.
+func (z *ParallelWriter) SetConcurrency(blockSize, blocks int) error {
+	if blockSize < 2*windowSize {
+		return fmt.Errorf("gzip: block size too small: %d", blockSize)
+	}
+	if blocks <= 0 {
+		return fmt.Errorf("gzip: invalid number of blocks: %d", blocks)
+	}
+	if z.wroteHeader {
+		return errors.New("gzip: SetConcurrency called after Write")
+	}
+	z.blockSize = blockSize
+	z.blocks = blocks
+	z.cur = nil
+	return nil
+}
+
+// Reset discards the [ParallelWriter] z's state and makes it equivalent
+// to the result of its original state from [NewParallelWriter], but
+// writing to w instead. The concurrency settings are retained.
+func (z *ParallelWriter) Reset(w io.Writer) {
+	for _, b := range z.pending {
+		<-b.done
+	}
+	*z = ParallelWriter{
+		Header: Header{
+			OS: 255, // unknown
+		},
+		w:         w,
+		level:     z.level,
+		blockSize: z.blockSize,
+		blocks:    z.blocks,
+		cur:       z.cur[:0],
+		dict:      z.dict[:0],
+		pending:   z.pending[:0],
+	}
+}
+
+// Write writes a compressed form of p to the underlying [io.Writer].
+// The compressed bytes are not necessarily flushed until the
+// [ParallelWriter] is closed.
+func (z *ParallelWriter) Write(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	if z.closed {
+		return 0, errors.New("gzip: write to closed ParallelWriter")
+	}
+	if !z.wroteHeader {
+		z.wroteHeader = true
+		hdr := Writer{Header: z.Header, w: z.w, level: z.level}
+		if z.err = hdr.writeHeader(); z.err != nil {
+			return 0, z.err
+		}
+	}
+	n := 0
+	for len(p) > 0 {
+		if z.cur == nil {
+			z.cur = make([]byte, 0, z.blockSize)
+		}
+		m := copy(z.cur[len(z.cur):cap(z.cur)], p)
+		z.cur = z.cur[:len(z.cur)+m]
+		n += m
+		p = p[m:]
+		if len(z.cur) == cap(z.cur) {
+			if z.err = z.startBlock(false); z.err != nil {
+				return n, z.err
+			}
+		}
+	}
+	return n, nil
+}
+
+// startBlock starts compressing the current block on a new goroutine,
+// first writing out older blocks if too many are in flight.
+// If last is set, the block ends the DEFLATE stream.
+func (z *ParallelWriter) startBlock(last bool) error {
+	for len(z.pending) >= z.blocks {
+		if err := z.writeBlock(); err != nil {
+			return err
+		}
+	}
+
+	b := &parallelBlock{
+		done: make(chan struct{}),
+		size: len(z.cur),
+	}
+	z.pending = append(z.pending, b)
+	data := z.cur
+	dict := append([]byte(nil), z.dict...)
+	go b.compress(data, dict, z.level, last)
+
+	// Save the end of this block as the dictionary for the next one.
+	if len(data) >= windowSize {
+		z.dict = append(z.dict[:0], data[len(data)-windowSize:]...)
+	} else {
+		z.dict = append(z.dict, data...)
+		if len(z.dict) > windowSize {
+			z.dict = z.dict[len(z.dict)-windowSize:]
+		}
+	}
+	// The goroutine owns data now.
+	z.cur = nil
+	return nil
+}
+
+// compress compresses data into b.out using dict as the preset
+// dictionary and closes b.done. Unless last is set, it ends
+// with a sync flush rather than a final block.
+func (b *parallelBlock) compress(data, dict []byte, level int, last bool) {
+	defer close(b.done)
+	b.digest = crc32.ChecksumIEEE(data)
+	fw, err := flate.NewWriterDict(&b.out, level, dict)
+	if err != nil {
+		b.err = err
+		return
+	}
+	if _, err := fw.Write(data); err != nil {
+		b.err = err
+		return
+	}
+	if last {
+		b.err = fw.Close()
+	} else {
+		b.err = fw.Flush()
+	}
+}
+
+// writeBlock waits for the oldest pending block to be compressed
+// and writes it to the underlying writer.
+func (z *ParallelWriter) writeBlock() error {
+	b := z.pending[0]
+	<-b.done
+	copy(z.pending, z.pending[1:])
+	z.pending[len(z.pending)-1] = nil
+	z.pending = z.pending[:len(z.pending)-1]
+	if b.err != nil {
+		return b.err
+	}
+	if _, err := z.w.Write(b.out.Bytes()); err != nil {
+		return err
+	}
+	z.digest = crc32Combine(z.digest, b.digest, int64(b.size))
+	z.size += uint32(b.size)
+	return nil
+}
+
+// writeAll writes all the pending blocks to the underlying writer.
+func (z *ParallelWriter) writeAll() error {
+	for len(z.pending) > 0 {
+		if err := z.writeBlock(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush compresses any buffered data and writes all the compressed
+// data to the underlying writer. Flush does not return until the data
+// has been written. If the underlying writer returns an error, Flush
+// returns that error.
+//
+// Flushing ends the current block early, which reduces the
+// concurrency and compression ratio of the following data.
+func (z *ParallelWriter) Flush() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed {
+		return nil
+	}
+	if !z.wroteHeader {
+		z.Write(nil)
+		if z.err != nil {
+			return z.err
+		}
+	}
+	if len(z.cur) > 0 {
+		if z.err = z.startBlock(false); z.err != nil {
+			return z.err
+		}
+	}
+	z.err = z.writeAll()
+	return z.err
+}
+
+// Close closes the [ParallelWriter] by compressing and writing any
+// unwritten data to the underlying [io.Writer] and writing the GZIP
+// footer. It does not close the underlying [io.Writer].
+func (z *ParallelWriter) Close() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed {
+		return nil
+	}
+	if !z.wroteHeader {
+		z.Write(nil)
+		if z.err != nil {
+			return z.err
+		}
+	}
+	z.closed = true
+	if z.err = z.startBlock(true); z.err != nil {
+		return z.err
+	}
+	if z.err = z.writeAll(); z.err != nil {
+		return z.err
+	}
+	var buf [8]byte
+	le.PutUint32(buf[:4], z.digest)
+	le.PutUint32(buf[4:], z.size)
+	_, z.err = z.w.Write(buf[:])
+	return z.err
+}
+
+// crc32Combine returns the CRC-32 (IEEE) of the concatenation of
+// two inputs, given their CRCs and the length of the second input.
+// It uses the method of zlib's crc32_combine, which applies the
+// effect of len2 zero bytes to crc1 using powers of a matrix
+// over GF(2).
+func crc32Combine(crc1, crc2 uint32, len2 int64) uint32 {
+	if len2 <= 0 {
+		return crc1 ^ crc2
+	}
+
+	var even, odd [32]uint32 // operators for 2^n zero bits
+
+	// Operator for one zero bit.
+	odd[0] = crc32.IEEE
+	row := uint32(1)
+	for n := 1; n < 32; n++ {
+		odd[n] = row
+		row <<= 1
+	}
+	gf2MatrixSquare(&even, &odd) // two zero bits
+	gf2MatrixSquare(&odd, &even) // four zero bits
+
+	// Apply len2 zero bytes to crc1.
+	// The first square puts the operator for one zero byte in even.
+	for {
+		gf2MatrixSquare(&even, &odd)
+		if len2&1 != 0 {
+			crc1 = gf2MatrixTimes(&even, crc1)
+		}
+		len2 >>= 1
+		if len2 == 0 {
+			break
+		}
+		gf2MatrixSquare(&odd, &even)
+		if len2&1 != 0 {
+			crc1 = gf2MatrixTimes(&odd, crc1)
+		}
+		len2 >>= 1
+		if len2 == 0 {
+			break
+		}
+	}
+	return crc1 ^ crc2
+}
+
+func gf2MatrixTimes(mat *[32]uint32, vec uint32) uint32 {
+	var sum uint32
+	for i := 0; vec != 0; i++ {
+		if vec&1 != 0 {
+			sum ^= mat[i]
+		}
+		vec >>= 1
+	}
+	return sum
+}
+
+func gf2MatrixSquare(square, mat *[32]uint32) {
+	for n := range mat {
+		square[n] = gf2MatrixTimes(mat, mat[n])
+	}
+}
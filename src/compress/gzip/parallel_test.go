@@ -0,0 +1,199 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gzip
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// parallelTestData returns n bytes of compressible data.
+func parallelTestData(n int) []byte {
+	rnd := rand.New(rand.NewSource(1))
+	var buf bytes.Buffer
+	for buf.Len() < n {
+		fmt.Fprintf(&buf, "line %d: value=%d\n", buf.Len(), rnd.Intn(1000))
+	}
+	return buf.Bytes()[:n]
+}
+
+func TestCRC32Combine(t *testing.T) {
+	data := parallelTestData(100000)
+	for _, split := range []int{0, 1, 7, 1000, 65536, len(data) - 1, len(data)} {
+		a, b := data[:split], data[split:]
+		got := crc32Combine(crc32.ChecksumIEEE(a), crc32.ChecksumIEEE(b), int64(len(b)))
+		if want := crc32.ChecksumIEEE(data); got != want {
+			t.Errorf("split at %d: crc32Combine = %#x, want %#x", split, got, want)
+		}
+	}
+}
+
+func TestParallelWriter(t *testing.T) {
+	data := parallelTestData(1 << 20)
+	tests := []struct {
+		size      int
+		blockSize int
+		blocks    int
+		level     int
+	}{
+		{0, 64 << 10, 4, DefaultCompression},
+		{1, 64 << 10, 4, DefaultCompression},
+		{64 << 10, 64 << 10, 4, DefaultCompression},
+		{64<<10 + 1, 64 << 10, 1, BestSpeed},
+		{1 << 20, 64 << 10, 3, BestCompression},
+		{1 << 20, 100000, 8, HuffmanOnly},
+		{1 << 20, 1 << 20, 2, NoCompression},
+	}
+	for _, test := range tests {
+		name := fmt.Sprintf("size=%d/block=%d/blocks=%d/level=%d", test.size, test.blockSize, test.blocks, test.level)
+		t.Run(name, func(t *testing.T) {
+			in := data[:test.size]
+			var buf bytes.Buffer
+			z, err := NewParallelWriter(&buf, test.level)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := z.SetConcurrency(test.blockSize, test.blocks); err != nil {
+				t.Fatal(err)
+			}
+			z.Name = "data.txt"
+			z.ModTime = time.Unix(1e8, 0)
+			// Write in odd-sized pieces to exercise block boundaries.
+			for p := in; len(p) > 0; {
+				n := min(len(p), 12345)
+				if _, err := z.Write(p[:n]); err != nil {
+					t.Fatal(err)
+				}
+				p = p[n:]
+			}
+			if err := z.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := NewReader(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Multistream(false)
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, in) {
+				t.Fatalf("round trip mismatch: got %d bytes, want %d", len(got), len(in))
+			}
+			if r.Name != "data.txt" || !r.ModTime.Equal(time.Unix(1e8, 0)) {
+				t.Errorf("header = %q, %v", r.Name, r.ModTime)
+			}
+			if buf.Len() != 0 {
+				t.Errorf("%d bytes after the member", buf.Len())
+			}
+		})
+	}
+}
+
+func TestParallelWriterRatio(t *testing.T) {
+	data := parallelTestData(1 << 20)
+	var serial, parallel bytes.Buffer
+	w := NewWriter(&serial)
+	w.Write(data)
+	w.Close()
+	z, _ := NewParallelWriter(&parallel, DefaultCompression)
+	z.SetConcurrency(128<<10, 4)
+	z.Write(data)
+	z.Close()
+	if parallel.Len() > serial.Len()*11/10 {
+		t.Errorf("parallel output is %d bytes, serial is %d", parallel.Len(), serial.Len())
+	}
+}
+
+func TestParallelWriterFlush(t *testing.T) {
+	var buf bytes.Buffer
+	z, _ := NewParallelWriter(&buf, DefaultCompression)
+	if _, err := z.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, 5)
+	if _, err := io.ReadFull(r, got); err != nil || string(got) != "hello" {
+		t.Fatalf("after Flush read %q, %v; want %q", got, err, "hello")
+	}
+
+	z.Write([]byte(", world"))
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err = NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := io.ReadAll(r)
+	if err != nil || string(all) != "hello, world" {
+		t.Errorf("read %q, %v; want %q", all, err, "hello, world")
+	}
+}
+
+func TestParallelWriterReset(t *testing.T) {
+	data := parallelTestData(300 << 10)
+	var buf1, buf2 bytes.Buffer
+	z, _ := NewParallelWriter(&buf1, BestSpeed)
+	z.SetConcurrency(64<<10, 2)
+	z.Write(data)
+	z.Close()
+	z.Reset(&buf2)
+	z.Write(data)
+	z.Close()
+	if !bytes.Equal(buf1.Bytes(), buf2.Bytes()) {
+		t.Error("output after Reset differs")
+	}
+}
+
+func TestParallelWriterErrors(t *testing.T) {
+	if _, err := NewParallelWriter(io.Discard, 10); err == nil {
+		t.Error("NewParallelWriter with invalid level succeeded")
+	}
+	z, _ := NewParallelWriter(io.Discard, DefaultCompression)
+	if err := z.SetConcurrency(1000, 1); err == nil {
+		t.Error("SetConcurrency with small block succeeded")
+	}
+	if err := z.SetConcurrency(1<<20, 0); err == nil {
+		t.Error("SetConcurrency with no blocks succeeded")
+	}
+
+	// A write error must be reported and remain sticky.
+	z.Reset(&limitedWriter{N: 100})
+	z.SetConcurrency(64<<10, 2)
+	data := parallelTestData(1 << 20)
+	if _, err := z.Write(data); err == nil {
+		if err := z.Close(); err == nil {
+			t.Fatal("writing past the limit succeeded")
+		}
+	}
+	if err := z.Close(); err == nil {
+		t.Error("Close after error succeeded")
+	}
+}
+
+func BenchmarkParallelWriter(b *testing.B) {
+	data := parallelTestData(8 << 20)
+	z, _ := NewParallelWriter(io.Discard, DefaultCompression)
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		z.Reset(io.Discard)
+		z.Write(data)
+		z.Close()
+	}
+}
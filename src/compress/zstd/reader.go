@@ -0,0 +1,96 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package zstd implements reading and writing of zstd format compressed data,
+// as specified in RFC 8878.
+//
+// A zstd stream is a sequence of one or more frames.
+// A [Writer] writes a single frame, and a [Reader] reads
+// all the frames in a stream as one sequence of bytes.
+//
+// Both Reader and Writer support dictionaries, which improve the
+// compression of small inputs that share content with the dictionary.
+// A dictionary may be either a formatted dictionary as produced by
+// the zstd command-line tool's training mode, or raw content.
+package zstd
+
+import (
+	"errors"
+	"internal/zstd"
+	"io"
+)
+
+// ErrChecksum is returned when reading zstd data that has an invalid checksum.
+var ErrChecksum = zstd.ErrChecksum
+
+// A Reader is an [io.Reader] that can be read to retrieve
+// uncompressed data from a zstd compressed stream.
+//
+// Concatenated frames, including skippable frames,
+// are read as a single stream.
+// Each frame's checksum, if present, is verified
+// as the end of the frame is reached.
+type Reader struct {
+	r *zstd.Reader
+}
+
+// NewReader creates a new [Reader] reading the given reader.
+// If r does not also implement [io.ByteReader],
+// the decompressor may read more data than necessary from r.
+//
+// It is the caller's responsibility to call Close on the Reader when done.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: zstd.NewReader(r)}
+}
+
+// NewReaderDict is like [NewReader] but uses a dictionary.
+// The dictionary is used for every frame in the stream.
+// A frame that requires a different dictionary causes
+// Read to return an error.
+//
+// The dictionary must not be modified while the Reader is in use.
+func NewReaderDict(r io.Reader, dict []byte) (*Reader, error) {
+	d, err := parseDict(dict)
+	if err != nil {
+		return nil, err
+	}
+	z := NewReader(r)
+	z.r.SetDict(d)
+	return z, nil
+}
+
+// parseDict parses a dictionary for a Reader or Writer.
+func parseDict(dict []byte) (*zstd.Dict, error) {
+	d, err := zstd.ParseDict(dict)
+	if err != nil {
+		return nil, errors.New("zstd: invalid dictionary: " + err.Error())
+	}
+	return d, nil
+}
+
+// Reset discards the [Reader] z's state and makes it equivalent to the
+// result of its original state from [NewReader] or [NewReaderDict],
+// but reading from r instead.
+// This permits reusing a Reader rather than allocating a new one.
+func (z *Reader) Reset(r io.Reader) {
+	z.r.Reset(r)
+}
+
+// Read implements [io.Reader], reading uncompressed bytes from its
+// underlying reader.
+func (z *Reader) Read(p []byte) (int, error) {
+	return z.r.Read(p)
+}
+
+// ReadByte implements [io.ByteReader].
+func (z *Reader) ReadByte() (byte, error) {
+	return z.r.ReadByte()
+}
+
+// Close closes the [Reader]. It does not close the underlying reader.
+// In order for the checksum of the last frame to be verified,
+// the reader must be fully consumed until [io.EOF].
+func (z *Reader) Close() error {
+	return nil
+}
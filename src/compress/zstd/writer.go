@@ -0,0 +1,108 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"fmt"
+	"internal/zstd"
+	"io"
+)
+
+// These constants follow those of compress/flate,
+// and do not correspond to the levels of the zstd command-line tool.
+const (
+	NoCompression      = 0
+	BestSpeed          = 1
+	BestCompression    = 9 // zstd.MaxLevel
+	DefaultCompression = -1
+
+	defaultLevel = 5
+)
+
+// A Writer is an [io.WriteCloser].
+// Writes to a Writer are compressed and written to w.
+//
+// All the data written to a Writer between calls to [Writer.Reset]
+// is written as a single frame, followed by a checksum of the
+// uncompressed data.
+type Writer struct {
+	w *zstd.Writer
+}
+
+// NewWriter returns a new [Writer].
+// Writes to the returned writer are compressed and written to w.
+//
+// It is the caller's responsibility to call Close on the [Writer] when done.
+// Writes may be buffered and not flushed until Close.
+func NewWriter(w io.Writer) *Writer {
+	z, _ := NewWriterLevelDict(w, DefaultCompression, nil)
+	return z
+}
+
+// NewWriterLevel is like [NewWriter] but specifies the compression level instead
+// of assuming [DefaultCompression].
+//
+// The compression level can be [DefaultCompression], [NoCompression],
+// or any integer value between [BestSpeed] and [BestCompression] inclusive.
+// The error returned will be nil if the level is valid.
+func NewWriterLevel(w io.Writer, level int) (*Writer, error) {
+	return NewWriterLevelDict(w, level, nil)
+}
+
+// NewWriterLevelDict is like [NewWriterLevel] but specifies a dictionary
+// to compress with. The compressed data can only be read by a [Reader]
+// created with [NewReaderDict] and the same dictionary.
+//
+// The dictionary may be nil. If not, its contents should not be modified
+// until the Writer is closed. The dictionary is ignored at [NoCompression].
+func NewWriterLevelDict(w io.Writer, level int, dict []byte) (*Writer, error) {
+	if level == DefaultCompression {
+		level = defaultLevel
+	}
+	if level < NoCompression || level > BestCompression {
+		return nil, fmt.Errorf("zstd: invalid compression level: %d", level)
+	}
+	z := &Writer{w: zstd.NewWriter(w, level)}
+	if dict != nil {
+		d, err := parseDict(dict)
+		if err != nil {
+			return nil, err
+		}
+		z.w.SetDict(d)
+	}
+	return z, nil
+}
+
+// Reset discards the [Writer] z's state and makes it equivalent to the
+// result of its original state from [NewWriter], [NewWriterLevel], or
+// [NewWriterLevelDict], but writing to w instead.
+// This permits reusing a [Writer] rather than allocating a new one.
+func (z *Writer) Reset(w io.Writer) {
+	z.w.Reset(w)
+}
+
+// Write writes a compressed form of p to the underlying [io.Writer].
+// The compressed bytes are not necessarily flushed until
+// the [Writer] is closed.
+func (z *Writer) Write(p []byte) (int, error) {
+	return z.w.Write(p)
+}
+
+// Flush flushes any pending compressed data to the underlying writer.
+//
+// It is useful mainly in compressed network protocols, to ensure that
+// a remote reader has enough data to reconstruct a packet. Flush does
+// not return until the data has been written. If the underlying
+// writer returns an error, Flush returns that error.
+func (z *Writer) Flush() error {
+	return z.w.Flush()
+}
+
+// Close closes the [Writer] by flushing any unwritten data to the underlying
+// [io.Writer] and completing the frame, but does not close the underlying
+// io.Writer.
+func (z *Writer) Close() error {
+	return z.w.Close()
+}
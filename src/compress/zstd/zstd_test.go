@@ -0,0 +1,134 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func compress(t *testing.T, data []byte, level int, dict []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriterLevelDict(&buf, level, dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat("Hello, Gophers! Compress me, please. ", 1000))
+	for _, level := range []int{DefaultCompression, NoCompression, BestSpeed, 3, BestCompression} {
+		t.Run(fmt.Sprint(level), func(t *testing.T) {
+			compressed := compress(t, data, level, nil)
+			if level != NoCompression && len(compressed) >= len(data)/10 {
+				t.Errorf("compressed %d bytes to %d", len(data), len(compressed))
+			}
+			r := NewReader(bytes.NewReader(compressed))
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Error("round trip mismatch")
+			}
+			if err := r.Close(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestInvalidLevel(t *testing.T) {
+	for _, level := range []int{-2, BestCompression + 1} {
+		if _, err := NewWriterLevel(io.Discard, level); err == nil {
+			t.Errorf("NewWriterLevel(%d) succeeded", level)
+		}
+	}
+}
+
+func TestDict(t *testing.T) {
+	dict := []byte(`{"name": "", "email": "@example.com", "active": true}`)
+	data := []byte(`{"name": "gopher", "email": "gopher@example.com", "active": true}`)
+
+	plain := compress(t, data, DefaultCompression, nil)
+	compressed := compress(t, data, DefaultCompression, dict)
+	if len(compressed) >= len(plain) {
+		t.Errorf("dictionary did not help: %d >= %d", len(compressed), len(plain))
+	}
+
+	r, err := NewReaderDict(bytes.NewReader(compressed), dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("got %q, want %q", got, data)
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	compressed := compress(t, []byte("hello, world\n"), DefaultCompression, nil)
+	compressed[len(compressed)-1] ^= 1
+	_, err := io.ReadAll(NewReader(bytes.NewReader(compressed)))
+	if !errors.Is(err, ErrChecksum) {
+		t.Errorf("got error %v, want %v", err, ErrChecksum)
+	}
+}
+
+func TestReset(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	r := NewReader(&buf)
+	for i := 0; i < 3; i++ {
+		buf.Reset()
+		w.Reset(&buf)
+		msg := fmt.Sprintf("message %d", i)
+		io.WriteString(w, msg)
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		r.Reset(&buf)
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != msg {
+			t.Errorf("got %q, want %q", got, msg)
+		}
+	}
+}
+
+func TestConcatenatedFrames(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	io.WriteString(w, "first frame, ")
+	w.Close()
+	w.Reset(&buf)
+	io.WriteString(w, "second frame")
+	w.Close()
+
+	got, err := io.ReadAll(NewReader(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "first frame, second frame"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
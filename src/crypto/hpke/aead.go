@@ -0,0 +1,88 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hpke
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"strconv"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// An AEAD identifies an Authenticated Encryption with Associated Data
+// algorithm, as registered in the IANA "HPKE AEAD Identifiers" registry.
+type AEAD uint16
+
+// The AEADs of RFC 9180, Section 7.3.
+const (
+	AES128GCM        AEAD = 0x0001
+	AES256GCM        AEAD = 0x0002
+	ChaCha20Poly1305 AEAD = 0x0003
+
+	// ExportOnly is the identifier of contexts that are only used with
+	// the secret export interface. Their Seal and Open methods return an
+	// error.
+	ExportOnly AEAD = 0xffff
+)
+
+// String returns the name of the AEAD, as in RFC 9180.
+func (a AEAD) String() string {
+	switch a {
+	case AES128GCM:
+		return "AES-128-GCM"
+	case AES256GCM:
+		return "AES-256-GCM"
+	case ChaCha20Poly1305:
+		return "ChaCha20Poly1305"
+	case ExportOnly:
+		return "Export-only"
+	}
+	return "unknown AEAD " + strconv.Itoa(int(a))
+}
+
+// Available reports whether a is implemented by this package.
+func (a AEAD) Available() bool {
+	switch a {
+	case AES128GCM, AES256GCM, ChaCha20Poly1305, ExportOnly:
+		return true
+	}
+	return false
+}
+
+// keySize returns Nk, the length in bytes of the AEAD key.
+func (a AEAD) keySize() int {
+	switch a {
+	case AES128GCM:
+		return 16
+	case AES256GCM:
+		return 32
+	case ChaCha20Poly1305:
+		return chacha20poly1305.KeySize
+	}
+	return 0
+}
+
+// nonceSize returns Nn, the length in bytes of the AEAD nonce.
+func (a AEAD) nonceSize() int {
+	if a == ExportOnly {
+		return 0
+	}
+	return 12
+}
+
+func (a AEAD) new(key []byte) (cipher.AEAD, error) {
+	switch a {
+	case AES128GCM, AES256GCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case ChaCha20Poly1305:
+		return chacha20poly1305.New(key)
+	}
+	return nil, nil
+}
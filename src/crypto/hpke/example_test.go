@@ -0,0 +1,49 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hpke_test
+
+import (
+	"crypto/hpke"
+	"crypto/rand"
+	"fmt"
+)
+
+func Example() {
+	suite := hpke.Suite{
+		KEM:  hpke.DHKEMX25519,
+		KDF:  hpke.HKDFSHA256,
+		AEAD: hpke.ChaCha20Poly1305,
+	}
+
+	// The recipient generates a key pair and publishes the public key.
+	recipientKey, err := suite.KEM.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	// The sender encrypts a message to the public key, and transmits the
+	// encapsulated key along with the ciphertext.
+	info := []byte("example application v1")
+	enc, sender, err := suite.NewSender(recipientKey.PublicKey(), info)
+	if err != nil {
+		panic(err)
+	}
+	ciphertext, err := sender.Seal(nil, []byte("hello, world"))
+	if err != nil {
+		panic(err)
+	}
+
+	// The recipient sets up the matching context and decrypts the message.
+	recipient, err := suite.NewRecipient(enc, recipientKey, info)
+	if err != nil {
+		panic(err)
+	}
+	plaintext, err := recipient.Open(nil, ciphertext)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%s\n", plaintext)
+	// Output: hello, world
+}
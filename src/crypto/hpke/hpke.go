@@ -0,0 +1,304 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package hpke implements Hybrid Public Key Encryption (HPKE) as defined in
+// RFC 9180.
+//
+// All four modes are supported: base, PSK, auth and auth-PSK. The supported
+// KEMs are DHKEM over X25519, P-256, P-384 and P-521; the supported KDFs are
+// HKDF with SHA-256, SHA-384 and SHA-512; and the supported AEADs are
+// AES-128-GCM, AES-256-GCM and ChaCha20-Poly1305, as well as the export-only
+// mode.
+//
+// A sender sets up a [Sender] with one of the Suite.NewSender methods, and
+// transmits the returned encapsulated key to the recipient, which sets up
+// the matching [Recipient] with the corresponding Suite.NewRecipient method.
+package hpke
+
+import (
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/subtle"
+	"errors"
+	"internal/byteorder"
+	"math"
+)
+
+// A Suite is a combination of KEM, KDF and AEAD algorithms.
+type Suite struct {
+	KEM  KEM
+	KDF  KDF
+	AEAD AEAD
+}
+
+// The modes of RFC 9180, Section 5.
+const (
+	modeBase    byte = 0x00
+	modePSK     byte = 0x01
+	modeAuth    byte = 0x02
+	modeAuthPSK byte = 0x03
+)
+
+func (s Suite) check() error {
+	if !s.KEM.Available() {
+		return errors.New("hpke: unsupported KEM")
+	}
+	if !s.KDF.Available() {
+		return errors.New("hpke: unsupported KDF")
+	}
+	if !s.AEAD.Available() {
+		return errors.New("hpke: unsupported AEAD")
+	}
+	return nil
+}
+
+func (s Suite) id() []byte {
+	id := make([]byte, 0, 4+2+2+2)
+	id = append(id, "HPKE"...)
+	id = byteorder.BeAppendUint16(id, uint16(s.KEM))
+	id = byteorder.BeAppendUint16(id, uint16(s.KDF))
+	id = byteorder.BeAppendUint16(id, uint16(s.AEAD))
+	return id
+}
+
+// context is the state shared by Sender and Recipient.
+type context struct {
+	suite          Suite
+	suiteID        []byte
+	aead           cipher.AEAD // nil for ExportOnly
+	baseNonce      []byte
+	exporterSecret []byte
+	seq            uint64
+}
+
+// A Sender is an HPKE context used to encrypt messages to a recipient.
+type Sender struct {
+	context
+}
+
+// A Recipient is an HPKE context used to decrypt messages from a sender.
+type Recipient struct {
+	context
+}
+
+// checkPSK implements VerifyPSKInputs of RFC 9180, Section 5.1.
+func checkPSK(mode byte, psk, pskID []byte) error {
+	if (len(psk) == 0) != (len(pskID) == 0) {
+		return errors.New("hpke: PSK and PSK ID must be both set or both empty")
+	}
+	switch mode {
+	case modePSK, modeAuthPSK:
+		if len(psk) == 0 {
+			return errors.New("hpke: missing PSK")
+		}
+		if len(psk) < 32 {
+			return errors.New("hpke: PSK must be at least 32 bytes")
+		}
+	}
+	return nil
+}
+
+// newContext implements KeySchedule of RFC 9180, Section 5.1.
+func (s Suite) newContext(mode byte, sharedSecret, info, psk, pskID []byte) (context, error) {
+	sid := s.id()
+	kdf := s.KDF
+
+	pskIDHash := kdf.labeledExtract(sid, nil, "psk_id_hash", pskID)
+	infoHash := kdf.labeledExtract(sid, nil, "info_hash", info)
+	ksContext := make([]byte, 0, 1+len(pskIDHash)+len(infoHash))
+	ksContext = append(ksContext, mode)
+	ksContext = append(ksContext, pskIDHash...)
+	ksContext = append(ksContext, infoHash...)
+
+	secret := kdf.labeledExtract(sid, sharedSecret, "secret", psk)
+
+	c := context{suite: s, suiteID: sid}
+	if s.AEAD != ExportOnly {
+		key := kdf.labeledExpand(sid, secret, "key", ksContext, uint16(s.AEAD.keySize()))
+		aead, err := s.AEAD.new(key)
+		if err != nil {
+			return context{}, err
+		}
+		c.aead = aead
+		c.baseNonce = kdf.labeledExpand(sid, secret, "base_nonce", ksContext, uint16(s.AEAD.nonceSize()))
+	}
+	c.exporterSecret = kdf.labeledExpand(sid, secret, "exp", ksContext, uint16(kdf.hash().Size()))
+	return c, nil
+}
+
+func (s Suite) newSender(mode byte, pub *ecdh.PublicKey, priv *ecdh.PrivateKey, info, psk, pskID []byte) ([]byte, *Sender, error) {
+	if err := s.check(); err != nil {
+		return nil, nil, err
+	}
+	if err := s.KEM.checkPublicKey(pub); err != nil {
+		return nil, nil, err
+	}
+	if mode == modeAuth || mode == modeAuthPSK {
+		if err := s.KEM.checkPrivateKey(priv); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := checkPSK(mode, psk, pskID); err != nil {
+		return nil, nil, err
+	}
+	sharedSecret, enc, err := s.KEM.encap(pub, priv)
+	if err != nil {
+		return nil, nil, err
+	}
+	c, err := s.newContext(mode, sharedSecret, info, psk, pskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return enc, &Sender{c}, nil
+}
+
+func (s Suite) newRecipient(mode byte, enc []byte, priv *ecdh.PrivateKey, pub *ecdh.PublicKey, info, psk, pskID []byte) (*Recipient, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	if err := s.KEM.checkPrivateKey(priv); err != nil {
+		return nil, err
+	}
+	if mode == modeAuth || mode == modeAuthPSK {
+		if err := s.KEM.checkPublicKey(pub); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkPSK(mode, psk, pskID); err != nil {
+		return nil, err
+	}
+	sharedSecret, err := s.KEM.decap(enc, priv, pub)
+	if err != nil {
+		return nil, err
+	}
+	c, err := s.newContext(mode, sharedSecret, info, psk, pskID)
+	if err != nil {
+		return nil, err
+	}
+	return &Recipient{c}, nil
+}
+
+// NewSender sets up a base mode context for encrypting messages to the holder
+// of the private key for pub, and returns it along with the encapsulated key
+// to transmit to the recipient.
+//
+// info is application-supplied information that binds the context, and must
+// match on the recipient side.
+func (s Suite) NewSender(pub *ecdh.PublicKey, info []byte) (enc []byte, sender *Sender, err error) {
+	return s.newSender(modeBase, pub, nil, info, nil, nil)
+}
+
+// NewSenderPSK is like [Suite.NewSender], but sets up a PSK mode context
+// that additionally authenticates the sender as a holder of the pre-shared key
+// psk, which is identified by pskID. psk must be at least 32 bytes long.
+func (s Suite) NewSenderPSK(pub *ecdh.PublicKey, info, psk, pskID []byte) (enc []byte, sender *Sender, err error) {
+	return s.newSender(modePSK, pub, nil, info, psk, pskID)
+}
+
+// NewSenderAuth is like [Suite.NewSender], but sets up an auth mode context
+// that additionally authenticates the sender as the holder of priv.
+func (s Suite) NewSenderAuth(pub *ecdh.PublicKey, priv *ecdh.PrivateKey, info []byte) (enc []byte, sender *Sender, err error) {
+	return s.newSender(modeAuth, pub, priv, info, nil, nil)
+}
+
+// NewSenderAuthPSK combines [Suite.NewSenderAuth] and [Suite.NewSenderPSK].
+func (s Suite) NewSenderAuthPSK(pub *ecdh.PublicKey, priv *ecdh.PrivateKey, info, psk, pskID []byte) (enc []byte, sender *Sender, err error) {
+	return s.newSender(modeAuthPSK, pub, priv, info, psk, pskID)
+}
+
+// NewRecipient sets up a base mode context for decrypting messages sent to
+// the holder of priv, given the encapsulated key enc produced by
+// [Suite.NewSender].
+func (s Suite) NewRecipient(enc []byte, priv *ecdh.PrivateKey, info []byte) (*Recipient, error) {
+	return s.newRecipient(modeBase, enc, priv, nil, info, nil, nil)
+}
+
+// NewRecipientPSK is the recipient side of [Suite.NewSenderPSK].
+func (s Suite) NewRecipientPSK(enc []byte, priv *ecdh.PrivateKey, info, psk, pskID []byte) (*Recipient, error) {
+	return s.newRecipient(modePSK, enc, priv, nil, info, psk, pskID)
+}
+
+// NewRecipientAuth is the recipient side of [Suite.NewSenderAuth]. pub is the
+// public key of the expected sender.
+func (s Suite) NewRecipientAuth(enc []byte, priv *ecdh.PrivateKey, pub *ecdh.PublicKey, info []byte) (*Recipient, error) {
+	return s.newRecipient(modeAuth, enc, priv, pub, info, nil, nil)
+}
+
+// NewRecipientAuthPSK is the recipient side of [Suite.NewSenderAuthPSK].
+func (s Suite) NewRecipientAuthPSK(enc []byte, priv *ecdh.PrivateKey, pub *ecdh.PublicKey, info, psk, pskID []byte) (*Recipient, error) {
+	return s.newRecipient(modeAuthPSK, enc, priv, pub, info, psk, pskID)
+}
+
+// nextNonce implements ComputeNonce of RFC 9180, Section 5.2.
+func (c *context) nextNonce() ([]byte, error) {
+	if c.aead == nil {
+		return nil, errors.New("hpke: Seal and Open are not available in export-only mode")
+	}
+	if c.seq == math.MaxUint64 {
+		return nil, errors.New("hpke: message limit reached")
+	}
+	nonce := make([]byte, len(c.baseNonce))
+	byteorder.BePutUint64(nonce[len(nonce)-8:], c.seq)
+	subtle.XORBytes(nonce, nonce, c.baseNonce)
+	return nonce, nil
+}
+
+// Seal encrypts and authenticates plaintext, authenticates aad, and returns
+// the resulting ciphertext. Each call uses the next nonce in the sequence, so
+// messages must be opened in the same order they were sealed.
+func (s *Sender) Seal(aad, plaintext []byte) ([]byte, error) {
+	nonce, err := s.nextNonce()
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := s.aead.Seal(nil, nonce, plaintext, aad)
+	s.seq++
+	return ciphertext, nil
+}
+
+// Open authenticates and decrypts ciphertext, authenticates aad, and returns
+// the resulting plaintext. A failed Open does not advance the nonce sequence.
+func (r *Recipient) Open(aad, ciphertext []byte) ([]byte, error) {
+	nonce, err := r.nextNonce()
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := r.aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, err
+	}
+	r.seq++
+	return plaintext, nil
+}
+
+func (c *context) export(exporterContext []byte, length int) ([]byte, error) {
+	if length < 0 || length > 255*c.suite.KDF.hash().Size() {
+		return nil, errors.New("hpke: invalid export length")
+	}
+	return c.suite.KDF.labeledExpand(c.suiteID, c.exporterSecret, "sec", exporterContext, uint16(length)), nil
+}
+
+// Export derives a secret of the given length from the context, bound to
+// exporterContext, as specified in RFC 9180, Section 5.3. length must be at
+// most 255 times the output size of the KDF hash.
+func (s *Sender) Export(exporterContext []byte, length int) ([]byte, error) {
+	return s.export(exporterContext, length)
+}
+
+// Export derives a secret of the given length from the context, bound to
+// exporterContext. It returns the same value as [Sender.Export] on the
+// matching sender context.
+func (r *Recipient) Export(exporterContext []byte, length int) ([]byte, error) {
+	return r.export(exporterContext, length)
+}
+
+// Suite returns the suite the context was set up with.
+func (s *Sender) Suite() Suite {
+	return s.suite
+}
+
+// Suite returns the suite the context was set up with.
+func (r *Recipient) Suite() Suite {
+	return r.suite
+}
@@ -0,0 +1,414 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hpke
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"golang.org/x/crypto/sha3"
+)
+
+func mustDecodeHex(t *testing.T, in string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func mustDeriveKeyPair(t *testing.T, kem KEM, ikm []byte) *ecdh.PrivateKey {
+	t.Helper()
+	priv, err := kem.DeriveKeyPair(ikm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv
+}
+
+func setEphemeralKey(t *testing.T, priv *ecdh.PrivateKey) {
+	testingOnlyGenerateKey = func() (*ecdh.PrivateKey, error) {
+		return priv, nil
+	}
+	t.Cleanup(func() { testingOnlyGenerateKey = nil })
+}
+
+func drawRandomInput(t *testing.T, r io.Reader) []byte {
+	t.Helper()
+	l := make([]byte, 1)
+	if _, err := r.Read(l); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, int(l[0]))
+	if _, err := r.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestRFC9180Vectors(t *testing.T) {
+	vectorsJSON, err := os.ReadFile("testdata/rfc9180.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors []struct {
+		Mode uint16 `json:"mode"`
+		KEM  KEM    `json:"kem_id"`
+		KDF  KDF    `json:"kdf_id"`
+		AEAD AEAD   `json:"aead_id"`
+		Info string `json:"info"`
+		IkmE string `json:"ikmE"`
+		IkmR string `json:"ikmR"`
+		SkRm string `json:"skRm"`
+		PkRm string `json:"pkRm"`
+		Enc  string `json:"enc"`
+
+		// AccEncryptions and AccExports are the first 16 bytes of
+		// SHAKE128 over the outputs of 1000 encryptions and exports of
+		// inputs drawn from an unkeyed SHAKE128 stream, rather than the
+		// individual values listed in the RFC.
+		AccEncryptions string `json:"encryptions_accumulated"`
+		AccExports     string `json:"exports_accumulated"`
+	}
+	if err := json.Unmarshal(vectorsJSON, &vectors); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, vector := range vectors {
+		name := fmt.Sprintf("mode %04x kem %04x kdf %04x aead %04x",
+			vector.Mode, uint16(vector.KEM), uint16(vector.KDF), uint16(vector.AEAD))
+		t.Run(name, func(t *testing.T) {
+			suite := Suite{vector.KEM, vector.KDF, vector.AEAD}
+			pub, err := suite.KEM.Curve().NewPublicKey(mustDecodeHex(t, vector.PkRm))
+			if err != nil {
+				t.Fatal(err)
+			}
+			priv, err := suite.KEM.Curve().NewPrivateKey(mustDecodeHex(t, vector.SkRm))
+			if err != nil {
+				t.Fatal(err)
+			}
+			derived := mustDeriveKeyPair(t, suite.KEM, mustDecodeHex(t, vector.IkmR))
+			if !bytes.Equal(derived.PublicKey().Bytes(), pub.Bytes()) {
+				t.Errorf("derived recipient key does not match pkRm")
+			}
+
+			setEphemeralKey(t, mustDeriveKeyPair(t, suite.KEM, mustDecodeHex(t, vector.IkmE)))
+
+			info := mustDecodeHex(t, vector.Info)
+			enc, sender, err := suite.NewSender(pub, info)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := mustDecodeHex(t, vector.Enc); !bytes.Equal(enc, want) {
+				t.Errorf("unexpected encapsulated key, got: %x, want %x", enc, want)
+			}
+
+			recipient, err := suite.NewRecipient(enc, priv, info)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if suite.AEAD != ExportOnly {
+				source, sink := sha3.NewShake128(), sha3.NewShake128()
+				for range 1000 {
+					aad, plaintext := drawRandomInput(t, source), drawRandomInput(t, source)
+					ciphertext, err := sender.Seal(aad, plaintext)
+					if err != nil {
+						t.Fatal(err)
+					}
+					sink.Write(ciphertext)
+					got, err := recipient.Open(aad, ciphertext)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !bytes.Equal(got, plaintext) {
+						t.Errorf("unexpected plaintext: got %x want %x", got, plaintext)
+					}
+				}
+				encryptions := make([]byte, 16)
+				sink.Read(encryptions)
+				if want := mustDecodeHex(t, vector.AccEncryptions); !bytes.Equal(encryptions, want) {
+					t.Errorf("unexpected accumulated encryptions, got: %x, want %x", encryptions, want)
+				}
+			} else {
+				if _, err := sender.Seal(nil, nil); err == nil {
+					t.Error("Seal succeeded in export-only mode")
+				}
+				if _, err := recipient.Open(nil, nil); err == nil {
+					t.Error("Open succeeded in export-only mode")
+				}
+			}
+
+			source, sink := sha3.NewShake128(), sha3.NewShake128()
+			for l := range 1000 {
+				context := drawRandomInput(t, source)
+				value, err := sender.Export(context, l)
+				if err != nil {
+					t.Fatal(err)
+				}
+				sink.Write(value)
+				got, err := recipient.Export(context, l)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, value) {
+					t.Errorf("recipient and sender exports differ: %x != %x", got, value)
+				}
+			}
+			exports := make([]byte, 16)
+			sink.Read(exports)
+			if want := mustDecodeHex(t, vector.AccExports); !bytes.Equal(exports, want) {
+				t.Errorf("unexpected accumulated exports, got: %x, want %x", exports, want)
+			}
+		})
+	}
+}
+
+// TestRFC9180AuthPSKVectors checks the key schedule of the PSK, auth and
+// auth-PSK modes against the DHKEM(X25519, HKDF-SHA256), HKDF-SHA256,
+// AES-128-GCM vectors of RFC 9180, Appendices A.1.2 to A.1.4.
+func TestRFC9180AuthPSKVectors(t *testing.T) {
+	suite := Suite{DHKEMX25519, HKDFSHA256, AES128GCM}
+	info := mustDecodeHex(t, "4f6465206f6e2061204772656369616e2055726e")
+	psk := mustDecodeHex(t, "0247fd33b913760fa1fa51e1892d9f307fbe65eb171e8132c2af18555a738b82")
+	pskID := mustDecodeHex(t, "456e6e796e20447572696e206172616e204d6f726961")
+
+	tests := []struct {
+		name             string
+		ikmE, ikmR, ikmS string
+		usePSK           bool
+		key, baseNonce   string
+	}{
+		{
+			name:      "PSK",
+			ikmE:      "78628c354e46f3e169bd231be7b2ff1c77aa302460a26dbfa15515684c00130b",
+			ikmR:      "d4a09d09f575fef425905d2ab396c1449141463f698f8efdb7accfaff8995098",
+			usePSK:    true,
+			key:       "15026dba546e3ae05836fc7de5a7bb26",
+			baseNonce: "9518635eba129d5ce0914555",
+		},
+		{
+			name:      "Auth",
+			ikmE:      "6e6d8f200ea2fb20c30b003a8b4f433d2f4ed4c2658d5bc8ce2fef718059c9f7",
+			ikmR:      "f1d4a30a4cef8d6d4e3b016e6fd3799ea057db4f345472ed302a67ce1c20cdec",
+			ikmS:      "94b020ce91d73fca4649006c7e7329a67b40c55e9e93cc907d282bbbff386f58",
+			key:       "b062cb2c4dd4bca0ad7c7a12bbc341e6",
+			baseNonce: "a1bc314c1942ade7051ffed0",
+		},
+		{
+			name:      "AuthPSK",
+			ikmE:      "4303619085a20ebcf18edd22782952b8a7161e1dbae6e46e143a52a96127cf84",
+			ikmR:      "4b16221f3b269a88e207270b5e1de28cb01f847841b344b8314d6a622fe5ee90",
+			ikmS:      "62f77dcf5df0dd7eac54eac9f654f426d4161ec850cc65c54f8b65d2e0b4e345",
+			usePSK:    true,
+			key:       "1364ead92c47aa7becfa95203037b19a",
+			baseNonce: "99d8b5c54669807e9fc70df1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			skR := mustDeriveKeyPair(t, suite.KEM, mustDecodeHex(t, tt.ikmR))
+			setEphemeralKey(t, mustDeriveKeyPair(t, suite.KEM, mustDecodeHex(t, tt.ikmE)))
+
+			var enc []byte
+			var sender *Sender
+			var recipient *Recipient
+			var err error
+			if tt.ikmS == "" {
+				enc, sender, err = suite.NewSenderPSK(skR.PublicKey(), info, psk, pskID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				recipient, err = suite.NewRecipientPSK(enc, skR, info, psk, pskID)
+			} else {
+				skS := mustDeriveKeyPair(t, suite.KEM, mustDecodeHex(t, tt.ikmS))
+				if tt.usePSK {
+					enc, sender, err = suite.NewSenderAuthPSK(skR.PublicKey(), skS, info, psk, pskID)
+					if err != nil {
+						t.Fatal(err)
+					}
+					recipient, err = suite.NewRecipientAuthPSK(enc, skR, skS.PublicKey(), info, psk, pskID)
+				} else {
+					enc, sender, err = suite.NewSenderAuth(skR.PublicKey(), skS, info)
+					if err != nil {
+						t.Fatal(err)
+					}
+					recipient, err = suite.NewRecipientAuth(enc, skR, skS.PublicKey(), info)
+				}
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Seal with the expected key and nonce, and check that the
+			// context produces the same ciphertext.
+			block, err := aes.NewCipher(mustDecodeHex(t, tt.key))
+			if err != nil {
+				t.Fatal(err)
+			}
+			gcm, err := cipher.NewGCM(block)
+			if err != nil {
+				t.Fatal(err)
+			}
+			aad, plaintext := []byte("Count-0"), []byte("Beauty is truth, truth beauty")
+			want := gcm.Seal(nil, mustDecodeHex(t, tt.baseNonce), plaintext, aad)
+			got, err := sender.Seal(aad, plaintext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("unexpected ciphertext, got: %x, want %x", got, want)
+			}
+			if pt, err := recipient.Open(aad, got); err != nil || !bytes.Equal(pt, plaintext) {
+				t.Errorf("Open = %x, %v", pt, err)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	psk := bytes.Repeat([]byte{0x42}, 32)
+	pskID := []byte("psk id")
+	for _, kem := range []KEM{DHKEMP256, DHKEMP384, DHKEMP521, DHKEMX25519} {
+		for _, kdf := range []KDF{HKDFSHA256, HKDFSHA384, HKDFSHA512} {
+			for _, aead := range []AEAD{AES128GCM, AES256GCM, ChaCha20Poly1305} {
+				suite := Suite{kem, kdf, aead}
+				t.Run(fmt.Sprintf("%v/%v/%v", kem, kdf, aead), func(t *testing.T) {
+					skR, err := kem.GenerateKey(rand.Reader)
+					if err != nil {
+						t.Fatal(err)
+					}
+					skS, err := kem.GenerateKey(rand.Reader)
+					if err != nil {
+						t.Fatal(err)
+					}
+					other, err := kem.GenerateKey(rand.Reader)
+					if err != nil {
+						t.Fatal(err)
+					}
+					info := []byte("info")
+
+					enc, sender, err := suite.NewSenderAuthPSK(skR.PublicKey(), skS, info, psk, pskID)
+					if err != nil {
+						t.Fatal(err)
+					}
+					ct, err := sender.Seal([]byte("aad"), []byte("plaintext"))
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					recipient, err := suite.NewRecipientAuthPSK(enc, skR, skS.PublicKey(), info, psk, pskID)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if pt, err := recipient.Open([]byte("aad"), ct); err != nil || string(pt) != "plaintext" {
+						t.Errorf("Open = %q, %v", pt, err)
+					}
+
+					// A different sender key or PSK must produce a
+					// different context.
+					recipient, err = suite.NewRecipientAuthPSK(enc, skR, other.PublicKey(), info, psk, pskID)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if _, err := recipient.Open([]byte("aad"), ct); err == nil {
+						t.Error("Open succeeded with the wrong sender key")
+					}
+					otherPSK := bytes.Repeat([]byte{0x43}, 32)
+					recipient, err = suite.NewRecipientAuthPSK(enc, skR, skS.PublicKey(), info, otherPSK, pskID)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if _, err := recipient.Open([]byte("aad"), ct); err == nil {
+						t.Error("Open succeeded with the wrong PSK")
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestOpenFailure(t *testing.T) {
+	suite := Suite{DHKEMX25519, HKDFSHA256, AES128GCM}
+	priv, err := suite.KEM.Curve().NewPrivateKey(bytes.Repeat([]byte{0x42}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, sender, err := suite.NewSender(priv.PublicKey(), []byte("info"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient, err := suite.NewRecipient(enc, priv, []byte("info"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, err := sender.Seal([]byte("aad"), []byte("plaintext"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recipient.Open([]byte("other aad"), ct); err == nil {
+		t.Error("Open succeeded with the wrong additional data")
+	}
+	// A failed Open must not advance the sequence number.
+	if pt, err := recipient.Open([]byte("aad"), ct); err != nil || string(pt) != "plaintext" {
+		t.Errorf("Open = %q, %v", pt, err)
+	}
+}
+
+func TestInvalidInputs(t *testing.T) {
+	suite := Suite{DHKEMX25519, HKDFSHA256, AES128GCM}
+	priv, err := suite.KEM.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256, err := DHKEMP256.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	psk := bytes.Repeat([]byte{0x42}, 32)
+
+	if _, _, err := (Suite{0x0042, HKDFSHA256, AES128GCM}).NewSender(priv.PublicKey(), nil); err == nil {
+		t.Error("NewSender succeeded with an unsupported KEM")
+	}
+	if _, _, err := (Suite{DHKEMX25519, 0x0042, AES128GCM}).NewSender(priv.PublicKey(), nil); err == nil {
+		t.Error("NewSender succeeded with an unsupported KDF")
+	}
+	if _, err := (Suite{DHKEMX25519, HKDFSHA256, 0x0042}).NewRecipient(nil, priv, nil); err == nil {
+		t.Error("NewRecipient succeeded with an unsupported AEAD")
+	}
+	if _, _, err := suite.NewSender(p256.PublicKey(), nil); err == nil {
+		t.Error("NewSender succeeded with a key for the wrong curve")
+	}
+	if _, _, err := suite.NewSenderAuth(priv.PublicKey(), p256, nil); err == nil {
+		t.Error("NewSenderAuth succeeded with a sender key for the wrong curve")
+	}
+	if _, _, err := suite.NewSenderPSK(priv.PublicKey(), nil, psk, nil); err == nil {
+		t.Error("NewSenderPSK succeeded without a PSK ID")
+	}
+	if _, _, err := suite.NewSenderPSK(priv.PublicKey(), nil, psk[:16], []byte("id")); err == nil {
+		t.Error("NewSenderPSK succeeded with a short PSK")
+	}
+	if _, err := suite.NewRecipient([]byte("short"), priv, nil); err == nil {
+		t.Error("NewRecipient succeeded with an invalid encapsulated key")
+	}
+
+	_, sender, err := suite.NewSender(priv.PublicKey(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sender.Export(nil, 255*32+1); err == nil {
+		t.Error("Export succeeded with an excessive length")
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hpke
+
+import (
+	"crypto"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"internal/byteorder"
+	"strconv"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// A KDF identifies a Key Derivation Function, as registered in the IANA
+// "HPKE KDF Identifiers" registry.
+type KDF uint16
+
+// The HKDF based KDFs of RFC 9180, Section 7.2.
+const (
+	HKDFSHA256 KDF = 0x0001
+	HKDFSHA384 KDF = 0x0002
+	HKDFSHA512 KDF = 0x0003
+)
+
+// String returns the name of the KDF, as in RFC 9180.
+func (k KDF) String() string {
+	switch k {
+	case HKDFSHA256:
+		return "HKDF-SHA256"
+	case HKDFSHA384:
+		return "HKDF-SHA384"
+	case HKDFSHA512:
+		return "HKDF-SHA512"
+	}
+	return "unknown KDF " + strconv.Itoa(int(k))
+}
+
+// Available reports whether k is implemented by this package.
+func (k KDF) Available() bool {
+	return k.hash() != 0
+}
+
+func (k KDF) hash() crypto.Hash {
+	switch k {
+	case HKDFSHA256:
+		return crypto.SHA256
+	case HKDFSHA384:
+		return crypto.SHA384
+	case HKDFSHA512:
+		return crypto.SHA512
+	}
+	return 0
+}
+
+const versionLabel = "HPKE-v1"
+
+// labeledExtract implements LabeledExtract of RFC 9180, Section 4.
+func (k KDF) labeledExtract(suiteID, salt []byte, label string, ikm []byte) []byte {
+	labeledIKM := make([]byte, 0, len(versionLabel)+len(suiteID)+len(label)+len(ikm))
+	labeledIKM = append(labeledIKM, versionLabel...)
+	labeledIKM = append(labeledIKM, suiteID...)
+	labeledIKM = append(labeledIKM, label...)
+	labeledIKM = append(labeledIKM, ikm...)
+	return hkdf.Extract(k.hash().New, labeledIKM, salt)
+}
+
+// labeledExpand implements LabeledExpand of RFC 9180, Section 4. length must
+// be at most 255 times the hash size.
+func (k KDF) labeledExpand(suiteID, prk []byte, label string, info []byte, length uint16) []byte {
+	labeledInfo := make([]byte, 0, 2+len(versionLabel)+len(suiteID)+len(label)+len(info))
+	labeledInfo = byteorder.BeAppendUint16(labeledInfo, length)
+	labeledInfo = append(labeledInfo, versionLabel...)
+	labeledInfo = append(labeledInfo, suiteID...)
+	labeledInfo = append(labeledInfo, label...)
+	labeledInfo = append(labeledInfo, info...)
+	out := make([]byte, length)
+	if _, err := hkdf.Expand(k.hash().New, prk, labeledInfo).Read(out); err != nil {
+		panic("hpke: internal error: HKDF-Expand failed: " + err.Error())
+	}
+	return out
+}
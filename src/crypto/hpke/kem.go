@@ -0,0 +1,195 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hpke
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"errors"
+	"internal/byteorder"
+	"io"
+	"strconv"
+)
+
+// A KEM identifies a Key Encapsulation Mechanism, as registered in the IANA
+// "HPKE KEM Identifiers" registry.
+type KEM uint16
+
+// The Diffie-Hellman based KEMs of RFC 9180, Section 7.1.
+const (
+	DHKEMP256   KEM = 0x0010 // DHKEM(P-256, HKDF-SHA256)
+	DHKEMP384   KEM = 0x0011 // DHKEM(P-384, HKDF-SHA384)
+	DHKEMP521   KEM = 0x0012 // DHKEM(P-521, HKDF-SHA512)
+	DHKEMX25519 KEM = 0x0020 // DHKEM(X25519, HKDF-SHA256)
+)
+
+type kemParams struct {
+	curve   func() ecdh.Curve
+	kdf     KDF
+	nSecret int
+	nSk     int
+	// bitmask is applied to the first byte of the candidate private keys in
+	// DeriveKeyPair. Zero means that the KEM uses X25519 derivation.
+	bitmask byte
+}
+
+var kems = map[KEM]kemParams{
+	DHKEMP256:   {ecdh.P256, HKDFSHA256, 32, 32, 0xff},
+	DHKEMP384:   {ecdh.P384, HKDFSHA384, 48, 48, 0xff},
+	DHKEMP521:   {ecdh.P521, HKDFSHA512, 64, 66, 0x01},
+	DHKEMX25519: {ecdh.X25519, HKDFSHA256, 32, 32, 0},
+}
+
+// String returns the name of the KEM, as in RFC 9180.
+func (k KEM) String() string {
+	switch k {
+	case DHKEMP256:
+		return "DHKEM(P-256, HKDF-SHA256)"
+	case DHKEMP384:
+		return "DHKEM(P-384, HKDF-SHA384)"
+	case DHKEMP521:
+		return "DHKEM(P-521, HKDF-SHA512)"
+	case DHKEMX25519:
+		return "DHKEM(X25519, HKDF-SHA256)"
+	}
+	return "unknown KEM " + strconv.Itoa(int(k))
+}
+
+// Available reports whether k is implemented by this package.
+func (k KEM) Available() bool {
+	_, ok := kems[k]
+	return ok
+}
+
+// Curve returns the curve used by k. It panics if k is not available.
+func (k KEM) Curve() ecdh.Curve {
+	return k.params().curve()
+}
+
+func (k KEM) params() kemParams {
+	p, ok := kems[k]
+	if !ok {
+		panic("hpke: requested KEM " + k.String() + " is unavailable")
+	}
+	return p
+}
+
+// suiteID returns the suite_id used by the KEM's labeled operations.
+func (k KEM) suiteID() []byte {
+	return byteorder.BeAppendUint16([]byte("KEM"), uint16(k))
+}
+
+// GenerateKey generates a random private key for k.
+func (k KEM) GenerateKey(rand io.Reader) (*ecdh.PrivateKey, error) {
+	if !k.Available() {
+		return nil, errors.New("hpke: unsupported KEM")
+	}
+	return k.Curve().GenerateKey(rand)
+}
+
+// DeriveKeyPair deterministically derives a private key for k from the input
+// keying material ikm, which should have at least as much entropy as the
+// private keys of k. See RFC 9180, Section 7.1.3.
+func (k KEM) DeriveKeyPair(ikm []byte) (*ecdh.PrivateKey, error) {
+	if !k.Available() {
+		return nil, errors.New("hpke: unsupported KEM")
+	}
+	p := k.params()
+	sid := k.suiteID()
+	dkpPRK := p.kdf.labeledExtract(sid, nil, "dkp_prk", ikm)
+	if p.bitmask == 0 {
+		sk := p.kdf.labeledExpand(sid, dkpPRK, "sk", nil, uint16(p.nSk))
+		return p.curve().NewPrivateKey(sk)
+	}
+	for counter := 0; counter < 256; counter++ {
+		sk := p.kdf.labeledExpand(sid, dkpPRK, "candidate", []byte{byte(counter)}, uint16(p.nSk))
+		sk[0] &= p.bitmask
+		// NewPrivateKey rejects zero and values not less than the order,
+		// which are the candidates the RFC requires skipping.
+		if priv, err := p.curve().NewPrivateKey(sk); err == nil {
+			return priv, nil
+		}
+	}
+	return nil, errors.New("hpke: DeriveKeyPair failed")
+}
+
+// checkPublicKey and checkPrivateKey return an error if the key is not for
+// the curve of k.
+func (k KEM) checkPublicKey(pub *ecdh.PublicKey) error {
+	if pub == nil || pub.Curve() != k.Curve() {
+		return errors.New("hpke: public key does not match KEM " + k.String())
+	}
+	return nil
+}
+
+func (k KEM) checkPrivateKey(priv *ecdh.PrivateKey) error {
+	if priv == nil || priv.Curve() != k.Curve() {
+		return errors.New("hpke: private key does not match KEM " + k.String())
+	}
+	return nil
+}
+
+// testingOnlyGenerateKey is only used during testing, to provide
+// a fixed ephemeral key when checking the RFC 9180 vectors.
+var testingOnlyGenerateKey func() (*ecdh.PrivateKey, error)
+
+func (k KEM) extractAndExpand(dh, kemContext []byte) []byte {
+	p := k.params()
+	sid := k.suiteID()
+	eaePRK := p.kdf.labeledExtract(sid, nil, "eae_prk", dh)
+	return p.kdf.labeledExpand(sid, eaePRK, "shared_secret", kemContext, uint16(p.nSecret))
+}
+
+// encap implements Encap and, if skS is not nil, AuthEncap of RFC 9180,
+// Section 4.1.
+func (k KEM) encap(pkR *ecdh.PublicKey, skS *ecdh.PrivateKey) (sharedSecret, enc []byte, err error) {
+	var skE *ecdh.PrivateKey
+	if testingOnlyGenerateKey != nil {
+		skE, err = testingOnlyGenerateKey()
+	} else {
+		skE, err = k.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	dh, err := skE.ECDH(pkR)
+	if err != nil {
+		return nil, nil, err
+	}
+	enc = skE.PublicKey().Bytes()
+	kemContext := append(enc[:len(enc):len(enc)], pkR.Bytes()...)
+	if skS != nil {
+		dhS, err := skS.ECDH(pkR)
+		if err != nil {
+			return nil, nil, err
+		}
+		dh = append(dh, dhS...)
+		kemContext = append(kemContext, skS.PublicKey().Bytes()...)
+	}
+	return k.extractAndExpand(dh, kemContext), enc, nil
+}
+
+// decap implements Decap and, if pkS is not nil, AuthDecap of RFC 9180,
+// Section 4.1.
+func (k KEM) decap(enc []byte, skR *ecdh.PrivateKey, pkS *ecdh.PublicKey) ([]byte, error) {
+	pkE, err := k.Curve().NewPublicKey(enc)
+	if err != nil {
+		return nil, errors.New("hpke: invalid encapsulated key")
+	}
+	dh, err := skR.ECDH(pkE)
+	if err != nil {
+		return nil, err
+	}
+	kemContext := append(enc[:len(enc):len(enc)], skR.PublicKey().Bytes()...)
+	if pkS != nil {
+		dhS, err := skR.ECDH(pkS)
+		if err != nil {
+			return nil, err
+		}
+		dh = append(dh, dhS...)
+		kemContext = append(kemContext, pkS.Bytes()...)
+	}
+	return k.extractAndExpand(dh, kemContext), nil
+}
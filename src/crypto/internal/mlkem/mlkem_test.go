@@ -0,0 +1,353 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mlkem
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"testing"
+
+	"golang.org/x/crypto/sha3"
+)
+
+type encapsulationKey interface {
+	Bytes() []byte
+	Encapsulate() ([]byte, []byte)
+	EncapsulateInternal(*[32]byte) ([]byte, []byte)
+}
+
+type decapsulationKey[E encapsulationKey] interface {
+	Bytes() []byte
+	Decapsulate([]byte) ([]byte, error)
+	EncapsulationKey() E
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Run("768", func(t *testing.T) {
+		testRoundTrip(t, GenerateKey768, NewEncapsulationKey768, NewDecapsulationKey768)
+	})
+	t.Run("1024", func(t *testing.T) {
+		testRoundTrip(t, GenerateKey1024, NewEncapsulationKey1024, NewDecapsulationKey1024)
+	})
+}
+
+func testRoundTrip[E encapsulationKey, D decapsulationKey[E]](
+	t *testing.T, generateKey func() (D, error),
+	newEncapsulationKey func([]byte) (E, error),
+	newDecapsulationKey func([]byte) (D, error)) {
+	dk, err := generateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek := dk.EncapsulationKey()
+	Ke, c := ek.Encapsulate()
+	Kd, err := dk.Decapsulate(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(Ke, Kd) {
+		t.Fail()
+	}
+
+	ek1, err := newEncapsulationKey(ek.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ek.Bytes(), ek1.Bytes()) {
+		t.Fail()
+	}
+	dk1, err := newDecapsulationKey(dk.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dk.Bytes(), dk1.Bytes()) {
+		t.Fail()
+	}
+	Ke1, c1 := ek1.Encapsulate()
+	Kd1, err := dk1.Decapsulate(c1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(Ke1, Kd1) {
+		t.Fail()
+	}
+
+	dk2, err := generateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(dk.EncapsulationKey().Bytes(), dk2.EncapsulationKey().Bytes()) {
+		t.Fail()
+	}
+	if bytes.Equal(dk.Bytes(), dk2.Bytes()) {
+		t.Fail()
+	}
+
+	Ke2, c2 := dk.EncapsulationKey().Encapsulate()
+	if bytes.Equal(c, c2) {
+		t.Fail()
+	}
+	if bytes.Equal(Ke, Ke2) {
+		t.Fail()
+	}
+}
+
+func TestBadLengths(t *testing.T) {
+	t.Run("768", func(t *testing.T) {
+		testBadLengths(t, GenerateKey768, NewEncapsulationKey768, NewDecapsulationKey768)
+	})
+	t.Run("1024", func(t *testing.T) {
+		testBadLengths(t, GenerateKey1024, NewEncapsulationKey1024, NewDecapsulationKey1024)
+	})
+}
+
+func testBadLengths[E encapsulationKey, D decapsulationKey[E]](
+	t *testing.T, generateKey func() (D, error),
+	newEncapsulationKey func([]byte) (E, error),
+	newDecapsulationKey func([]byte) (D, error)) {
+	dk, err := generateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dkBytes := dk.Bytes()
+	ek := dk.EncapsulationKey()
+	ekBytes := dk.EncapsulationKey().Bytes()
+	_, c := ek.Encapsulate()
+
+	for i := 0; i < len(dkBytes)-1; i++ {
+		if _, err := newDecapsulationKey(dkBytes[:i]); err == nil {
+			t.Errorf("expected error for dk length %d", i)
+		}
+	}
+	dkLong := dkBytes
+	for i := 0; i < 100; i++ {
+		dkLong = append(dkLong, 0)
+		if _, err := newDecapsulationKey(dkLong); err == nil {
+			t.Errorf("expected error for dk length %d", len(dkLong))
+		}
+	}
+
+	for i := 0; i < len(ekBytes)-1; i++ {
+		if _, err := newEncapsulationKey(ekBytes[:i]); err == nil {
+			t.Errorf("expected error for ek length %d", i)
+		}
+	}
+	ekLong := ekBytes
+	for i := 0; i < 100; i++ {
+		ekLong = append(ekLong, 0)
+		if _, err := newEncapsulationKey(ekLong); err == nil {
+			t.Errorf("expected error for ek length %d", len(ekLong))
+		}
+	}
+
+	for i := 0; i < len(c)-1; i++ {
+		if _, err := dk.Decapsulate(c[:i]); err == nil {
+			t.Errorf("expected error for c length %d", i)
+		}
+	}
+	cLong := c
+	for i := 0; i < 100; i++ {
+		cLong = append(cLong, 0)
+		if _, err := dk.Decapsulate(cLong); err == nil {
+			t.Errorf("expected error for c length %d", len(cLong))
+		}
+	}
+}
+
+// TestVectors checks key generation, encapsulation and decapsulation against
+// the ML-KEM vectors of draft-ietf-hpke-pq. The encapsulation key and the
+// ciphertext are checked by their SHA3-256 hash.
+func TestVectors(t *testing.T) {
+	vectors := []struct {
+		name    string
+		seed, m string
+		ekHash  string
+		ctHash  string
+		K       string
+	}{
+		{
+			name:   "768",
+			seed:   "3530176644619eb968895c1a251e8568e063278a7d9f4314b7d0ad973be2fd0b9560e77a2ca3f07958d782cab43cbae46e16bbc90277545d333e11ddcf18df61",
+			m:      "54274849d6fa9d1c71d658b4bcdec56bba6a4a49e0178fe4639d321920c258c0",
+			ekHash: "8ccefdebbe65c2eccbf3f26b6250b2b02ce7854c1d9badfaa6563296c9c1c68d",
+			ctHash: "6fb4b11babdb41e67f8304b5bc8e2a2f327e4a33c43dafc233e6fc05972d6266",
+			K:      "02a5ae918c2061093153b64a9ab0e7fd0557b83c525ae40b5105445562acf451",
+		},
+		{
+			name:   "1024",
+			seed:   "f279454d08150d5bd81252001d02e1099f12fb7e9be6da2fe427bbaa2d79b0ab67306c0153c052610c4fdba3fad3435aeb1b65817d442c5c18ce07ea42440005",
+			m:      "b79ccf36c6d61fb48511de939a6a23be436eb9c744bdbd3a6aab85bcad61377b",
+			ekHash: "564d71aee5ec3d57776c9a401c2009dfae2bdc26af77e3547369a4a58cc054a1",
+			ctHash: "2ffa98aa60523f73ae3e3d1266af39d9f95729d4b5c877ffed1cc59766553687",
+			K:      "82e39853d199735aa5bf8fb3fbee412de8b39ae39cbad0bd7326c3cf1f6c6232",
+		},
+		{
+			name:   "1024",
+			seed:   "d919b835b98c968e6a1e85a6e7c54be8774df0a4d00775626dea7c0fd0750d92fa2c8655a59401910e2da51f5bf78b2014840fed74753f760ac85586c0891570",
+			m:      "a20456fa8c2a558686a231eea685c004974b90f53a716e9cc1716ddab8619efe",
+			ekHash: "0fcac83e9a176352ec2cb7bb2a9fb3d6d50255166a7a0a1afee7095933b9e669",
+			ctHash: "ce0a1423a6dd17478d2588592a03c73f40b845ee7cf6dd8b05223f5a0b0f89f1",
+			K:      "c2575ffa4d3ac41eb1b7e31cff87172128a33a0f3065313351cb8e556e13a9b1",
+		},
+	}
+	for _, v := range vectors {
+		t.Run(v.name, func(t *testing.T) {
+			seed := mustDecodeHex(t, v.seed)
+			m := (*[32]byte)(mustDecodeHex(t, v.m))
+			switch v.name {
+			case "768":
+				dk, err := NewDecapsulationKey768(seed)
+				if err != nil {
+					t.Fatal(err)
+				}
+				checkVector(t, dk, m, v.ekHash, v.ctHash, v.K)
+			case "1024":
+				dk, err := NewDecapsulationKey1024(seed)
+				if err != nil {
+					t.Fatal(err)
+				}
+				checkVector(t, dk, m, v.ekHash, v.ctHash, v.K)
+			}
+		})
+	}
+}
+
+func checkVector[E encapsulationKey, D decapsulationKey[E]](t *testing.T, dk D, m *[32]byte, ekHash, ctHash, K string) {
+	t.Helper()
+	ek := dk.EncapsulationKey()
+	if got := sha3.Sum256(ek.Bytes()); hex.EncodeToString(got[:]) != ekHash {
+		t.Errorf("ek hash: got %x, expected %s", got, ekHash)
+	}
+	k, c := ek.EncapsulateInternal(m)
+	if got := sha3.Sum256(c); hex.EncodeToString(got[:]) != ctHash {
+		t.Errorf("ciphertext hash: got %x, expected %s", got, ctHash)
+	}
+	if hex.EncodeToString(k) != K {
+		t.Errorf("encapsulated K: got %x, expected %s", k, K)
+	}
+	kk, err := dk.Decapsulate(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(kk) != K {
+		t.Errorf("decapsulated K: got %x, expected %s", kk, K)
+	}
+}
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+var millionFlag = flag.Bool("million", false, "run the million vector test")
+
+// TestAccumulated accumulates 10k (or 100, or 1M) random vectors and checks the
+// hash of the result, to avoid checking in 150MB of test vectors.
+func TestAccumulated(t *testing.T) {
+	n := 10000
+	expected := "8a518cc63da366322a8e7a818c7a0d63483cb3528d34a4cf42f35d5ad73f22fc"
+	if testing.Short() {
+		n = 100
+		expected = "1114b1b6699ed191734fa339376afa7e285c9e6acf6ff0177d346696ce564415"
+	}
+	if *millionFlag {
+		n = 1000000
+		expected = "424bf8f0e8ae99b78d788a6e2e8e9cdaf9773fc0c08a6f433507cb559edfd0f0"
+	}
+
+	s := sha3.NewShake128()
+	o := sha3.NewShake128()
+	seed := make([]byte, SeedSize)
+	var msg [messageSize]byte
+	ct1 := make([]byte, CiphertextSize768)
+
+	for i := 0; i < n; i++ {
+		s.Read(seed)
+		dk, err := NewDecapsulationKey768(seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ek := dk.EncapsulationKey()
+		o.Write(ek.Bytes())
+
+		s.Read(msg[:])
+		k, ct := ek.EncapsulateInternal(&msg)
+		o.Write(ct)
+		o.Write(k)
+
+		kk, err := dk.Decapsulate(ct)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(kk, k) {
+			t.Errorf("k: got %x, expected %x", kk, k)
+		}
+
+		s.Read(ct1)
+		k1, err := dk.Decapsulate(ct1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o.Write(k1)
+	}
+
+	got := make([]byte, 32)
+	o.Read(got)
+	if hex.EncodeToString(got) != expected {
+		t.Errorf("got %x, expected %s", got, expected)
+	}
+}
+
+var sink byte
+
+func BenchmarkKeyGen(b *testing.B) {
+	var d, z [32]byte
+	rand.Read(d[:])
+	rand.Read(z[:])
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dk := GenerateKeyInternal768(&d, &z)
+		sink ^= dk.EncapsulationKey().Bytes()[0]
+	}
+}
+
+func BenchmarkEncaps(b *testing.B) {
+	seed := make([]byte, SeedSize)
+	rand.Read(seed)
+	dk, err := NewDecapsulationKey768(seed)
+	if err != nil {
+		b.Fatal(err)
+	}
+	ekBytes := dk.EncapsulationKey().Bytes()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ek, err := NewEncapsulationKey768(ekBytes)
+		if err != nil {
+			b.Fatal(err)
+		}
+		K, c := ek.Encapsulate()
+		sink ^= c[0] ^ K[0]
+	}
+}
+
+func BenchmarkDecaps(b *testing.B) {
+	dk, err := GenerateKey768()
+	if err != nil {
+		b.Fatal(err)
+	}
+	ek := dk.EncapsulationKey()
+	_, c := ek.Encapsulate()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		K, _ := dk.Decapsulate(c)
+		sink ^= K[0]
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mlkem_test
+
+import (
+	"bytes"
+	"crypto/internal/mlkem"
+	. "crypto/mlkem"
+	"testing"
+)
+
+func TestRoundTrip768(t *testing.T) {
+	dk, err := GenerateKey768()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek, err := NewEncapsulationKey768(dk.EncapsulationKey().Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	Ke, c := ek.Encapsulate()
+	if len(c) != CiphertextSize768 || len(Ke) != SharedKeySize {
+		t.Fatalf("unexpected lengths: ciphertext %d, shared key %d", len(c), len(Ke))
+	}
+	dk1, err := NewDecapsulationKey768(dk.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	Kd, err := dk1.Decapsulate(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(Ke, Kd) {
+		t.Errorf("shared keys differ: %x != %x", Ke, Kd)
+	}
+}
+
+func TestRoundTrip1024(t *testing.T) {
+	dk, err := GenerateKey1024()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek, err := NewEncapsulationKey1024(dk.EncapsulationKey().Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	Ke, c := ek.Encapsulate()
+	if len(c) != CiphertextSize1024 || len(Ke) != SharedKeySize {
+		t.Fatalf("unexpected lengths: ciphertext %d, shared key %d", len(c), len(Ke))
+	}
+	dk1, err := NewDecapsulationKey1024(dk.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	Kd, err := dk1.Decapsulate(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(Ke, Kd) {
+		t.Errorf("shared keys differ: %x != %x", Ke, Kd)
+	}
+}
+
+// Test that the constants from the public API match the corresponding values from the internal API.
+func TestConstantSizes(t *testing.T) {
+	if SharedKeySize != mlkem.SharedKeySize {
+		t.Errorf("SharedKeySize mismatch: got %d, want %d", SharedKeySize, mlkem.SharedKeySize)
+	}
+	if SeedSize != mlkem.SeedSize {
+		t.Errorf("SeedSize mismatch: got %d, want %d", SeedSize, mlkem.SeedSize)
+	}
+	if CiphertextSize768 != mlkem.CiphertextSize768 {
+		t.Errorf("CiphertextSize768 mismatch: got %d, want %d", CiphertextSize768, mlkem.CiphertextSize768)
+	}
+	if EncapsulationKeySize768 != mlkem.EncapsulationKeySize768 {
+		t.Errorf("EncapsulationKeySize768 mismatch: got %d, want %d", EncapsulationKeySize768, mlkem.EncapsulationKeySize768)
+	}
+	if CiphertextSize1024 != mlkem.CiphertextSize1024 {
+		t.Errorf("CiphertextSize1024 mismatch: got %d, want %d", CiphertextSize1024, mlkem.CiphertextSize1024)
+	}
+	if EncapsulationKeySize1024 != mlkem.EncapsulationKeySize1024 {
+		t.Errorf("EncapsulationKeySize1024 mismatch: got %d, want %d", EncapsulationKeySize1024, mlkem.EncapsulationKeySize1024)
+	}
+}
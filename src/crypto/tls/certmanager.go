@@ -0,0 +1,218 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A CertificateManager serves certificates loaded from files, for use as a
+// [Config.GetCertificate] callback. It selects among multiple certificates
+// based on the server name, key type, and signature algorithms supported by
+// each client, and can reload them when the files change, without
+// interrupting connections.
+//
+// The zero value is an empty CertificateManager ready to use. A
+// CertificateManager is safe for concurrent use, and must not be copied
+// after first use.
+type CertificateManager struct {
+	// ReloadError, if not nil, is called by Watch with the errors encountered
+	// while reloading certificates. The previously loaded certificates keep
+	// being used in that case.
+	ReloadError func(error)
+
+	mu    sync.Mutex // serializes changes to pairs and certs
+	pairs []*keyPairFiles
+	certs atomic.Pointer[[]*Certificate]
+}
+
+// keyPairFiles is a certificate and key file pair loaded by a
+// CertificateManager, along with the last version loaded from them.
+type keyPairFiles struct {
+	certFile, keyFile   string
+	certStamp, keyStamp fileStamp
+	cert                *Certificate
+}
+
+// fileStamp identifies a version of a file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func statFile(name string) (fileStamp, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{fi.ModTime(), fi.Size()}, nil
+}
+
+// load reads the files of p, and on success updates p.cert.
+func (p *keyPairFiles) load() error {
+	// The files are stat'ed before reading them, so that if they change
+	// while they are read, the next check by Watch will reload them again.
+	certStamp, err := statFile(p.certFile)
+	if err != nil {
+		return err
+	}
+	keyStamp, err := statFile(p.keyFile)
+	if err != nil {
+		return err
+	}
+	cert, err := LoadX509KeyPair(p.certFile, p.keyFile)
+	if err != nil {
+		return fmt.Errorf("tls: loading %s and %s: %w", p.certFile, p.keyFile, err)
+	}
+	// Parse the leaf once, rather than on every selection, if
+	// LoadX509KeyPair didn't, as with GODEBUG x509keypairleaf=0.
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return fmt.Errorf("tls: loading %s: %w", p.certFile, err)
+		}
+	}
+	p.certStamp, p.keyStamp, p.cert = certStamp, keyStamp, &cert
+	return nil
+}
+
+// changed reports whether the files of p changed since they were loaded.
+func (p *keyPairFiles) changed() bool {
+	certStamp, err := statFile(p.certFile)
+	if err != nil {
+		return true
+	}
+	keyStamp, err := statFile(p.keyFile)
+	if err != nil {
+		return true
+	}
+	return certStamp != p.certStamp || keyStamp != p.keyStamp
+}
+
+// AddKeyPair loads a public/private key pair from PEM encoded files, like
+// [LoadX509KeyPair], and adds it to the certificates served by m.
+//
+// When multiple certificates are supported by a client, the first one added
+// is selected, so certificates should be added in order of preference. For
+// example, an ECDSA certificate added before an RSA one for the same names
+// is served to clients that support ECDSA, and the RSA one to the others.
+func (m *CertificateManager) AddKeyPair(certFile, keyFile string) error {
+	p := &keyPairFiles{certFile: certFile, keyFile: keyFile}
+	if err := p.load(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pairs = append(m.pairs, p)
+	m.publishLocked()
+	return nil
+}
+
+// publishLocked atomically replaces the certificates served by m with the
+// last versions loaded from the files. It must be called with m.mu held.
+func (m *CertificateManager) publishLocked() {
+	certs := make([]*Certificate, 0, len(m.pairs))
+	for _, p := range m.pairs {
+		certs = append(certs, p.cert)
+	}
+	m.certs.Store(&certs)
+}
+
+// Reload reads all the key pairs of m from their files again, and atomically
+// replaces the certificates served by m with them. Connections always see
+// either the old or the new version of a certificate.
+//
+// If a key pair fails to load, for example because its files are being
+// rewritten and the certificate doesn't match the key, the previous version
+// of that key pair is kept, and the error is returned after updating the
+// other key pairs.
+func (m *CertificateManager) Reload() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var errs []error
+	for _, p := range m.pairs {
+		if err := p.load(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	m.publishLocked()
+	return errors.Join(errs...)
+}
+
+// Watch checks the files of the key pairs of m for changes every interval,
+// reloading the key pairs whose certificate or key file changed, until ctx is
+// done. It then returns ctx.Err().
+//
+// Changes are detected based on the modification time and size of the files,
+// which should be replaced atomically, for example by renaming them into
+// place. If a key pair fails to load, for example because only one of its
+// files was replaced yet, the error is reported to ReloadError, the previous
+// version keeps being served, and loading is retried at the next interval.
+//
+// Watch returns an error right away if interval is not positive.
+func (m *CertificateManager) Watch(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return errors.New("tls: non-positive interval for CertificateManager.Watch")
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		if err := m.reloadChanged(); err != nil && m.ReloadError != nil {
+			m.ReloadError(err)
+		}
+	}
+}
+
+func (m *CertificateManager) reloadChanged() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var errs []error
+	reloaded := false
+	for _, p := range m.pairs {
+		if !p.changed() {
+			continue
+		}
+		if err := p.load(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		reloaded = true
+	}
+	if reloaded {
+		m.publishLocked()
+	}
+	return errors.Join(errs...)
+}
+
+// GetCertificate returns the first certificate of m that is supported by the
+// client, as reported by [ClientHelloInfo.SupportsCertificate], which takes
+// into account the server name, key type, and signature algorithms supported
+// by the client. If none is supported, it returns the first certificate, so
+// that the client can report a meaningful error.
+//
+// GetCertificate can be used as a [Config.GetCertificate] callback.
+func (m *CertificateManager) GetCertificate(hello *ClientHelloInfo) (*Certificate, error) {
+	certs := m.certs.Load()
+	if certs == nil || len(*certs) == 0 {
+		return nil, errNoCertificates
+	}
+	for _, cert := range *certs {
+		if err := hello.SupportsCertificate(cert); err == nil {
+			return cert, nil
+		}
+	}
+	return (*certs)[0], nil
+}
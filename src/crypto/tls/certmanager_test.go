@@ -0,0 +1,205 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestKeyPair writes a self-signed certificate for name and its key to
+// PEM files, and returns the serial number of the certificate. The files are
+// replaced by renaming, key first, and their modification time is set to
+// mtime.
+func writeTestKeyPair(t *testing.T, certFile, keyFile, name string, key crypto.Signer, mtime time.Time) *big.Int {
+	t.Helper()
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []struct {
+		name  string
+		block *pem.Block
+	}{
+		{keyFile, &pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}},
+		{certFile, &pem.Block{Type: "CERTIFICATE", Bytes: der}},
+	} {
+		tmp := f.name + ".tmp"
+		if err := os.WriteFile(tmp, pem.EncodeToMemory(f.block), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(tmp, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, f.name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return serial
+}
+
+func TestCertificateManagerSelection(t *testing.T) {
+	dir := t.TempDir()
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now()
+	pairs := []struct {
+		name string
+		key  crypto.Signer
+	}{
+		{"a.example", edKey},
+		{"a.example", ecKey},
+		{"b.example", ecKey},
+	}
+	m := &CertificateManager{}
+	var serials []*big.Int
+	for i, p := range pairs {
+		certFile := filepath.Join(dir, string(rune('0'+i))+".crt")
+		keyFile := filepath.Join(dir, string(rune('0'+i))+".key")
+		serials = append(serials, writeTestKeyPair(t, certFile, keyFile, p.name, p.key, mtime))
+		if err := m.AddKeyPair(certFile, keyFile); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		serverName string
+		schemes    []SignatureScheme
+		want       int
+	}{
+		{"a.example", []SignatureScheme{Ed25519, ECDSAWithP256AndSHA256}, 0},
+		{"a.example", []SignatureScheme{ECDSAWithP256AndSHA256}, 1},
+		{"b.example", []SignatureScheme{Ed25519, ECDSAWithP256AndSHA256}, 2},
+		{"c.example", []SignatureScheme{Ed25519, ECDSAWithP256AndSHA256}, 0},
+	} {
+		cert, err := m.GetCertificate(&ClientHelloInfo{
+			ServerName:        tt.serverName,
+			SignatureSchemes:  tt.schemes,
+			SupportedVersions: []uint16{VersionTLS13},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cert.Leaf.SerialNumber; got.Cmp(serials[tt.want]) != 0 {
+			t.Errorf("%s with %v: got certificate %d, want %d", tt.serverName, tt.schemes, got, serials[tt.want])
+		}
+	}
+
+	if _, err := (&CertificateManager{}).GetCertificate(&ClientHelloInfo{}); err == nil {
+		t.Errorf("empty CertificateManager returned a certificate")
+	}
+}
+
+func TestCertificateManagerReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	newKey := func() crypto.Signer {
+		k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	mtime := time.Now().Add(-time.Hour)
+	serial := writeTestKeyPair(t, certFile, keyFile, "example.com", newKey(), mtime)
+
+	m := &CertificateManager{}
+	if err := m.AddKeyPair(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	clientConfig := &Config{ServerName: "example.com", InsecureSkipVerify: true}
+	serverConfig := &Config{GetCertificate: m.GetCertificate}
+	expectSerial := func(want *big.Int) {
+		t.Helper()
+		_, cs, err := testHandshake(t, clientConfig, serverConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cs.PeerCertificates[0].SerialNumber; got.Cmp(want) != 0 {
+			t.Fatalf("got certificate %d, want %d", got, want)
+		}
+	}
+	expectSerial(serial)
+
+	// A certificate that doesn't match its key is not loaded.
+	writeTestKeyPair(t, certFile, filepath.Join(dir, "other.pem"), "example.com", newKey(), mtime)
+	if err := m.Reload(); err == nil {
+		t.Errorf("Reload succeeded with a mismatched key")
+	}
+	expectSerial(serial)
+
+	serial = writeTestKeyPair(t, certFile, keyFile, "example.com", newKey(), mtime)
+	if err := m.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	expectSerial(serial)
+
+	if err := m.Watch(context.Background(), 0); err == nil {
+		t.Errorf("Watch accepted a zero interval")
+	}
+
+	// Watch picks up changes to the files.
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	// The key file is replaced first, so Watch might observe a mismatched
+	// key pair, which it retries later.
+	m.ReloadError = func(err error) { t.Logf("reload error: %v", err) }
+	go func() { errs <- m.Watch(ctx, 10*time.Millisecond) }()
+	serial = writeTestKeyPair(t, certFile, keyFile, "example.com", newKey(), mtime.Add(time.Minute))
+	for deadline := time.Now().Add(10 * time.Second); ; {
+		cert, err := m.GetCertificate(&ClientHelloInfo{ServerName: "example.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cert.Leaf.SerialNumber.Cmp(serial) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Watch didn't reload the changed certificate")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	expectSerial(serial)
+	cancel()
+	if err := <-errs; err != context.Canceled {
+		t.Errorf("Watch returned %v, want context.Canceled", err)
+	}
+}
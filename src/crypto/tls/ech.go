@@ -0,0 +1,584 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"crypto/hpke"
+	"errors"
+	"hash"
+	"strings"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// The ECHClientHello types of draft-ietf-tls-esni-18, Section 5.
+type echExtType uint8
+
+const (
+	outerECHExt echExtType = 0
+	innerECHExt echExtType = 1
+)
+
+type echCipher struct {
+	KDFID  uint16
+	AEADID uint16
+}
+
+type echExtension struct {
+	Type uint16
+	Data []byte
+}
+
+// echConfig is an ECHConfig, as specified in draft-ietf-tls-esni-18,
+// Section 4.
+type echConfig struct {
+	raw []byte // the whole ECHConfig, including its version and length
+
+	Version uint16
+
+	ConfigID             uint8
+	KemID                uint16
+	PublicKey            []byte
+	SymmetricCipherSuite []echCipher
+
+	MaxNameLength uint8
+	PublicName    []byte
+	Extensions    []echExtension
+}
+
+var errMalformedECHConfig = errors.New("tls: malformed ECHConfigList")
+
+// readECHConfig reads an ECHConfig from s. skip is true if the config has an
+// unsupported version, in which case its contents are not parsed.
+func readECHConfig(s *cryptobyte.String) (skip bool, ec echConfig, err error) {
+	raw := []byte(*s)
+	var contents cryptobyte.String
+	if !s.ReadUint16(&ec.Version) || !s.ReadUint16LengthPrefixed(&contents) {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	ec.raw = raw[:4+len(contents)]
+	if ec.Version != extensionEncryptedClientHello {
+		return true, echConfig{}, nil
+	}
+
+	var cipherSuites, publicName, extensions cryptobyte.String
+	if !contents.ReadUint8(&ec.ConfigID) ||
+		!contents.ReadUint16(&ec.KemID) ||
+		!readUint16LengthPrefixed(&contents, &ec.PublicKey) ||
+		!contents.ReadUint16LengthPrefixed(&cipherSuites) || cipherSuites.Empty() ||
+		!contents.ReadUint8(&ec.MaxNameLength) ||
+		!contents.ReadUint8LengthPrefixed(&publicName) || publicName.Empty() ||
+		!contents.ReadUint16LengthPrefixed(&extensions) ||
+		!contents.Empty() {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	ec.PublicName = publicName
+	for !cipherSuites.Empty() {
+		var c echCipher
+		if !cipherSuites.ReadUint16(&c.KDFID) || !cipherSuites.ReadUint16(&c.AEADID) {
+			return false, echConfig{}, errMalformedECHConfig
+		}
+		ec.SymmetricCipherSuite = append(ec.SymmetricCipherSuite, c)
+	}
+	for !extensions.Empty() {
+		var e echExtension
+		if !extensions.ReadUint16(&e.Type) || !readUint16LengthPrefixed(&extensions, &e.Data) {
+			return false, echConfig{}, errMalformedECHConfig
+		}
+		ec.Extensions = append(ec.Extensions, e)
+	}
+	return false, ec, nil
+}
+
+// parseECHConfig parses a single ECHConfig, such as the Config field of an
+// EncryptedClientHelloKey.
+func parseECHConfig(enc []byte) (skip bool, ec echConfig, err error) {
+	s := cryptobyte.String(enc)
+	skip, ec, err = readECHConfig(&s)
+	if err == nil && !s.Empty() {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	return skip, ec, err
+}
+
+// parseECHConfigList parses an ECHConfigList, skipping the configs with an
+// unsupported version.
+func parseECHConfigList(data []byte) ([]echConfig, error) {
+	s := cryptobyte.String(data)
+	var list cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&list) || !s.Empty() || list.Empty() {
+		return nil, errMalformedECHConfig
+	}
+	var configs []echConfig
+	for !list.Empty() {
+		skip, ec, err := readECHConfig(&list)
+		if err != nil {
+			return nil, err
+		}
+		if !skip {
+			configs = append(configs, ec)
+		}
+	}
+	return configs, nil
+}
+
+// pickECHConfig returns the first config in list that this implementation
+// can use, or nil if there is none.
+func pickECHConfig(list []echConfig) *echConfig {
+	for i := range list {
+		ec := &list[i]
+		if !hpke.KEM(ec.KemID).Available() {
+			continue
+		}
+		if _, err := pickECHCipherSuite(ec.SymmetricCipherSuite); err != nil {
+			continue
+		}
+		if !validECHPublicName(string(ec.PublicName)) {
+			continue
+		}
+		mandatoryExt := false
+		for _, ext := range ec.Extensions {
+			// Extensions with the high bit set are mandatory, and since
+			// none are supported, configs carrying them can't be used.
+			if ext.Type&0x8000 != 0 {
+				mandatoryExt = true
+				break
+			}
+		}
+		if mandatoryExt {
+			continue
+		}
+		return ec
+	}
+	return nil
+}
+
+// pickECHCipherSuite returns the first of suites that is supported.
+func pickECHCipherSuite(suites []echCipher) (echCipher, error) {
+	for _, s := range suites {
+		if !hpke.KDF(s.KDFID).Available() {
+			continue
+		}
+		// The export-only AEAD can't encrypt the ClientHelloInner.
+		if aead := hpke.AEAD(s.AEADID); !aead.Available() || aead == hpke.ExportOnly {
+			continue
+		}
+		return s, nil
+	}
+	return echCipher{}, errors.New("tls: no supported ECH cipher suite")
+}
+
+// validECHPublicName reports whether name is a valid public_name, which must
+// be a DNS name and not an IPv4 address. See draft-ietf-tls-esni-18,
+// Section 4.
+func validECHPublicName(name string) bool {
+	if len(name) == 0 || len(name) > 253 {
+		return false
+	}
+	labels := strings.Split(name, ".")
+	for _, l := range labels {
+		if len(l) == 0 || len(l) > 63 || l[0] == '-' || l[len(l)-1] == '-' {
+			return false
+		}
+		for _, c := range []byte(l) {
+			if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	// The last label must not be numeric, so that the name can't be
+	// mistaken for an IPv4 address.
+	last := labels[len(labels)-1]
+	return strings.Trim(last, "0123456789") != "" &&
+		!(strings.HasPrefix(last, "0x") || strings.HasPrefix(last, "0X"))
+}
+
+// echClientContext is the state of a client that offers ECH.
+type echClientContext struct {
+	config          *echConfig
+	hpkeContext     *hpke.Sender
+	encapsulatedKey []byte
+	innerHello      *clientHelloMsg
+	innerTranscript hash.Hash
+	kdfID           uint16
+	aeadID          uint16
+	echRejected     bool
+	retryConfigs    []byte
+}
+
+// echServerContext is the state of a server that accepted ECH.
+type echServerContext struct {
+	hpkeContext *hpke.Recipient
+	configID    uint8
+	ciphersuite echCipher
+}
+
+// encodeInnerClientHello returns the padded EncodedClientHelloInner for inner.
+// See draft-ietf-tls-esni-18, Sections 5.1 and 6.1.3.
+func encodeInnerClientHello(inner *clientHelloMsg, maxNameLength int) ([]byte, error) {
+	h, err := inner.marshalMsg(true)
+	if err != nil {
+		return nil, err
+	}
+	h = h[4:] // strip the message type and length
+
+	var paddingLen int
+	if inner.serverName != "" {
+		paddingLen = max(0, maxNameLength-len(inner.serverName))
+	} else {
+		paddingLen = maxNameLength + 9
+	}
+	paddingLen += 31 - ((len(h) + paddingLen - 1) % 32)
+
+	return append(h, make([]byte, paddingLen)...), nil
+}
+
+// generateOuterECHExt returns the encoding of an outer ECHClientHello.
+func generateOuterECHExt(id uint8, kdfID, aeadID uint16, encodedKey []byte, payload []byte) ([]byte, error) {
+	var b cryptobyte.Builder
+	b.AddUint8(uint8(outerECHExt))
+	b.AddUint16(kdfID)
+	b.AddUint16(aeadID)
+	b.AddUint8(id)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(encodedKey) })
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(payload) })
+	return b.Bytes()
+}
+
+// computeAndUpdateOuterECHExtension encrypts inner and stores it in the
+// encrypted_client_hello extension of outer. The encapsulated key is only sent
+// with the first ClientHello. See draft-ietf-tls-esni-18, Section 6.1.
+func computeAndUpdateOuterECHExtension(outer, inner *clientHelloMsg, ech *echClientContext, useKey bool) error {
+	var encapKey []byte
+	if useKey {
+		encapKey = ech.encapsulatedKey
+	}
+	encodedInner, err := encodeInnerClientHello(inner, int(ech.config.MaxNameLength))
+	if err != nil {
+		return err
+	}
+	// The additional data is the ClientHelloOuter with a zeroed payload, so
+	// first compute it with a placeholder of the right length. All the
+	// supported AEADs have 16 byte tags.
+	encryptedLen := len(encodedInner) + 16
+	outer.encryptedClientHello, err = generateOuterECHExt(ech.config.ConfigID, ech.kdfID, ech.aeadID, encapKey, make([]byte, encryptedLen))
+	if err != nil {
+		return err
+	}
+	serializedOuter, err := outer.marshal()
+	if err != nil {
+		return err
+	}
+	serializedOuter = serializedOuter[4:] // strip the message type and length
+	encryptedInner, err := ech.hpkeContext.Seal(serializedOuter, encodedInner)
+	if err != nil {
+		return err
+	}
+	outer.encryptedClientHello, err = generateOuterECHExt(ech.config.ConfigID, ech.kdfID, ech.aeadID, encapKey, encryptedInner)
+	return err
+}
+
+var errMalformedECHExt = errors.New("tls: malformed encrypted_client_hello extension")
+
+// parseECHExt parses an ECHClientHello. For the inner type, only echType is
+// returned.
+func parseECHExt(ext []byte) (echType echExtType, cs echCipher, configID uint8, encap []byte, payload []byte, err error) {
+	s := cryptobyte.String(ext)
+	var t uint8
+	if !s.ReadUint8(&t) {
+		return 0, echCipher{}, 0, nil, nil, errMalformedECHExt
+	}
+	echType = echExtType(t)
+	switch echType {
+	case innerECHExt:
+		if !s.Empty() {
+			return 0, echCipher{}, 0, nil, nil, errMalformedECHExt
+		}
+		return echType, echCipher{}, 0, nil, nil, nil
+	case outerECHExt:
+	default:
+		return 0, echCipher{}, 0, nil, nil, errMalformedECHExt
+	}
+	if !s.ReadUint16(&cs.KDFID) || !s.ReadUint16(&cs.AEADID) ||
+		!s.ReadUint8(&configID) ||
+		!readUint16LengthPrefixed(&s, &encap) ||
+		!readUint16LengthPrefixed(&s, &payload) || len(payload) == 0 ||
+		!s.Empty() {
+		return 0, echCipher{}, 0, nil, nil, errMalformedECHExt
+	}
+	return echType, cs, configID, bytes.Clone(encap), bytes.Clone(payload), nil
+}
+
+// decryptECHPayload decrypts payload, which is carried by the ClientHelloOuter
+// whose encoding is hello.
+func decryptECHPayload(context *hpke.Recipient, hello, payload []byte) ([]byte, error) {
+	outerAAD := bytes.Replace(hello[4:], payload, make([]byte, len(payload)), 1)
+	return context.Open(outerAAD, payload)
+}
+
+type rawExtension struct {
+	extType uint16
+	data    []byte
+}
+
+// extractRawExtensions returns the extensions of the encoded ClientHello
+// hello, in order.
+func extractRawExtensions(hello []byte) ([]rawExtension, error) {
+	s := cryptobyte.String(hello)
+	var sessionID, cipherSuites, compressionMethods cryptobyte.String
+	if !s.Skip(4) || // message type and uint24 length field
+		!s.Skip(2+32) || // vers, random
+		!s.ReadUint8LengthPrefixed(&sessionID) ||
+		!s.ReadUint16LengthPrefixed(&cipherSuites) ||
+		!s.ReadUint8LengthPrefixed(&compressionMethods) {
+		return nil, errors.New("tls: malformed outer client hello")
+	}
+	var rawExtensions []rawExtension
+	var extensions cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&extensions) {
+		return nil, errors.New("tls: malformed outer client hello")
+	}
+	for !extensions.Empty() {
+		var extension uint16
+		var extData cryptobyte.String
+		if !extensions.ReadUint16(&extension) ||
+			!extensions.ReadUint16LengthPrefixed(&extData) {
+			return nil, errors.New("tls: invalid inner client hello")
+		}
+		rawExtensions = append(rawExtensions, rawExtension{extension, extData})
+	}
+	return rawExtensions, nil
+}
+
+var errInvalidECHExt = errors.New("tls: client sent invalid encrypted_client_hello extension")
+
+// decodeInnerClientHello reconstructs the ClientHelloInner from encoded, the
+// decrypted EncodedClientHelloInner, and the ClientHelloOuter, as described
+// in draft-ietf-tls-esni-18, Section 5.1.
+func decodeInnerClientHello(outer *clientHelloMsg, encoded []byte) (*clientHelloMsg, error) {
+	// The EncodedClientHelloInner lacks the message type and length and the
+	// legacy_session_id, and some of its extensions are references to the
+	// extensions of the ClientHelloOuter. The reconstruction must match
+	// byte for byte the ClientHelloInner the client put in its transcript,
+	// so the referenced extensions are copied from the raw outer hello.
+	innerReader := cryptobyte.String(encoded)
+	var versionAndRandom, sessionID, cipherSuites, compressionMethods []byte
+	var extensions cryptobyte.String
+	if !innerReader.ReadBytes(&versionAndRandom, 2+32) ||
+		!readUint8LengthPrefixed(&innerReader, &sessionID) ||
+		len(sessionID) != 0 ||
+		!readUint16LengthPrefixed(&innerReader, &cipherSuites) ||
+		!readUint8LengthPrefixed(&innerReader, &compressionMethods) ||
+		!innerReader.ReadUint16LengthPrefixed(&extensions) {
+		return nil, errInvalidECHExt
+	}
+
+	// The padding must be all zeros.
+	for _, p := range innerReader {
+		if p != 0 {
+			return nil, errInvalidECHExt
+		}
+	}
+
+	rawOuterExts, err := extractRawExtensions(outer.original)
+	if err != nil {
+		return nil, err
+	}
+
+	recon := cryptobyte.NewBuilder(nil)
+	recon.AddUint8(typeClientHello)
+	recon.AddUint24LengthPrefixed(func(recon *cryptobyte.Builder) {
+		recon.AddBytes(versionAndRandom)
+		recon.AddUint8LengthPrefixed(func(recon *cryptobyte.Builder) {
+			recon.AddBytes(outer.sessionId)
+		})
+		recon.AddUint16LengthPrefixed(func(recon *cryptobyte.Builder) {
+			recon.AddBytes(cipherSuites)
+		})
+		recon.AddUint8LengthPrefixed(func(recon *cryptobyte.Builder) {
+			recon.AddBytes(compressionMethods)
+		})
+		recon.AddUint16LengthPrefixed(func(recon *cryptobyte.Builder) {
+			// The referenced extensions must appear in the outer hello
+			// in the same order, so the search resumes after each match.
+			next := 0
+			for !extensions.Empty() {
+				var extension uint16
+				var extData cryptobyte.String
+				if !extensions.ReadUint16(&extension) ||
+					!extensions.ReadUint16LengthPrefixed(&extData) {
+					recon.SetError(errInvalidECHExt)
+					return
+				}
+				if extension != extensionECHOuterExtensions {
+					recon.AddUint16(extension)
+					recon.AddUint16LengthPrefixed(func(recon *cryptobyte.Builder) {
+						recon.AddBytes(extData)
+					})
+					continue
+				}
+				var outerExts cryptobyte.String
+				if !extData.ReadUint8LengthPrefixed(&outerExts) || outerExts.Empty() || !extData.Empty() {
+					recon.SetError(errInvalidECHExt)
+					return
+				}
+				for !outerExts.Empty() {
+					var extType uint16
+					if !outerExts.ReadUint16(&extType) || extType == extensionEncryptedClientHello {
+						recon.SetError(errInvalidECHExt)
+						return
+					}
+					for next < len(rawOuterExts) && rawOuterExts[next].extType != extType {
+						next++
+					}
+					if next == len(rawOuterExts) {
+						recon.SetError(errInvalidECHExt)
+						return
+					}
+					ext := rawOuterExts[next]
+					next++
+					recon.AddUint16(ext.extType)
+					recon.AddUint16LengthPrefixed(func(recon *cryptobyte.Builder) {
+						recon.AddBytes(ext.data)
+					})
+				}
+			}
+		})
+	})
+
+	reconBytes, err := recon.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	inner := &clientHelloMsg{}
+	if !inner.unmarshal(reconBytes) {
+		return nil, errInvalidECHExt
+	}
+
+	if !bytes.Equal(inner.encryptedClientHello, []byte{uint8(innerECHExt)}) {
+		return nil, errInvalidECHExt
+	}
+	if len(inner.supportedVersions) == 0 {
+		return nil, errors.New("tls: client sent encrypted_client_hello extension and offered incompatible versions")
+	}
+	for _, v := range inner.supportedVersions {
+		if v < VersionTLS13 {
+			return nil, errors.New("tls: client sent encrypted_client_hello extension and offered incompatible versions")
+		}
+	}
+
+	return inner, nil
+}
+
+// processECHClientHello attempts to decrypt the ClientHelloInner carried by
+// outer with the configured EncryptedClientHelloKeys. If decryption succeeds,
+// it returns the ClientHelloInner and the state needed to process a second
+// ClientHello. Otherwise, it returns outer and a nil context, and the
+// handshake proceeds with the ClientHelloOuter.
+func (c *Conn) processECHClientHello(outer *clientHelloMsg) (*clientHelloMsg, *echServerContext, error) {
+	echType, echCiphersuite, configID, encap, payload, err := parseECHExt(outer.encryptedClientHello)
+	if err != nil {
+		c.sendAlert(alertDecodeError)
+		return nil, nil, err
+	}
+	if echType == innerECHExt {
+		// Only the ClientHelloInner may carry the inner type, and
+		// splitting the client-facing and backend servers is not supported.
+		c.sendAlert(alertIllegalParameter)
+		return nil, nil, errInvalidECHExt
+	}
+
+	for _, echKey := range c.config.EncryptedClientHelloKeys {
+		skip, config, err := parseECHConfig(echKey.Config)
+		if err != nil || skip {
+			c.sendAlert(alertInternalError)
+			return nil, nil, errors.New("tls: invalid EncryptedClientHelloKeys Config")
+		}
+		if config.ConfigID != configID {
+			continue
+		}
+		supported := false
+		for _, cs := range config.SymmetricCipherSuite {
+			if cs == echCiphersuite {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			continue
+		}
+		kem := hpke.KEM(config.KemID)
+		if !kem.Available() {
+			c.sendAlert(alertInternalError)
+			return nil, nil, errors.New("tls: unsupported KEM in EncryptedClientHelloKeys Config")
+		}
+		echPriv, err := kem.Curve().NewPrivateKey(echKey.PrivateKey)
+		if err != nil {
+			c.sendAlert(alertInternalError)
+			return nil, nil, errors.New("tls: invalid EncryptedClientHelloKeys PrivateKey: " + err.Error())
+		}
+		info := append([]byte("tls ech\x00"), echKey.Config...)
+		suite := hpke.Suite{KEM: kem, KDF: hpke.KDF(echCiphersuite.KDFID), AEAD: hpke.AEAD(echCiphersuite.AEADID)}
+		hpkeContext, err := suite.NewRecipient(encap, echPriv, info)
+		if err != nil {
+			// Try the next key with the same config_id.
+			continue
+		}
+		encodedInner, err := decryptECHPayload(hpkeContext, outer.original, payload)
+		if err != nil {
+			continue
+		}
+		inner, err := decodeInnerClientHello(outer, encodedInner)
+		if err != nil {
+			c.sendAlert(alertIllegalParameter)
+			return nil, nil, err
+		}
+		c.echAccepted = true
+		return inner, &echServerContext{
+			hpkeContext: hpkeContext,
+			configID:    configID,
+			ciphersuite: echCiphersuite,
+		}, nil
+	}
+
+	return outer, nil, nil
+}
+
+// buildRetryConfigList returns the ECHConfigList of the keys marked to be sent
+// as retry configs, or nil if there are none.
+func buildRetryConfigList(keys []EncryptedClientHelloKey) ([]byte, error) {
+	var atLeastOneRetryConfig bool
+	var retryBuilder cryptobyte.Builder
+	retryBuilder.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, c := range keys {
+			if !c.SendAsRetry {
+				continue
+			}
+			atLeastOneRetryConfig = true
+			b.AddBytes(c.Config)
+		}
+	})
+	if !atLeastOneRetryConfig {
+		return nil, nil
+	}
+	return retryBuilder.Bytes()
+}
+
+// ECHRejectionError is the error returned by a client handshake when the
+// server rejects Encrypted Client Hello. In that case the handshake is
+// completed with the ClientHelloOuter and authenticated for its public name,
+// but no application data is exchanged.
+//
+// RetryConfigList holds the ECHConfigList the server provided for retrying
+// the connection, if any. An empty RetryConfigList is a signal, authenticated
+// by the server, that ECH should be disabled for the server.
+type ECHRejectionError struct {
+	RetryConfigList []byte
+}
+
+func (e *ECHRejectionError) Error() string {
+	return "tls: server rejected ECH"
+}
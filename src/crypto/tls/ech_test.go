@@ -0,0 +1,369 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// marshalECHConfig returns an ECHConfig for DHKEM(X25519, HKDF-SHA256) with
+// the given cipher suites.
+func marshalECHConfig(id uint8, pubKey []byte, publicName string, maxNameLen uint8, suites ...echCipher) []byte {
+	var b cryptobyte.Builder
+	b.AddUint16(extensionEncryptedClientHello)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint8(id)
+		b.AddUint16(0x0020) // DHKEM(X25519, HKDF-SHA256)
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(pubKey) })
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			for _, s := range suites {
+				b.AddUint16(s.KDFID)
+				b.AddUint16(s.AEADID)
+			}
+		})
+		b.AddUint8(maxNameLen)
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes([]byte(publicName)) })
+		b.AddUint16(0) // extensions
+	})
+	return b.BytesOrPanic()
+}
+
+func marshalECHConfigList(configs ...[]byte) []byte {
+	var b cryptobyte.Builder
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, c := range configs {
+			b.AddBytes(c)
+		}
+	})
+	return b.BytesOrPanic()
+}
+
+func TestParseECHConfigList(t *testing.T) {
+	pub := bytes.Repeat([]byte{1}, 32)
+	config := marshalECHConfig(7, pub, "public.example", 32, echCipher{1, 1}, echCipher{1, 3})
+	unknown := []byte{0xfe, 0x0a, 0x00, 0x02, 0xaa, 0xbb}
+
+	configs, err := parseECHConfigList(marshalECHConfigList(unknown, config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 1 {
+		t.Fatalf("got %d configs, want 1", len(configs))
+	}
+	c := configs[0]
+	if c.ConfigID != 7 || c.KemID != 0x0020 || !bytes.Equal(c.PublicKey, pub) ||
+		string(c.PublicName) != "public.example" || c.MaxNameLength != 32 ||
+		len(c.SymmetricCipherSuite) != 2 || c.SymmetricCipherSuite[1] != (echCipher{1, 3}) {
+		t.Errorf("unexpected config: %+v", c)
+	}
+	if !bytes.Equal(c.raw, config) {
+		t.Errorf("raw = %x, want %x", c.raw, config)
+	}
+	if pickECHConfig(configs) == nil {
+		t.Error("pickECHConfig rejected a supported config")
+	}
+
+	for _, bad := range [][]byte{
+		nil,
+		{0, 0},
+		marshalECHConfigList(config)[:len(config)],
+		append(marshalECHConfigList(config), 0),
+	} {
+		if _, err := parseECHConfigList(bad); err == nil {
+			t.Errorf("parseECHConfigList(%x) succeeded", bad)
+		}
+	}
+
+	for _, c := range [][]byte{
+		marshalECHConfig(1, pub, "public.example", 0, echCipher{1, 42}),
+		marshalECHConfig(1, pub, "192.0.2.1", 0, echCipher{1, 1}),
+		marshalECHConfig(1, pub, "-public.example", 0, echCipher{1, 1}),
+	} {
+		configs, err := parseECHConfigList(marshalECHConfigList(c))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pickECHConfig(configs) != nil {
+			t.Errorf("pickECHConfig accepted unusable config %x", c)
+		}
+	}
+}
+
+// echTestCertificates returns a root pool and a server certificate valid for
+// names, issued by the root.
+func echTestCertificates(t *testing.T, names ...string) (*x509.CertPool, []Certificate) {
+	t.Helper()
+	rootPub, rootPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	rootTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ECH Test Root"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTmpl, rootTmpl, rootPub, rootPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := x509.ParseCertificate(rootDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(root)
+
+	var certs []Certificate
+	for i, name := range names {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(i + 2)),
+			Subject:      pkix.Name{CommonName: name},
+			DNSNames:     []string{name},
+			NotBefore:    now.Add(-time.Hour),
+			NotAfter:     now.Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, root, pub, rootPriv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certs = append(certs, Certificate{Certificate: [][]byte{der}, PrivateKey: priv})
+	}
+	return pool, certs
+}
+
+func echTestKey(t *testing.T, id uint8, sendAsRetry bool) EncryptedClientHelloKey {
+	t.Helper()
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return EncryptedClientHelloKey{
+		Config:      marshalECHConfig(id, priv.PublicKey().Bytes(), "public.example", 32, echCipher{1, 1}, echCipher{1, 3}),
+		PrivateKey:  priv.Bytes(),
+		SendAsRetry: sendAsRetry,
+	}
+}
+
+// echHandshake performs a handshake and returns the client error unwrapped,
+// unlike testHandshake.
+func echHandshake(t *testing.T, clientConfig, serverConfig *Config) (serverState, clientState ConnectionState, clientErr, serverErr error) {
+	t.Helper()
+	c, s := localPipe(t)
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		server := Server(s, serverConfig)
+		serverErr = server.Handshake()
+		if serverErr == nil {
+			serverState = server.ConnectionState()
+			// Wait for the client to finish reading the session tickets.
+			server.Read(make([]byte, 1))
+		}
+		server.Close()
+	}()
+	client := Client(c, clientConfig)
+	clientErr = client.Handshake()
+	if clientErr == nil {
+		clientState = client.ConnectionState()
+	}
+	client.Close()
+	<-done
+	return
+}
+
+func TestECHHandshake(t *testing.T) {
+	roots, certs := echTestCertificates(t, "secret.example", "public.example")
+	key := echTestKey(t, 1, true)
+
+	serverConfig := &Config{
+		Certificates:             certs,
+		EncryptedClientHelloKeys: []EncryptedClientHelloKey{key},
+	}
+	clientConfig := &Config{
+		ServerName:                     "secret.example",
+		RootCAs:                        roots,
+		EncryptedClientHelloConfigList: marshalECHConfigList(key.Config),
+	}
+
+	t.Run("Accepted", func(t *testing.T) {
+		ss, cs, err := testHandshake(t, clientConfig, serverConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ss.ECHAccepted || !cs.ECHAccepted {
+			t.Errorf("ECHAccepted = %v (server), %v (client), want true", ss.ECHAccepted, cs.ECHAccepted)
+		}
+		if ss.ServerName != "secret.example" || cs.ServerName != "secret.example" {
+			t.Errorf("ServerName = %q (server), %q (client), want secret.example", ss.ServerName, cs.ServerName)
+		}
+		if cs.Version != VersionTLS13 {
+			t.Errorf("Version = %x, want TLS 1.3", cs.Version)
+		}
+	})
+
+	t.Run("ServerNameEncrypted", func(t *testing.T) {
+		c, s := localPipe(t)
+		rec := &recordingConn{Conn: c}
+		done := make(chan error, 1)
+		go func() {
+			server := Server(s, serverConfig)
+			done <- server.Handshake()
+			server.Close()
+		}()
+		client := Client(rec, clientConfig)
+		if err := client.Handshake(); err != nil {
+			t.Fatal(err)
+		}
+		client.Close()
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+		clientHello := rec.flows[0]
+		if bytes.Contains(clientHello, []byte("secret.example")) {
+			t.Error("ServerName sent in the clear")
+		}
+		if !bytes.Contains(clientHello, []byte("public.example")) {
+			t.Error("public name not sent")
+		}
+	})
+
+	t.Run("HelloRetryRequest", func(t *testing.T) {
+		serverConfig := serverConfig.Clone()
+		serverConfig.CurvePreferences = []CurveID{CurveP256}
+		clientConfig := clientConfig.Clone()
+		clientConfig.CurvePreferences = []CurveID{X25519, CurveP256}
+		ss, cs, err := testHandshake(t, clientConfig, serverConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ss.ECHAccepted || !cs.ECHAccepted {
+			t.Errorf("ECHAccepted = %v (server), %v (client), want true", ss.ECHAccepted, cs.ECHAccepted)
+		}
+		if ss.ServerName != "secret.example" {
+			t.Errorf("ServerName = %q, want secret.example", ss.ServerName)
+		}
+	})
+
+	t.Run("Resumption", func(t *testing.T) {
+		clientConfig := clientConfig.Clone()
+		clientConfig.ClientSessionCache = NewLRUClientSessionCache(1)
+		for i := range 2 {
+			ss, cs, err := testHandshake(t, clientConfig, serverConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ss.ECHAccepted || !cs.ECHAccepted {
+				t.Errorf("ECHAccepted = %v (server), %v (client), want true", ss.ECHAccepted, cs.ECHAccepted)
+			}
+			if cs.DidResume != (i == 1) {
+				t.Errorf("connection %d: DidResume = %v", i, cs.DidResume)
+			}
+		}
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		retryKey := echTestKey(t, 2, true)
+		serverConfig := serverConfig.Clone()
+		serverConfig.EncryptedClientHelloKeys = []EncryptedClientHelloKey{retryKey, echTestKey(t, 3, false)}
+		ss, _, err, _ := echHandshake(t, clientConfig, serverConfig)
+		var echErr *ECHRejectionError
+		if !errors.As(err, &echErr) {
+			t.Fatalf("client error = %v, want ECHRejectionError", err)
+		}
+		if want := marshalECHConfigList(retryKey.Config); !bytes.Equal(echErr.RetryConfigList, want) {
+			t.Errorf("RetryConfigList = %x, want %x", echErr.RetryConfigList, want)
+		}
+		if ss.ECHAccepted {
+			t.Error("server accepted ECH")
+		}
+
+		// The retry configs can be used for a new connection.
+		clientConfig := clientConfig.Clone()
+		clientConfig.EncryptedClientHelloConfigList = echErr.RetryConfigList
+		_, cs, err := testHandshake(t, clientConfig, serverConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cs.ECHAccepted {
+			t.Error("ECH not accepted with retry configs")
+		}
+	})
+
+	t.Run("RejectedWithoutECHKeys", func(t *testing.T) {
+		serverConfig := serverConfig.Clone()
+		serverConfig.EncryptedClientHelloKeys = nil
+		_, _, err, _ := echHandshake(t, clientConfig, serverConfig)
+		var echErr *ECHRejectionError
+		if !errors.As(err, &echErr) {
+			t.Fatalf("client error = %v, want ECHRejectionError", err)
+		}
+		if echErr.RetryConfigList != nil {
+			t.Errorf("RetryConfigList = %x, want nil", echErr.RetryConfigList)
+		}
+	})
+
+	t.Run("RejectionVerify", func(t *testing.T) {
+		serverConfig := serverConfig.Clone()
+		serverConfig.EncryptedClientHelloKeys = []EncryptedClientHelloKey{echTestKey(t, 2, true)}
+		clientConfig := clientConfig.Clone()
+		clientConfig.RootCAs = x509.NewCertPool()
+		verifyErr := errors.New("rejection verify")
+		var serverName string
+		clientConfig.EncryptedClientHelloRejectionVerify = func(cs ConnectionState) error {
+			serverName = cs.ServerName
+			return verifyErr
+		}
+		_, _, err, _ := echHandshake(t, clientConfig, serverConfig)
+		if err != verifyErr {
+			t.Errorf("client error = %v, want %v", err, verifyErr)
+		}
+		if serverName != "public.example" {
+			t.Errorf("RejectionVerify ServerName = %q, want public.example", serverName)
+		}
+	})
+
+	t.Run("PublicNameMismatch", func(t *testing.T) {
+		// Without EncryptedClientHelloRejectionVerify, the certificate must
+		// be valid for the public name, not for ServerName.
+		serverConfig := serverConfig.Clone()
+		serverConfig.Certificates = certs[:1]
+		serverConfig.EncryptedClientHelloKeys = []EncryptedClientHelloKey{echTestKey(t, 2, true)}
+		_, _, err, _ := echHandshake(t, clientConfig, serverConfig)
+		var certErr *CertificateVerificationError
+		if !errors.As(err, &certErr) {
+			t.Errorf("client error = %v, want CertificateVerificationError", err)
+		}
+	})
+
+	t.Run("MinVersion", func(t *testing.T) {
+		clientConfig := clientConfig.Clone()
+		clientConfig.MinVersion = VersionTLS12
+		if _, _, err, _ := echHandshake(t, clientConfig, serverConfig); err == nil {
+			t.Error("handshake succeeded with MinVersion below TLS 1.3")
+		}
+	})
+}
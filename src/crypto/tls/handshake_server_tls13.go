@@ -9,6 +9,7 @@ import (
 	"context"
 	"crypto"
 	"crypto/hmac"
+	"crypto/internal/mlkem"
 	"crypto/rsa"
 	"encoding/binary"
 	"errors"
@@ -40,6 +41,7 @@ type serverHandshakeStateTLS13 struct {
 	trafficSecret   []byte // client_application_traffic_secret_0
 	transcript      hash.Hash
 	clientFinished  []byte
+	echContext      *echServerContext
 }
 
 func (hs *serverHandshakeStateTLS13) handshake() error {
@@ -182,7 +184,7 @@ func (hs *serverHandshakeStateTLS13) processClientHello() error {
 	var selectedGroup CurveID
 	var clientKeyShare *keyShare
 GroupSelection:
-	for _, preferredGroup := range c.config.curvePreferences() {
+	for _, preferredGroup := range c.config.curvePreferences(c.vers) {
 		for _, ks := range hs.clientHello.keyShares {
 			if ks.group == preferredGroup {
 				selectedGroup = ks.group
@@ -211,17 +213,27 @@ GroupSelection:
 		clientKeyShare = &hs.clientHello.keyShares[0]
 	}
 
-	if _, ok := curveForCurveID(selectedGroup); !ok {
+	ecdhGroup := selectedGroup
+	ecdhData := clientKeyShare.data
+	if selectedGroup == X25519MLKEM768 {
+		ecdhGroup = X25519
+		if len(ecdhData) != mlkem.EncapsulationKeySize768+x25519PublicKeySize {
+			c.sendAlert(alertIllegalParameter)
+			return errors.New("tls: invalid X25519MLKEM768 client key share")
+		}
+		ecdhData = ecdhData[mlkem.EncapsulationKeySize768:]
+	}
+	if _, ok := curveForCurveID(ecdhGroup); !ok {
 		c.sendAlert(alertInternalError)
 		return errors.New("tls: CurvePreferences includes unsupported curve")
 	}
-	key, err := generateECDHEKey(c.config.rand(), selectedGroup)
+	key, err := generateECDHEKey(c.config.rand(), ecdhGroup)
 	if err != nil {
 		c.sendAlert(alertInternalError)
 		return err
 	}
 	hs.hello.serverShare = keyShare{group: selectedGroup, data: key.PublicKey().Bytes()}
-	peerKey, err := key.Curve().NewPublicKey(clientKeyShare.data)
+	peerKey, err := key.Curve().NewPublicKey(ecdhData)
 	if err != nil {
 		c.sendAlert(alertIllegalParameter)
 		return errors.New("tls: invalid client key share")
@@ -231,6 +243,21 @@ GroupSelection:
 		c.sendAlert(alertIllegalParameter)
 		return errors.New("tls: invalid client key share")
 	}
+	if selectedGroup == X25519MLKEM768 {
+		k, err := mlkem.NewEncapsulationKey768(clientKeyShare.data[:mlkem.EncapsulationKeySize768])
+		if err != nil {
+			c.sendAlert(alertIllegalParameter)
+			return errors.New("tls: invalid X25519MLKEM768 client key share")
+		}
+		mlkemSharedSecret, ciphertext := k.Encapsulate()
+		// See draft-kwiatkowski-tls-ecdhe-mlkem-02, Section 3.1.3. The
+		// shared secret is the concatenation of the ML-KEM and the X25519
+		// shared secrets, and the server's key exchange value is the
+		// concatenation of the ML-KEM ciphertext and the X25519 share.
+		hs.sharedKey = append(mlkemSharedSecret, hs.sharedKey...)
+		hs.hello.serverShare.data = append(ciphertext, hs.hello.serverShare.data...)
+	}
+	c.curveID = selectedGroup
 
 	selectedProto, err := negotiateALPN(c.config.NextProtos, hs.clientHello.alpnProtocols, c.quic != nil)
 	if err != nil {
@@ -497,6 +524,20 @@ func (hs *serverHandshakeStateTLS13) doHelloRetryRequest(selectedGroup CurveID)
 		selectedGroup:     selectedGroup,
 	}
 
+	if hs.echContext != nil {
+		// The acceptance confirmation is computed over the transcript with
+		// the HelloRetryRequest carrying a zeroed confirmation in its
+		// encrypted_client_hello extension. See draft-ietf-tls-esni-18,
+		// Section 7.2.1.
+		helloRetryRequest.encryptedClientHello = make([]byte, 8)
+		confTranscript := cloneHash(hs.transcript, hs.suite.hash)
+		if err := transcriptMsg(helloRetryRequest, confTranscript); err != nil {
+			return err
+		}
+		helloRetryRequest.encryptedClientHello = hs.suite.expandLabel(hs.suite.extract(hs.clientHello.random, nil),
+			"hrr ech accept confirmation", confTranscript.Sum(nil), 8)
+	}
+
 	if _, err := hs.c.writeHandshakeRecord(helloRetryRequest, hs.transcript); err != nil {
 		return err
 	}
@@ -517,6 +558,36 @@ func (hs *serverHandshakeStateTLS13) doHelloRetryRequest(selectedGroup CurveID)
 		return unexpectedMessageError(clientHello, msg)
 	}
 
+	if hs.echContext != nil {
+		// The second ClientHelloOuter must carry a ClientHelloInner
+		// encrypted with the same HPKE context. See draft-ietf-tls-esni-18,
+		// Section 7.1.1.
+		if len(clientHello.encryptedClientHello) == 0 {
+			c.sendAlert(alertMissingExtension)
+			return errors.New("tls: second client hello missing encrypted client hello extension")
+		}
+		echType, echCiphersuite, configID, encap, payload, err := parseECHExt(clientHello.encryptedClientHello)
+		if err != nil {
+			c.sendAlert(alertDecodeError)
+			return err
+		}
+		if echType != outerECHExt || echCiphersuite != hs.echContext.ciphersuite ||
+			configID != hs.echContext.configID || len(encap) != 0 {
+			c.sendAlert(alertIllegalParameter)
+			return errors.New("tls: second client hello encrypted client hello extension does not match")
+		}
+		encodedInner, err := decryptECHPayload(hs.echContext.hpkeContext, clientHello.original, payload)
+		if err != nil {
+			c.sendAlert(alertDecryptError)
+			return errors.New("tls: failed to decrypt second client hello encrypted client hello extension payload")
+		}
+		clientHello, err = decodeInnerClientHello(clientHello, encodedInner)
+		if err != nil {
+			c.sendAlert(alertIllegalParameter)
+			return err
+		}
+	}
+
 	if len(clientHello.keyShares) != 1 || clientHello.keyShares[0].group != selectedGroup {
 		c.sendAlert(alertIllegalParameter)
 		return errors.New("tls: client sent invalid key share in second ClientHello")
@@ -597,6 +668,23 @@ func illegalClientHelloChange(ch, ch1 *clientHelloMsg) bool {
 func (hs *serverHandshakeStateTLS13) sendServerParameters() error {
 	c := hs.c
 
+	if hs.echContext != nil {
+		// Signal acceptance of ECH with the last 8 bytes of the random,
+		// computed over the transcript with them zeroed. See
+		// draft-ietf-tls-esni-18, Section 7.2.
+		copy(hs.hello.random[32-8:], make([]byte, 8))
+		echTranscript := cloneHash(hs.transcript, hs.suite.hash)
+		if err := transcriptMsg(hs.clientHello, echTranscript); err != nil {
+			return err
+		}
+		if err := transcriptMsg(hs.hello, echTranscript); err != nil {
+			return err
+		}
+		acceptConfirmation := hs.suite.expandLabel(hs.suite.extract(hs.clientHello.random, nil),
+			"ech accept confirmation", echTranscript.Sum(nil), 8)
+		copy(hs.hello.random[32-8:], acceptConfirmation)
+	}
+
 	if err := transcriptMsg(hs.clientHello, hs.transcript); err != nil {
 		return err
 	}
@@ -653,6 +741,16 @@ func (hs *serverHandshakeStateTLS13) sendServerParameters() error {
 		encryptedExtensions.earlyData = hs.earlyData
 	}
 
+	// If the client offered ECH but it was not accepted, send the retry
+	// configs, if any.
+	if len(c.config.EncryptedClientHelloKeys) > 0 && len(hs.clientHello.encryptedClientHello) > 0 && hs.echContext == nil {
+		encryptedExtensions.echRetryConfigs, err = buildRetryConfigList(c.config.EncryptedClientHelloKeys)
+		if err != nil {
+			c.sendAlert(alertInternalError)
+			return err
+		}
+	}
+
 	if _, err := hs.c.writeHandshakeRecord(encryptedExtensions, hs.transcript); err != nil {
 		return err
 	}
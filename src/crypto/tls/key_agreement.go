@@ -159,6 +159,7 @@ type ecdheKeyAgreement struct {
 	version uint16
 	isRSA   bool
 	key     *ecdh.PrivateKey
+	curveID CurveID
 
 	// ckx and preMasterSecret are generated in processServerKeyExchange
 	// and returned in generateClientKeyExchange.
@@ -169,7 +170,7 @@ type ecdheKeyAgreement struct {
 func (ka *ecdheKeyAgreement) generateServerKeyExchange(config *Config, cert *Certificate, clientHello *clientHelloMsg, hello *serverHelloMsg) (*serverKeyExchangeMsg, error) {
 	var curveID CurveID
 	for _, c := range clientHello.supportedCurves {
-		if config.supportsCurve(c) {
+		if config.supportsCurve(ka.version, c) {
 			curveID = c
 			break
 		}
@@ -187,6 +188,7 @@ func (ka *ecdheKeyAgreement) generateServerKeyExchange(config *Config, cert *Cer
 		return nil, err
 	}
 	ka.key = key
+	ka.curveID = curveID
 
 	// See RFC 4492, Section 5.4.
 	ecdhePublic := key.PublicKey().Bytes()
@@ -302,6 +304,7 @@ func (ka *ecdheKeyAgreement) processServerKeyExchange(config *Config, clientHell
 		return err
 	}
 	ka.key = key
+	ka.curveID = curveID
 
 	peerKey, err := key.Curve().NewPublicKey(publicKey)
 	if err != nil {
@@ -7,6 +7,7 @@ package tls
 import (
 	"crypto/ecdh"
 	"crypto/hmac"
+	"crypto/internal/mlkem"
 	"errors"
 	"fmt"
 	"hash"
@@ -117,6 +118,55 @@ func (c *cipherSuiteTLS13) exportKeyingMaterial(masterSecret []byte, transcript
 	}
 }
 
+// x25519PublicKeySize is the size of an X25519 public key, and of the X25519
+// part of an X25519MLKEM768 key share.
+const x25519PublicKeySize = 32
+
+// keySharePrivateKeys holds the private keys for a key share sent by the
+// client. For X25519MLKEM768, ecdhe is the X25519 key and mlkem is set.
+type keySharePrivateKeys struct {
+	curveID CurveID
+	ecdhe   *ecdh.PrivateKey
+	mlkem   *mlkem.DecapsulationKey768
+}
+
+// generateKeyShare generates the private keys and the key_share entry for
+// curveID, according to RFC 8446, Section 4.2.8, and for X25519MLKEM768
+// according to draft-kwiatkowski-tls-ecdhe-mlkem-02, Section 3.1.2.
+func generateKeyShare(rand io.Reader, curveID CurveID) (*keySharePrivateKeys, keyShare, error) {
+	keys := &keySharePrivateKeys{curveID: curveID}
+	if curveID != X25519MLKEM768 {
+		if _, ok := curveForCurveID(curveID); !ok {
+			return nil, keyShare{}, errors.New("tls: CurvePreferences includes unsupported curve")
+		}
+		key, err := generateECDHEKey(rand, curveID)
+		if err != nil {
+			return nil, keyShare{}, err
+		}
+		keys.ecdhe = key
+		return keys, keyShare{group: curveID, data: key.PublicKey().Bytes()}, nil
+	}
+
+	key, err := generateECDHEKey(rand, X25519)
+	if err != nil {
+		return nil, keyShare{}, err
+	}
+	keys.ecdhe = key
+	seed := make([]byte, mlkem.SeedSize)
+	if _, err := io.ReadFull(rand, seed); err != nil {
+		return nil, keyShare{}, err
+	}
+	keys.mlkem, err = mlkem.NewDecapsulationKey768(seed)
+	if err != nil {
+		return nil, keyShare{}, err
+	}
+	// The client's key exchange value is the concatenation of the ML-KEM
+	// encapsulation key and the X25519 ephemeral share.
+	data := keys.mlkem.EncapsulationKey().Bytes()
+	data = append(data, key.PublicKey().Bytes()...)
+	return keys, keyShare{group: curveID, data: data}, nil
+}
+
 // generateECDHEKey returns a PrivateKey that implements Diffie-Hellman
 // according to RFC 8446, Section 4.2.8.2.
 func generateECDHEKey(rand io.Reader, curveID CurveID) (*ecdh.PrivateKey, error) {
@@ -142,18 +192,3 @@ func curveForCurveID(id CurveID) (ecdh.Curve, bool) {
 		return nil, false
 	}
 }
-
-func curveIDForCurve(curve ecdh.Curve) (CurveID, bool) {
-	switch curve {
-	case ecdh.X25519():
-		return X25519, true
-	case ecdh.P256():
-		return CurveP256, true
-	case ecdh.P384():
-		return CurveP384, true
-	case ecdh.P521():
-		return CurveP521, true
-	default:
-		return 0, false
-	}
-}
@@ -4,5 +4,171 @@
 
 package tls
 
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
 var _ = &Config{WrapSession: (&Config{}).EncryptTicket}
 var _ = &Config{UnwrapSession: (&Config{}).DecryptTicket}
+
+// memTicketKeyStore is an in-memory TicketKeyStore shared by the servers of a
+// test, which can be made to fail.
+type memTicketKeyStore struct {
+	mu   sync.Mutex
+	keys []TicketKey
+	fail bool
+}
+
+func (s *memTicketKeyStore) LoadTicketKeys() ([]TicketKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return nil, errors.New("store unavailable")
+	}
+	return slices.Clone(s.keys), nil
+}
+
+func (s *memTicketKeyStore) AddTicketKey(key TicketKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return errors.New("store unavailable")
+	}
+	s.keys = append(s.keys, key)
+	return nil
+}
+
+func TestTicketKeyManager(t *testing.T) {
+	t.Run("TLSv12", func(t *testing.T) { testTicketKeyManager(t, VersionTLS12) })
+	t.Run("TLSv13", func(t *testing.T) { testTicketKeyManager(t, VersionTLS13) })
+}
+
+func testTicketKeyManager(t *testing.T, version uint16) {
+	store := &memTicketKeyStore{}
+	now := testConfig.Time()
+	newServer := func() (*Config, *TicketKeyManager) {
+		m := &TicketKeyManager{
+			Store:            store,
+			RotationInterval: time.Hour,
+			KeyLifetime:      3 * time.Hour,
+		}
+		config := testConfig.Clone()
+		config.Rand = nil
+		config.Time = func() time.Time { return now }
+		config.MaxVersion = version
+		config.TicketKeyManager = m
+		return config, m
+	}
+	serverA, managerA := newServer()
+	serverB, managerB := newServer()
+
+	clientConfig := testConfig.Clone()
+	clientConfig.Time = func() time.Time { return now }
+	clientConfig.MaxVersion = version
+	clientConfig.ServerName = "example.golang"
+	clientConfig.ClientSessionCache = NewLRUClientSessionCache(32)
+
+	testResumeState := func(test string, server *Config, didResume bool) {
+		t.Helper()
+		_, cs, err := testHandshake(t, clientConfig, server)
+		if err != nil {
+			t.Fatalf("%s: handshake failed: %s", test, err)
+		}
+		if cs.DidResume != didResume {
+			t.Fatalf("%s: resumed: %v, expected: %v", test, cs.DidResume, didResume)
+		}
+	}
+
+	testResumeState("Handshake", serverA, false)
+	testResumeState("ResumeOnOtherServer", serverB, true)
+	if len(store.keys) != 1 {
+		t.Fatalf("got %d keys in store, want 1", len(store.keys))
+	}
+
+	// After the rotation interval, the first server to notice creates a new
+	// key, and the other one picks it up from the store.
+	now = now.Add(90 * time.Minute)
+	testResumeState("ResumeAfterRotation", serverB, true)
+	testResumeState("ResumeWithRotatedKey", serverA, true)
+	if len(store.keys) != 2 {
+		t.Fatalf("got %d keys in store, want 2", len(store.keys))
+	}
+
+	now = now.Add(4 * time.Hour)
+	testResumeState("ExpiredKey", serverA, false)
+
+	if got, want := managerA.Stats(), (ResumptionStats{Handshakes: 3, Attempts: 2, Resumptions: 1}); got != want {
+		t.Errorf("server A stats: got %+v, want %+v", got, want)
+	}
+	if got, want := managerB.Stats(), (ResumptionStats{Handshakes: 2, Attempts: 2, Resumptions: 2}); got != want {
+		t.Errorf("server B stats: got %+v, want %+v", got, want)
+	}
+	if got := managerA.Stats().HitRate(); got != 0.5 {
+		t.Errorf("server A hit rate: got %v, want 0.5", got)
+	}
+
+	// If the store fails, a server still uses a local key.
+	store.fail = true
+	serverC, managerC := newServer()
+	var storeErrs []error
+	managerC.StoreError = func(err error) { storeErrs = append(storeErrs, err) }
+	testResumeState("HandshakeWithFailingStore", serverC, false)
+	testResumeState("ResumeWithFailingStore", serverC, true)
+	if len(storeErrs) != 2 {
+		t.Errorf("got store errors %v, want a load and an add error", storeErrs)
+	}
+}
+
+// blockingTicketKeyStore is a TicketKeyStore whose LoadTicketKeys blocks
+// until unblock is closed.
+type blockingTicketKeyStore struct {
+	memTicketKeyStore
+	loading chan struct{}
+	unblock chan struct{}
+}
+
+func (s *blockingTicketKeyStore) LoadTicketKeys() ([]TicketKey, error) {
+	s.loading <- struct{}{}
+	<-s.unblock
+	return s.memTicketKeyStore.LoadTicketKeys()
+}
+
+func TestTicketKeyManagerRefresh(t *testing.T) {
+	store := &blockingTicketKeyStore{loading: make(chan struct{}), unblock: make(chan struct{})}
+	m := &TicketKeyManager{Store: store, RefreshInterval: time.Minute}
+	now := time.Now()
+	config := &Config{Time: func() time.Time { return now }}
+
+	// The first refresh has no keys to fall back to, so it is waited for.
+	first := make(chan []ticketKey)
+	go func() { first <- m.ticketKeys(config) }()
+	<-store.loading
+	waiting := make(chan []ticketKey)
+	go func() { waiting <- m.ticketKeys(config) }()
+	close(store.unblock)
+	keys := <-first
+	if len(keys) != 1 {
+		t.Fatalf("got %d keys, want 1", len(keys))
+	}
+	if got := <-waiting; len(got) != 1 || got[0] != keys[0] {
+		t.Errorf("concurrent handshake got different keys")
+	}
+
+	// Later refreshes don't block the other handshakes.
+	now = now.Add(2 * time.Minute)
+	store.unblock = make(chan struct{})
+	refreshed := make(chan []ticketKey)
+	go func() { refreshed <- m.ticketKeys(config) }()
+	<-store.loading
+	if got := m.ticketKeys(config); len(got) != 1 || got[0] != keys[0] {
+		t.Errorf("handshake during a refresh didn't get the current keys")
+	}
+	close(store.unblock)
+	if got := <-refreshed; len(got) != 1 || got[0] != keys[0] {
+		t.Errorf("refresh didn't load the stored key")
+	}
+}
@@ -0,0 +1,272 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A TicketKey is a session ticket key along with the time it was created.
+type TicketKey struct {
+	// Key is the secret key material, like the keys passed to
+	// [Config.SetSessionTicketKeys].
+	Key [32]byte
+	// Created is the time at which the key was created, which determines
+	// when it is replaced and when it expires.
+	Created time.Time
+}
+
+// A TicketKeyStore holds the session ticket keys of a [TicketKeyManager],
+// so that they can be shared by multiple servers terminating connections for
+// the same host. Implementations could be backed by a database or a
+// distributed key-value store, and must be safe for concurrent use.
+//
+// The methods are called during a handshake, at most once per
+// [TicketKeyManager.RefreshInterval] for each server, and delay that
+// handshake, while the other ones keep using the current keys. They should
+// not block for long. Since the store holds secret keys, it should be
+// protected like any other private key.
+type TicketKeyStore interface {
+	// LoadTicketKeys returns all the keys in the store, in any order.
+	LoadTicketKeys() ([]TicketKey, error)
+
+	// AddTicketKey adds a new key to the store. Keys older than the
+	// KeyLifetime of the TicketKeyManagers using the store are not used
+	// anymore, and may be deleted.
+	AddTicketKey(key TicketKey) error
+}
+
+// A TicketKeyManager manages the session ticket keys used by a server to
+// encrypt and decrypt session tickets, when set in [Config.TicketKeyManager].
+//
+// A new key is created for encrypting tickets every RotationInterval, and
+// keys can decrypt tickets for KeyLifetime after they are created. If Store
+// is set, keys are created and loaded through it, so that all the servers
+// using the same store can resume each other's sessions. Otherwise, the keys
+// are only kept in memory, like the automatically rotated keys used when no
+// keys or manager are configured.
+//
+// A TicketKeyManager also keeps statistics on session resumption for the
+// server connections that use it. See [TicketKeyManager.Stats].
+//
+// A TicketKeyManager must not be copied after first use, and is safe for
+// concurrent use by multiple Configs and connections.
+type TicketKeyManager struct {
+	// Store, if not nil, is where keys are loaded from and added to.
+	Store TicketKeyStore
+
+	// RotationInterval is how often a new key is created to encrypt tickets.
+	// If zero, it defaults to 24 hours.
+	RotationInterval time.Duration
+
+	// KeyLifetime is how long a key can be used to decrypt tickets after it
+	// is created. It should be longer than RotationInterval, or tickets will
+	// stop being accepted before the key that encrypted them is replaced.
+	// If zero, it defaults to 7 days.
+	KeyLifetime time.Duration
+
+	// RefreshInterval is how often keys are reloaded from Store, and
+	// therefore how long a key created by another server can take to be
+	// used by this one. If zero, it defaults to one minute.
+	RefreshInterval time.Duration
+
+	// StoreError, if not nil, is called with the errors returned by Store.
+	// The previously loaded keys keep being used in that case, and a key
+	// that can't be added to the store is only used by this server.
+	StoreError func(error)
+
+	mu         sync.RWMutex
+	known      []TicketKey // sorted by creation time, newest first
+	keys       []ticketKey // expanded from known
+	loadedAt   time.Time
+	refreshing chan struct{} // closed when the refresh in progress is done
+
+	handshakes  atomic.Uint64
+	attempts    atomic.Uint64
+	resumptions atomic.Uint64
+}
+
+// ResumptionStats are counters of the server handshakes completed with a
+// [TicketKeyManager].
+type ResumptionStats struct {
+	// Handshakes is the number of completed handshakes.
+	Handshakes uint64
+	// Attempts is the number of completed handshakes in which the client
+	// offered a session ticket.
+	Attempts uint64
+	// Resumptions is the number of completed handshakes that resumed a
+	// session, and for which [ConnectionState.DidResume] is true.
+	Resumptions uint64
+}
+
+// HitRate returns the fraction of resumption attempts that succeeded, or zero
+// if there were none. A low hit rate with a shared Store can indicate that
+// servers are not sharing keys, or that KeyLifetime is too short.
+func (s ResumptionStats) HitRate() float64 {
+	if s.Attempts == 0 {
+		return 0
+	}
+	return float64(s.Resumptions) / float64(s.Attempts)
+}
+
+// Stats returns the resumption statistics of the server connections that
+// used m.
+func (m *TicketKeyManager) Stats() ResumptionStats {
+	return ResumptionStats{
+		Handshakes:  m.handshakes.Load(),
+		Attempts:    m.attempts.Load(),
+		Resumptions: m.resumptions.Load(),
+	}
+}
+
+func (m *TicketKeyManager) recordHandshake(attempted, resumed bool) {
+	m.handshakes.Add(1)
+	if attempted {
+		m.attempts.Add(1)
+	}
+	if resumed {
+		m.resumptions.Add(1)
+	}
+}
+
+func (m *TicketKeyManager) rotationInterval() time.Duration {
+	if m.RotationInterval == 0 {
+		return ticketKeyRotation
+	}
+	return m.RotationInterval
+}
+
+func (m *TicketKeyManager) keyLifetime() time.Duration {
+	if m.KeyLifetime == 0 {
+		return ticketKeyLifetime
+	}
+	return m.KeyLifetime
+}
+
+func (m *TicketKeyManager) refreshInterval() time.Duration {
+	if m.RefreshInterval == 0 {
+		return time.Minute
+	}
+	return m.RefreshInterval
+}
+
+// fresh reports whether the keys can be used without reloading or rotating
+// them. It must be called with m.mu held.
+func (m *TicketKeyManager) fresh(now time.Time) bool {
+	if len(m.keys) == 0 || now.Sub(m.known[0].Created) >= m.rotationInterval() {
+		return false
+	}
+	return m.Store == nil || now.Sub(m.loadedAt) < m.refreshInterval()
+}
+
+// ticketKeys returns the current keys, with the one to encrypt new tickets
+// first, using the Rand and Time of config.
+//
+// Only one handshake at a time refreshes the keys, without holding m.mu
+// while it calls Store, and the others keep using the current keys
+// meanwhile, or wait for it if there are none. If Store fails, the keys
+// loaded previously keep being used until the next refresh, and a key
+// created locally is used if there are none, so that session tickets keep
+// working on this server.
+func (m *TicketKeyManager) ticketKeys(config *Config) []ticketKey {
+	now := config.time()
+	m.mu.RLock()
+	if m.fresh(now) {
+		defer m.mu.RUnlock()
+		return m.keys
+	}
+	m.mu.RUnlock()
+
+	m.mu.Lock()
+	for {
+		// Re-check the condition in case it changed since obtaining the new lock.
+		if m.fresh(now) || m.refreshing != nil && len(m.keys) > 0 {
+			keys := m.keys
+			m.mu.Unlock()
+			return keys
+		}
+		if m.refreshing == nil {
+			break
+		}
+		done := m.refreshing
+		m.mu.Unlock()
+		<-done
+		m.mu.Lock()
+	}
+	done := make(chan struct{})
+	m.refreshing = done
+	known := m.known
+	load := m.Store != nil && now.Sub(m.loadedAt) >= m.refreshInterval()
+	if load {
+		// Wait until the next refresh to retry on errors, rather than
+		// hammering a failing store on every handshake.
+		m.loadedAt = now
+	}
+	m.mu.Unlock()
+
+	var valid []TicketKey
+	var keys []ticketKey
+	defer func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if keys != nil {
+			m.known, m.keys = valid, keys
+		}
+		m.refreshing = nil
+		close(done)
+	}()
+
+	if load {
+		if loaded, err := m.Store.LoadTicketKeys(); err != nil {
+			m.storeError(fmt.Errorf("tls: loading session ticket keys: %w", err))
+		} else {
+			known = loaded
+		}
+	}
+
+	valid = make([]TicketKey, 0, len(known)+1)
+	for _, k := range known {
+		if now.Sub(k.Created) < m.keyLifetime() {
+			valid = append(valid, k)
+		}
+	}
+	slices.SortStableFunc(valid, func(a, b TicketKey) int {
+		return b.Created.Compare(a.Created)
+	})
+
+	if len(valid) == 0 || now.Sub(valid[0].Created) >= m.rotationInterval() {
+		newKey := TicketKey{Created: now}
+		if _, err := io.ReadFull(config.rand(), newKey.Key[:]); err != nil {
+			panic(fmt.Sprintf("tls: unable to generate random session ticket key: %v", err))
+		}
+		if m.Store != nil {
+			// If the key can't be stored, it's still used here, but other
+			// servers won't be able to resume sessions from its tickets.
+			if err := m.Store.AddTicketKey(newKey); err != nil {
+				m.storeError(fmt.Errorf("tls: adding session ticket key: %w", err))
+			}
+		}
+		valid = slices.Insert(valid, 0, newKey)
+	}
+
+	keys = make([]ticketKey, 0, len(valid))
+	for _, k := range valid {
+		key := config.ticketKeyFromBytes(k.Key)
+		key.created = k.Created
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (m *TicketKeyManager) storeError(err error) {
+	if m.StoreError != nil {
+		m.StoreError(err)
+	}
+}
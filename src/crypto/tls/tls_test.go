@@ -8,6 +8,10 @@ import (
 	"bytes"
 	"context"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/json"
 	"errors"
@@ -22,6 +26,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"golang.org/x/crypto/cryptobyte"
 )
 
 var rsaCertPEM = `-----BEGIN CERTIFICATE-----
@@ -764,7 +770,7 @@ func TestWarningAlertFlood(t *testing.T) {
 }
 
 func TestCloneFuncFields(t *testing.T) {
-	const expectedCount = 8
+	const expectedCount = 9
 	called := 0
 
 	c1 := Config{
@@ -800,6 +806,10 @@ func TestCloneFuncFields(t *testing.T) {
 			called |= 1 << 7
 			return nil, nil
 		},
+		EncryptedClientHelloRejectionVerify: func(ConnectionState) error {
+			called |= 1 << 8
+			return nil
+		},
 	}
 
 	c2 := c1.Clone()
@@ -812,6 +822,7 @@ func TestCloneFuncFields(t *testing.T) {
 	c2.VerifyConnection(ConnectionState{})
 	c2.UnwrapSession(nil, ConnectionState{})
 	c2.WrapSession(ConnectionState{}, nil)
+	c2.EncryptedClientHelloRejectionVerify(ConnectionState{})
 
 	if called != (1<<expectedCount)-1 {
 		t.Fatalf("expected %d calls but saw calls %b", expectedCount, called)
@@ -830,7 +841,7 @@ func TestCloneNonFuncFields(t *testing.T) {
 		switch fn := typ.Field(i).Name; fn {
 		case "Rand":
 			f.Set(reflect.ValueOf(io.Reader(os.Stdin)))
-		case "Time", "GetCertificate", "GetConfigForClient", "VerifyPeerCertificate", "VerifyConnection", "GetClientCertificate", "WrapSession", "UnwrapSession":
+		case "Time", "GetCertificate", "GetConfigForClient", "VerifyPeerCertificate", "VerifyConnection", "GetClientCertificate", "WrapSession", "UnwrapSession", "EncryptedClientHelloRejectionVerify":
 			// DeepEqual can't compare functions. If you add a
 			// function field to this list, you must also change
 			// TestCloneFuncFields to ensure that the func field is
@@ -843,8 +854,12 @@ func TestCloneNonFuncFields(t *testing.T) {
 			f.Set(reflect.ValueOf(map[string]*Certificate{"a": nil}))
 		case "RootCAs", "ClientCAs":
 			f.Set(reflect.ValueOf(x509.NewCertPool()))
+		case "CertificateTransparency":
+			f.Set(reflect.ValueOf(&x509.CTPolicy{MinSCTs: 1}))
 		case "ClientSessionCache":
 			f.Set(reflect.ValueOf(NewLRUClientSessionCache(10)))
+		case "TicketKeyManager":
+			f.Set(reflect.ValueOf(&TicketKeyManager{RotationInterval: time.Hour}))
 		case "KeyLogWriter":
 			f.Set(reflect.ValueOf(io.Writer(os.Stdout)))
 		case "NextProtos":
@@ -865,6 +880,12 @@ func TestCloneNonFuncFields(t *testing.T) {
 			f.Set(reflect.ValueOf([]CurveID{CurveP256}))
 		case "Renegotiation":
 			f.Set(reflect.ValueOf(RenegotiateOnceAsClient))
+		case "EncryptedClientHelloConfigList":
+			f.Set(reflect.ValueOf([]byte{'x'}))
+		case "EncryptedClientHelloKeys":
+			f.Set(reflect.ValueOf([]EncryptedClientHelloKey{
+				{Config: []byte{1}, PrivateKey: []byte{1}},
+			}))
 		case "mutex", "autoSessionTicketKeys", "sessionTicketKeys":
 			continue // these are unexported fields that are handled separately
 		default:
@@ -1805,3 +1826,209 @@ func testVerifyCertificates(t *testing.T, version uint16) {
 		})
 	}
 }
+
+func TestHandshakeMLKEM(t *testing.T) {
+	var tests = []struct {
+		name           string
+		clientConfig   func(*Config)
+		serverConfig   func(*Config)
+		expectClient   []CurveID
+		expectSelected CurveID
+	}{
+		{
+			name:           "Default",
+			expectClient:   defaultCurvePreferences,
+			expectSelected: X25519,
+		},
+		{
+			name: "Both",
+			clientConfig: func(config *Config) {
+				config.CurvePreferences = []CurveID{X25519MLKEM768, X25519}
+			},
+			serverConfig: func(config *Config) {
+				config.CurvePreferences = []CurveID{X25519MLKEM768, X25519}
+			},
+			expectClient:   []CurveID{X25519MLKEM768, X25519},
+			expectSelected: X25519MLKEM768,
+		},
+		{
+			name: "ClientOnly",
+			clientConfig: func(config *Config) {
+				config.CurvePreferences = []CurveID{X25519MLKEM768, X25519}
+			},
+			expectClient:   []CurveID{X25519MLKEM768, X25519},
+			expectSelected: X25519,
+		},
+		{
+			name: "ClientOnlyHRR",
+			clientConfig: func(config *Config) {
+				config.CurvePreferences = []CurveID{X25519MLKEM768, CurveP256}
+			},
+			expectClient:   []CurveID{X25519MLKEM768, CurveP256},
+			expectSelected: CurveP256,
+		},
+		{
+			name: "ServerOnlyHRR",
+			clientConfig: func(config *Config) {
+				config.CurvePreferences = []CurveID{X25519, X25519MLKEM768}
+			},
+			serverConfig: func(config *Config) {
+				config.CurvePreferences = []CurveID{X25519MLKEM768}
+			},
+			expectClient:   []CurveID{X25519, X25519MLKEM768},
+			expectSelected: X25519MLKEM768,
+		},
+		{
+			name: "ClientTLSv12",
+			clientConfig: func(config *Config) {
+				config.CurvePreferences = []CurveID{X25519MLKEM768, X25519}
+				config.MaxVersion = VersionTLS12
+			},
+			serverConfig: func(config *Config) {
+				config.CurvePreferences = []CurveID{X25519MLKEM768, X25519}
+			},
+			expectClient:   []CurveID{X25519},
+			expectSelected: X25519,
+		},
+		{
+			name: "ServerTLSv12",
+			clientConfig: func(config *Config) {
+				config.CurvePreferences = []CurveID{X25519MLKEM768, X25519}
+			},
+			serverConfig: func(config *Config) {
+				config.CurvePreferences = []CurveID{X25519MLKEM768, X25519}
+				config.MaxVersion = VersionTLS12
+			},
+			expectClient:   []CurveID{X25519MLKEM768, X25519},
+			expectSelected: X25519,
+		},
+	}
+
+	baseConfig := testConfig.Clone()
+	baseConfig.CurvePreferences = nil
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			serverConfig := baseConfig.Clone()
+			if test.serverConfig != nil {
+				test.serverConfig(serverConfig)
+			}
+			serverConfig.GetConfigForClient = func(hello *ClientHelloInfo) (*Config, error) {
+				if !reflect.DeepEqual(hello.SupportedCurves, test.expectClient) {
+					t.Errorf("got client curves %v, expected %v", hello.SupportedCurves, test.expectClient)
+				}
+				return nil, nil
+			}
+			clientConfig := baseConfig.Clone()
+			if test.clientConfig != nil {
+				test.clientConfig(clientConfig)
+			}
+			ss, cs, err := testHandshake(t, clientConfig, serverConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ss.CurveID != test.expectSelected {
+				t.Errorf("server selected curve %v, expected %v", ss.CurveID, test.expectSelected)
+			}
+			if cs.CurveID != test.expectSelected {
+				t.Errorf("client selected curve %v, expected %v", cs.CurveID, test.expectSelected)
+			}
+		})
+	}
+}
+
+// testSCT returns an SCT for the DER certificate cert from the CT log with
+// the given ECDSA P-256 key, as delivered in the TLS extension.
+func testSCT(t *testing.T, logKey *ecdsa.PrivateKey, cert []byte) []byte {
+	t.Helper()
+	spki, err := x509.MarshalPKIXPublicKey(&logKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logID := sha256.Sum256(spki)
+	timestamp := uint64(time.Now().Add(-time.Minute).UnixMilli())
+
+	var signed cryptobyte.Builder
+	signed.AddUint8(0) // v1
+	signed.AddUint8(0) // certificate_timestamp
+	signed.AddUint64(timestamp)
+	signed.AddUint16(0) // x509_entry
+	signed.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(cert)
+	})
+	signed.AddUint16(0) // no extensions
+	h := sha256.Sum256(signed.BytesOrPanic())
+	sig, err := ecdsa.SignASN1(rand.Reader, logKey, h[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b cryptobyte.Builder
+	b.AddUint8(0) // v1
+	b.AddBytes(logID[:])
+	b.AddUint64(timestamp)
+	b.AddUint16(0) // no extensions
+	b.AddUint8(4)  // sha256
+	b.AddUint8(3)  // ecdsa
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(sig)
+	})
+	return b.BytesOrPanic()
+}
+
+func TestCertificateTransparency(t *testing.T) {
+	roots, certs := echTestCertificates(t, "ct.example")
+	logKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherLogKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := &x509.CTPolicy{
+		Logs:    []*x509.CTLog{{PublicKey: &logKey.PublicKey}},
+		MinSCTs: 1,
+	}
+
+	for _, v := range []uint16{VersionTLS12, VersionTLS13} {
+		t.Run(VersionName(v), func(t *testing.T) {
+			for _, test := range []struct {
+				name string
+				scts [][]byte
+				ok   bool
+			}{
+				{"Valid", [][]byte{testSCT(t, logKey, certs[0].Certificate[0])}, true},
+				{"None", nil, false},
+				{"UnknownLog", [][]byte{testSCT(t, otherLogKey, certs[0].Certificate[0])}, false},
+			} {
+				t.Run(test.name, func(t *testing.T) {
+					serverConfig := testConfig.Clone()
+					serverConfig.MaxVersion = v
+					serverConfig.Certificates = []Certificate{certs[0]}
+					serverConfig.Certificates[0].SignedCertificateTimestamps = test.scts
+					clientConfig := testConfig.Clone()
+					clientConfig.InsecureSkipVerify = false
+					clientConfig.Time = time.Now
+					clientConfig.RootCAs = roots
+					clientConfig.ServerName = "ct.example"
+					clientConfig.CertificateTransparency = policy
+
+					_, cs, clientErr, _ := echHandshake(t, clientConfig, serverConfig)
+					if !test.ok {
+						var invErr x509.CertificateInvalidError
+						if !errors.As(clientErr, &invErr) || invErr.Reason != x509.InsufficientSCTs {
+							t.Fatalf("got error %v, want x509.CertificateInvalidError with reason InsufficientSCTs", clientErr)
+						}
+						return
+					}
+					if clientErr != nil {
+						t.Fatal(clientErr)
+					}
+					if len(cs.SignedCertificateTimestamps) != 1 {
+						t.Errorf("got %d SCTs in ConnectionState, want 1", len(cs.SignedCertificateTimestamps))
+					}
+				})
+			}
+		})
+	}
+}
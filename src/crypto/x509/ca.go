@@ -0,0 +1,334 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"slices"
+	"time"
+)
+
+var oidAnyPolicy = asn1.ObjectIdentifier{2, 5, 29, 32, 0}
+
+// A CertificateAuthority issues certificates signed by a CA certificate,
+// applying an [IssuanceProfile] to the contents of certificate requests.
+//
+// Unlike [CreateCertificate], which signs whatever its template describes,
+// a CertificateAuthority only copies the subject, public key and subject
+// alternative names from a request, fills in the serial number and key
+// identifiers, and refuses to issue certificates that would not chain to
+// the CA certificate under the rules applied by [Certificate.Verify].
+//
+// A CertificateAuthority is safe for concurrent use if its signer is.
+type CertificateAuthority struct {
+	cert   *Certificate
+	signer crypto.Signer
+}
+
+// NewCertificateAuthority returns a CertificateAuthority that issues
+// certificates signed by signer on behalf of cert.
+//
+// cert must be a CA certificate, as indicated by its basic constraints, that
+// is allowed to sign certificates, and signer's public key must match the
+// public key of cert. signer may be backed by a hardware module or a remote
+// service; it is only used through its Sign method.
+func NewCertificateAuthority(cert *Certificate, signer crypto.Signer) (*CertificateAuthority, error) {
+	if cert == nil || signer == nil {
+		return nil, errors.New("x509: CertificateAuthority requires a certificate and a signer")
+	}
+	if !cert.BasicConstraintsValid || !cert.IsCA {
+		return nil, errors.New("x509: certificate is not a CA certificate")
+	}
+	if cert.KeyUsage != 0 && cert.KeyUsage&KeyUsageCertSign == 0 {
+		return nil, errors.New("x509: CA certificate is not allowed to sign certificates")
+	}
+	pub, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(cert.PublicKey) {
+		return nil, errors.New("x509: signer's public key doesn't match the CA certificate")
+	}
+	return &CertificateAuthority{cert: cert, signer: signer}, nil
+}
+
+// Certificate returns the CA certificate. It must not be modified.
+func (ca *CertificateAuthority) Certificate() *Certificate {
+	return ca.cert
+}
+
+// An IssuanceProfile describes the contents of the certificates issued by
+// a [CertificateAuthority], other than those taken from the certificate
+// request. The fields have the same meaning as the [Certificate] fields of
+// the same name, unless documented otherwise.
+type IssuanceProfile struct {
+	// Validity is the lifetime of issued certificates. It must be positive.
+	// The expiration of issued certificates is capped to that of the CA
+	// certificate.
+	Validity time.Duration
+
+	// Backdate is subtracted from the current time to obtain the NotBefore
+	// time of issued certificates, to tolerate clock skew in relying
+	// parties.
+	Backdate time.Duration
+
+	// KeyUsage defaults to KeyUsageCertSign|KeyUsageCRLSign for CA
+	// certificates, and KeyUsageDigitalSignature otherwise.
+	KeyUsage KeyUsage
+
+	// ExtKeyUsage must be permitted by the extended key usages of the CA
+	// certificate, if any.
+	ExtKeyUsage []ExtKeyUsage
+
+	// IsCA makes the issued certificates CA certificates. Issuing them is
+	// only allowed if the path length constraint of the CA certificate
+	// allows for another CA below it.
+	IsCA bool
+
+	// MaxPathLen and MaxPathLenZero only apply if IsCA is set. If the CA
+	// certificate has a path length constraint, MaxPathLen must be lower,
+	// and defaults to one less than it.
+	MaxPathLen     int
+	MaxPathLenZero bool
+
+	// Policies must be permitted by the policies of the CA certificate, if
+	// it has any other than anyPolicy.
+	Policies []OID
+
+	// Name constraints only apply if IsCA is set.
+	PermittedDNSDomainsCritical bool
+	PermittedDNSDomains         []string
+	ExcludedDNSDomains          []string
+	PermittedIPRanges           []*net.IPNet
+	ExcludedIPRanges            []*net.IPNet
+
+	OCSPServer            []string
+	IssuingCertificateURL []string
+	CRLDistributionPoints []string
+
+	// SignatureAlgorithm is the algorithm used to sign issued certificates.
+	// If zero, a default is chosen based on the CA's key, as in
+	// CreateCertificate.
+	SignatureAlgorithm SignatureAlgorithm
+}
+
+// IssueFromRequest issues a certificate for the public key, subject and
+// subject alternative names of csr, according to profile. All other contents
+// of csr, including its requested extensions, are ignored.
+//
+// The signature of csr must be valid, and the request must contain a subject
+// or at least one subject alternative name. The serial number of the
+// certificate is 128 random bits read from rand, which is also used for
+// signing. The subject key identifier is derived from the public key, as in
+// RFC 5280, Section 4.2.1.2, method 1, and the authority key identifier is
+// taken from the CA certificate.
+//
+// IssueFromRequest fails if the resulting certificate would not be accepted
+// by [Certificate.Verify] below the CA certificate at the current time, for
+// example because one of its names is not allowed by the name constraints
+// of the CA certificate.
+func (ca *CertificateAuthority) IssueFromRequest(rand io.Reader, csr *CertificateRequest, profile *IssuanceProfile) (*Certificate, error) {
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("x509: invalid certificate request signature: %w", err)
+	}
+	if k, ok := csr.PublicKey.(*rsa.PublicKey); ok && k.N.BitLen() < 2048 {
+		return nil, fmt.Errorf("x509: certificate request has an insecure %d-bit RSA key", k.N.BitLen())
+	}
+	if k, ok := csr.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); ok && k.Equal(ca.cert.PublicKey) {
+		return nil, errors.New("x509: certificate request uses the CA's public key")
+	}
+	if len(csr.Subject.ToRDNSequence()) == 0 && len(csr.DNSNames) == 0 && len(csr.EmailAddresses) == 0 &&
+		len(csr.IPAddresses) == 0 && len(csr.URIs) == 0 {
+		return nil, errors.New("x509: certificate request has no subject or subject alternative names")
+	}
+
+	template, err := ca.template(profile)
+	if err != nil {
+		return nil, err
+	}
+	template.RawSubject = csr.RawSubject
+	template.Subject = csr.Subject
+	template.DNSNames = csr.DNSNames
+	template.EmailAddresses = csr.EmailAddresses
+	template.IPAddresses = csr.IPAddresses
+	template.URIs = csr.URIs
+
+	publicKeyBytes, _, err := marshalPublicKey(csr.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	h := sha1.Sum(publicKeyBytes)
+	template.SubjectKeyId = h[:]
+
+	template.SerialNumber, err = randomSerialNumber(rand)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := CreateCertificate(rand, template, ca.cert, csr.PublicKey, ca.signer)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	if err := cert.CheckSignatureFrom(ca.cert); err != nil {
+		return nil, err
+	}
+	if err := ca.cert.isValid(intermediateCertificate, []*Certificate{cert}, &VerifyOptions{}); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// template returns a certificate template with the fields set by profile,
+// after checking them against the CA certificate.
+func (ca *CertificateAuthority) template(profile *IssuanceProfile) (*Certificate, error) {
+	if profile.Validity <= 0 {
+		return nil, errors.New("x509: IssuanceProfile.Validity must be positive")
+	}
+	if profile.Backdate < 0 {
+		return nil, errors.New("x509: IssuanceProfile.Backdate must not be negative")
+	}
+
+	now := time.Now()
+	notAfter := now.Add(profile.Validity)
+	if notAfter.After(ca.cert.NotAfter) {
+		notAfter = ca.cert.NotAfter
+	}
+	if !now.Before(notAfter) {
+		return nil, errors.New("x509: CA certificate has expired")
+	}
+
+	template := &Certificate{
+		NotBefore:             now.Add(-profile.Backdate),
+		NotAfter:              notAfter,
+		KeyUsage:              profile.KeyUsage,
+		ExtKeyUsage:           profile.ExtKeyUsage,
+		BasicConstraintsValid: true,
+		IsCA:                  profile.IsCA,
+		MaxPathLen:            -1,
+		Policies:              profile.Policies,
+		OCSPServer:            profile.OCSPServer,
+		IssuingCertificateURL: profile.IssuingCertificateURL,
+		CRLDistributionPoints: profile.CRLDistributionPoints,
+		SignatureAlgorithm:    profile.SignatureAlgorithm,
+	}
+
+	if ca.cert.NotBefore.After(template.NotBefore) {
+		template.NotBefore = ca.cert.NotBefore
+	}
+
+	if len(ca.cert.ExtKeyUsage) > 0 && !slices.Contains(ca.cert.ExtKeyUsage, ExtKeyUsageAny) {
+		for _, eku := range profile.ExtKeyUsage {
+			if !slices.Contains(ca.cert.ExtKeyUsage, eku) {
+				return nil, fmt.Errorf("x509: extended key usage %d is not permitted by the CA certificate", eku)
+			}
+		}
+	}
+
+	if len(ca.cert.Policies) > 0 && !slices.ContainsFunc(ca.cert.Policies, func(oid OID) bool {
+		return oid.EqualASN1OID(oidAnyPolicy)
+	}) {
+		for _, policy := range profile.Policies {
+			if !slices.ContainsFunc(ca.cert.Policies, policy.Equal) {
+				return nil, fmt.Errorf("x509: policy %v is not permitted by the CA certificate", policy)
+			}
+		}
+	}
+
+	// Policies is only encoded if the x509usepolicies GODEBUG is set, so
+	// also fill in PolicyIdentifiers for CreateCertificate.
+	for _, policy := range profile.Policies {
+		oid, ok := policy.toASN1OID()
+		if !ok {
+			if x509usepolicies.Value() != "1" {
+				return nil, fmt.Errorf("x509: policy %v can't be encoded as an asn1.ObjectIdentifier", policy)
+			}
+			continue
+		}
+		template.PolicyIdentifiers = append(template.PolicyIdentifiers, oid)
+	}
+
+	if !profile.IsCA {
+		if profile.MaxPathLen != 0 || profile.MaxPathLenZero {
+			return nil, errors.New("x509: only CAs are allowed to specify MaxPathLen")
+		}
+		if len(profile.PermittedDNSDomains) > 0 || len(profile.ExcludedDNSDomains) > 0 ||
+			len(profile.PermittedIPRanges) > 0 || len(profile.ExcludedIPRanges) > 0 {
+			return nil, errors.New("x509: only CAs are allowed to specify name constraints")
+		}
+		if template.KeyUsage == 0 {
+			template.KeyUsage = KeyUsageDigitalSignature
+		}
+		if template.KeyUsage&(KeyUsageCertSign|KeyUsageCRLSign) != 0 {
+			return nil, errors.New("x509: only CAs are allowed to use KeyUsageCertSign or KeyUsageCRLSign")
+		}
+		return template, nil
+	}
+
+	// The path length constraint of the CA counts the CA certificates that
+	// may follow it in a path, so issuing a CA uses up one.
+	parentPathLen := -1
+	if ca.cert.MaxPathLen > 0 || ca.cert.MaxPathLen == 0 && ca.cert.MaxPathLenZero {
+		parentPathLen = ca.cert.MaxPathLen
+	}
+	if parentPathLen == 0 {
+		return nil, errors.New("x509: CA certificate path length constraint doesn't allow issuing CA certificates")
+	}
+	if profile.MaxPathLen < 0 {
+		return nil, errors.New("x509: IssuanceProfile.MaxPathLen must not be negative")
+	}
+	pathLen := -1
+	if profile.MaxPathLen > 0 || profile.MaxPathLenZero {
+		pathLen = profile.MaxPathLen
+	}
+	if parentPathLen > 0 {
+		if pathLen < 0 {
+			pathLen = parentPathLen - 1
+		} else if pathLen >= parentPathLen {
+			return nil, fmt.Errorf("x509: path length %d is not permitted by the CA certificate path length %d", pathLen, parentPathLen)
+		}
+	}
+	if pathLen >= 0 {
+		template.MaxPathLen = pathLen
+		template.MaxPathLenZero = pathLen == 0
+	}
+
+	if template.KeyUsage == 0 {
+		template.KeyUsage = KeyUsageCertSign | KeyUsageCRLSign
+	}
+	if template.KeyUsage&KeyUsageCertSign == 0 {
+		return nil, errors.New("x509: CA certificates must have KeyUsageCertSign")
+	}
+
+	template.PermittedDNSDomainsCritical = profile.PermittedDNSDomainsCritical
+	template.PermittedDNSDomains = profile.PermittedDNSDomains
+	template.ExcludedDNSDomains = profile.ExcludedDNSDomains
+	template.PermittedIPRanges = profile.PermittedIPRanges
+	template.ExcludedIPRanges = profile.ExcludedIPRanges
+	return template, nil
+}
+
+// randomSerialNumber returns a positive 128-bit serial number, as suggested
+// by the CA/Browser Forum Baseline Requirements, Section 7.1.
+func randomSerialNumber(rand io.Reader) (*big.Int, error) {
+	b := make([]byte, 16)
+	for {
+		if _, err := io.ReadFull(rand, b); err != nil {
+			return nil, fmt.Errorf("x509: generating serial number: %w", err)
+		}
+		serial := new(big.Int).SetBytes(b)
+		if serial.Sign() > 0 {
+			return serial, nil
+		}
+	}
+}
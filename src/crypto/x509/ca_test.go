@@ -0,0 +1,218 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"strings"
+	"testing"
+	"time"
+)
+
+func createTestCSR(t *testing.T, key crypto.Signer, cn string, dnsNames ...string) *CertificateRequest {
+	t.Helper()
+	der, err := CreateCertificateRequest(rand.Reader, &CertificateRequest{
+		Subject:  pkix.Name{CommonName: cn},
+		DNSNames: dnsNames,
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := ParseCertificateRequest(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return csr
+}
+
+func newTestCA(t *testing.T, mutateTmpl func(*Certificate)) *CertificateAuthority {
+	t.Helper()
+	key := mustGenerateECDSAKey(t)
+	ca, err := NewCertificateAuthority(genCertEdge(t, "root", key, mutateTmpl, rootCertificate, nil, nil), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca
+}
+
+func TestCertificateAuthorityIssueLeaf(t *testing.T) {
+	policy := mustNewOIDFromInts(t, []uint64{1, 2, 3, 4})
+	ca := newTestCA(t, func(c *Certificate) {
+		c.ExtKeyUsage = []ExtKeyUsage{ExtKeyUsageServerAuth, ExtKeyUsageClientAuth}
+		c.PolicyIdentifiers = []asn1.ObjectIdentifier{{1, 2, 3, 4}}
+		c.PermittedDNSDomains = []string{"example.com"}
+	})
+	leafKey := mustGenerateECDSAKey(t)
+	csr := createTestCSR(t, leafKey, "leaf", "www.example.com")
+
+	profile := &IssuanceProfile{
+		Validity:    24 * time.Hour,
+		Backdate:    time.Minute,
+		ExtKeyUsage: []ExtKeyUsage{ExtKeyUsageServerAuth},
+		Policies:    []OID{policy},
+		OCSPServer:  []string{"http://ocsp.example.com"},
+	}
+	cert, err := ca.IssueFromRequest(rand.Reader, csr, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cert.SerialNumber.Sign() <= 0 || cert.SerialNumber.BitLen() > 128 {
+		t.Errorf("unexpected serial number %v", cert.SerialNumber)
+	}
+	if !leafKey.Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(cert.PublicKey) {
+		t.Errorf("certificate has the wrong public key")
+	}
+	spki, _, _ := marshalPublicKey(leafKey.Public())
+	if h := sha1.Sum(spki); !bytes.Equal(cert.SubjectKeyId, h[:]) {
+		t.Errorf("SubjectKeyId = %x, want %x", cert.SubjectKeyId, h)
+	}
+	if !bytes.Equal(cert.AuthorityKeyId, ca.Certificate().SubjectKeyId) {
+		t.Errorf("AuthorityKeyId = %x, want %x", cert.AuthorityKeyId, ca.Certificate().SubjectKeyId)
+	}
+	if !cert.NotAfter.Equal(ca.Certificate().NotAfter) {
+		t.Errorf("NotAfter = %v, want it capped to %v", cert.NotAfter, ca.Certificate().NotAfter)
+	}
+	if cert.IsCA || !cert.BasicConstraintsValid || cert.KeyUsage != KeyUsageDigitalSignature {
+		t.Errorf("unexpected basic constraints or key usage: IsCA = %v, KeyUsage = %v", cert.IsCA, cert.KeyUsage)
+	}
+	if len(cert.Policies) != 1 || !cert.Policies[0].Equal(policy) {
+		t.Errorf("Policies = %v, want [%v]", cert.Policies, policy)
+	}
+	if len(cert.OCSPServer) != 1 || cert.OCSPServer[0] != profile.OCSPServer[0] {
+		t.Errorf("OCSPServer = %v, want %v", cert.OCSPServer, profile.OCSPServer)
+	}
+
+	roots := NewCertPool()
+	roots.AddCert(ca.Certificate())
+	if _, err := cert.Verify(VerifyOptions{Roots: roots, DNSName: "www.example.com"}); err != nil {
+		t.Errorf("issued certificate doesn't verify: %v", err)
+	}
+
+	other, err := ca.IssueFromRequest(rand.Reader, csr, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+		t.Errorf("serial number was reused")
+	}
+}
+
+func TestCertificateAuthorityIssueCA(t *testing.T) {
+	root := newTestCA(t, func(c *Certificate) {
+		c.MaxPathLen = 1
+	})
+
+	intKey := mustGenerateECDSAKey(t)
+	csr := createTestCSR(t, intKey, "intermediate")
+	if _, err := root.IssueFromRequest(rand.Reader, csr, &IssuanceProfile{Validity: time.Hour, IsCA: true, MaxPathLen: 1}); err == nil {
+		t.Errorf("issued CA with a path length not lower than the issuer's")
+	}
+	intCert, err := root.IssueFromRequest(rand.Reader, csr, &IssuanceProfile{
+		Validity:            time.Hour,
+		IsCA:                true,
+		PermittedDNSDomains: []string{"example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !intCert.IsCA || intCert.MaxPathLen != 0 || !intCert.MaxPathLenZero {
+		t.Errorf("IsCA = %v, MaxPathLen = %d, MaxPathLenZero = %v; want a CA with path length 0",
+			intCert.IsCA, intCert.MaxPathLen, intCert.MaxPathLenZero)
+	}
+	if intCert.KeyUsage != KeyUsageCertSign|KeyUsageCRLSign {
+		t.Errorf("KeyUsage = %v, want KeyUsageCertSign|KeyUsageCRLSign", intCert.KeyUsage)
+	}
+
+	intermediate, err := NewCertificateAuthority(intCert, intKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subCSR := createTestCSR(t, mustGenerateECDSAKey(t), "sub")
+	if _, err := intermediate.IssueFromRequest(rand.Reader, subCSR, &IssuanceProfile{Validity: time.Hour, IsCA: true}); err == nil {
+		t.Errorf("CA with path length 0 issued a CA certificate")
+	}
+
+	if _, err := intermediate.IssueFromRequest(rand.Reader, createTestCSR(t, mustGenerateECDSAKey(t), "leaf", "www.example.org"),
+		&IssuanceProfile{Validity: time.Hour}); err == nil || !strings.Contains(err.Error(), "not permitted") {
+		t.Errorf("issued certificate for a name outside the name constraints, err = %v", err)
+	}
+	leaf, err := intermediate.IssueFromRequest(rand.Reader, createTestCSR(t, mustGenerateECDSAKey(t), "leaf", "www.example.com"),
+		&IssuanceProfile{Validity: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots, intermediates := NewCertPool(), NewCertPool()
+	roots.AddCert(root.Certificate())
+	intermediates.AddCert(intCert)
+	if _, err := leaf.Verify(VerifyOptions{Roots: roots, Intermediates: intermediates}); err != nil {
+		t.Errorf("issued certificate doesn't verify: %v", err)
+	}
+}
+
+func TestCertificateAuthorityErrors(t *testing.T) {
+	key := mustGenerateECDSAKey(t)
+	rootCert := genCertEdge(t, "root", key, func(c *Certificate) {
+		c.ExtKeyUsage = []ExtKeyUsage{ExtKeyUsageClientAuth}
+		c.PolicyIdentifiers = []asn1.ObjectIdentifier{{1, 2, 3}}
+	}, rootCertificate, nil, nil)
+	if _, err := NewCertificateAuthority(rootCert, mustGenerateECDSAKey(t)); err == nil {
+		t.Errorf("NewCertificateAuthority accepted a mismatched signer")
+	}
+	leafCert := genCertEdge(t, "leaf", key, nil, leafCertificate, rootCert, key)
+	if _, err := NewCertificateAuthority(leafCert, key); err == nil {
+		t.Errorf("NewCertificateAuthority accepted a leaf certificate")
+	}
+	ca, err := NewCertificateAuthority(rootCert, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csr := createTestCSR(t, mustGenerateECDSAKey(t), "leaf", "localhost")
+	badCSR := *csr
+	badCSR.Signature = bytes.Clone(csr.Signature)
+	badCSR.Signature[len(badCSR.Signature)-1] ^= 1
+
+	for _, tt := range []struct {
+		name    string
+		csr     *CertificateRequest
+		profile IssuanceProfile
+	}{
+		{"bad CSR signature", &badCSR, IssuanceProfile{Validity: time.Hour}},
+		{"CA key", createTestCSR(t, key, "leaf", "localhost"), IssuanceProfile{Validity: time.Hour}},
+		{"no names", createTestCSR(t, mustGenerateECDSAKey(t), ""), IssuanceProfile{Validity: time.Hour}},
+		{"no validity", csr, IssuanceProfile{}},
+		{"EKU", csr, IssuanceProfile{Validity: time.Hour, ExtKeyUsage: []ExtKeyUsage{ExtKeyUsageServerAuth}}},
+		{"policy", csr, IssuanceProfile{Validity: time.Hour, Policies: []OID{mustNewOIDFromInts(t, []uint64{1, 2, 4})}}},
+		{"leaf path length", csr, IssuanceProfile{Validity: time.Hour, MaxPathLenZero: true}},
+		{"leaf name constraints", csr, IssuanceProfile{Validity: time.Hour, PermittedDNSDomains: []string{"localhost"}}},
+		{"leaf cert sign", csr, IssuanceProfile{Validity: time.Hour, KeyUsage: KeyUsageCertSign}},
+		{"CA without cert sign", csr, IssuanceProfile{Validity: time.Hour, IsCA: true, KeyUsage: KeyUsageDigitalSignature}},
+	} {
+		if _, err := ca.IssueFromRequest(rand.Reader, tt.csr, &tt.profile); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+
+	rsaCSR, err := CreateCertificateRequest(rand.Reader, &CertificateRequest{DNSNames: []string{"localhost"}}, testPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseCertificateRequest(rsaCSR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ca.IssueFromRequest(rand.Reader, parsed, &IssuanceProfile{Validity: time.Hour})
+	if bits := testPrivateKey.N.BitLen(); bits < 2048 && err == nil {
+		t.Errorf("issued certificate for a %d-bit RSA key", bits)
+	} else if bits >= 2048 && err != nil {
+		t.Errorf("unexpected error for a %d-bit RSA key: %v", bits, err)
+	}
+}
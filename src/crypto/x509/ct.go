@@ -0,0 +1,340 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// oidExtensionSCTList is the embedded SCT list extension of RFC 6962,
+// Section 3.3.
+var oidExtensionSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// Values of the SignatureAndHashAlgorithm structure of RFC 5246, Section
+// 7.4.1.4.1, which RFC 6962 uses for SCT signatures.
+const (
+	ctHashSHA256 = 4
+	ctSigRSA     = 1
+	ctSigECDSA   = 3
+)
+
+// Values of the LogEntryType enum of RFC 6962, Section 3.1.
+const (
+	ctX509Entry    = 0
+	ctPrecertEntry = 1
+)
+
+// A SignedCertificateTimestamp is a Signed Certificate Timestamp (SCT), a
+// promise by a Certificate Transparency log to publish a certificate, as
+// specified in RFC 6962, Section 3.2. Only version 1 SCTs are supported.
+type SignedCertificateTimestamp struct {
+	// Raw contains the complete TLS encoding of the SCT.
+	Raw []byte
+
+	// LogID is the SHA-256 hash of the log's DER-encoded public key.
+	LogID [32]byte
+	// Timestamp is the time at which the log issued the SCT, with
+	// millisecond precision.
+	Timestamp time.Time
+	// Extensions contains the raw CtExtensions of the SCT.
+	Extensions []byte
+
+	// SignatureAlgorithm is SHA256WithRSA or ECDSAWithSHA256 for the
+	// signatures allowed by RFC 6962, Section 2.1.4, and
+	// UnknownSignatureAlgorithm otherwise.
+	SignatureAlgorithm SignatureAlgorithm
+	Signature          []byte
+}
+
+// ParseSignedCertificateTimestamp parses a single SCT, as sent in the
+// signed_certificate_timestamp TLS extension and exposed by
+// crypto/tls.ConnectionState.SignedCertificateTimestamps.
+func ParseSignedCertificateTimestamp(data []byte) (*SignedCertificateTimestamp, error) {
+	sct := &SignedCertificateTimestamp{Raw: data}
+	s := cryptobyte.String(data)
+	var version, hash, sig uint8
+	var timestamp uint64
+	var extensions, signature cryptobyte.String
+	if !s.ReadUint8(&version) {
+		return nil, errors.New("x509: malformed SCT")
+	}
+	if version != 0 {
+		return nil, fmt.Errorf("x509: unsupported SCT version %d", version)
+	}
+	if !s.CopyBytes(sct.LogID[:]) ||
+		!s.ReadUint64(&timestamp) ||
+		!s.ReadUint16LengthPrefixed(&extensions) ||
+		!s.ReadUint8(&hash) || !s.ReadUint8(&sig) ||
+		!s.ReadUint16LengthPrefixed(&signature) || !s.Empty() {
+		return nil, errors.New("x509: malformed SCT")
+	}
+	sct.Timestamp = time.UnixMilli(int64(timestamp))
+	sct.Extensions = extensions
+	sct.Signature = signature
+	switch {
+	case hash == ctHashSHA256 && sig == ctSigRSA:
+		sct.SignatureAlgorithm = SHA256WithRSA
+	case hash == ctHashSHA256 && sig == ctSigECDSA:
+		sct.SignatureAlgorithm = ECDSAWithSHA256
+	}
+	return sct, nil
+}
+
+// ParseSignedCertificateTimestampList parses a SignedCertificateTimestampList,
+// as specified in RFC 6962, Section 3.3, which is the format used by the SCT
+// list certificate extension and OCSP response extension.
+func ParseSignedCertificateTimestampList(data []byte) ([]*SignedCertificateTimestamp, error) {
+	s := cryptobyte.String(data)
+	var list cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&list) || !s.Empty() || list.Empty() {
+		return nil, errors.New("x509: malformed SCT list")
+	}
+	var scts []*SignedCertificateTimestamp
+	for !list.Empty() {
+		var raw cryptobyte.String
+		if !list.ReadUint16LengthPrefixed(&raw) || raw.Empty() {
+			return nil, errors.New("x509: malformed SCT list")
+		}
+		sct, err := ParseSignedCertificateTimestamp(raw)
+		if err != nil {
+			return nil, err
+		}
+		scts = append(scts, sct)
+	}
+	return scts, nil
+}
+
+// SignedCertificateTimestamps returns the SCTs embedded in c by its issuer,
+// which are verified with [SignedCertificateTimestamp.CheckEmbeddedSignature].
+// It returns nil and no error if c has no SCT list extension.
+func (c *Certificate) SignedCertificateTimestamps() ([]*SignedCertificateTimestamp, error) {
+	for _, e := range c.Extensions {
+		if !e.Id.Equal(oidExtensionSCTList) {
+			continue
+		}
+		var list []byte
+		if rest, err := asn1.Unmarshal(e.Value, &list); err != nil || len(rest) != 0 {
+			return nil, errors.New("x509: malformed SCT list extension")
+		}
+		return ParseSignedCertificateTimestampList(list)
+	}
+	return nil, nil
+}
+
+// A CTLog is a Certificate Transparency log.
+type CTLog struct {
+	// PublicKey is the public key of the log, which must be an
+	// *ecdsa.PublicKey on the P-256 curve or an *rsa.PublicKey.
+	PublicKey crypto.PublicKey
+}
+
+// ID returns the log ID, the SHA-256 hash of the DER-encoded public key of l.
+func (l *CTLog) ID() ([32]byte, error) {
+	switch l.PublicKey.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey:
+	default:
+		return [32]byte{}, fmt.Errorf("x509: unsupported CT log public key type %T", l.PublicKey)
+	}
+	der, err := MarshalPKIXPublicKey(l.PublicKey)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return sha256.Sum256(der), nil
+}
+
+// CheckSignature verifies that sct is a valid SCT from log for cert, when sct
+// was delivered separately from cert, for example in a TLS extension or an
+// OCSP response.
+func (sct *SignedCertificateTimestamp) CheckSignature(log *CTLog, cert *Certificate) error {
+	var b cryptobyte.Builder
+	b.AddUint16(ctX509Entry)
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(cert.Raw)
+	})
+	entry, err := b.Bytes()
+	if err != nil {
+		return err
+	}
+	return sct.checkSignature(log, entry)
+}
+
+// CheckEmbeddedSignature verifies that sct is a valid SCT from log for cert,
+// when sct is embedded in cert, which was issued by issuer.
+func (sct *SignedCertificateTimestamp) CheckEmbeddedSignature(log *CTLog, cert, issuer *Certificate) error {
+	tbs, err := removeSCTListExtension(cert.RawTBSCertificate)
+	if err != nil {
+		return err
+	}
+	issuerKeyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+	var b cryptobyte.Builder
+	b.AddUint16(ctPrecertEntry)
+	b.AddBytes(issuerKeyHash[:])
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(tbs)
+	})
+	entry, err := b.Bytes()
+	if err != nil {
+		return err
+	}
+	return sct.checkSignature(log, entry)
+}
+
+func (sct *SignedCertificateTimestamp) checkSignature(log *CTLog, entry []byte) error {
+	id, err := log.ID()
+	if err != nil {
+		return err
+	}
+	if id != sct.LogID {
+		return errors.New("x509: SCT was not issued by the CT log")
+	}
+	if sct.SignatureAlgorithm == UnknownSignatureAlgorithm {
+		return errors.New("x509: unsupported SCT signature algorithm")
+	}
+
+	// The digitally-signed struct of RFC 6962, Section 3.2.
+	var b cryptobyte.Builder
+	b.AddUint8(0) // v1
+	b.AddUint8(0) // certificate_timestamp
+	b.AddUint64(uint64(sct.Timestamp.UnixMilli()))
+	b.AddBytes(entry)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(sct.Extensions)
+	})
+	signed, err := b.Bytes()
+	if err != nil {
+		return err
+	}
+	return checkSignature(sct.SignatureAlgorithm, signed, sct.Signature, log.PublicKey, false)
+}
+
+// removeSCTListExtension returns the DER encoding of tbs with the SCT list
+// extension removed, which is the TBSCertificate of the precertificate that
+// embedded SCTs are computed over, see RFC 6962, Section 3.2.
+func removeSCTListExtension(tbs []byte) ([]byte, error) {
+	input := cryptobyte.String(tbs)
+	if !input.ReadASN1(&input, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed tbs certificate")
+	}
+	extensionsTag := cryptobyte_asn1.Tag(3).Constructed().ContextSpecific()
+	var b cryptobyte.Builder
+	var err error
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		for !input.Empty() {
+			var element cryptobyte.String
+			var tag cryptobyte_asn1.Tag
+			if !input.ReadAnyASN1Element(&element, &tag) {
+				err = errors.New("x509: malformed tbs certificate")
+				return
+			}
+			if tag != extensionsTag {
+				b.AddBytes(element)
+				continue
+			}
+			var extensions cryptobyte.String
+			if !element.ReadASN1(&element, extensionsTag) ||
+				!element.ReadASN1(&extensions, cryptobyte_asn1.SEQUENCE) {
+				err = errors.New("x509: malformed extensions")
+				return
+			}
+			var kept [][]byte
+			for !extensions.Empty() {
+				var ext, extContents cryptobyte.String
+				var oid asn1.ObjectIdentifier
+				if !extensions.ReadASN1Element(&ext, cryptobyte_asn1.SEQUENCE) {
+					err = errors.New("x509: malformed extension")
+					return
+				}
+				extContents = ext
+				if !extContents.ReadASN1(&extContents, cryptobyte_asn1.SEQUENCE) ||
+					!extContents.ReadASN1ObjectIdentifier(&oid) {
+					err = errors.New("x509: malformed extension")
+					return
+				}
+				if !oid.Equal(oidExtensionSCTList) {
+					kept = append(kept, ext)
+				}
+			}
+			if len(kept) == 0 {
+				continue
+			}
+			b.AddASN1(extensionsTag, func(b *cryptobyte.Builder) {
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					for _, ext := range kept {
+						b.AddBytes(ext)
+					}
+				})
+			})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes()
+}
+
+// CTPolicy configures the Certificate Transparency checks performed by
+// [Certificate.Verify] when set in [VerifyOptions.CertificateTransparency].
+type CTPolicy struct {
+	// Logs are the trusted Certificate Transparency logs.
+	Logs []*CTLog
+
+	// MinSCTs is the minimum number of valid SCTs for the leaf certificate,
+	// each from a distinct log in Logs. SCTs embedded in the leaf and SCTs in
+	// the SCTs field are counted. If the number of valid SCTs is smaller,
+	// Verify returns a CertificateInvalidError with reason InsufficientSCTs.
+	MinSCTs int
+
+	// SCTs are SCTs for the leaf certificate that were delivered separately
+	// from it, such as those sent in the TLS signed_certificate_timestamp
+	// extension, in the format accepted by [ParseSignedCertificateTimestamp].
+	// SCTs that don't parse are ignored.
+	SCTs [][]byte
+}
+
+// checkChain returns an error if the leaf of chain doesn't have enough valid
+// SCTs.
+func (p *CTPolicy) checkChain(chain []*Certificate) error {
+	leaf := chain[0]
+	logs := make(map[[32]byte]*CTLog, len(p.Logs))
+	for _, log := range p.Logs {
+		if id, err := log.ID(); err == nil {
+			logs[id] = log
+		}
+	}
+	valid := make(map[[32]byte]bool)
+	if len(chain) > 1 {
+		// Errors parsing the extension are treated like an absent one.
+		embedded, _ := leaf.SignedCertificateTimestamps()
+		for _, sct := range embedded {
+			if log, ok := logs[sct.LogID]; ok && sct.CheckEmbeddedSignature(log, leaf, chain[1]) == nil {
+				valid[sct.LogID] = true
+			}
+		}
+	}
+	for _, raw := range p.SCTs {
+		sct, err := ParseSignedCertificateTimestamp(raw)
+		if err != nil {
+			continue
+		}
+		if log, ok := logs[sct.LogID]; ok && sct.CheckSignature(log, leaf) == nil {
+			valid[sct.LogID] = true
+		}
+	}
+	if len(valid) < p.MinSCTs {
+		return CertificateInvalidError{leaf, InsufficientSCTs,
+			fmt.Sprintf("%d valid SCTs from distinct logs, need %d", len(valid), p.MinSCTs)}
+	}
+	return nil
+}
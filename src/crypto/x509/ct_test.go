@@ -0,0 +1,251 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// createTestSCT returns a version 1 SCT from the log with the given key over
+// the TLS-encoded signed_entry of a LogEntry, with an ECDSA or RSA signature.
+func createTestSCT(t *testing.T, key crypto.Signer, timestamp time.Time, entry []byte) []byte {
+	t.Helper()
+	id, err := (&CTLog{PublicKey: key.Public()}).ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var signed cryptobyte.Builder
+	signed.AddUint8(0) // v1
+	signed.AddUint8(0) // certificate_timestamp
+	signed.AddUint64(uint64(timestamp.UnixMilli()))
+	signed.AddBytes(entry)
+	signed.AddUint16(0) // no extensions
+	h := sha256.Sum256(signed.BytesOrPanic())
+	sig, err := key.Sign(rand.Reader, h[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigAlg := uint8(ctSigECDSA)
+	if key == crypto.Signer(testPrivateKey) {
+		sigAlg = ctSigRSA
+	}
+
+	var b cryptobyte.Builder
+	b.AddUint8(0) // v1
+	b.AddBytes(id[:])
+	b.AddUint64(uint64(timestamp.UnixMilli()))
+	b.AddUint16(0) // no extensions
+	b.AddUint8(ctHashSHA256)
+	b.AddUint8(sigAlg)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(sig)
+	})
+	return b.BytesOrPanic()
+}
+
+type testCTSetup struct {
+	root, leaf *Certificate
+	logKeys    []crypto.Signer
+	logs       []*CTLog
+	timestamp  time.Time
+}
+
+// newTestCTSetup returns a leaf certificate with embedded SCTs from the first
+// two of three logs.
+func newTestCTSetup(t *testing.T) *testCTSetup {
+	t.Helper()
+	s := &testCTSetup{
+		logKeys:   []crypto.Signer{mustGenerateECDSAKey(t), testPrivateKey, mustGenerateECDSAKey(t)},
+		timestamp: time.Now().Add(-time.Minute).Truncate(time.Millisecond),
+	}
+	for _, k := range s.logKeys {
+		s.logs = append(s.logs, &CTLog{PublicKey: k.Public()})
+	}
+	rootKey := mustGenerateECDSAKey(t)
+	s.root = genCertEdge(t, "root", rootKey, nil, rootCertificate, nil, nil)
+
+	leafKey := mustGenerateECDSAKey(t)
+	tmpl := &Certificate{
+		SerialNumber: big.NewInt(7),
+		Subject:      pkix.Name{CommonName: "leaf"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		ExtraExtensions: []pkix.Extension{
+			{Id: asn1.ObjectIdentifier{1, 2, 3, 4}, Value: []byte{0x05, 0x00}},
+		},
+	}
+	precertDER, err := CreateCertificate(rand.Reader, tmpl, s.root, leafKey.Public(), rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	precert, err := ParseCertificate(precertDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuerKeyHash := sha256.Sum256(s.root.RawSubjectPublicKeyInfo)
+	var entry cryptobyte.Builder
+	entry.AddUint16(ctPrecertEntry)
+	entry.AddBytes(issuerKeyHash[:])
+	entry.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(precert.RawTBSCertificate)
+	})
+
+	var list cryptobyte.Builder
+	list.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, k := range s.logKeys[:2] {
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddBytes(createTestSCT(t, k, s.timestamp, entry.BytesOrPanic()))
+			})
+		}
+	})
+	ext, err := asn1.Marshal(list.BytesOrPanic())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Insert the SCT list before the other extra extension, to check that
+	// it's removed from the middle of the extensions.
+	tmpl.ExtraExtensions = append([]pkix.Extension{{Id: oidExtensionSCTList, Value: ext}}, tmpl.ExtraExtensions...)
+	der, err := CreateCertificate(rand.Reader, tmpl, s.root, leafKey.Public(), rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.leaf, err = ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// deliveredSCT returns an SCT for the leaf from the i-th log, as if delivered
+// in a TLS extension.
+func (s *testCTSetup) deliveredSCT(t *testing.T, i int) []byte {
+	var entry cryptobyte.Builder
+	entry.AddUint16(ctX509Entry)
+	entry.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(s.leaf.Raw)
+	})
+	return createTestSCT(t, s.logKeys[i], s.timestamp, entry.BytesOrPanic())
+}
+
+func TestSignedCertificateTimestamps(t *testing.T) {
+	s := newTestCTSetup(t)
+
+	scts, err := s.leaf.SignedCertificateTimestamps()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scts) != 2 {
+		t.Fatalf("got %d embedded SCTs, want 2", len(scts))
+	}
+	wantAlgs := []SignatureAlgorithm{ECDSAWithSHA256, SHA256WithRSA}
+	for i, sct := range scts {
+		id, _ := s.logs[i].ID()
+		if sct.LogID != id {
+			t.Errorf("SCT %d: wrong log ID", i)
+		}
+		if !sct.Timestamp.Equal(s.timestamp) {
+			t.Errorf("SCT %d: Timestamp = %v, want %v", i, sct.Timestamp, s.timestamp)
+		}
+		if sct.SignatureAlgorithm != wantAlgs[i] {
+			t.Errorf("SCT %d: SignatureAlgorithm = %v, want %v", i, sct.SignatureAlgorithm, wantAlgs[i])
+		}
+		if err := sct.CheckEmbeddedSignature(s.logs[i], s.leaf, s.root); err != nil {
+			t.Errorf("SCT %d: CheckEmbeddedSignature: %v", i, err)
+		}
+		if err := sct.CheckSignature(s.logs[i], s.leaf); err == nil {
+			t.Errorf("SCT %d: embedded SCT verified as a delivered one", i)
+		}
+		if err := sct.CheckEmbeddedSignature(s.logs[1-i], s.leaf, s.root); err == nil {
+			t.Errorf("SCT %d: verified with the wrong log", i)
+		}
+		if err := sct.CheckEmbeddedSignature(s.logs[i], s.leaf, s.leaf); err == nil {
+			t.Errorf("SCT %d: verified with the wrong issuer", i)
+		}
+	}
+
+	sct, err := ParseSignedCertificateTimestamp(s.deliveredSCT(t, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sct.CheckSignature(s.logs[2], s.leaf); err != nil {
+		t.Errorf("CheckSignature: %v", err)
+	}
+	if err := sct.CheckEmbeddedSignature(s.logs[2], s.leaf, s.root); err == nil {
+		t.Errorf("delivered SCT verified as an embedded one")
+	}
+	sct.Signature[len(sct.Signature)-1] ^= 1
+	if err := sct.CheckSignature(s.logs[2], s.leaf); err == nil {
+		t.Errorf("SCT with modified signature verified")
+	}
+
+	if scts, err := s.root.SignedCertificateTimestamps(); scts != nil || err != nil {
+		t.Errorf("certificate without SCTs: got %v, %v", scts, err)
+	}
+}
+
+func TestParseSignedCertificateTimestampErrors(t *testing.T) {
+	s := newTestCTSetup(t)
+	valid := s.deliveredSCT(t, 0)
+	for name, data := range map[string][]byte{
+		"empty":     {},
+		"truncated": valid[:len(valid)-1],
+		"trailing":  append(valid[:len(valid):len(valid)], 0),
+		"version":   append([]byte{1}, valid[1:]...),
+	} {
+		if _, err := ParseSignedCertificateTimestamp(data); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	for name, data := range map[string][]byte{
+		"empty":     {},
+		"emptyList": {0, 0},
+		"emptySCT":  {0, 2, 0, 0},
+		"badLength": {0, 3, 0, 1},
+	} {
+		if _, err := ParseSignedCertificateTimestampList(data); err == nil {
+			t.Errorf("list %s: expected error", name)
+		}
+	}
+}
+
+func TestVerifyCertificateTransparency(t *testing.T) {
+	s := newTestCTSetup(t)
+	verify := func(p *CTPolicy) error {
+		roots := NewCertPool()
+		roots.AddCert(s.root)
+		_, err := s.leaf.Verify(VerifyOptions{Roots: roots, CertificateTransparency: p})
+		return err
+	}
+	expectInsufficient := func(t *testing.T, err error) {
+		t.Helper()
+		var invErr CertificateInvalidError
+		if !errors.As(err, &invErr) || invErr.Reason != InsufficientSCTs {
+			t.Errorf("got error %v, want CertificateInvalidError with reason InsufficientSCTs", err)
+		}
+	}
+
+	if err := verify(&CTPolicy{Logs: s.logs, MinSCTs: 2}); err != nil {
+		t.Errorf("two embedded SCTs: %v", err)
+	}
+	expectInsufficient(t, verify(&CTPolicy{Logs: s.logs, MinSCTs: 3}))
+	if err := verify(&CTPolicy{Logs: s.logs, MinSCTs: 3, SCTs: [][]byte{s.deliveredSCT(t, 2)}}); err != nil {
+		t.Errorf("two embedded and one delivered SCT: %v", err)
+	}
+
+	// SCTs from untrusted logs, repeated logs, or that don't parse don't count.
+	expectInsufficient(t, verify(&CTPolicy{Logs: s.logs[1:], MinSCTs: 2}))
+	expectInsufficient(t, verify(&CTPolicy{Logs: s.logs, MinSCTs: 3, SCTs: [][]byte{s.deliveredSCT(t, 0), {0}}}))
+}
@@ -0,0 +1,372 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"slices"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// This file implements the subset of the Online Certificate Status Protocol
+// (RFC 6960) needed to check the revocation status of certificates during
+// verification: encoding requests, and parsing and validating basic responses.
+
+var (
+	oidOCSPBasicResponse = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+	oidSHA1              = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+)
+
+// OCSP certificate statuses, as the context-specific tags of the CertStatus
+// CHOICE.
+const (
+	ocspGood    = 0
+	ocspRevoked = 1
+	ocspUnknown = 2
+)
+
+type ocspResponse struct {
+	rawTBSResponseData []byte
+	signatureAlgorithm SignatureAlgorithm
+	signature          []byte
+
+	// Exactly one of responderName and responderKeyHash is set.
+	responderName    []byte
+	responderKeyHash []byte
+
+	responses []ocspSingleResponse
+	certs     []*Certificate
+}
+
+type ocspSingleResponse struct {
+	hash           crypto.Hash
+	issuerNameHash []byte
+	issuerKeyHash  []byte
+	serialNumber   *big.Int
+
+	status         int
+	revocationTime time.Time
+	reasonCode     int
+
+	thisUpdate time.Time
+	nextUpdate time.Time
+}
+
+// ocspHashFromOID returns the hash function identified by the
+// hashAlgorithm of a CertID, or zero if it's not supported.
+func ocspHashFromOID(oid asn1.ObjectIdentifier) crypto.Hash {
+	switch {
+	case oid.Equal(oidSHA1):
+		return crypto.SHA1
+	case oid.Equal(oidSHA256):
+		return crypto.SHA256
+	case oid.Equal(oidSHA384):
+		return crypto.SHA384
+	case oid.Equal(oidSHA512):
+		return crypto.SHA512
+	}
+	return 0
+}
+
+// subjectPublicKeyBytes returns the contents of the subjectPublicKey BIT
+// STRING of c, which is what the key hashes of OCSP are computed over.
+func subjectPublicKeyBytes(c *Certificate) ([]byte, error) {
+	spki := cryptobyte.String(c.RawSubjectPublicKeyInfo)
+	var bits asn1.BitString
+	if !spki.ReadASN1(&spki, cryptobyte_asn1.SEQUENCE) ||
+		!spki.SkipASN1(cryptobyte_asn1.SEQUENCE) ||
+		!spki.ReadASN1BitString(&bits) {
+		return nil, errors.New("x509: malformed subject public key info")
+	}
+	return bits.RightAlign(), nil
+}
+
+// createOCSPRequest returns an unsigned DER-encoded OCSP request for the
+// status of cert, which was issued by issuer. The CertID uses SHA-1, which
+// is what responders are required to support, see RFC 5019, Section 2.1.1.
+func createOCSPRequest(cert, issuer *Certificate) ([]byte, error) {
+	issuerKey, err := subjectPublicKeyBytes(issuer)
+	if err != nil {
+		return nil, err
+	}
+	nameHash := sha1.Sum(issuer.RawSubject)
+	keyHash := sha1.Sum(issuerKey)
+
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) { // OCSPRequest
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) { // TBSRequest
+			b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) { // requestList
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) { // Request
+					b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) { // CertID
+						b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+							b.AddASN1ObjectIdentifier(oidSHA1)
+							b.AddASN1NULL()
+						})
+						b.AddASN1OctetString(nameHash[:])
+						b.AddASN1OctetString(keyHash[:])
+						b.AddASN1BigInt(cert.SerialNumber)
+					})
+				})
+			})
+		})
+	})
+	return b.Bytes()
+}
+
+// parseOCSPResponse parses a DER-encoded OCSPResponse. It returns an error if
+// the response is not a successful basic response.
+func parseOCSPResponse(der []byte) (*ocspResponse, error) {
+	input := cryptobyte.String(der)
+	if !input.ReadASN1(&input, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed OCSP response")
+	}
+	var status int
+	if !input.ReadASN1Enum(&status) {
+		return nil, errors.New("x509: malformed OCSP response status")
+	}
+	if status != 0 {
+		return nil, errors.New("x509: OCSP response status is not successful")
+	}
+	var responseBytes cryptobyte.String
+	var responseType asn1.ObjectIdentifier
+	var basic cryptobyte.String
+	if !input.ReadASN1(&responseBytes, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) ||
+		!responseBytes.ReadASN1(&responseBytes, cryptobyte_asn1.SEQUENCE) ||
+		!responseBytes.ReadASN1ObjectIdentifier(&responseType) ||
+		!responseBytes.ReadASN1(&basic, cryptobyte_asn1.OCTET_STRING) {
+		return nil, errors.New("x509: malformed OCSP response bytes")
+	}
+	if !responseType.Equal(oidOCSPBasicResponse) {
+		return nil, errors.New("x509: unsupported OCSP response type")
+	}
+
+	resp := &ocspResponse{}
+	if !basic.ReadASN1(&basic, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed OCSP basic response")
+	}
+	var tbs cryptobyte.String
+	if !basic.ReadASN1Element(&tbs, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed OCSP response data")
+	}
+	resp.rawTBSResponseData = tbs
+	var sigAISeq cryptobyte.String
+	if !basic.ReadASN1(&sigAISeq, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed OCSP signature algorithm identifier")
+	}
+	sigAI, err := parseAI(sigAISeq)
+	if err != nil {
+		return nil, err
+	}
+	resp.signatureAlgorithm = getSignatureAlgorithmFromAI(sigAI)
+	var signature asn1.BitString
+	if !basic.ReadASN1BitString(&signature) {
+		return nil, errors.New("x509: malformed OCSP signature")
+	}
+	resp.signature = signature.RightAlign()
+	var certsSeq cryptobyte.String
+	var hasCerts bool
+	if !basic.ReadOptionalASN1(&certsSeq, &hasCerts, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) {
+		return nil, errors.New("x509: malformed OCSP certificates")
+	}
+	if hasCerts {
+		if !certsSeq.ReadASN1(&certsSeq, cryptobyte_asn1.SEQUENCE) {
+			return nil, errors.New("x509: malformed OCSP certificates")
+		}
+		for !certsSeq.Empty() {
+			var certDER cryptobyte.String
+			if !certsSeq.ReadASN1Element(&certDER, cryptobyte_asn1.SEQUENCE) {
+				return nil, errors.New("x509: malformed OCSP certificates")
+			}
+			cert, err := ParseCertificate(certDER)
+			if err != nil {
+				return nil, err
+			}
+			resp.certs = append(resp.certs, cert)
+		}
+	}
+
+	if !tbs.ReadASN1(&tbs, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed OCSP response data")
+	}
+	var version int64
+	if !tbs.ReadOptionalASN1Integer(&version, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific(), int64(0)) {
+		return nil, errors.New("x509: malformed OCSP response version")
+	}
+	if version != 0 {
+		return nil, errors.New("x509: unsupported OCSP response version")
+	}
+	var responderID cryptobyte.String
+	switch {
+	case tbs.ReadASN1(&responderID, cryptobyte_asn1.Tag(1).Constructed().ContextSpecific()):
+		if !responderID.ReadASN1Element((*cryptobyte.String)(&resp.responderName), cryptobyte_asn1.SEQUENCE) {
+			return nil, errors.New("x509: malformed OCSP responder name")
+		}
+	case tbs.ReadASN1(&responderID, cryptobyte_asn1.Tag(2).Constructed().ContextSpecific()):
+		if !responderID.ReadASN1((*cryptobyte.String)(&resp.responderKeyHash), cryptobyte_asn1.OCTET_STRING) {
+			return nil, errors.New("x509: malformed OCSP responder key hash")
+		}
+	default:
+		return nil, errors.New("x509: malformed OCSP responder ID")
+	}
+	var producedAt time.Time
+	if !tbs.ReadASN1GeneralizedTime(&producedAt) {
+		return nil, errors.New("x509: malformed OCSP producedAt")
+	}
+	var responses cryptobyte.String
+	if !tbs.ReadASN1(&responses, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed OCSP responses")
+	}
+	for !responses.Empty() {
+		var single cryptobyte.String
+		if !responses.ReadASN1(&single, cryptobyte_asn1.SEQUENCE) {
+			return nil, errors.New("x509: malformed OCSP single response")
+		}
+		sr, err := parseOCSPSingleResponse(single)
+		if err != nil {
+			return nil, err
+		}
+		resp.responses = append(resp.responses, sr)
+	}
+
+	return resp, nil
+}
+
+func parseOCSPSingleResponse(der cryptobyte.String) (ocspSingleResponse, error) {
+	var sr ocspSingleResponse
+	var certID, hashAI cryptobyte.String
+	if !der.ReadASN1(&certID, cryptobyte_asn1.SEQUENCE) ||
+		!certID.ReadASN1(&hashAI, cryptobyte_asn1.SEQUENCE) ||
+		!certID.ReadASN1((*cryptobyte.String)(&sr.issuerNameHash), cryptobyte_asn1.OCTET_STRING) ||
+		!certID.ReadASN1((*cryptobyte.String)(&sr.issuerKeyHash), cryptobyte_asn1.OCTET_STRING) {
+		return sr, errors.New("x509: malformed OCSP CertID")
+	}
+	sr.serialNumber = new(big.Int)
+	if !certID.ReadASN1Integer(sr.serialNumber) {
+		return sr, errors.New("x509: malformed OCSP serial number")
+	}
+	ai, err := parseAI(hashAI)
+	if err != nil {
+		return sr, err
+	}
+	sr.hash = ocspHashFromOID(ai.Algorithm)
+
+	var status cryptobyte.String
+	var statusTag cryptobyte_asn1.Tag
+	if !der.ReadAnyASN1(&status, &statusTag) {
+		return sr, errors.New("x509: malformed OCSP certificate status")
+	}
+	switch statusTag {
+	case cryptobyte_asn1.Tag(ocspGood).ContextSpecific():
+		sr.status = ocspGood
+	case cryptobyte_asn1.Tag(ocspUnknown).ContextSpecific():
+		sr.status = ocspUnknown
+	case cryptobyte_asn1.Tag(ocspRevoked).Constructed().ContextSpecific():
+		sr.status = ocspRevoked
+		if !status.ReadASN1GeneralizedTime(&sr.revocationTime) {
+			return sr, errors.New("x509: malformed OCSP revocation time")
+		}
+		var reason cryptobyte.String
+		var hasReason bool
+		if !status.ReadOptionalASN1(&reason, &hasReason, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) {
+			return sr, errors.New("x509: malformed OCSP revocation reason")
+		}
+		if hasReason {
+			if !reason.ReadASN1Enum(&sr.reasonCode) {
+				return sr, errors.New("x509: malformed OCSP revocation reason")
+			}
+		}
+	default:
+		return sr, errors.New("x509: malformed OCSP certificate status")
+	}
+
+	if !der.ReadASN1GeneralizedTime(&sr.thisUpdate) {
+		return sr, errors.New("x509: malformed OCSP thisUpdate")
+	}
+	var nextUpdate cryptobyte.String
+	var hasNextUpdate bool
+	if !der.ReadOptionalASN1(&nextUpdate, &hasNextUpdate, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) {
+		return sr, errors.New("x509: malformed OCSP nextUpdate")
+	}
+	if hasNextUpdate && !nextUpdate.ReadASN1GeneralizedTime(&sr.nextUpdate) {
+		return sr, errors.New("x509: malformed OCSP nextUpdate")
+	}
+	return sr, nil
+}
+
+// checkSignatureFrom verifies that resp was signed by issuer, either directly
+// or by an OCSP responder certificate issued by issuer and included in resp,
+// as described in RFC 6960, Section 4.2.2.2.
+func (resp *ocspResponse) checkSignatureFrom(issuer *Certificate, now time.Time) error {
+	if resp.identifies(issuer) {
+		return issuer.CheckSignature(resp.signatureAlgorithm, resp.rawTBSResponseData, resp.signature)
+	}
+	for _, responder := range resp.certs {
+		if !resp.identifies(responder) {
+			continue
+		}
+		if !bytes.Equal(responder.RawIssuer, issuer.RawSubject) {
+			return errors.New("x509: OCSP responder certificate was not issued by the certificate issuer")
+		}
+		if err := responder.CheckSignatureFrom(issuer); err != nil {
+			return err
+		}
+		if now.Before(responder.NotBefore) || now.After(responder.NotAfter) {
+			return errors.New("x509: OCSP responder certificate is expired or not yet valid")
+		}
+		if !slices.Contains(responder.ExtKeyUsage, ExtKeyUsageOCSPSigning) {
+			return errors.New("x509: OCSP responder certificate is not authorized for OCSP signing")
+		}
+		return responder.CheckSignature(resp.signatureAlgorithm, resp.rawTBSResponseData, resp.signature)
+	}
+	return errors.New("x509: OCSP response is not signed by the certificate issuer or its responder")
+}
+
+// identifies reports whether the responder ID of resp matches c.
+func (resp *ocspResponse) identifies(c *Certificate) bool {
+	if resp.responderName != nil {
+		return bytes.Equal(resp.responderName, c.RawSubject)
+	}
+	key, err := subjectPublicKeyBytes(c)
+	if err != nil {
+		return false
+	}
+	h := sha1.Sum(key)
+	return bytes.Equal(resp.responderKeyHash, h[:])
+}
+
+// find returns the single response in resp about cert, which was issued by
+// issuer, if any.
+func (resp *ocspResponse) find(cert, issuer *Certificate) (*ocspSingleResponse, bool) {
+	issuerKey, err := subjectPublicKeyBytes(issuer)
+	if err != nil {
+		return nil, false
+	}
+	for i := range resp.responses {
+		sr := &resp.responses[i]
+		if sr.hash == 0 || !sr.hash.Available() || sr.serialNumber.Cmp(cert.SerialNumber) != 0 {
+			continue
+		}
+		h := sr.hash.New()
+		h.Write(issuer.RawSubject)
+		if !bytes.Equal(sr.issuerNameHash, h.Sum(nil)) {
+			continue
+		}
+		h.Reset()
+		h.Write(issuerKey)
+		if !bytes.Equal(sr.issuerKeyHash, h.Sum(nil)) {
+			continue
+		}
+		return sr, true
+	}
+	return nil, false
+}
@@ -0,0 +1,576 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+// RFC 5958 describes the encryption of PKCS #8 private keys, and RFC 8018
+// the PBES2 scheme that modern tools use to encrypt them, with a key
+// derived from the password by PBKDF2 or, per RFC 7914, scrypt.
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+	"internal/byteorder"
+	"internal/pbkdf2"
+	"io"
+	"math/bits"
+)
+
+// PKCS8Cipher identifies the cipher that [MarshalPKCS8EncryptedPrivateKey]
+// encrypts a key with.
+type PKCS8Cipher int
+
+const (
+	_ PKCS8Cipher = iota
+	PKCS8CipherAES128CBC
+	PKCS8CipherAES192CBC
+	PKCS8CipherAES256CBC
+	PKCS8CipherAES128GCM
+	PKCS8CipherAES256GCM
+)
+
+// PKCS8KDF identifies the function that [MarshalPKCS8EncryptedPrivateKey]
+// derives the encryption key from the password with.
+type PKCS8KDF int
+
+const (
+	_ PKCS8KDF = iota
+	// PKCS8KDFPBKDF2 is PBKDF2 with HMAC-SHA-256.
+	PKCS8KDFPBKDF2
+	PKCS8KDFScrypt
+)
+
+// PKCS8EncryptionOptions configures [MarshalPKCS8EncryptedPrivateKey].
+// The zero value selects AES-256-CBC with a key derived by PBKDF2.
+type PKCS8EncryptionOptions struct {
+	Cipher PKCS8Cipher
+	KDF    PKCS8KDF
+
+	// Iterations is the PBKDF2 iteration count, which must be at most
+	// 10,000,000. If zero, 600,000 iterations are used.
+	Iterations int
+
+	// ScryptN, ScryptR and ScryptP are the scrypt CPU/memory cost,
+	// block size and parallelization parameters. ScryptN must be a
+	// power of two, 128·ScryptR·(ScryptN+ScryptP) must be at most 1 GiB,
+	// and ScryptN·ScryptR·ScryptP at most 2^24. If zero, they default to
+	// 2^15, 8 and 1.
+	ScryptN, ScryptR, ScryptP int
+}
+
+var (
+	oidPBES2  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidScrypt = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11591, 4, 11}
+
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA224 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 8}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+
+	oidDESEDE3CBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+)
+
+// pbes2Cipher describes an encryption scheme of PBES2.
+type pbes2Cipher struct {
+	cipher     PKCS8Cipher // zero if only supported for decryption
+	oid        asn1.ObjectIdentifier
+	cipherFunc func(key []byte) (cipher.Block, error)
+	keySize    int
+	gcm        bool
+}
+
+var pbes2Ciphers = []pbes2Cipher{
+	{PKCS8CipherAES128CBC, asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}, aes.NewCipher, 16, false},
+	{PKCS8CipherAES192CBC, asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}, aes.NewCipher, 24, false},
+	{PKCS8CipherAES256CBC, asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}, aes.NewCipher, 32, false},
+	{PKCS8CipherAES128GCM, asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 6}, aes.NewCipher, 16, true},
+	{0, asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 26}, aes.NewCipher, 24, true},
+	{PKCS8CipherAES256GCM, asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 46}, aes.NewCipher, 32, true},
+	{0, oidDESEDE3CBC, des.NewTripleDESCipher, 24, false},
+}
+
+// encryptedPrivateKeyInfo reflects an ASN.1 EncryptedPrivateKeyInfo,
+// RFC 5958, Section 3.
+type encryptedPrivateKeyInfo struct {
+	Algo          pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// pbes2Params reflects the PBES2-params of RFC 8018, Appendix A.4.
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+// pbkdf2Params reflects the PBKDF2-params of RFC 8018, Appendix A.2.
+// Only a specified salt is supported.
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// scryptParams reflects the scrypt-params of RFC 7914, Section 7.1.
+type scryptParams struct {
+	Salt                     []byte
+	CostParameter            int
+	BlockSize                int
+	ParallelizationParameter int
+	KeyLength                int `asn1:"optional"`
+}
+
+// gcmParams reflects the GCMParameters of RFC 5084, Section 3.2.
+type gcmParams struct {
+	Nonce  []byte
+	ICVLen int `asn1:"optional,default:12"`
+}
+
+// maxScryptMemory bounds the memory, 128·r·(N+p) bytes, that the scrypt
+// parameters of a key being decrypted may require.
+const maxScryptMemory = 1 << 30
+
+// maxScryptWork bounds the work, N·r·p, that the scrypt parameters of a
+// key being decrypted may require. It is 64 times the default used by
+// MarshalPKCS8EncryptedPrivateKey.
+const maxScryptWork = 1 << 24
+
+// maxPBKDF2Iterations bounds the work that the PBKDF2 parameters of a key
+// being decrypted may require. It is more than ten times the default used
+// by MarshalPKCS8EncryptedPrivateKey.
+const maxPBKDF2Iterations = 10_000_000
+
+// ParsePKCS8EncryptedPrivateKey decrypts a private key encrypted with
+// the password, in PKCS #8, ASN.1 DER form, and parses it as
+// [ParsePKCS8PrivateKey] does.
+//
+// It supports the PBES2 scheme of RFC 8018 with AES in CBC or GCM mode,
+// or Triple DES in CBC mode, and a key derived by PBKDF2 with HMAC and
+// SHA-1 or SHA-2, or by scrypt. Keys derived with more than 10,000,000
+// PBKDF2 iterations, or with scrypt parameters that need more than 1 GiB
+// of memory or for which N·r·p exceeds 2^24, are rejected.
+//
+// When a key is encrypted in CBC mode, an incorrect password cannot
+// always be detected. When it is, [IncorrectPasswordError] is returned.
+//
+// This kind of key is commonly encoded in PEM blocks of type
+// "ENCRYPTED PRIVATE KEY".
+func ParsePKCS8EncryptedPrivateKey(der, password []byte) (key any, err error) {
+	var info encryptedPrivateKeyInfo
+	if rest, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, err
+	} else if len(rest) != 0 {
+		return nil, errors.New("x509: trailing data after encrypted PKCS#8 private key")
+	}
+	if !info.Algo.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("x509: unsupported PKCS#8 encryption scheme %v", info.Algo.Algorithm)
+	}
+	var params pbes2Params
+	if err := unmarshalParams(info.Algo.Parameters, &params); err != nil {
+		return nil, errors.New("x509: invalid PBES2 parameters: " + err.Error())
+	}
+
+	var ciph *pbes2Cipher
+	for i := range pbes2Ciphers {
+		if pbes2Ciphers[i].oid.Equal(params.EncryptionScheme.Algorithm) {
+			ciph = &pbes2Ciphers[i]
+		}
+	}
+	if ciph == nil {
+		return nil, fmt.Errorf("x509: unsupported PBES2 cipher %v", params.EncryptionScheme.Algorithm)
+	}
+
+	dk, err := deriveKey(params.KeyDerivationFunc, password, ciph.keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := ciph.cipherFunc(dk)
+	if err != nil {
+		return nil, err
+	}
+
+	var plaintext []byte
+	if ciph.gcm {
+		var gp gcmParams
+		if err := unmarshalParams(params.EncryptionScheme.Parameters, &gp); err != nil {
+			return nil, errors.New("x509: invalid GCM parameters: " + err.Error())
+		}
+		if gp.ICVLen < 12 || gp.ICVLen > 16 || len(gp.Nonce) == 0 {
+			return nil, errors.New("x509: invalid GCM parameters")
+		}
+		aead, err := cipher.NewGCMWithNonceSize(block, len(gp.Nonce))
+		if err != nil {
+			return nil, err
+		}
+		if gp.ICVLen != aead.Overhead() {
+			if len(gp.Nonce) != 12 {
+				return nil, errors.New("x509: unsupported GCM parameters")
+			}
+			if aead, err = cipher.NewGCMWithTagSize(block, gp.ICVLen); err != nil {
+				return nil, err
+			}
+		}
+		if plaintext, err = aead.Open(nil, gp.Nonce, info.EncryptedData, nil); err != nil {
+			return nil, IncorrectPasswordError
+		}
+	} else {
+		var iv []byte
+		if err := unmarshalParams(params.EncryptionScheme.Parameters, &iv); err != nil {
+			return nil, errors.New("x509: invalid CBC parameters: " + err.Error())
+		}
+		blockSize := block.BlockSize()
+		if len(iv) != blockSize {
+			return nil, errors.New("x509: invalid CBC initialization vector")
+		}
+		data := info.EncryptedData
+		if len(data) == 0 || len(data)%blockSize != 0 {
+			return nil, errors.New("x509: encrypted PKCS#8 data is not a multiple of the block size")
+		}
+		plaintext = make([]byte, len(data))
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, data)
+		// The padding is as in pem_decrypt.go.
+		last := int(plaintext[len(plaintext)-1])
+		if last == 0 || last > blockSize || last > len(plaintext) {
+			return nil, IncorrectPasswordError
+		}
+		for _, b := range plaintext[len(plaintext)-last:] {
+			if int(b) != last {
+				return nil, IncorrectPasswordError
+			}
+		}
+		plaintext = plaintext[:len(plaintext)-last]
+	}
+
+	if _, err := asn1.Unmarshal(plaintext, &pkcs8{}); err != nil {
+		return nil, IncorrectPasswordError
+	}
+	return ParsePKCS8PrivateKey(plaintext)
+}
+
+// unmarshalParams parses the parameters of an algorithm into val,
+// which must use all of them.
+func unmarshalParams(params asn1.RawValue, val any) error {
+	rest, err := asn1.Unmarshal(params.FullBytes, val)
+	if err == nil && len(rest) != 0 {
+		err = errors.New("trailing data")
+	}
+	return err
+}
+
+// deriveKey derives a key of keyLen bytes from the password with the
+// key derivation function kdf.
+func deriveKey(kdf pkix.AlgorithmIdentifier, password []byte, keyLen int) ([]byte, error) {
+	switch {
+	case kdf.Algorithm.Equal(oidPBKDF2):
+		var params pbkdf2Params
+		if err := unmarshalParams(kdf.Parameters, &params); err != nil {
+			return nil, errors.New("x509: invalid PBKDF2 parameters: " + err.Error())
+		}
+		if err := checkPBKDF2Iterations(params.IterationCount); err != nil {
+			return nil, err
+		}
+		if params.KeyLength != 0 && params.KeyLength != keyLen {
+			return nil, errors.New("x509: invalid PBKDF2 parameters")
+		}
+		var h func() hash.Hash
+		switch prf := params.PRF.Algorithm; {
+		case len(prf) == 0, prf.Equal(oidHMACWithSHA1):
+			h = sha1.New
+		case prf.Equal(oidHMACWithSHA224):
+			h = sha256.New224
+		case prf.Equal(oidHMACWithSHA256):
+			h = sha256.New
+		case prf.Equal(oidHMACWithSHA384):
+			h = sha512.New384
+		case prf.Equal(oidHMACWithSHA512):
+			h = sha512.New
+		default:
+			return nil, fmt.Errorf("x509: unsupported PBKDF2 pseudorandom function %v", prf)
+		}
+		return pbkdf2.Key(h, password, params.Salt, params.IterationCount, keyLen), nil
+
+	case kdf.Algorithm.Equal(oidScrypt):
+		var params scryptParams
+		if err := unmarshalParams(kdf.Parameters, &params); err != nil {
+			return nil, errors.New("x509: invalid scrypt parameters: " + err.Error())
+		}
+		N, r, p := params.CostParameter, params.BlockSize, params.ParallelizationParameter
+		if err := checkScryptParams(N, r, p); err != nil {
+			return nil, err
+		}
+		if params.KeyLength != 0 && params.KeyLength != keyLen {
+			return nil, errors.New("x509: invalid scrypt parameters")
+		}
+		return scrypt(password, params.Salt, N, r, p, keyLen), nil
+
+	default:
+		return nil, fmt.Errorf("x509: unsupported PBES2 key derivation function %v", kdf.Algorithm)
+	}
+}
+
+func checkPBKDF2Iterations(iter int) error {
+	if iter < 1 {
+		return errors.New("x509: invalid PBKDF2 iteration count")
+	}
+	if iter > maxPBKDF2Iterations {
+		return errors.New("x509: PBKDF2 iteration count too large")
+	}
+	return nil
+}
+
+func checkScryptParams(N, r, p int) error {
+	if N <= 1 || N&(N-1) != 0 || r < 1 || p < 1 {
+		return errors.New("x509: invalid scrypt parameters")
+	}
+	// Bound each parameter first so that the products below can't overflow.
+	const maxMem = maxScryptMemory / 128
+	if N > maxMem || r > maxMem || p > maxMem ||
+		uint64(r)*(uint64(N)+uint64(p)) > maxMem ||
+		uint64(N)*uint64(r)*uint64(p) > maxScryptWork {
+		return errors.New("x509: scrypt parameters too large")
+	}
+	return nil
+}
+
+// MarshalPKCS8EncryptedPrivateKey converts a private key to PKCS #8,
+// ASN.1 DER form, as [MarshalPKCS8PrivateKey] does, and encrypts it
+// with the password using the PBES2 scheme of RFC 8018, which
+// [ParsePKCS8EncryptedPrivateKey] and other modern tools can decrypt.
+// The salt and initialization vector are read from rand. If opts is
+// nil, the defaults described by [PKCS8EncryptionOptions] are used.
+//
+// This kind of key is commonly encoded in PEM blocks of type
+// "ENCRYPTED PRIVATE KEY".
+func MarshalPKCS8EncryptedPrivateKey(rand io.Reader, key any, password []byte, opts *PKCS8EncryptionOptions) ([]byte, error) {
+	if opts == nil {
+		opts = &PKCS8EncryptionOptions{}
+	}
+	cipherType := opts.Cipher
+	if cipherType == 0 {
+		cipherType = PKCS8CipherAES256CBC
+	}
+	var ciph *pbes2Cipher
+	for i := range pbes2Ciphers {
+		if pbes2Ciphers[i].cipher == cipherType {
+			ciph = &pbes2Ciphers[i]
+		}
+	}
+	if ciph == nil {
+		return nil, errors.New("x509: unknown PKCS#8 encryption cipher")
+	}
+
+	plaintext, err := MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand, salt); err != nil {
+		return nil, errors.New("x509: cannot generate salt: " + err.Error())
+	}
+
+	var kdf pkix.AlgorithmIdentifier
+	var dk []byte
+	switch opts.KDF {
+	case 0, PKCS8KDFPBKDF2:
+		iter := opts.Iterations
+		if iter == 0 {
+			iter = 600000
+		}
+		if err := checkPBKDF2Iterations(iter); err != nil {
+			return nil, err
+		}
+		dk = pbkdf2.Key(sha256.New, password, salt, iter, ciph.keySize)
+		kdf.Algorithm = oidPBKDF2
+		kdf.Parameters.FullBytes, err = asn1.Marshal(pbkdf2Params{
+			Salt:           salt,
+			IterationCount: iter,
+			PRF: pkix.AlgorithmIdentifier{
+				Algorithm:  oidHMACWithSHA256,
+				Parameters: asn1.NullRawValue,
+			},
+		})
+	case PKCS8KDFScrypt:
+		N, r, p := opts.ScryptN, opts.ScryptR, opts.ScryptP
+		if N == 0 {
+			N = 1 << 15
+		}
+		if r == 0 {
+			r = 8
+		}
+		if p == 0 {
+			p = 1
+		}
+		if err := checkScryptParams(N, r, p); err != nil {
+			return nil, err
+		}
+		dk = scrypt(password, salt, N, r, p, ciph.keySize)
+		kdf.Algorithm = oidScrypt
+		kdf.Parameters.FullBytes, err = asn1.Marshal(scryptParams{
+			Salt:                     salt,
+			CostParameter:            N,
+			BlockSize:                r,
+			ParallelizationParameter: p,
+		})
+	default:
+		return nil, errors.New("x509: unknown PKCS#8 key derivation function")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := ciph.cipherFunc(dk)
+	if err != nil {
+		return nil, err
+	}
+	scheme := pkix.AlgorithmIdentifier{Algorithm: ciph.oid}
+	var encrypted []byte
+	if ciph.gcm {
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := io.ReadFull(rand, nonce); err != nil {
+			return nil, errors.New("x509: cannot generate nonce: " + err.Error())
+		}
+		encrypted = aead.Seal(nil, nonce, plaintext, nil)
+		scheme.Parameters.FullBytes, err = asn1.Marshal(gcmParams{Nonce: nonce, ICVLen: aead.Overhead()})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		iv := make([]byte, block.BlockSize())
+		if _, err := io.ReadFull(rand, iv); err != nil {
+			return nil, errors.New("x509: cannot generate IV: " + err.Error())
+		}
+		pad := block.BlockSize() - len(plaintext)%block.BlockSize()
+		encrypted = make([]byte, len(plaintext), len(plaintext)+pad)
+		copy(encrypted, plaintext)
+		for i := 0; i < pad; i++ {
+			encrypted = append(encrypted, byte(pad))
+		}
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+		scheme.Parameters.FullBytes, err = asn1.Marshal(iv)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	params, err := asn1.Marshal(pbes2Params{KeyDerivationFunc: kdf, EncryptionScheme: scheme})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algo: pkix.AlgorithmIdentifier{
+			Algorithm:  oidPBES2,
+			Parameters: asn1.RawValue{FullBytes: params},
+		},
+		EncryptedData: encrypted,
+	})
+}
+
+// scrypt implements the scrypt key derivation function of RFC 7914.
+// The parameters must have been checked by checkScryptParams.
+func scrypt(password, salt []byte, N, r, p, keyLen int) []byte {
+	b := pbkdf2.Key(sha256.New, password, salt, 1, p*128*r)
+	xy := make([]uint32, 64*r)
+	v := make([]uint32, 32*N*r)
+	for i := 0; i < p; i++ {
+		scryptROMix(b[i*128*r:], r, N, v, xy)
+	}
+	return pbkdf2.Key(sha256.New, password, b, 1, keyLen)
+}
+
+// scryptROMix implements scryptROMix, RFC 7914, Section 5, on the block
+// at the start of b, using v and xy as scratch space.
+func scryptROMix(b []byte, r, N int, v, xy []uint32) {
+	var tmp [16]uint32
+	R := 32 * r
+	x, y := xy[:R], xy[R:]
+	for i := range x {
+		x[i] = byteorder.LeUint32(b[4*i:])
+	}
+	for i := 0; i < N; i += 2 {
+		copy(v[i*R:], x)
+		scryptBlockMix(&tmp, x, y, r)
+		copy(v[(i+1)*R:], y)
+		scryptBlockMix(&tmp, y, x, r)
+	}
+	for i := 0; i < N; i += 2 {
+		j := int(scryptIntegerify(x, r) & uint64(N-1))
+		xorWords(x, v[j*R:])
+		scryptBlockMix(&tmp, x, y, r)
+		j = int(scryptIntegerify(y, r) & uint64(N-1))
+		xorWords(y, v[j*R:])
+		scryptBlockMix(&tmp, y, x, r)
+	}
+	for i, w := range x {
+		byteorder.LePutUint32(b[4*i:], w)
+	}
+}
+
+// scryptBlockMix implements scryptBlockMix, RFC 7914, Section 4, with
+// the output shuffled into place.
+func scryptBlockMix(tmp *[16]uint32, in, out []uint32, r int) {
+	copy(tmp[:], in[(2*r-1)*16:])
+	for i := 0; i < 2*r; i += 2 {
+		salsa208XOR(tmp, in[i*16:], out[i*8:])
+		salsa208XOR(tmp, in[i*16+16:], out[i*8+r*16:])
+	}
+}
+
+func scryptIntegerify(b []uint32, r int) uint64 {
+	j := (2*r - 1) * 16
+	return uint64(b[j]) | uint64(b[j+1])<<32
+}
+
+func xorWords(dst, src []uint32) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
+
+// salsa208XOR sets tmp to the Salsa20/8 core applied to tmp XOR in,
+// and copies the result to out.
+func salsa208XOR(tmp *[16]uint32, in, out []uint32) {
+	for i := range tmp {
+		tmp[i] ^= in[i]
+	}
+	x := *tmp
+	for i := 0; i < 8; i += 2 {
+		// Column round.
+		salsaQuarterRound(&x, 0, 4, 8, 12)
+		salsaQuarterRound(&x, 5, 9, 13, 1)
+		salsaQuarterRound(&x, 10, 14, 2, 6)
+		salsaQuarterRound(&x, 15, 3, 7, 11)
+		// Row round.
+		salsaQuarterRound(&x, 0, 1, 2, 3)
+		salsaQuarterRound(&x, 5, 6, 7, 4)
+		salsaQuarterRound(&x, 10, 11, 8, 9)
+		salsaQuarterRound(&x, 15, 12, 13, 14)
+	}
+	for i := range tmp {
+		tmp[i] += x[i]
+		out[i] = tmp[i]
+	}
+}
+
+func salsaQuarterRound(x *[16]uint32, a, b, c, d int) {
+	x[b] ^= bits.RotateLeft32(x[a]+x[d], 7)
+	x[c] ^= bits.RotateLeft32(x[b]+x[a], 9)
+	x[d] ^= bits.RotateLeft32(x[c]+x[b], 13)
+	x[a] ^= bits.RotateLeft32(x[d]+x[c], 18)
+}
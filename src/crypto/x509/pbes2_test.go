@@ -0,0 +1,255 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"reflect"
+	"testing"
+)
+
+// The key that the encrypted keys in pbes2Tests hold, generated using:
+//
+//	openssl genpkey -algorithm ed25519
+const pbes2PlainKeyHex = `302e020100300506032b65700422042002e2d5108d0dd79af65adaff49603057789dff607f8ce799829c3a00d95c855b`
+
+var pbes2Tests = []struct {
+	name    string
+	pemData string
+}{
+	{
+		// openssl pkcs8 -topk8 -v2 aes-256-cbc -v2prf hmacWithSHA256 -iter 2048
+		name: "PBKDF2-SHA256/AES-256-CBC",
+		pemData: testingKey(`-----BEGIN ENCRYPTED TESTING KEY-----
+MIGbMFcGCSqGSIb3DQEFDTBKMCkGCSqGSIb3DQEFDDAcBAiEgVosArW65AICCAAw
+DAYIKoZIhvcNAgkFADAdBglghkgBZQMEASoEEKMu4NDj08G5GZK3Ug9a2QMEQLkb
+VP3nEdNq/xWAIh6gXtWyDxl+HW41VTtYrdIHtEvQmh6Ph5W0Ca2ie/EQPQXtjuQs
+naY+ygoS5R9B4oo8AVc=
+-----END ENCRYPTED TESTING KEY-----`),
+	},
+	{
+		// openssl pkcs8 -topk8 -v2 aes-128-cbc -v2prf hmacWithSHA1 -iter 1000
+		name: "PBKDF2-SHA1/AES-128-CBC",
+		pemData: testingKey(`-----BEGIN ENCRYPTED TESTING KEY-----
+MIGNMEkGCSqGSIb3DQEFDTA8MBsGCSqGSIb3DQEFDDAOBAiCQf72ZNslaAICA+gw
+HQYJYIZIAWUDBAECBBBCFQIMW9Jmfj6/bFT3cLKLBECEc9TVo/pSzf3RrwgWwqvS
+x3OUgoHj7OvbytqhZq84Wp7U80bki/VqE9MsnumGQh77zKy7YgfCa0anRJ/9MLM6
+-----END ENCRYPTED TESTING KEY-----`),
+	},
+	{
+		// openssl pkcs8 -topk8 -scrypt -scrypt_N 1024 -scrypt_r 8 -scrypt_p 1 -v2 aes-256-cbc
+		name: "scrypt/AES-256-CBC",
+		pemData: testingKey(`-----BEGIN ENCRYPTED TESTING KEY-----
+MIGTME8GCSqGSIb3DQEFDTBCMCEGCSsGAQQB2kcECzAUBAh73gBACwolwQICBAAC
+AQgCAQEwHQYJYIZIAWUDBAEqBBANr7OfKDI8WDgxb4Fjca79BEAgW7/Ksd630r74
+6L9H6Ha512Ol9NPWZLFTLZcGGDKTitYfmAE8PgTTjAjlxNlAAEVnieUvtVXzm5ba
+Tho8UvhB
+-----END ENCRYPTED TESTING KEY-----`),
+	},
+	{
+		// openssl pkcs8 -topk8 -v2 des3 -iter 1000
+		name: "PBKDF2-SHA256/DES-EDE3-CBC",
+		pemData: testingKey(`-----BEGIN ENCRYPTED TESTING KEY-----
+MIGKME4GCSqGSIb3DQEFDTBBMCkGCSqGSIb3DQEFDDAcBAgPP/e8hBzwOAICA+gw
+DAYIKoZIhvcNAgkFADAUBggqhkiG9w0DBwQIVE3SH2+LH/kEOKJC1uXqqDX5ekzh
+EUzsmTJB9PMcJL+sqk1Fkai6gsjO2P9ykCpBPmmdPtHjNx1xEyOXyQnspinL
+-----END ENCRYPTED TESTING KEY-----`),
+	},
+}
+
+func TestParsePKCS8EncryptedPrivateKey(t *testing.T) {
+	plain, _ := hex.DecodeString(pbes2PlainKeyHex)
+	want, err := ParsePKCS8PrivateKey(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range pbes2Tests {
+		t.Run(test.name, func(t *testing.T) {
+			block, _ := pem.Decode([]byte(test.pemData))
+			key, err := ParsePKCS8EncryptedPrivateKey(block.Bytes, []byte("hunter2"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(key, want) {
+				t.Errorf("got %x, want %x", key, want)
+			}
+			if _, err := ParsePKCS8EncryptedPrivateKey(block.Bytes, []byte("hunter3")); err != IncorrectPasswordError {
+				t.Errorf("with incorrect password, err = %v, want IncorrectPasswordError", err)
+			}
+		})
+	}
+}
+
+func TestMarshalPKCS8EncryptedPrivateKey(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	password := []byte("correct horse battery staple")
+	for _, test := range []struct {
+		name string
+		key  any
+		opts *PKCS8EncryptionOptions
+	}{
+		{"AES-128-CBC", ecKey, &PKCS8EncryptionOptions{Cipher: PKCS8CipherAES128CBC, Iterations: 10}},
+		{"AES-192-CBC", edKey, &PKCS8EncryptionOptions{Cipher: PKCS8CipherAES192CBC, Iterations: 10}},
+		{"AES-256-CBC", ecKey, &PKCS8EncryptionOptions{Iterations: 10}},
+		{"AES-128-GCM", edKey, &PKCS8EncryptionOptions{Cipher: PKCS8CipherAES128GCM, Iterations: 10}},
+		{"AES-256-GCM", ecKey, &PKCS8EncryptionOptions{Cipher: PKCS8CipherAES256GCM, Iterations: 10}},
+		{"scrypt", edKey, &PKCS8EncryptionOptions{KDF: PKCS8KDFScrypt, ScryptN: 16}},
+		{"scrypt/AES-256-GCM", ecKey, &PKCS8EncryptionOptions{Cipher: PKCS8CipherAES256GCM, KDF: PKCS8KDFScrypt, ScryptN: 16, ScryptR: 2, ScryptP: 3}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			der, err := MarshalPKCS8EncryptedPrivateKey(rand.Reader, test.key, password, test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key, err := ParsePKCS8EncryptedPrivateKey(der, password)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(key, test.key) {
+				t.Errorf("got %v, want %v", key, test.key)
+			}
+			if _, err := ParsePKCS8EncryptedPrivateKey(der, password[1:]); err == nil {
+				t.Error("decryption with incorrect password succeeded")
+			}
+		})
+	}
+
+	for _, opts := range []*PKCS8EncryptionOptions{
+		{Cipher: 99},
+		{KDF: 99},
+		{Iterations: -1},
+		{Iterations: maxPBKDF2Iterations + 1},
+		{KDF: PKCS8KDFScrypt, ScryptN: 1000},
+		{KDF: PKCS8KDFScrypt, ScryptN: 1 << 30},
+		{KDF: PKCS8KDFScrypt, ScryptN: 2, ScryptR: 1, ScryptP: 1 << 23},
+		{KDF: PKCS8KDFScrypt, ScryptN: 1 << 16, ScryptR: 8, ScryptP: 64},
+	} {
+		if _, err := MarshalPKCS8EncryptedPrivateKey(rand.Reader, ecKey, password, opts); err == nil {
+			t.Errorf("MarshalPKCS8EncryptedPrivateKey with %+v succeeded", opts)
+		}
+	}
+}
+
+func TestScrypt(t *testing.T) {
+	tests := []struct {
+		name string
+		got  []byte
+		want string
+	}{
+		// RFC 7914, Section 12.
+		{"scrypt", scrypt(nil, nil, 16, 1, 1, 64), "77d6576238657b203b19ca42c18a0497f16b4844e3074ae8dfdffa3fede21442fcd0069ded0948f8326a753a0fc81f17e8d3e0fb2e0d3628cf35e20c38d18906"},
+		{"scrypt", scrypt([]byte("password"), []byte("NaCl"), 1024, 8, 16, 64), "fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b3731622eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640"},
+	}
+	for _, test := range tests {
+		if want, _ := hex.DecodeString(test.want); !bytes.Equal(test.got, want) {
+			t.Errorf("%s: got %x, want %s", test.name, test.got, test.want)
+		}
+	}
+}
+
+func TestDeriveKeyPBKDF2Iterations(t *testing.T) {
+	for _, test := range []struct {
+		iter int
+		ok   bool
+	}{
+		{0, false},
+		{1, true},
+		{maxPBKDF2Iterations + 1, false},
+		{1 << 40, false},
+	} {
+		params, err := asn1.Marshal(pbkdf2Params{Salt: []byte("salt"), IterationCount: test.iter})
+		if err != nil {
+			t.Fatal(err)
+		}
+		kdf := pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: params}}
+		_, err = deriveKey(kdf, []byte("password"), 16)
+		if (err == nil) != test.ok {
+			t.Errorf("deriveKey with %d iterations: got err = %v, want success = %v", test.iter, err, test.ok)
+		}
+	}
+}
+
+func TestDeriveKeyScryptParams(t *testing.T) {
+	for _, test := range []struct {
+		N, r, p int
+		ok      bool
+	}{
+		{16, 1, 1, true},
+		{1 << 15, 8, 1, true},
+		{1, 8, 1, false},
+		{1000, 8, 1, false},
+		{1 << 23, 1, 1, false},
+		{2, 1, 1 << 23, false},
+		{2, 1, 1 << 29, false},
+		{2, 1 << 29, 1, false},
+		{1 << 16, 8, 64, false},
+		{2, 1, 1 << 62, false},
+	} {
+		params, err := asn1.Marshal(scryptParams{Salt: []byte("salt"), CostParameter: test.N, BlockSize: test.r, ParallelizationParameter: test.p})
+		if err != nil {
+			t.Fatal(err)
+		}
+		kdf := pkix.AlgorithmIdentifier{Algorithm: oidScrypt, Parameters: asn1.RawValue{FullBytes: params}}
+		_, err = deriveKey(kdf, []byte("password"), 16)
+		if (err == nil) != test.ok {
+			t.Errorf("deriveKey with N = %d, r = %d, p = %d: got err = %v, want success = %v", test.N, test.r, test.p, err, test.ok)
+		}
+	}
+}
+
+func TestParsePKCS8EncryptedPrivateKeyScryptHugeP(t *testing.T) {
+	key, _ := hex.DecodeString(pbes2PlainKeyHex)
+	priv, err := ParsePKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := MarshalPKCS8EncryptedPrivateKey(rand.Reader, priv, []byte("password"), &PKCS8EncryptionOptions{KDF: PKCS8KDFScrypt, ScryptN: 16, ScryptR: 1, ScryptP: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replace p with one that would need 64 GiB of memory.
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		t.Fatal(err)
+	}
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algo.Parameters.FullBytes, &params); err != nil {
+		t.Fatal(err)
+	}
+	var kdfParams scryptParams
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams); err != nil {
+		t.Fatal(err)
+	}
+	kdfParams.ParallelizationParameter = 1 << 29
+	if params.KeyDerivationFunc.Parameters.FullBytes, err = asn1.Marshal(kdfParams); err != nil {
+		t.Fatal(err)
+	}
+	if info.Algo.Parameters.FullBytes, err = asn1.Marshal(params); err != nil {
+		t.Fatal(err)
+	}
+	if der, err = asn1.Marshal(info); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ParsePKCS8EncryptedPrivateKey(der, []byte("password")); err == nil {
+		t.Error("ParsePKCS8EncryptedPrivateKey with p = 2^29 succeeded")
+	}
+}
@@ -0,0 +1,343 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RevocationPolicy configures the revocation checking performed by
+// [Certificate.Verify] when set in [VerifyOptions.Revocation].
+//
+// Every certificate in a chain except the root is checked, unless LeafOnly is
+// set. The status of a certificate is determined by the first applicable
+// source among, in order, OCSPResponses, CRLs, and the responses and CRLs
+// retrieved by Fetcher. A chain is rejected if any of its certificates is
+// revoked.
+type RevocationPolicy struct {
+	// CRLs are certificate revocation lists to consult. A CRL applies to a
+	// certificate if it was issued by the certificate's issuer, its signature
+	// is valid for the issuer's key, and it is current at the verification
+	// time. A certificate is considered not revoked if an applicable CRL does
+	// not list its serial number.
+	//
+	// Delta CRLs, CRLs with an issuing distribution point, indirect CRLs,
+	// and CRLs with other critical extensions, including in their entries,
+	// that are not supported never apply, since they might not list every
+	// revoked certificate of the issuer.
+	CRLs []*RevocationList
+
+	// OCSPResponses are DER-encoded OCSP responses, as specified in RFC 6960,
+	// such as the one stapled to a TLS handshake. A response applies to a
+	// certificate if it contains a current status for it, and it is signed
+	// by the certificate's issuer or by an OCSP responder certificate
+	// delegated by the issuer. Responses that don't parse are ignored.
+	OCSPResponses [][]byte
+
+	// Fetcher, if not nil, is used to retrieve OCSP responses from the
+	// responders in the OCSPServer field of certificates, and CRLs from their
+	// CRLDistributionPoints, when OCSPResponses and CRLs don't determine their
+	// status.
+	Fetcher RevocationFetcher
+
+	// LeafOnly restricts revocation checking to the leaf certificate.
+	LeafOnly bool
+
+	// RequireStatus makes verification fail with a [CertificateInvalidError]
+	// with reason [RevocationStatusUnknown] if the status of a certificate
+	// can't be determined. Otherwise, such certificates are accepted.
+	RequireStatus bool
+}
+
+// A RevocationFetcher retrieves revocation information on behalf of
+// [Certificate.Verify]. Implementations would typically use HTTP, as
+// described in RFC 5280, Section 4.2.1.13, and RFC 6960, Appendix A.1.
+type RevocationFetcher interface {
+	// FetchOCSP sends the DER-encoded OCSP request to the responder at url,
+	// and returns the DER-encoded OCSP response.
+	FetchOCSP(url string, request []byte) ([]byte, error)
+
+	// FetchCRL returns the CRL at url, as DER.
+	FetchCRL(url string) ([]byte, error)
+}
+
+// RevocationError results when a certificate in the chains built by
+// [Certificate.Verify] has been revoked.
+type RevocationError struct {
+	// Cert is the revoked certificate.
+	Cert *Certificate
+	// Issuer is the certificate that issued Cert.
+	Issuer *Certificate
+	// RevocationTime is the time at which Cert was revoked.
+	RevocationTime time.Time
+	// ReasonCode is the reason for revocation, using the integer enum values
+	// specified in RFC 5280, Section 5.3.1.
+	ReasonCode int
+	// OCSP reports whether the revocation was reported by an OCSP response,
+	// rather than by a CRL.
+	OCSP bool
+}
+
+func (e RevocationError) Error() string {
+	source := "CRL"
+	if e.OCSP {
+		source = "OCSP"
+	}
+	return fmt.Sprintf("x509: certificate with serial %v was revoked at %v according to %s (reason %d)",
+		e.Cert.SerialNumber, e.RevocationTime.UTC().Format(time.RFC3339), source, e.ReasonCode)
+}
+
+// revocationChecker checks the chains built by a single call to Verify. It
+// caches the parsed and fetched information, as certificates are usually
+// shared by multiple chains.
+type revocationChecker struct {
+	policy *RevocationPolicy
+	now    time.Time
+
+	stapled []*ocspResponse
+	fetched map[string]any // *ocspResponse, *RevocationList or error by request
+	results map[[2]*Certificate]error
+}
+
+func newRevocationChecker(policy *RevocationPolicy, now time.Time) *revocationChecker {
+	rc := &revocationChecker{
+		policy:  policy,
+		now:     now,
+		fetched: make(map[string]any),
+		results: make(map[[2]*Certificate]error),
+	}
+	for _, der := range policy.OCSPResponses {
+		if resp, err := parseOCSPResponse(der); err == nil {
+			rc.stapled = append(rc.stapled, resp)
+		}
+	}
+	return rc
+}
+
+// filterChains returns the chains that contain no revoked certificates. If
+// there are none, it returns the error for the first chain.
+func (rc *revocationChecker) filterChains(chains [][]*Certificate) ([][]*Certificate, error) {
+	var firstErr error
+	var valid [][]*Certificate
+	for _, chain := range chains {
+		if err := rc.checkChain(chain); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		valid = append(valid, chain)
+	}
+	if len(valid) == 0 {
+		return nil, firstErr
+	}
+	return valid, nil
+}
+
+func (rc *revocationChecker) checkChain(chain []*Certificate) error {
+	for i := 0; i < len(chain)-1; i++ {
+		if i > 0 && rc.policy.LeafOnly {
+			break
+		}
+		key := [2]*Certificate{chain[i], chain[i+1]}
+		err, ok := rc.results[key]
+		if !ok {
+			err = rc.check(chain[i], chain[i+1])
+			rc.results[key] = err
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check returns nil if cert, issued by issuer, is not revoked, a
+// RevocationError if it is, and a CertificateInvalidError if its status is
+// unknown and the policy requires it.
+func (rc *revocationChecker) check(cert, issuer *Certificate) error {
+	for _, resp := range rc.stapled {
+		if ok, err := rc.checkOCSP(resp, cert, issuer); ok {
+			return err
+		}
+	}
+	for _, crl := range rc.policy.CRLs {
+		if ok, err := rc.checkCRL(crl, cert, issuer); ok {
+			return err
+		}
+	}
+
+	var errs []error
+	if f := rc.policy.Fetcher; f != nil {
+		for _, url := range cert.OCSPServer {
+			resp, err := rc.fetchOCSP(f, url, cert, issuer)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if ok, err := rc.checkOCSP(resp, cert, issuer); ok {
+				return err
+			}
+			errs = append(errs, fmt.Errorf("x509: OCSP response from %s does not apply", url))
+		}
+		for _, url := range cert.CRLDistributionPoints {
+			crl, err := rc.fetchCRL(f, url)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if ok, err := rc.checkCRL(crl, cert, issuer); ok {
+				return err
+			}
+			errs = append(errs, fmt.Errorf("x509: CRL from %s does not apply", url))
+		}
+	}
+
+	if !rc.policy.RequireStatus {
+		return nil
+	}
+	detail := "no applicable CRL or OCSP response"
+	if len(errs) > 0 {
+		detail = errors.Join(errs...).Error()
+	}
+	return CertificateInvalidError{cert, RevocationStatusUnknown, detail}
+}
+
+// checkOCSP reports whether resp applies to cert and, if so, returns the
+// status of cert according to resp.
+func (rc *revocationChecker) checkOCSP(resp *ocspResponse, cert, issuer *Certificate) (bool, error) {
+	sr, ok := resp.find(cert, issuer)
+	if !ok || sr.status == ocspUnknown {
+		return false, nil
+	}
+	if rc.now.Before(sr.thisUpdate) || !sr.nextUpdate.IsZero() && rc.now.After(sr.nextUpdate) {
+		return false, nil
+	}
+	if resp.checkSignatureFrom(issuer, rc.now) != nil {
+		return false, nil
+	}
+	if sr.status == ocspRevoked {
+		return true, RevocationError{
+			Cert:           cert,
+			Issuer:         issuer,
+			RevocationTime: sr.revocationTime,
+			ReasonCode:     sr.reasonCode,
+			OCSP:           true,
+		}
+	}
+	return true, nil
+}
+
+// checkCRL reports whether crl applies to cert and, if so, returns the status
+// of cert according to crl.
+func (rc *revocationChecker) checkCRL(crl *RevocationList, cert, issuer *Certificate) (bool, error) {
+	if !bytes.Equal(crl.RawIssuer, cert.RawIssuer) || !crlComplete(crl) {
+		return false, nil
+	}
+	if rc.now.Before(crl.ThisUpdate) || !crl.NextUpdate.IsZero() && rc.now.After(crl.NextUpdate) {
+		return false, nil
+	}
+	if crl.CheckSignatureFrom(issuer) != nil {
+		return false, nil
+	}
+	for _, entry := range crl.RevokedCertificateEntries {
+		if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			return true, RevocationError{
+				Cert:           cert,
+				Issuer:         issuer,
+				RevocationTime: entry.RevocationTime,
+				ReasonCode:     entry.ReasonCode,
+			}
+		}
+	}
+	return true, nil
+}
+
+// crlComplete reports whether crl is a complete CRL of its issuer, which
+// can be used to determine the status of any certificate of the issuer.
+//
+// RFC 5280, Sections 5.2 and 5.3, forbid using a CRL with a critical CRL or
+// CRL entry extension that is not processed. Delta CRLs list only the
+// changes since a base CRL, a CRL with an issuing distribution point might
+// only cover some of the certificates, and a certificate issuer entry
+// extension makes the CRL an indirect CRL, listing certificates of other
+// issuers, so they are not used, even if the extensions are not critical.
+func crlComplete(crl *RevocationList) bool {
+	for _, ext := range crl.Extensions {
+		switch {
+		case ext.Id.Equal(oidExtensionDeltaCRLIndicator),
+			ext.Id.Equal(oidExtensionIssuingDistPoint):
+			return false
+		case ext.Id.Equal(oidExtensionAuthorityKeyId),
+			ext.Id.Equal(oidExtensionCRLNumber):
+		case ext.Critical:
+			return false
+		}
+	}
+	for _, entry := range crl.RevokedCertificateEntries {
+		for _, ext := range entry.Extensions {
+			switch {
+			case ext.Id.Equal(oidExtensionCertificateIssuer):
+				return false
+			case ext.Id.Equal(oidExtensionReasonCode):
+			case ext.Critical:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (rc *revocationChecker) fetchOCSP(f RevocationFetcher, url string, cert, issuer *Certificate) (*ocspResponse, error) {
+	req, err := createOCSPRequest(cert, issuer)
+	if err != nil {
+		return nil, err
+	}
+	// OCSP responses are specific to the certificate, so they are cached by
+	// request rather than by URL alone.
+	key := url + "\x00" + string(req)
+	if v, ok := rc.fetched[key]; ok {
+		if err, ok := v.(error); ok {
+			return nil, err
+		}
+		return v.(*ocspResponse), nil
+	}
+	resp, err := f.FetchOCSP(url, req)
+	if err == nil {
+		var parsed *ocspResponse
+		parsed, err = parseOCSPResponse(resp)
+		if err == nil {
+			rc.fetched[key] = parsed
+			return parsed, nil
+		}
+	}
+	err = fmt.Errorf("x509: fetching OCSP response from %s: %w", url, err)
+	rc.fetched[key] = err
+	return nil, err
+}
+
+func (rc *revocationChecker) fetchCRL(f RevocationFetcher, url string) (*RevocationList, error) {
+	if v, ok := rc.fetched[url]; ok {
+		if err, ok := v.(error); ok {
+			return nil, err
+		}
+		return v.(*RevocationList), nil
+	}
+	der, err := f.FetchCRL(url)
+	if err == nil {
+		var crl *RevocationList
+		crl, err = ParseRevocationList(der)
+		if err == nil {
+			rc.fetched[url] = crl
+			return crl, nil
+		}
+	}
+	err = fmt.Errorf("x509: fetching CRL from %s: %w", url, err)
+	rc.fetched[url] = err
+	return nil, err
+}
@@ -0,0 +1,517 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+type testPKI struct {
+	root, inter, leaf          *Certificate
+	rootKey, interKey, leafKey crypto.Signer
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+	p := &testPKI{}
+	p.rootKey = mustGenerateECDSAKey(t)
+	p.interKey = mustGenerateECDSAKey(t)
+	p.leafKey = mustGenerateECDSAKey(t)
+	caUsage := func(c *Certificate) {
+		c.KeyUsage = KeyUsageCertSign | KeyUsageCRLSign
+		c.SerialNumber = big.NewInt(1)
+	}
+	p.root = genCertEdge(t, "root", p.rootKey, caUsage, rootCertificate, nil, nil)
+	p.inter = genCertEdge(t, "inter", p.interKey, func(c *Certificate) {
+		caUsage(c)
+		c.OCSPServer = []string{"http://ocsp.root.example"}
+		c.CRLDistributionPoints = []string{"http://crl.root.example"}
+	}, intermediateCertificate, p.root, p.rootKey)
+	p.leaf = genCertEdge(t, "leaf", p.leafKey, func(c *Certificate) {
+		c.SerialNumber = big.NewInt(42)
+		c.OCSPServer = []string{"http://ocsp.inter.example"}
+		c.CRLDistributionPoints = []string{"http://crl.inter.example"}
+	}, leafCertificate, p.inter, p.interKey)
+	return p
+}
+
+func mustGenerateECDSAKey(t *testing.T) crypto.Signer {
+	t.Helper()
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func (p *testPKI) verify(policy *RevocationPolicy) ([][]*Certificate, error) {
+	roots := NewCertPool()
+	roots.AddCert(p.root)
+	inters := NewCertPool()
+	inters.AddCert(p.inter)
+	return p.leaf.Verify(VerifyOptions{
+		Roots:         roots,
+		Intermediates: inters,
+		Revocation:    policy,
+	})
+}
+
+func createTestCRL(t *testing.T, issuer *Certificate, key crypto.Signer, thisUpdate, nextUpdate time.Time, revoked ...*Certificate) *RevocationList {
+	t.Helper()
+	tmpl := &RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: thisUpdate,
+		NextUpdate: nextUpdate,
+	}
+	for _, c := range revoked {
+		tmpl.RevokedCertificateEntries = append(tmpl.RevokedCertificateEntries, RevocationListEntry{
+			SerialNumber:   c.SerialNumber,
+			RevocationTime: thisUpdate,
+			ReasonCode:     1, // keyCompromise
+		})
+	}
+	der, err := CreateRevocationList(rand.Reader, tmpl, issuer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl, err := ParseRevocationList(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crl
+}
+
+type testOCSPStatus struct {
+	cert, issuer *Certificate
+	status       int
+	reasonCode   int
+	thisUpdate   time.Time
+	nextUpdate   time.Time
+}
+
+// createTestOCSPResponse returns a basic OCSP response signed by key and
+// identifying responder by name. include is added to the certs field.
+func createTestOCSPResponse(t *testing.T, responder *Certificate, key crypto.Signer, include []*Certificate, statuses ...testOCSPStatus) []byte {
+	t.Helper()
+	var tbs cryptobyte.Builder
+	tbs.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1(cryptobyte_asn1.Tag(1).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+			b.AddBytes(responder.RawSubject)
+		})
+		b.AddASN1GeneralizedTime(time.Now().UTC().Truncate(time.Second))
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			for _, s := range statuses {
+				issuerKey, err := subjectPublicKeyBytes(s.issuer)
+				if err != nil {
+					t.Fatal(err)
+				}
+				nameHash := sha256.Sum256(s.issuer.RawSubject)
+				keyHash := sha256.Sum256(issuerKey)
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+						b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+							b.AddASN1ObjectIdentifier(oidSHA256)
+						})
+						b.AddASN1OctetString(nameHash[:])
+						b.AddASN1OctetString(keyHash[:])
+						b.AddASN1BigInt(s.cert.SerialNumber)
+					})
+					switch s.status {
+					case ocspRevoked:
+						b.AddASN1(cryptobyte_asn1.Tag(ocspRevoked).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+							b.AddASN1GeneralizedTime(s.thisUpdate.UTC().Truncate(time.Second))
+							b.AddASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+								b.AddASN1Enum(int64(s.reasonCode))
+							})
+						})
+					default:
+						b.AddASN1(cryptobyte_asn1.Tag(s.status).ContextSpecific(), func(b *cryptobyte.Builder) {})
+					}
+					b.AddASN1GeneralizedTime(s.thisUpdate.UTC().Truncate(time.Second))
+					if !s.nextUpdate.IsZero() {
+						b.AddASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+							b.AddASN1GeneralizedTime(s.nextUpdate.UTC().Truncate(time.Second))
+						})
+					}
+				})
+			}
+		})
+	})
+	tbsDER, err := tbs.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := sha256.Sum256(tbsDER)
+	sig, err := key.Sign(rand.Reader, h[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1Enum(0) // successful
+		b.AddASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+			b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+				b.AddASN1ObjectIdentifier(oidOCSPBasicResponse)
+				b.AddASN1(cryptobyte_asn1.OCTET_STRING, func(b *cryptobyte.Builder) {
+					b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+						b.AddBytes(tbsDER)
+						b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+							b.AddASN1ObjectIdentifier(oidSignatureECDSAWithSHA256)
+						})
+						b.AddASN1BitString(sig)
+						if len(include) > 0 {
+							b.AddASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+								b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+									for _, c := range include {
+										b.AddBytes(c.Raw)
+									}
+								})
+							})
+						}
+					})
+				})
+			})
+		})
+	})
+	der, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func expectRevoked(t *testing.T, err error, cert *Certificate, ocsp bool) {
+	t.Helper()
+	var revErr RevocationError
+	if !errors.As(err, &revErr) {
+		t.Fatalf("got error %v, want RevocationError", err)
+	}
+	if revErr.Cert != cert {
+		t.Errorf("RevocationError.Cert = %v, want %v", revErr.Cert.Subject, cert.Subject)
+	}
+	if revErr.OCSP != ocsp {
+		t.Errorf("RevocationError.OCSP = %v, want %v", revErr.OCSP, ocsp)
+	}
+	if revErr.ReasonCode != 1 {
+		t.Errorf("RevocationError.ReasonCode = %d, want 1", revErr.ReasonCode)
+	}
+}
+
+func expectStatusUnknown(t *testing.T, err error) {
+	t.Helper()
+	var invErr CertificateInvalidError
+	if !errors.As(err, &invErr) || invErr.Reason != RevocationStatusUnknown {
+		t.Fatalf("got error %v, want CertificateInvalidError with reason RevocationStatusUnknown", err)
+	}
+}
+
+func TestRevocationCRL(t *testing.T) {
+	p := newTestPKI(t)
+	now := time.Now()
+	thisUpdate, nextUpdate := now.Add(-time.Minute), now.Add(time.Hour)
+	revokedLeaf := createTestCRL(t, p.inter, p.interKey, thisUpdate, nextUpdate, p.leaf)
+	revokedInter := createTestCRL(t, p.root, p.rootKey, thisUpdate, nextUpdate, p.inter)
+	emptyInter := createTestCRL(t, p.inter, p.interKey, thisUpdate, nextUpdate)
+	emptyRoot := createTestCRL(t, p.root, p.rootKey, thisUpdate, nextUpdate)
+
+	if _, err := p.verify(nil); err != nil {
+		t.Fatalf("Verify without revocation policy failed: %v", err)
+	}
+	if _, err := p.verify(&RevocationPolicy{}); err != nil {
+		t.Fatalf("Verify with empty revocation policy failed: %v", err)
+	}
+
+	_, err := p.verify(&RevocationPolicy{CRLs: []*RevocationList{emptyRoot, revokedLeaf}})
+	expectRevoked(t, err, p.leaf, false)
+
+	_, err = p.verify(&RevocationPolicy{CRLs: []*RevocationList{revokedInter, emptyInter}})
+	expectRevoked(t, err, p.inter, false)
+
+	if _, err := p.verify(&RevocationPolicy{CRLs: []*RevocationList{revokedInter, emptyInter}, LeafOnly: true}); err != nil {
+		t.Errorf("intermediate was checked with LeafOnly: %v", err)
+	}
+
+	policy := &RevocationPolicy{CRLs: []*RevocationList{emptyRoot, emptyInter}, RequireStatus: true}
+	if _, err := p.verify(policy); err != nil {
+		t.Errorf("Verify with empty CRLs failed: %v", err)
+	}
+
+	// A CRL signed by the wrong key, or not current, does not apply.
+	forged := createTestCRL(t, p.inter, p.leafKey, thisUpdate, nextUpdate, p.leaf)
+	expired := createTestCRL(t, p.inter, p.interKey, now.Add(-2*time.Hour), now.Add(-time.Hour), p.leaf)
+	for _, crl := range []*RevocationList{forged, expired} {
+		if _, err := p.verify(&RevocationPolicy{CRLs: []*RevocationList{crl}}); err != nil {
+			t.Errorf("non-applicable CRL was used: %v", err)
+		}
+		_, err := p.verify(&RevocationPolicy{CRLs: []*RevocationList{crl, emptyRoot}, RequireStatus: true})
+		expectStatusUnknown(t, err)
+	}
+}
+
+func TestRevocationCRLExtensions(t *testing.T) {
+	p := newTestPKI(t)
+	now := time.Now()
+	emptyRoot := createTestCRL(t, p.root, p.rootKey, now.Add(-time.Minute), now.Add(time.Hour))
+	createCRL := func(crlExts, entryExts []pkix.Extension) *RevocationList {
+		t.Helper()
+		tmpl := &RevocationList{
+			Number:          big.NewInt(2),
+			ThisUpdate:      now.Add(-time.Minute),
+			NextUpdate:      now.Add(time.Hour),
+			ExtraExtensions: crlExts,
+		}
+		if entryExts != nil {
+			// An entry for another certificate.
+			tmpl.RevokedCertificateEntries = []RevocationListEntry{{
+				SerialNumber:    big.NewInt(7),
+				RevocationTime:  now.Add(-time.Minute),
+				ExtraExtensions: entryExts,
+			}}
+		}
+		der, err := CreateRevocationList(rand.Reader, tmpl, p.inter, p.interKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		crl, err := ParseRevocationList(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return crl
+	}
+	baseCRLNumber := []byte{0x02, 0x01, 0x01}
+	// onlyContainsUserCerts TRUE.
+	idp := []byte{0x30, 0x03, 0x81, 0x01, 0xff}
+	unknown := asn1.ObjectIdentifier{1, 2, 3, 4}
+	null := []byte{0x05, 0x00}
+
+	for _, tt := range []struct {
+		name               string
+		crlExts, entryExts []pkix.Extension
+	}{
+		{"delta", []pkix.Extension{{Id: oidExtensionDeltaCRLIndicator, Critical: true, Value: baseCRLNumber}}, nil},
+		{"non-critical delta", []pkix.Extension{{Id: oidExtensionDeltaCRLIndicator, Value: baseCRLNumber}}, nil},
+		{"issuing distribution point", []pkix.Extension{{Id: oidExtensionIssuingDistPoint, Critical: true, Value: idp}}, nil},
+		{"unknown critical extension", []pkix.Extension{{Id: unknown, Critical: true, Value: null}}, nil},
+		{"certificate issuer", nil, []pkix.Extension{{Id: oidExtensionCertificateIssuer, Critical: true, Value: []byte{0x30, 0x00}}}},
+		{"unknown critical entry extension", nil, []pkix.Extension{{Id: unknown, Critical: true, Value: null}}},
+	} {
+		crl := createCRL(tt.crlExts, tt.entryExts)
+		_, err := p.verify(&RevocationPolicy{CRLs: []*RevocationList{crl, emptyRoot}, RequireStatus: true})
+		if err == nil {
+			t.Errorf("%s: CRL was used", tt.name)
+			continue
+		}
+		expectStatusUnknown(t, err)
+	}
+
+	// Extensions that are not critical are ignored.
+	crl := createCRL([]pkix.Extension{{Id: unknown, Value: null}}, []pkix.Extension{{Id: unknown, Value: null}})
+	if _, err := p.verify(&RevocationPolicy{CRLs: []*RevocationList{crl, emptyRoot}, RequireStatus: true}); err != nil {
+		t.Errorf("CRL with non-critical extensions was not used: %v", err)
+	}
+}
+
+func TestRevocationOCSP(t *testing.T) {
+	p := newTestPKI(t)
+	now := time.Now()
+	thisUpdate, nextUpdate := now.Add(-time.Minute), now.Add(time.Hour)
+	status := func(status int) testOCSPStatus {
+		return testOCSPStatus{p.leaf, p.inter, status, 1, thisUpdate, nextUpdate}
+	}
+
+	good := createTestOCSPResponse(t, p.inter, p.interKey, nil, status(ocspGood))
+	policy := &RevocationPolicy{OCSPResponses: [][]byte{good}, RequireStatus: true, LeafOnly: true}
+	if _, err := p.verify(policy); err != nil {
+		t.Errorf("Verify with good OCSP response failed: %v", err)
+	}
+
+	revoked := createTestOCSPResponse(t, p.inter, p.interKey, nil, status(ocspRevoked))
+	_, err := p.verify(&RevocationPolicy{OCSPResponses: [][]byte{revoked}})
+	expectRevoked(t, err, p.leaf, true)
+
+	// OCSP responses take precedence over CRLs.
+	crl := createTestCRL(t, p.inter, p.interKey, thisUpdate, nextUpdate, p.leaf)
+	if _, err := p.verify(&RevocationPolicy{OCSPResponses: [][]byte{good}, CRLs: []*RevocationList{crl}}); err != nil {
+		t.Errorf("CRL was used over OCSP response: %v", err)
+	}
+
+	// Responses that are unknown, forged, stale or malformed are ignored.
+	stale := status(ocspRevoked)
+	stale.thisUpdate, stale.nextUpdate = now.Add(-2*time.Hour), now.Add(-time.Hour)
+	for name, resp := range map[string][]byte{
+		"unknown":   createTestOCSPResponse(t, p.inter, p.interKey, nil, status(ocspUnknown)),
+		"forged":    createTestOCSPResponse(t, p.inter, p.leafKey, nil, status(ocspRevoked)),
+		"stale":     createTestOCSPResponse(t, p.inter, p.interKey, nil, stale),
+		"malformed": revoked[:len(revoked)-1],
+	} {
+		if _, err := p.verify(&RevocationPolicy{OCSPResponses: [][]byte{resp}}); err != nil {
+			t.Errorf("%s OCSP response was used: %v", name, err)
+		}
+		_, err := p.verify(&RevocationPolicy{OCSPResponses: [][]byte{resp}, RequireStatus: true, LeafOnly: true})
+		expectStatusUnknown(t, err)
+	}
+}
+
+func TestRevocationOCSPDelegatedResponder(t *testing.T) {
+	p := newTestPKI(t)
+	now := time.Now()
+	responderKey := mustGenerateECDSAKey(t)
+	newResponder := func(ekus []ExtKeyUsage) *Certificate {
+		return genCertEdge(t, "responder", responderKey, func(c *Certificate) {
+			c.ExtKeyUsage = ekus
+		}, leafCertificate, p.inter, p.interKey)
+	}
+	leafStatus := testOCSPStatus{p.leaf, p.inter, ocspRevoked, 1, now.Add(-time.Minute), now.Add(time.Hour)}
+
+	responder := newResponder([]ExtKeyUsage{ExtKeyUsageOCSPSigning})
+	resp := createTestOCSPResponse(t, responder, responderKey, []*Certificate{responder}, leafStatus)
+	_, err := p.verify(&RevocationPolicy{OCSPResponses: [][]byte{resp}})
+	expectRevoked(t, err, p.leaf, true)
+
+	for _, ekus := range [][]ExtKeyUsage{nil, {ExtKeyUsageServerAuth}, {ExtKeyUsageAny}} {
+		responder := newResponder(ekus)
+		resp := createTestOCSPResponse(t, responder, responderKey, []*Certificate{responder}, leafStatus)
+		if _, err := p.verify(&RevocationPolicy{OCSPResponses: [][]byte{resp}}); err != nil {
+			t.Errorf("response from responder with EKUs %v was used: %v", ekus, err)
+		}
+	}
+
+	// A responder certificate from another issuer is not trusted.
+	otherResponder := genCertEdge(t, "responder", responderKey, func(c *Certificate) {
+		c.ExtKeyUsage = []ExtKeyUsage{ExtKeyUsageOCSPSigning}
+	}, leafCertificate, p.root, p.rootKey)
+	resp = createTestOCSPResponse(t, otherResponder, responderKey, []*Certificate{otherResponder}, leafStatus)
+	if _, err := p.verify(&RevocationPolicy{OCSPResponses: [][]byte{resp}}); err != nil {
+		t.Errorf("response from responder of another issuer was used: %v", err)
+	}
+}
+
+// testRevocationFetcher serves revocation information from memory.
+type testRevocationFetcher struct {
+	t       *testing.T
+	ocsp    map[string]func(cert *Certificate) []byte
+	crls    map[string][]byte
+	fetches []string
+}
+
+func (f *testRevocationFetcher) FetchOCSP(url string, request []byte) ([]byte, error) {
+	f.fetches = append(f.fetches, url)
+	h, ok := f.ocsp[url]
+	if !ok {
+		return nil, errors.New("no such responder")
+	}
+	serial, err := parseTestOCSPRequest(request)
+	if err != nil {
+		f.t.Errorf("malformed OCSP request: %v", err)
+		return nil, err
+	}
+	return h(&Certificate{SerialNumber: serial}), nil
+}
+
+func (f *testRevocationFetcher) FetchCRL(url string) ([]byte, error) {
+	f.fetches = append(f.fetches, url)
+	crl, ok := f.crls[url]
+	if !ok {
+		return nil, errors.New("no such CRL")
+	}
+	return crl, nil
+}
+
+// parseTestOCSPRequest returns the serial number requested by an OCSP
+// request with a single SHA-1 CertID.
+func parseTestOCSPRequest(der []byte) (*big.Int, error) {
+	s := cryptobyte.String(der)
+	var certID, hashAI cryptobyte.String
+	var nameHash, keyHash []byte
+	serial := new(big.Int)
+	if !s.ReadASN1(&s, cryptobyte_asn1.SEQUENCE) || // OCSPRequest
+		!s.ReadASN1(&s, cryptobyte_asn1.SEQUENCE) || // TBSRequest
+		!s.ReadASN1(&s, cryptobyte_asn1.SEQUENCE) || // requestList
+		!s.ReadASN1(&s, cryptobyte_asn1.SEQUENCE) || // Request
+		!s.ReadASN1(&certID, cryptobyte_asn1.SEQUENCE) ||
+		!certID.ReadASN1(&hashAI, cryptobyte_asn1.SEQUENCE) ||
+		!certID.ReadASN1Bytes(&nameHash, cryptobyte_asn1.OCTET_STRING) ||
+		!certID.ReadASN1Bytes(&keyHash, cryptobyte_asn1.OCTET_STRING) ||
+		!certID.ReadASN1Integer(serial) {
+		return nil, errors.New("malformed request")
+	}
+	ai, err := parseAI(hashAI)
+	if err != nil {
+		return nil, err
+	}
+	if !ai.Algorithm.Equal(oidSHA1) || len(nameHash) != sha1.Size || len(keyHash) != sha1.Size {
+		return nil, fmt.Errorf("unexpected CertID hash %v", ai.Algorithm)
+	}
+	return serial, nil
+}
+
+func TestRevocationFetcher(t *testing.T) {
+	p := newTestPKI(t)
+	now := time.Now()
+	thisUpdate, nextUpdate := now.Add(-time.Minute), now.Add(time.Hour)
+	f := &testRevocationFetcher{
+		t: t,
+		ocsp: map[string]func(*Certificate) []byte{
+			"http://ocsp.inter.example": func(c *Certificate) []byte {
+				if c.SerialNumber.Cmp(p.leaf.SerialNumber) != 0 {
+					t.Errorf("OCSP request for serial %v, want %v", c.SerialNumber, p.leaf.SerialNumber)
+				}
+				return createTestOCSPResponse(t, p.inter, p.interKey, nil,
+					testOCSPStatus{p.leaf, p.inter, ocspGood, 0, thisUpdate, nextUpdate})
+			},
+		},
+		crls: map[string][]byte{
+			"http://crl.root.example": createTestCRL(t, p.root, p.rootKey, thisUpdate, nextUpdate).Raw,
+		},
+	}
+
+	policy := &RevocationPolicy{Fetcher: f, RequireStatus: true}
+	if _, err := p.verify(policy); err != nil {
+		t.Fatalf("Verify with fetcher failed: %v", err)
+	}
+	// The OCSP responder of the intermediate fails, so its CRL is fetched.
+	want := "http://ocsp.inter.example http://ocsp.root.example http://crl.root.example"
+	if got := strings.Join(f.fetches, " "); got != want {
+		t.Errorf("fetched %s, want %s", got, want)
+	}
+
+	// Supplied information is used without fetching.
+	f.fetches = nil
+	crl := createTestCRL(t, p.inter, p.interKey, thisUpdate, nextUpdate, p.leaf)
+	_, err := p.verify(&RevocationPolicy{Fetcher: f, CRLs: []*RevocationList{crl}, LeafOnly: true})
+	expectRevoked(t, err, p.leaf, false)
+	if len(f.fetches) != 0 {
+		t.Errorf("unexpected fetches %v", f.fetches)
+	}
+
+	// Fetch errors only fail verification if the status is required.
+	f.crls = nil
+	f.fetches = nil
+	if _, err := p.verify(&RevocationPolicy{Fetcher: f}); err != nil {
+		t.Errorf("fetch errors failed verification without RequireStatus: %v", err)
+	}
+	_, err = p.verify(&RevocationPolicy{Fetcher: f, RequireStatus: true})
+	expectStatusUnknown(t, err)
+	if !strings.Contains(err.Error(), "no such CRL") {
+		t.Errorf("error %q does not include the fetch error", err)
+	}
+}
@@ -56,6 +56,14 @@ const (
 	// CANotAuthorizedForExtKeyUsage results when an intermediate or root
 	// certificate does not permit a requested extended key usage.
 	CANotAuthorizedForExtKeyUsage
+	// RevocationStatusUnknown results when the revocation status of a
+	// certificate can't be determined and VerifyOptions.Revocation requires
+	// it. Certificates that are revoked result in a RevocationError instead.
+	RevocationStatusUnknown
+	// InsufficientSCTs results when the leaf certificate doesn't have as
+	// many valid Signed Certificate Timestamps as required by
+	// VerifyOptions.CertificateTransparency.
+	InsufficientSCTs
 )
 
 // CertificateInvalidError results when an odd error occurs. Users of this
@@ -86,6 +94,10 @@ func (e CertificateInvalidError) Error() string {
 		return "x509: issuer has name constraints but leaf doesn't have a SAN extension"
 	case UnconstrainedName:
 		return "x509: issuer has name constraints but leaf contains unknown or unconstrained name: " + e.Detail
+	case RevocationStatusUnknown:
+		return "x509: unable to determine revocation status of certificate: " + e.Detail
+	case InsufficientSCTs:
+		return "x509: certificate does not have enough valid signed certificate timestamps: " + e.Detail
 	}
 	return "x509: unknown error"
 }
@@ -201,6 +213,18 @@ type VerifyOptions struct {
 	// certificates from consuming excessive amounts of CPU time when
 	// validating. It does not apply to the platform verifier.
 	MaxConstraintComparisions int
+
+	// Revocation, if not nil, enables revocation checking of the verified
+	// chains according to the policy, including those built by the platform
+	// verifier. Chains containing a revoked certificate are discarded, and if
+	// none remain, Verify returns a RevocationError.
+	Revocation *RevocationPolicy
+
+	// CertificateTransparency, if not nil, requires the leaf certificate to
+	// have a minimum number of valid Signed Certificate Timestamps, as
+	// configured by the policy. It applies to the chains built by the
+	// platform verifier too.
+	CertificateTransparency *CTPolicy
 }
 
 const (
@@ -749,7 +773,8 @@ func (c *Certificate) isValid(certType int, currentChain []*Certificate, opts *V
 //
 // Certificates other than c in the returned chains should not be modified.
 //
-// WARNING: this function doesn't do any revocation checking.
+// WARNING: this function doesn't do any revocation checking unless
+// opts.Revocation is set.
 func (c *Certificate) Verify(opts VerifyOptions) (chains [][]*Certificate, err error) {
 	// Platform-specific verification needs the ASN.1 contents so
 	// this makes the behavior consistent across platforms.
@@ -772,15 +797,22 @@ func (c *Certificate) Verify(opts VerifyOptions) (chains [][]*Certificate, err e
 		// i.e. if SetFallbackRoots was called with x509usefallbackroots=1.
 		systemPool := systemRootsPool()
 		if opts.Roots == nil && (systemPool == nil || systemPool.systemPool) {
-			return c.systemVerify(&opts)
+			platformChains, err := c.systemVerify(&opts)
+			if err != nil {
+				return nil, err
+			}
+			return checkPolicies(platformChains, &opts)
 		}
 		if opts.Roots != nil && opts.Roots.systemPool {
 			platformChains, err := c.systemVerify(&opts)
 			// If the platform verifier succeeded, or there are no additional
 			// roots, return the platform verifier result. Otherwise, continue
 			// with the Go verifier.
-			if err == nil || opts.Roots.len() == 0 {
-				return platformChains, err
+			if err == nil {
+				return checkPolicies(platformChains, &opts)
+			}
+			if opts.Roots.len() == 0 {
+				return nil, err
 			}
 		}
 	}
@@ -822,7 +854,7 @@ func (c *Certificate) Verify(opts VerifyOptions) (chains [][]*Certificate, err e
 		if eku == ExtKeyUsageAny {
 			// If any key usage is acceptable, no need to check the chain for
 			// key usages.
-			return candidateChains, nil
+			return checkPolicies(candidateChains, &opts)
 		}
 	}
 
@@ -837,6 +869,36 @@ func (c *Certificate) Verify(opts VerifyOptions) (chains [][]*Certificate, err e
 		return nil, CertificateInvalidError{c, IncompatibleUsage, ""}
 	}
 
+	return checkPolicies(chains, &opts)
+}
+
+// checkPolicies returns the chains that pass the Certificate Transparency and
+// revocation checks configured in opts.
+func checkPolicies(chains [][]*Certificate, opts *VerifyOptions) ([][]*Certificate, error) {
+	if ct := opts.CertificateTransparency; ct != nil {
+		var firstErr error
+		var valid [][]*Certificate
+		for _, chain := range chains {
+			if err := ct.checkChain(chain); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			valid = append(valid, chain)
+		}
+		if len(valid) == 0 {
+			return nil, firstErr
+		}
+		chains = valid
+	}
+	if opts.Revocation != nil {
+		now := opts.CurrentTime
+		if now.IsZero() {
+			now = time.Now()
+		}
+		return newRevocationChecker(opts.Revocation, now).filterChains(chains)
+	}
 	return chains, nil
 }
 
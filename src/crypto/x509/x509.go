@@ -1039,6 +1039,9 @@ var (
 	oidExtensionAuthorityInfoAccess   = []int{1, 3, 6, 1, 5, 5, 7, 1, 1}
 	oidExtensionCRLNumber             = []int{2, 5, 29, 20}
 	oidExtensionReasonCode            = []int{2, 5, 29, 21}
+	oidExtensionDeltaCRLIndicator     = []int{2, 5, 29, 27}
+	oidExtensionIssuingDistPoint      = []int{2, 5, 29, 28}
+	oidExtensionCertificateIssuer     = []int{2, 5, 29, 29}
 )
 
 var (
@@ -521,6 +521,13 @@ type RawValue struct {
 // this type. It's an error for any of the other fields to have this type.
 type RawContent []byte
 
+// Choice is used to mark a struct as an ASN.1 CHOICE. To use it, the first
+// field of the struct must have this type. Each of the other fields is an
+// alternative, distinguished from the others by its tag, and a value holds
+// exactly one of them. An alternative whose zero value must be told apart
+// from its absence may be a pointer.
+type Choice struct{}
+
 // Tagging
 
 // parseTagAndLength parses an ASN.1 tag and length pair from the given offset
@@ -528,6 +535,18 @@ type RawContent []byte
 // SET OF (tag 17) are mapped to SEQUENCE and SEQUENCE OF (tag 16) since we
 // don't distinguish between ordered and unordered objects in this code.
 func parseTagAndLength(bytes []byte, initOffset int) (ret tagAndLength, offset int, err error) {
+	return parseTagAndLengthBER(bytes, initOffset, false)
+}
+
+// indefiniteLength is the length of a BER element whose end is
+// marked by end-of-contents octets.
+const indefiniteLength = -1
+
+// parseTagAndLengthBER is like parseTagAndLength, but if ber is set it
+// accepts the lengths that BER allows and DER does not: non-minimal
+// lengths, and the indefinite length of a constructed element, for
+// which it returns a length of indefiniteLength.
+func parseTagAndLengthBER(bytes []byte, initOffset int, ber bool) (ret tagAndLength, offset int, err error) {
 	offset = initOffset
 	// parseTagAndLength should not be called without at least a single
 	// byte to read. Thus this check is for robustness:
@@ -567,6 +586,10 @@ func parseTagAndLength(bytes []byte, initOffset int) (ret tagAndLength, offset i
 		// Bottom 7 bits give the number of length bytes to follow.
 		numBytes := int(b & 0x7f)
 		if numBytes == 0 {
+			if ber && ret.isCompound {
+				ret.length = indefiniteLength
+				return
+			}
 			err = SyntaxError{"indefinite length found (not DER)"}
 			return
 		}
@@ -586,14 +609,14 @@ func parseTagAndLength(bytes []byte, initOffset int) (ret tagAndLength, offset i
 			}
 			ret.length <<= 8
 			ret.length |= int(b)
-			if ret.length == 0 {
+			if ret.length == 0 && !ber {
 				// DER requires that lengths be minimal.
 				err = StructuralError{"superfluous leading zeros in length"}
 				return
 			}
 		}
 		// Short lengths must be encoded in short form.
-		if ret.length < 0x80 {
+		if ret.length < 0x80 && !ber {
 			err = StructuralError{"non-minimal length"}
 			return
 		}
@@ -605,7 +628,7 @@ func parseTagAndLength(bytes []byte, initOffset int) (ret tagAndLength, offset i
 // parseSequenceOf is used for SEQUENCE OF and SET OF values. It tries to parse
 // a number of ASN.1 values from the given byte slice and returns them as a
 // slice of Go values of the given type.
-func parseSequenceOf(bytes []byte, sliceType reflect.Type, elemType reflect.Type) (ret reflect.Value, err error) {
+func parseSequenceOf(bytes []byte, sliceType reflect.Type, elemType reflect.Type, ber bool) (ret reflect.Value, err error) {
 	matchAny, expectedTag, compoundType, ok := getUniversalType(elemType)
 	if !ok {
 		err = StructuralError{"unknown Go type for slice"}
@@ -644,7 +667,7 @@ func parseSequenceOf(bytes []byte, sliceType reflect.Type, elemType reflect.Type
 		numElements++
 	}
 	ret = reflect.MakeSlice(sliceType, numElements, numElements)
-	params := fieldParameters{}
+	params := fieldParameters{ber: ber}
 	offset := 0
 	for i := 0; i < numElements; i++ {
 		offset, err = parseField(ret.Index(i), bytes, offset, params)
@@ -663,6 +686,7 @@ var (
 	timeType             = reflect.TypeFor[time.Time]()
 	rawValueType         = reflect.TypeFor[RawValue]()
 	rawContentsType      = reflect.TypeFor[RawContent]()
+	choiceType           = reflect.TypeFor[Choice]()
 	bigIntType           = reflect.TypeFor[*big.Int]()
 )
 
@@ -740,6 +764,10 @@ func parseField(v reflect.Value, bytes []byte, initOffset int, params fieldParam
 		return
 	}
 
+	if isChoice(fieldType) {
+		return parseChoice(v, bytes, offset, params)
+	}
+
 	t, offset, err := parseTagAndLength(bytes, offset)
 	if err != nil {
 		return
@@ -834,6 +862,23 @@ func parseField(v reflect.Value, bytes []byte, initOffset int, params fieldParam
 		matchAnyClassAndTag = false
 	}
 
+	// BER allows an implicitly tagged string to have a constructed
+	// encoding, which UnmarshalBER could not join without knowing
+	// that the element is a string.
+	var joined []byte
+	if params.ber && !matchAnyClassAndTag && t.class == expectedClass && t.tag == expectedTag &&
+		t.isCompound && !compoundType && isStringTag(universalTag) {
+		if invalidLength(offset, t.length, len(bytes)) {
+			err = SyntaxError{"data truncated"}
+			return
+		}
+		st := tagAndLength{class: ClassUniversal, tag: universalTag, length: t.length, isCompound: true}
+		if joined, offset, err = joinSegments(bytes, offset, st, 0); err != nil {
+			return
+		}
+		t.isCompound, t.length = false, 0
+	}
+
 	// We have unwrapped any explicit tagging at this point.
 	if !matchAnyClassAndTag && (t.class != expectedClass || t.tag != expectedTag) ||
 		(!matchAny && t.isCompound != compoundType) {
@@ -852,6 +897,9 @@ func parseField(v reflect.Value, bytes []byte, initOffset int, params fieldParam
 	}
 	innerBytes := bytes[offset : offset+t.length]
 	offset += t.length
+	if joined != nil {
+		innerBytes = joined
+	}
 
 	// We deal with the structures defined in this package first.
 	switch v := v.Addr().Interface().(type) {
@@ -929,13 +977,20 @@ func parseField(v reflect.Value, bytes []byte, initOffset int, params fieldParam
 			val.Field(0).Set(reflect.ValueOf(RawContent(bytes)))
 		}
 
+		if universalTag == TagSet {
+			err = parseSet(val, innerBytes, params.ber)
+			return
+		}
+
 		innerOffset := 0
 		for i := 0; i < structType.NumField(); i++ {
 			field := structType.Field(i)
 			if i == 0 && field.Type == rawContentsType {
 				continue
 			}
-			innerOffset, err = parseField(val.Field(i), innerBytes, innerOffset, parseFieldParameters(field.Tag.Get("asn1")))
+			fieldParams := parseFieldParameters(field.Tag.Get("asn1"))
+			fieldParams.ber = params.ber
+			innerOffset, err = parseField(val.Field(i), innerBytes, innerOffset, fieldParams)
 			if err != nil {
 				return
 			}
@@ -951,7 +1006,7 @@ func parseField(v reflect.Value, bytes []byte, initOffset int, params fieldParam
 			reflect.Copy(val, reflect.ValueOf(innerBytes))
 			return
 		}
-		newSlice, err1 := parseSequenceOf(innerBytes, sliceType, sliceType.Elem())
+		newSlice, err1 := parseSequenceOf(innerBytes, sliceType, sliceType.Elem(), params.ber)
 		if err1 == nil {
 			val.Set(newSlice)
 		}
@@ -991,6 +1046,133 @@ func parseField(v reflect.Value, bytes []byte, initOffset int, params fieldParam
 	return
 }
 
+// parseChoice parses the alternative of the CHOICE v found at the given
+// offset into a byte slice.
+func parseChoice(v reflect.Value, bytes []byte, initOffset int, params fieldParameters) (offset int, err error) {
+	offset = initOffset
+	t, offset, err := parseTagAndLength(bytes, offset)
+	if err != nil {
+		return
+	}
+	if params.tag != nil {
+		// A tagged CHOICE is always explicitly tagged.
+		if !params.explicit {
+			err = StructuralError{"CHOICE with an implicit tag"}
+			return
+		}
+		if !t.isCompound || !matchesTag(t, v.Type(), params) {
+			ok := setDefaultValue(v, params)
+			if ok {
+				offset = initOffset
+			} else {
+				err = StructuralError{"explicitly tagged member didn't match"}
+			}
+			return
+		}
+		if invalidLength(offset, t.length, len(bytes)) {
+			err = SyntaxError{"data truncated"}
+			return
+		}
+		if t.length == 0 {
+			err = StructuralError{"explicit tag has no child"}
+			return
+		}
+		inner := bytes[offset : offset+t.length]
+		var innerOffset int
+		innerOffset, err = parseChoice(v, inner, 0, fieldParameters{ber: params.ber})
+		if err == nil && innerOffset != len(inner) {
+			err = SyntaxError{"trailing data after CHOICE in explicit tag"}
+		}
+		offset += t.length
+		return
+	}
+
+	structType := v.Type()
+	for i := 1; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if !field.IsExported() {
+			err = StructuralError{"struct contains unexported fields"}
+			return
+		}
+		fieldParams := parseFieldParameters(field.Tag.Get("asn1"))
+		if !matchesTag(t, field.Type, fieldParams) {
+			continue
+		}
+		fieldParams.optional = false
+		fieldParams.ber = params.ber
+		v.SetZero()
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Pointer && fv.Type() != bigIntType {
+			fv.Set(reflect.New(fv.Type().Elem()))
+			fv = fv.Elem()
+		}
+		return parseField(fv, bytes, initOffset, fieldParams)
+	}
+	ok := setDefaultValue(v, params)
+	if ok {
+		offset = initOffset
+	} else {
+		err = StructuralError{fmt.Sprintf("no CHOICE alternative of %s matches tag %d in class %d", structType.Name(), t.tag, t.class)}
+	}
+	return
+}
+
+// parseSet parses the components of a SET into the fields of the struct v.
+// Unlike those of a SEQUENCE, the components may appear in any order, and
+// are matched to fields by their tags.
+func parseSet(v reflect.Value, bytes []byte, ber bool) error {
+	structType := v.Type()
+	first := 0
+	if structType.NumField() > 0 && structType.Field(0).Type == rawContentsType {
+		first = 1
+	}
+	params := make([]fieldParameters, structType.NumField())
+	for i := first; i < structType.NumField(); i++ {
+		params[i] = parseFieldParameters(structType.Field(i).Tag.Get("asn1"))
+		params[i].ber = ber
+	}
+	seen := make([]bool, structType.NumField())
+	for offset := 0; offset < len(bytes); {
+		t, next, err := parseTagAndLength(bytes, offset)
+		if err != nil {
+			return err
+		}
+		// Components with the same tag, which are not valid ASN.1
+		// but have been accepted in order, fill the fields in order.
+		i, known := first, false
+		for ; i < structType.NumField(); i++ {
+			if matchesTag(t, structType.Field(i).Type, params[i]) {
+				known = true
+				if !seen[i] {
+					break
+				}
+			}
+		}
+		if known && i == structType.NumField() {
+			return StructuralError{"duplicate SET component"}
+		}
+		if !known {
+			// As with trailing elements of a SEQUENCE, we allow
+			// components that we don't know about.
+			if invalidLength(next, t.length, len(bytes)) {
+				return SyntaxError{"data truncated"}
+			}
+			offset = next + t.length
+			continue
+		}
+		seen[i] = true
+		if offset, err = parseField(v.Field(i), bytes, offset, params[i]); err != nil {
+			return err
+		}
+	}
+	for i := first; i < structType.NumField(); i++ {
+		if !seen[i] && !setDefaultValue(v.Field(i), params[i]) {
+			return StructuralError{"missing SET component " + structType.Field(i).Name}
+		}
+	}
+	return nil
+}
+
 // canHaveDefaultValue reports whether k is a Kind that we will set a default
 // value for. (A signed integer, essentially.)
 func canHaveDefaultValue(k reflect.Kind) bool {
@@ -1058,6 +1240,12 @@ func setDefaultValue(v reflect.Value, params fieldParameters) (ok bool) {
 //   - An ASN.1 SEQUENCE or SET can be written to a struct
 //     if each of the elements in the sequence can be
 //     written to the corresponding element in the struct.
+//     The components of a SET are matched to fields by their tags,
+//     in any order.
+//
+//   - An ASN.1 CHOICE can be written to a struct whose first field
+//     has type [Choice]. The alternative present is written to the
+//     field whose tag matches it, and the other fields are left zero.
 //
 // The following tags on struct fields have special meaning to Unmarshal:
 //
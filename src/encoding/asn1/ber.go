@@ -0,0 +1,189 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package asn1
+
+import "reflect"
+
+// maxBERDepth limits the nesting of the elements that UnmarshalBER
+// converts, so that hostile input cannot exhaust the stack.
+const maxBERDepth = 64
+
+// UnmarshalBER is like [Unmarshal] but accepts the Basic Encoding Rules
+// (BER), of which DER is a restricted form, as produced by some older
+// and streaming encoders. In particular, it accepts:
+//
+//   - constructed elements of indefinite length, terminated by
+//     end-of-contents octets;
+//   - lengths encoded in more octets than necessary;
+//   - constructed encodings of strings, which are made up of segments,
+//     including implicitly tagged ones;
+//   - any non-zero octet as the encoding of a BOOLEAN true.
+//
+// UnmarshalBER converts the first element of b to DER before parsing it
+// as Unmarshal does, so RawValue and RawContent fields receive the DER
+// encoding of their elements rather than the original bytes.
+func UnmarshalBER(b []byte, val any) (rest []byte, err error) {
+	return UnmarshalBERWithParams(b, val, "")
+}
+
+// UnmarshalBERWithParams is like [UnmarshalWithParams] but accepts BER,
+// as described for [UnmarshalBER].
+func UnmarshalBERWithParams(b []byte, val any, params string) (rest []byte, err error) {
+	v := reflect.ValueOf(val)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return nil, &invalidUnmarshalError{reflect.TypeOf(val)}
+	}
+	if len(b) == 0 {
+		// Let UnmarshalWithParams decide about an absent element,
+		// which may be optional or have a default.
+		return UnmarshalWithParams(b, val, params)
+	}
+	der, n, err := appendDER(nil, b, 0)
+	if err != nil {
+		return nil, err
+	}
+	p := parseFieldParameters(params)
+	p.ber = true
+	if _, err := parseField(v.Elem(), der, 0, p); err != nil {
+		return nil, err
+	}
+	return b[n:], nil
+}
+
+// appendDER appends the DER encoding of the BER element at the start of
+// b to dst, and returns the extended slice and the length of the element
+// in b.
+func appendDER(dst, b []byte, depth int) ([]byte, int, error) {
+	if depth > maxBERDepth {
+		return nil, 0, StructuralError{"BER elements nested too deeply"}
+	}
+	t, offset, err := parseTagAndLengthBER(b, 0, true)
+	if err != nil {
+		return nil, 0, err
+	}
+	if t.length != indefiniteLength && invalidLength(offset, t.length, len(b)) {
+		return nil, 0, SyntaxError{"data truncated"}
+	}
+
+	var contents []byte
+	switch {
+	case !t.isCompound:
+		contents = b[offset : offset+t.length]
+		if t.class == ClassUniversal && t.tag == TagBoolean && t.length == 1 && contents[0] != 0 {
+			contents = []byte{0xff}
+		}
+		offset += t.length
+	case t.class == ClassUniversal && isStringTag(t.tag):
+		// A constructed string is the concatenation of its segments,
+		// which DER encodes as a single primitive string.
+		contents, offset, err = joinSegments(b, offset, t, depth)
+		if err != nil {
+			return nil, 0, err
+		}
+		t.isCompound = false
+	default:
+		end := len(b)
+		if t.length != indefiniteLength {
+			end = offset + t.length
+		}
+		for {
+			if t.length == indefiniteLength {
+				if offset+2 > len(b) {
+					return nil, 0, SyntaxError{"missing end-of-contents"}
+				}
+				if b[offset] == 0 && b[offset+1] == 0 {
+					offset += 2
+					break
+				}
+			} else if offset == end {
+				break
+			}
+			var n int
+			contents, n, err = appendDER(contents, b[offset:end], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			offset += n
+		}
+	}
+
+	t.length = len(contents)
+	dst = appendTagAndLength(dst, t)
+	return append(dst, contents...), offset, nil
+}
+
+// joinSegments returns the contents of the constructed string t, whose
+// segments start at the given offset into b, and the offset of the end
+// of t. The class and tag of t must be the universal ones of the string
+// type, even if it is implicitly tagged.
+func joinSegments(b []byte, offset int, t tagAndLength, depth int) ([]byte, int, error) {
+	end := len(b)
+	if t.length != indefiniteLength {
+		end = offset + t.length
+	}
+	var contents []byte
+	unusedBits := byte(0)
+	for {
+		if t.length == indefiniteLength {
+			if offset+2 > len(b) {
+				return nil, 0, SyntaxError{"missing end-of-contents"}
+			}
+			if b[offset] == 0 && b[offset+1] == 0 {
+				offset += 2
+				break
+			}
+		} else if offset == end {
+			break
+		}
+		seg, n, err := appendDER(nil, b[offset:end], depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		offset += n
+		st, segOffset, err := parseTagAndLength(seg, 0)
+		if err != nil {
+			return nil, 0, err
+		}
+		// The segments of a character string are encoded as those of
+		// an OCTET STRING (X.690, section 8.23.6).
+		if st.class != ClassUniversal || st.tag != t.tag && (st.tag != TagOctetString || t.tag == TagBitString) {
+			return nil, 0, StructuralError{"constructed string contains a segment of another type"}
+		}
+		seg = seg[segOffset:]
+		if t.tag == TagBitString {
+			// Each segment starts with its count of unused bits,
+			// and only the last segment may have any.
+			if len(seg) == 0 {
+				return nil, 0, SyntaxError{"zero length BIT STRING segment"}
+			}
+			if unusedBits != 0 {
+				return nil, 0, StructuralError{"unused bits in a BIT STRING segment other than the last"}
+			}
+			unusedBits = seg[0]
+			seg = seg[1:]
+		}
+		contents = append(contents, seg...)
+	}
+	if t.tag == TagBitString {
+		contents = append([]byte{unusedBits}, contents...)
+	}
+	return contents, offset, nil
+}
+
+// isStringTag reports whether tag is a universal tag of a string
+// type, which BER allows to have a constructed encoding.
+func isStringTag(tag int) bool {
+	switch tag {
+	case TagBitString, TagOctetString, TagUTF8String, TagNumericString,
+		TagPrintableString, TagT61String, TagIA5String, TagUTCTime,
+		TagGeneralizedTime, TagGeneralString, TagBMPString,
+		21, // VideotexString
+		25, // GraphicString
+		26, // VisibleString
+		28: // UniversalString
+		return true
+	}
+	return false
+}
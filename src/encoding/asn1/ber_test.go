@@ -0,0 +1,151 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package asn1
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type berStruct struct {
+	A int
+	B []byte
+	C bool `asn1:"optional"`
+}
+
+var berTests = []struct {
+	in   string
+	want berStruct
+}{
+	// DER.
+	{"300a020101040201020101ff", berStruct{1, []byte{1, 2}, true}},
+	// Indefinite length.
+	{"3080020101040201020000", berStruct{1, []byte{1, 2}, false}},
+	// Non-minimal lengths.
+	{"30810d02820001010481020102" + "010101", berStruct{1, []byte{1, 2}, true}},
+	// Constructed OCTET STRING of definite length.
+	{"300c020101240704010104020203", berStruct{1, []byte{1, 2, 3}, false}},
+	// Constructed OCTET STRING of indefinite length, nested.
+	{"308002010124802480040101000004010200000000", berStruct{1, []byte{1, 2}, false}},
+	// BOOLEAN true other than 0xff.
+	{"300a020101040201020101" + "01", berStruct{1, []byte{1, 2}, true}},
+}
+
+func TestUnmarshalBER(t *testing.T) {
+	for _, test := range berTests {
+		b, err := hex.DecodeString(test.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b = append(b, 0xaa)
+		var out berStruct
+		rest, err := UnmarshalBER(b, &out)
+		if err != nil {
+			t.Errorf("UnmarshalBER(%s): %v", test.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(out, test.want) {
+			t.Errorf("UnmarshalBER(%s) = %+v, want %+v", test.in, out, test.want)
+		}
+		if !bytes.Equal(rest, []byte{0xaa}) {
+			t.Errorf("UnmarshalBER(%s) rest = %x, want aa", test.in, rest)
+		}
+	}
+}
+
+func TestUnmarshalBERBitString(t *testing.T) {
+	// A constructed BIT STRING whose last segment has unused bits.
+	b, _ := hex.DecodeString("2380030200ff030306ffc00000")
+	var out BitString
+	if _, err := UnmarshalBER(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := BitString{Bytes: []byte{0xff, 0xff, 0xc0}, BitLength: 18}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("UnmarshalBER = %+v, want %+v", out, want)
+	}
+}
+
+func TestUnmarshalBERImplicitString(t *testing.T) {
+	// A constructed [0] IMPLICIT OCTET STRING, whose segments can only be
+	// joined knowing that it is a string.
+	b, _ := hex.DecodeString("3080" + "06022a03" + "a080" + "04026162" + "04026364" + "0000" + "0000")
+	var out struct {
+		ID ObjectIdentifier
+		B  []byte `asn1:"tag:0"`
+	}
+	if _, err := UnmarshalBER(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !out.ID.Equal(ObjectIdentifier{1, 2, 3}) || string(out.B) != "abcd" {
+		t.Errorf("UnmarshalBER = %v, %q; want 1.2.3, \"abcd\"", out.ID, out.B)
+	}
+
+	// The segments of character strings are OCTET STRINGs,
+	// and those of BIT STRINGs are BIT STRINGs.
+	b, _ = hex.DecodeString("3014" + "a1080403616263040164" + "a2080302000303020680")
+	var out2 struct {
+		S   string    `asn1:"tag:1,utf8"`
+		Bit BitString `asn1:"tag:2"`
+	}
+	if _, err := UnmarshalBER(b, &out2); err != nil {
+		t.Fatal(err)
+	}
+	wantBit := BitString{Bytes: []byte{0x03, 0x80}, BitLength: 10}
+	if out2.S != "abcd" || !reflect.DeepEqual(out2.Bit, wantBit) {
+		t.Errorf("UnmarshalBER = %q, %+v; want \"abcd\", %+v", out2.S, out2.Bit, wantBit)
+	}
+
+	// An explicit tag is still unwrapped rather than joined.
+	b, _ = hex.DecodeString("3080" + "a080" + "24800401610401620000" + "0000" + "0000")
+	var out3 struct {
+		B []byte `asn1:"explicit,tag:0"`
+	}
+	if _, err := UnmarshalBER(b, &out3); err != nil {
+		t.Fatal(err)
+	}
+	if string(out3.B) != "ab" {
+		t.Errorf("UnmarshalBER = %q, want \"ab\"", out3.B)
+	}
+}
+
+func TestUnmarshalBERRawValue(t *testing.T) {
+	// RawValue fields receive the DER encoding.
+	b, _ := hex.DecodeString("30802480040102" + "00000000")
+	var out struct{ V RawValue }
+	if _, err := UnmarshalBER(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x04, 0x01, 0x02}; !bytes.Equal(out.V.FullBytes, want) {
+		t.Errorf("FullBytes = %x, want %x", out.V.FullBytes, want)
+	}
+}
+
+func TestUnmarshalBERErrors(t *testing.T) {
+	for _, in := range []string{
+		"3080020101",             // missing end-of-contents
+		"0480",                   // indefinite primitive
+		"300502010104",           // truncated
+		"2406040101020101",       // segment of another type
+		"2380030201ff0302000000", // unused bits in a middle segment
+		strings.Repeat("3080", 100) + strings.Repeat("0000", 100), // too deep
+	} {
+		b, _ := hex.DecodeString(in)
+		var v any
+		if _, err := UnmarshalBER(b, &v); err == nil {
+			t.Errorf("UnmarshalBER(%s) succeeded", in)
+		}
+	}
+
+	// Unmarshal still rejects BER.
+	b, _ := hex.DecodeString(berTests[1].in)
+	var out berStruct
+	if _, err := Unmarshal(b, &out); err == nil {
+		t.Error("Unmarshal of indefinite length succeeded")
+	}
+}
@@ -83,6 +83,7 @@ type fieldParameters struct {
 	timeType     int    // the time tag to use when marshaling.
 	set          bool   // true iff this should be encoded as a SET
 	omitEmpty    bool   // true iff this should be omitted if empty when marshaling.
+	ber          bool   // true iff an implicitly tagged string may be constructed, as in BER.
 
 	// Invariants:
 	//   if explicit is set, tag is non-nil.
@@ -163,6 +164,10 @@ func getUniversalType(t reflect.Type) (matchAny bool, tagNumber int, isCompound,
 	case bigIntType:
 		return false, TagInteger, false, true
 	}
+	if isChoice(t) {
+		// The tag depends on the alternative.
+		return true, -1, false, true
+	}
 	switch t.Kind() {
 	case reflect.Bool:
 		return false, TagBoolean, false, true
@@ -183,3 +188,63 @@ func getUniversalType(t reflect.Type) (matchAny bool, tagNumber int, isCompound,
 	}
 	return false, 0, false, false
 }
+
+// isChoice reports whether t is a struct marked as a CHOICE by a first
+// field of type Choice.
+func isChoice(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t.NumField() > 0 && t.Field(0).Type == choiceType
+}
+
+// matchesTag reports whether an element with the tag t could be parsed
+// into a field of type typ with the given parameters or, for a CHOICE,
+// into one of its alternatives.
+func matchesTag(t tagAndLength, typ reflect.Type, params fieldParameters) bool {
+	if params.tag != nil {
+		class := ClassContextSpecific
+		if params.application {
+			class = ClassApplication
+		} else if params.private {
+			class = ClassPrivate
+		}
+		return t.class == class && t.tag == *params.tag
+	}
+	if typ.Kind() == reflect.Pointer && typ != bigIntType {
+		typ = typ.Elem()
+	}
+	if isChoice(typ) {
+		for i := 1; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if matchesTag(t, field.Type, parseFieldParameters(field.Tag.Get("asn1"))) {
+				return true
+			}
+		}
+		return false
+	}
+	if typ.Kind() == reflect.Interface && typ.NumMethod() == 0 {
+		return true
+	}
+	matchAny, tag, isCompound, ok := getUniversalType(typ)
+	if !ok {
+		return false
+	}
+	if matchAny {
+		return true
+	}
+	if t.class != ClassUniversal {
+		return false
+	}
+	switch tag {
+	case TagPrintableString:
+		switch t.tag {
+		case TagPrintableString, TagIA5String, TagGeneralString, TagT61String, TagUTF8String, TagNumericString, TagBMPString:
+			return !t.isCompound
+		}
+		return false
+	case TagUTCTime:
+		return (t.tag == TagUTCTime || t.tag == TagGeneralizedTime) && !t.isCompound
+	}
+	if params.set {
+		tag = TagSet
+	}
+	return t.tag == tag && t.isCompound == isCompound
+}
@@ -6,10 +6,12 @@ package asn1
 
 import (
 	"bytes"
+	"cmp"
 	"errors"
 	"fmt"
 	"math/big"
 	"reflect"
+	"slices"
 	"sort"
 	"time"
 	"unicode/utf8"
@@ -529,6 +531,9 @@ func makeBody(value reflect.Value, params fieldParameters) (e encoder, err error
 				}
 			}
 
+			if params.set {
+				return makeSetBody(m)
+			}
 			return multiEncoder(m), nil
 		}
 	case reflect.Slice:
@@ -575,6 +580,92 @@ func makeBody(value reflect.Value, params fieldParameters) (e encoder, err error
 	return nil, StructuralError{"unknown Go type"}
 }
 
+// makeSetBody returns the body of a SET with the components m, which DER
+// requires to appear in the canonical order of their tags: by class, then
+// by tag number (X.690, section 10.3).
+func makeSetBody(m []encoder) (encoder, error) {
+	type component struct {
+		t tagAndLength
+		b []byte
+	}
+	var l []component
+	for _, e := range m {
+		if e.Len() == 0 {
+			// An omitted optional component.
+			continue
+		}
+		b := make([]byte, e.Len())
+		e.Encode(b)
+		t, _, err := parseTagAndLength(b, 0)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, component{t, b})
+	}
+	slices.SortStableFunc(l, func(a, b component) int {
+		if a.t.class != b.t.class {
+			return cmp.Compare(a.t.class, b.t.class)
+		}
+		return cmp.Compare(a.t.tag, b.t.tag)
+	})
+	var body []byte
+	for _, c := range l {
+		body = append(body, c.b...)
+	}
+	return bytesEncoder(body), nil
+}
+
+// makeChoice returns the encoding of the alternative present in the CHOICE v.
+func makeChoice(v reflect.Value, params fieldParameters) (e encoder, err error) {
+	t := v.Type()
+	for i := 1; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			return nil, StructuralError{"struct contains unexported fields"}
+		}
+		fv := v.Field(i)
+		if fv.IsZero() {
+			continue
+		}
+		if e != nil {
+			return nil, StructuralError{"more than one CHOICE alternative present in " + t.Name()}
+		}
+		if fv.Kind() == reflect.Pointer && fv.Type() != bigIntType {
+			fv = fv.Elem()
+		}
+		fieldParams := parseFieldParameters(field.Tag.Get("asn1"))
+		fieldParams.optional = false
+		if e, err = makeField(fv, fieldParams); err != nil {
+			return nil, err
+		}
+	}
+	if e == nil {
+		return nil, StructuralError{"no CHOICE alternative present in " + t.Name()}
+	}
+	if params.tag == nil {
+		return e, nil
+	}
+	// A tagged CHOICE is always explicitly tagged.
+	if !params.explicit {
+		return nil, StructuralError{"CHOICE with an implicit tag"}
+	}
+	class := ClassContextSpecific
+	if params.application {
+		class = ClassApplication
+	} else if params.private {
+		class = ClassPrivate
+	}
+	tt := new(taggedEncoder)
+	tt.body = e
+	tt.tag = bytesEncoder(appendTagAndLength(tt.scratch[:0], tagAndLength{
+		class:      class,
+		tag:        *params.tag,
+		length:     e.Len(),
+		isCompound: true,
+	}))
+	return tt, nil
+}
+
 func makeField(v reflect.Value, params fieldParameters) (e encoder, err error) {
 	if !v.IsValid() {
 		return nil, fmt.Errorf("asn1: cannot marshal nil value")
@@ -606,6 +697,10 @@ func makeField(v reflect.Value, params fieldParameters) (e encoder, err error) {
 		}
 	}
 
+	if isChoice(v.Type()) {
+		return makeChoice(v, params)
+	}
+
 	if v.Type() == rawValueType {
 		rv := v.Interface().(RawValue)
 		if len(rv.FullBytes) != 0 {
@@ -729,6 +824,11 @@ func makeField(v reflect.Value, params fieldParameters) (e encoder, err error) {
 //	utf8:        causes strings to be marshaled as ASN.1, UTF8String values
 //	utc:         causes time.Time to be marshaled as ASN.1, UTCTime values
 //	generalized: causes time.Time to be marshaled as ASN.1, GeneralizedTime values
+//
+// A struct whose first field has type [Choice] is marshaled as the one of
+// its other fields that is not zero. The components of a SET are sorted
+// by their tags, and the elements of a SET OF by their encodings, as DER
+// requires.
 func Marshal(val any) ([]byte, error) {
 	return MarshalWithParams(val, "")
 }
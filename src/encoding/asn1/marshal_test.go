@@ -9,6 +9,7 @@ import (
 	"encoding/hex"
 	"math/big"
 	"reflect"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -377,6 +378,118 @@ func TestSetEncoderSETSliceSuffix(t *testing.T) {
 	}
 }
 
+type testChoice struct {
+	Choice
+	N int
+	S string `asn1:"utf8"`
+	B []byte `asn1:"tag:0"`
+}
+
+type choiceStruct struct {
+	A testChoice
+	B testChoice   `asn1:"explicit,tag:1"`
+	C testChoice   `asn1:"optional,explicit,tag:2"`
+	D []testChoice `asn1:"set"`
+}
+
+func TestChoice(t *testing.T) {
+	in := choiceStruct{
+		A: testChoice{N: 5},
+		B: testChoice{S: "hi"},
+		D: []testChoice{{B: []byte{1}}, {N: 1}},
+	}
+	const want = "3011" +
+		"020105" + // A
+		"a1040c026869" + // B
+		"3106020101800101" // D, in DER order
+	b, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(b); got != want {
+		t.Errorf("Marshal:\ngot  %s\nwant %s", got, want)
+	}
+
+	var out choiceStruct
+	if _, err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	slices.Reverse(out.D)
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("Unmarshal:\ngot  %+v\nwant %+v", out, in)
+	}
+
+	for _, v := range []any{
+		testChoice{},
+		testChoice{N: 1, S: "a"},
+		struct {
+			C testChoice `asn1:"tag:1"`
+		}{testChoice{N: 1}},
+	} {
+		if _, err := Marshal(v); err == nil {
+			t.Errorf("Marshal(%+v) succeeded", v)
+		}
+	}
+
+	var c testChoice
+	if _, err := Unmarshal([]byte{0x01, 0x01, 0xff}, &c); err == nil {
+		t.Error("Unmarshal of an unknown alternative succeeded")
+	}
+}
+
+type setStruct struct {
+	A string `asn1:"tag:1"`
+	B int
+	C []byte `asn1:"optional,tag:0"`
+	D bool   `asn1:"application,tag:0"`
+}
+
+func TestSetStruct(t *testing.T) {
+	in := setStruct{A: "a", B: 2, C: []byte{3}, D: true}
+	const want = "310c" +
+		"020102" + // B, universal
+		"4001ff" + // D, application
+		"800103" + // C, context-specific 0
+		"810161" // A, context-specific 1
+	b, err := MarshalWithParams(in, "set")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(b); got != want {
+		t.Errorf("Marshal:\ngot  %s\nwant %s", got, want)
+	}
+
+	for _, test := range []struct {
+		in   string
+		want setStruct
+	}{
+		{want, in},
+		{"310c8101614001ff020102800103", in},
+		{"3109810161020102" + "4001ff", setStruct{A: "a", B: 2, D: true}},
+	} {
+		var out setStruct
+		b, _ := hex.DecodeString(test.in)
+		if _, err := UnmarshalWithParams(b, &out, "set"); err != nil {
+			t.Errorf("Unmarshal(%s): %v", test.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(out, test.want) {
+			t.Errorf("Unmarshal(%s) = %+v, want %+v", test.in, out, test.want)
+		}
+	}
+
+	for _, in := range []string{
+		"3106020102" + "4001ff",       // missing A
+		"3109020102020102" + "810161", // duplicate B
+	} {
+		var out setStruct
+		b, _ := hex.DecodeString(in)
+		if _, err := UnmarshalWithParams(b, &out, "set"); err == nil {
+			t.Errorf("Unmarshal(%s) succeeded", in)
+		}
+	}
+}
+
 func BenchmarkUnmarshal(b *testing.B) {
 	b.ReportAllocs()
 
@@ -10,6 +10,24 @@
 // type (bool, int8, uint8, int16, float32, complex64, ...)
 // or an array or struct containing only fixed-size values.
 //
+// Struct fields may be annotated with a "binary" tag holding a
+// comma-separated list of options, such as `binary:"big,pad=2"`:
+//
+//   - "big", "little" or "native" encodes the field, including any
+//     fields or elements within it that have no such option of their
+//     own, in the given byte order instead of the one passed to the
+//     encoding or decoding function.
+//   - "skip" treats the field as padding, as for a blank (_) field:
+//     zero bytes are written in its place and its data is ignored
+//     when reading.
+//   - "pad=N" adds N bytes of zero padding after the field,
+//     where N is a non-negative decimal integer.
+//
+// Options other than these, including "pad" options with a malformed
+// count, are ignored, so that "binary" tags written for other purposes
+// do not change the encoding of existing types. If several options set
+// the byte order or the padding, the last one wins.
+//
 // The varint functions encode and decode single integer values using
 // a variable-length encoding; smaller values require fewer bytes.
 // For a specification, see
@@ -26,9 +44,13 @@ import (
 	"io"
 	"math"
 	"reflect"
+	"slices"
+	"strconv"
 	"sync"
 )
 
+var errBufferTooSmall = errors.New("buffer too small")
+
 // A ByteOrder specifies how to convert byte slices into
 // 16-, 32-, or 64-bit unsigned integers.
 //
@@ -241,75 +263,7 @@ func Read(r io.Reader, order ByteOrder, data any) error {
 		if _, err := io.ReadFull(r, bs); err != nil {
 			return err
 		}
-		switch data := data.(type) {
-		case *bool:
-			*data = bs[0] != 0
-		case *int8:
-			*data = int8(bs[0])
-		case *uint8:
-			*data = bs[0]
-		case *int16:
-			*data = int16(order.Uint16(bs))
-		case *uint16:
-			*data = order.Uint16(bs)
-		case *int32:
-			*data = int32(order.Uint32(bs))
-		case *uint32:
-			*data = order.Uint32(bs)
-		case *int64:
-			*data = int64(order.Uint64(bs))
-		case *uint64:
-			*data = order.Uint64(bs)
-		case *float32:
-			*data = math.Float32frombits(order.Uint32(bs))
-		case *float64:
-			*data = math.Float64frombits(order.Uint64(bs))
-		case []bool:
-			for i, x := range bs { // Easier to loop over the input for 8-bit values.
-				data[i] = x != 0
-			}
-		case []int8:
-			for i, x := range bs {
-				data[i] = int8(x)
-			}
-		case []uint8:
-			copy(data, bs)
-		case []int16:
-			for i := range data {
-				data[i] = int16(order.Uint16(bs[2*i:]))
-			}
-		case []uint16:
-			for i := range data {
-				data[i] = order.Uint16(bs[2*i:])
-			}
-		case []int32:
-			for i := range data {
-				data[i] = int32(order.Uint32(bs[4*i:]))
-			}
-		case []uint32:
-			for i := range data {
-				data[i] = order.Uint32(bs[4*i:])
-			}
-		case []int64:
-			for i := range data {
-				data[i] = int64(order.Uint64(bs[8*i:]))
-			}
-		case []uint64:
-			for i := range data {
-				data[i] = order.Uint64(bs[8*i:])
-			}
-		case []float32:
-			for i := range data {
-				data[i] = math.Float32frombits(order.Uint32(bs[4*i:]))
-			}
-		case []float64:
-			for i := range data {
-				data[i] = math.Float64frombits(order.Uint64(bs[8*i:]))
-			}
-		default:
-			n = 0 // fast path doesn't apply
-		}
-		if n != 0 {
+		if decodeFast(bs, order, data) {
 			return nil
 		}
 	}
@@ -335,6 +289,117 @@ func Read(r io.Reader, order ByteOrder, data any) error {
 	return nil
 }
 
+// Decode decodes binary data from buf into data according to
+// the given byte order.
+// It returns an error if buf is too small, otherwise the number of
+// bytes consumed from buf.
+//
+// Data is decoded as by [Read].
+func Decode(buf []byte, order ByteOrder, data any) (int, error) {
+	if n := intDataSize(data); n != 0 {
+		if len(buf) < n {
+			return 0, errBufferTooSmall
+		}
+		if decodeFast(buf, order, data) {
+			return n, nil
+		}
+	}
+
+	// Fallback to reflect-based decoding.
+	v := reflect.ValueOf(data)
+	size := -1
+	switch v.Kind() {
+	case reflect.Pointer:
+		v = v.Elem()
+		size = dataSize(v)
+	case reflect.Slice:
+		size = dataSize(v)
+	}
+	if size < 0 {
+		return 0, errors.New("binary.Decode: invalid type " + reflect.TypeOf(data).String())
+	}
+	if len(buf) < size {
+		return 0, errBufferTooSmall
+	}
+	d := &decoder{order: order, buf: buf[:size]}
+	d.value(v)
+	return size, nil
+}
+
+// decodeFast decodes bs into data, whose size is intDataSize(data),
+// and reports whether the fast path applies to the type of data.
+func decodeFast(bs []byte, order ByteOrder, data any) bool {
+	switch data := data.(type) {
+	case *bool:
+		*data = bs[0] != 0
+	case *int8:
+		*data = int8(bs[0])
+	case *uint8:
+		*data = bs[0]
+	case *int16:
+		*data = int16(order.Uint16(bs))
+	case *uint16:
+		*data = order.Uint16(bs)
+	case *int32:
+		*data = int32(order.Uint32(bs))
+	case *uint32:
+		*data = order.Uint32(bs)
+	case *int64:
+		*data = int64(order.Uint64(bs))
+	case *uint64:
+		*data = order.Uint64(bs)
+	case *float32:
+		*data = math.Float32frombits(order.Uint32(bs))
+	case *float64:
+		*data = math.Float64frombits(order.Uint64(bs))
+	case []bool:
+		for i, x := range bs { // Easier to loop over the input for 8-bit values.
+			data[i] = x != 0
+		}
+	case []int8:
+		for i, x := range bs {
+			data[i] = int8(x)
+		}
+	case []uint8:
+		copy(data, bs)
+	case []int16:
+		for i := range data {
+			data[i] = int16(order.Uint16(bs[2*i:]))
+		}
+	case []uint16:
+		for i := range data {
+			data[i] = order.Uint16(bs[2*i:])
+		}
+	case []int32:
+		for i := range data {
+			data[i] = int32(order.Uint32(bs[4*i:]))
+		}
+	case []uint32:
+		for i := range data {
+			data[i] = order.Uint32(bs[4*i:])
+		}
+	case []int64:
+		for i := range data {
+			data[i] = int64(order.Uint64(bs[8*i:]))
+		}
+	case []uint64:
+		for i := range data {
+			data[i] = order.Uint64(bs[8*i:])
+		}
+	case []float32:
+		for i := range data {
+			data[i] = math.Float32frombits(order.Uint32(bs[4*i:]))
+		}
+	case []float64:
+		for i := range data {
+			data[i] = math.Float64frombits(order.Uint64(bs[8*i:]))
+		}
+	default:
+		return false
+	}
+	return true
+}
+
 // Write writes the binary representation of data into w.
 // Data must be a fixed-size value or a slice of fixed-size
 // values, or a pointer to such data.
@@ -346,106 +411,10 @@ func Read(r io.Reader, order ByteOrder, data any) error {
 func Write(w io.Writer, order ByteOrder, data any) error {
 	// Fast path for basic types and slices.
 	if n := intDataSize(data); n != 0 {
-		bs := make([]byte, n)
-		switch v := data.(type) {
-		case *bool:
-			if *v {
-				bs[0] = 1
-			} else {
-				bs[0] = 0
-			}
-		case bool:
-			if v {
-				bs[0] = 1
-			} else {
-				bs[0] = 0
-			}
-		case []bool:
-			for i, x := range v {
-				if x {
-					bs[i] = 1
-				} else {
-					bs[i] = 0
-				}
-			}
-		case *int8:
-			bs[0] = byte(*v)
-		case int8:
-			bs[0] = byte(v)
-		case []int8:
-			for i, x := range v {
-				bs[i] = byte(x)
-			}
-		case *uint8:
-			bs[0] = *v
-		case uint8:
-			bs[0] = v
-		case []uint8:
-			bs = v
-		case *int16:
-			order.PutUint16(bs, uint16(*v))
-		case int16:
-			order.PutUint16(bs, uint16(v))
-		case []int16:
-			for i, x := range v {
-				order.PutUint16(bs[2*i:], uint16(x))
-			}
-		case *uint16:
-			order.PutUint16(bs, *v)
-		case uint16:
-			order.PutUint16(bs, v)
-		case []uint16:
-			for i, x := range v {
-				order.PutUint16(bs[2*i:], x)
-			}
-		case *int32:
-			order.PutUint32(bs, uint32(*v))
-		case int32:
-			order.PutUint32(bs, uint32(v))
-		case []int32:
-			for i, x := range v {
-				order.PutUint32(bs[4*i:], uint32(x))
-			}
-		case *uint32:
-			order.PutUint32(bs, *v)
-		case uint32:
-			order.PutUint32(bs, v)
-		case []uint32:
-			for i, x := range v {
-				order.PutUint32(bs[4*i:], x)
-			}
-		case *int64:
-			order.PutUint64(bs, uint64(*v))
-		case int64:
-			order.PutUint64(bs, uint64(v))
-		case []int64:
-			for i, x := range v {
-				order.PutUint64(bs[8*i:], uint64(x))
-			}
-		case *uint64:
-			order.PutUint64(bs, *v)
-		case uint64:
-			order.PutUint64(bs, v)
-		case []uint64:
-			for i, x := range v {
-				order.PutUint64(bs[8*i:], x)
-			}
-		case *float32:
-			order.PutUint32(bs, math.Float32bits(*v))
-		case float32:
-			order.PutUint32(bs, math.Float32bits(v))
-		case []float32:
-			for i, x := range v {
-				order.PutUint32(bs[4*i:], math.Float32bits(x))
-			}
-		case *float64:
-			order.PutUint64(bs, math.Float64bits(*v))
-		case float64:
-			order.PutUint64(bs, math.Float64bits(v))
-		case []float64:
-			for i, x := range v {
-				order.PutUint64(bs[8*i:], math.Float64bits(x))
-			}
+		bs, ok := data.([]uint8)
+		if !ok {
+			bs = make([]byte, n)
+			encodeFast(bs, order, data)
 		}
 		_, err := w.Write(bs)
 		return err
@@ -464,6 +433,172 @@ func Write(w io.Writer, order ByteOrder, data any) error {
 	return err
 }
 
+// Encode encodes the binary representation of data into buf according to
+// the given byte order.
+// It returns an error if buf is too small, otherwise the number of
+// bytes written into buf.
+//
+// Data is encoded as by [Write].
+func Encode(buf []byte, order ByteOrder, data any) (int, error) {
+	// Fast path for basic types and slices.
+	if n := intDataSize(data); n != 0 {
+		if len(buf) < n {
+			return 0, errBufferTooSmall
+		}
+		encodeFast(buf, order, data)
+		return n, nil
+	}
+
+	// Fallback to reflect-based encoding.
+	v := reflect.Indirect(reflect.ValueOf(data))
+	size := dataSize(v)
+	if size < 0 {
+		return 0, errors.New("binary.Encode: some values are not fixed-sized in type " + reflect.TypeOf(data).String())
+	}
+	if len(buf) < size {
+		return 0, errBufferTooSmall
+	}
+	e := &encoder{order: order, buf: buf}
+	e.value(v)
+	return size, nil
+}
+
+// Append appends the binary representation of data to buf.
+// buf may be nil, in which case a new buffer will be allocated.
+// See [Write] on which data are acceptable.
+// It returns the (possibly extended) buffer containing data or an error.
+func Append(buf []byte, order ByteOrder, data any) ([]byte, error) {
+	// Fast path for basic types and slices.
+	if n := intDataSize(data); n != 0 {
+		buf, pos := ensure(buf, n)
+		encodeFast(pos, order, data)
+		return buf, nil
+	}
+
+	// Fallback to reflect-based encoding.
+	v := reflect.Indirect(reflect.ValueOf(data))
+	size := dataSize(v)
+	if size < 0 {
+		return nil, errors.New("binary.Append: some values are not fixed-sized in type " + reflect.TypeOf(data).String())
+	}
+	buf, pos := ensure(buf, size)
+	e := &encoder{order: order, buf: pos}
+	e.value(v)
+	return buf, nil
+}
+
+// encodeFast encodes data, whose size is intDataSize(data), into bs.
+func encodeFast(bs []byte, order ByteOrder, data any) {
+	switch v := data.(type) {
+	case *bool:
+		if *v {
+			bs[0] = 1
+		} else {
+			bs[0] = 0
+		}
+	case bool:
+		if v {
+			bs[0] = 1
+		} else {
+			bs[0] = 0
+		}
+	case []bool:
+		for i, x := range v {
+			if x {
+				bs[i] = 1
+			} else {
+				bs[i] = 0
+			}
+		}
+	case *int8:
+		bs[0] = byte(*v)
+	case int8:
+		bs[0] = byte(v)
+	case []int8:
+		for i, x := range v {
+			bs[i] = byte(x)
+		}
+	case *uint8:
+		bs[0] = *v
+	case uint8:
+		bs[0] = v
+	case []uint8:
+		copy(bs, v)
+	case *int16:
+		order.PutUint16(bs, uint16(*v))
+	case int16:
+		order.PutUint16(bs, uint16(v))
+	case []int16:
+		for i, x := range v {
+			order.PutUint16(bs[2*i:], uint16(x))
+		}
+	case *uint16:
+		order.PutUint16(bs, *v)
+	case uint16:
+		order.PutUint16(bs, v)
+	case []uint16:
+		for i, x := range v {
+			order.PutUint16(bs[2*i:], x)
+		}
+	case *int32:
+		order.PutUint32(bs, uint32(*v))
+	case int32:
+		order.PutUint32(bs, uint32(v))
+	case []int32:
+		for i, x := range v {
+			order.PutUint32(bs[4*i:], uint32(x))
+		}
+	case *uint32:
+		order.PutUint32(bs, *v)
+	case uint32:
+		order.PutUint32(bs, v)
+	case []uint32:
+		for i, x := range v {
+			order.PutUint32(bs[4*i:], x)
+		}
+	case *int64:
+		order.PutUint64(bs, uint64(*v))
+	case int64:
+		order.PutUint64(bs, uint64(v))
+	case []int64:
+		for i, x := range v {
+			order.PutUint64(bs[8*i:], uint64(x))
+		}
+	case *uint64:
+		order.PutUint64(bs, *v)
+	case uint64:
+		order.PutUint64(bs, v)
+	case []uint64:
+		for i, x := range v {
+			order.PutUint64(bs[8*i:], x)
+		}
+	case *float32:
+		order.PutUint32(bs, math.Float32bits(*v))
+	case float32:
+		order.PutUint32(bs, math.Float32bits(v))
+	case []float32:
+		for i, x := range v {
+			order.PutUint32(bs[4*i:], math.Float32bits(x))
+		}
+	case *float64:
+		order.PutUint64(bs, math.Float64bits(*v))
+	case float64:
+		order.PutUint64(bs, math.Float64bits(v))
+	case []float64:
+		for i, x := range v {
+			order.PutUint64(bs[8*i:], math.Float64bits(x))
+		}
+	}
+}
+
+// ensure grows buf to length len(buf) + n and returns the grown buffer
+// and a slice starting at the original length of buf (that is, buf2[len(buf):]).
+func ensure(buf []byte, n int) (buf2, pos []byte) {
+	l := len(buf)
+	buf = slices.Grow(buf, n)[:l+n]
+	return buf, buf[l:]
+}
+
 // Size returns how many bytes [Write] would generate to encode the value v, which
 // must be a fixed-size value or a slice of fixed-size values, or a pointer to such data.
 // If v is neither of these, Size returns -1.
@@ -471,8 +606,6 @@ func Size(v any) int {
 	return dataSize(reflect.Indirect(reflect.ValueOf(v)))
 }
 
-var structSize sync.Map // map[reflect.Type]int
-
 // dataSize returns the number of bytes the actual data represented by v occupies in memory.
 // For compound structures, it sums the sizes of the elements. Thus, for instance, for a slice
 // it returns the length of the slice times the element size and does not count the memory
@@ -480,28 +613,10 @@ var structSize sync.Map // map[reflect.Type]int
 func dataSize(v reflect.Value) int {
 	switch v.Kind() {
 	case reflect.Slice:
-		t := v.Type().Elem()
-		if size, ok := structSize.Load(t); ok {
-			return size.(int) * v.Len()
-		}
-
-		size := sizeof(t)
-		if size >= 0 {
-			if t.Kind() == reflect.Struct {
-				structSize.Store(t, size)
-			}
+		if size := sizeof(v.Type().Elem()); size >= 0 {
 			return size * v.Len()
 		}
 
-	case reflect.Struct:
-		t := v.Type()
-		if size, ok := structSize.Load(t); ok {
-			return size.(int)
-		}
-		size := sizeof(t)
-		structSize.Store(t, size)
-		return size
-
 	default:
 		if v.IsValid() {
 			return sizeof(v.Type())
@@ -520,15 +635,7 @@ func sizeof(t reflect.Type) int {
 		}
 
 	case reflect.Struct:
-		sum := 0
-		for i, n := 0, t.NumField(); i < n; i++ {
-			s := sizeof(t.Field(i).Type)
-			if s < 0 {
-				return -1
-			}
-			sum += s
-		}
-		return sum
+		return cachedStructInfo(t).size
 
 	case reflect.Bool,
 		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
@@ -540,6 +647,86 @@ func sizeof(t reflect.Type) int {
 	return -1
 }
 
+// A structInfo describes the encoding of a struct type.
+type structInfo struct {
+	size   int // or -1 if the struct is not a fixed-size value
+	fields []fieldInfo
+}
+
+// A fieldInfo describes the encoding of a struct field.
+type fieldInfo struct {
+	index int
+	size  int
+	skip  bool      // blank or "skip" field, encoded as zeros
+	pad   int       // bytes of padding following the field
+	order ByteOrder // or nil for the byte order of the enclosing value
+}
+
+var structInfos sync.Map // map[reflect.Type]*structInfo
+
+// cachedStructInfo returns the encoding of the struct type t,
+// computing and caching it on first use.
+func cachedStructInfo(t reflect.Type) *structInfo {
+	if si, ok := structInfos.Load(t); ok {
+		return si.(*structInfo)
+	}
+	si, _ := structInfos.LoadOrStore(t, newStructInfo(t))
+	return si.(*structInfo)
+}
+
+func newStructInfo(t reflect.Type) *structInfo {
+	invalid := &structInfo{size: -1}
+	si := &structInfo{fields: make([]fieldInfo, t.NumField())}
+	for i := range si.fields {
+		sf := t.Field(i)
+		f := &si.fields[i]
+		f.index = i
+		f.size = sizeof(sf.Type)
+		if f.size < 0 {
+			return invalid
+		}
+		f.skip = sf.Name == "_"
+		parseTag(f, sf.Tag.Get("binary"))
+		if f.pad > math.MaxInt-si.size-f.size {
+			return invalid
+		}
+		si.size += f.size + f.pad
+	}
+	return si
+}
+
+// parseTag applies the options in the "binary" struct tag to f.
+// Options it does not recognize are ignored.
+func parseTag(f *fieldInfo, tag string) {
+	for tag != "" {
+		opt := tag
+		tag = ""
+		for i := 0; i < len(opt); i++ {
+			if opt[i] == ',' {
+				opt, tag = opt[:i], opt[i+1:]
+				break
+			}
+		}
+		switch opt {
+		case "big":
+			f.order = BigEndian
+		case "little":
+			f.order = LittleEndian
+		case "native":
+			f.order = NativeEndian
+		case "skip":
+			f.skip = true
+		default:
+			if len(opt) < len("pad=") || opt[:len("pad=")] != "pad=" {
+				continue
+			}
+			if pad, err := strconv.Atoi(opt[len("pad="):]); err == nil && pad >= 0 {
+				f.pad = pad
+			}
+		}
+	}
+}
+
 type coder struct {
 	order  ByteOrder
 	buf    []byte
@@ -633,19 +820,20 @@ func (d *decoder) value(v reflect.Value) {
 		}
 
 	case reflect.Struct:
-		t := v.Type()
-		l := v.NumField()
-		for i := 0; i < l; i++ {
-			// Note: Calling v.CanSet() below is an optimization.
-			// It would be sufficient to check the field name,
-			// but creating the StructField info for each field is
-			// costly (run "go test -bench=ReadStruct" and compare
-			// results when making changes to this code).
-			if v := v.Field(i); v.CanSet() || t.Field(i).Name != "_" {
-				d.value(v)
+		order := d.order
+		fields := cachedStructInfo(v.Type()).fields
+		for i := range fields {
+			f := &fields[i]
+			if f.skip {
+				d.offset += f.size
+			} else if f.order != nil {
+				d.order = f.order
+				d.value(v.Field(f.index))
+				d.order = order
 			} else {
-				d.skip(v)
+				d.value(v.Field(f.index))
 			}
+			d.offset += f.pad
 		}
 
 	case reflect.Slice:
@@ -702,15 +890,20 @@ func (e *encoder) value(v reflect.Value) {
 		}
 
 	case reflect.Struct:
-		t := v.Type()
-		l := v.NumField()
-		for i := 0; i < l; i++ {
-			// see comment for corresponding code in decoder.value()
-			if v := v.Field(i); v.CanSet() || t.Field(i).Name != "_" {
-				e.value(v)
+		order := e.order
+		fields := cachedStructInfo(v.Type()).fields
+		for i := range fields {
+			f := &fields[i]
+			if f.skip {
+				e.skip(f.size)
+			} else if f.order != nil {
+				e.order = f.order
+				e.value(v.Field(f.index))
+				e.order = order
 			} else {
-				e.skip(v)
+				e.value(v.Field(f.index))
 			}
+			e.skip(f.pad)
 		}
 
 	case reflect.Slice:
@@ -768,12 +961,8 @@ func (e *encoder) value(v reflect.Value) {
 	}
 }
 
-func (d *decoder) skip(v reflect.Value) {
-	d.offset += dataSize(v)
-}
-
-func (e *encoder) skip(v reflect.Value) {
-	n := dataSize(v)
+// skip writes n zero bytes.
+func (e *encoder) skip(n int) {
 	clear(e.buf[e.offset : e.offset+n])
 	e.offset += n
 }
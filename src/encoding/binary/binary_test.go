@@ -10,6 +10,8 @@ import (
 	"io"
 	"math"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
@@ -136,6 +138,33 @@ func testWrite(t *testing.T, order ByteOrder, b []byte, s1 any) {
 	checkResult(t, "Write", order, err, buf.Bytes(), b)
 }
 
+func testDecode(t *testing.T, order ByteOrder, b []byte, s1 any) {
+	var s2 Struct
+	n, err := Decode(b, order, &s2)
+	if err == nil && n != len(b) {
+		t.Errorf("Decode %v: consumed %d bytes, want %d", order, n, len(b))
+	}
+	checkResult(t, "Decode", order, err, s2, s1)
+
+	if _, err := Decode(b[:len(b)-1], order, &s2); err != errBufferTooSmall {
+		t.Errorf("Decode %v of short buffer: err = %v, want %v", order, err, errBufferTooSmall)
+	}
+}
+
+func testEncode(t *testing.T, order ByteOrder, b []byte, s1 any) {
+	buf := make([]byte, len(b)+1)
+	n, err := Encode(buf, order, s1)
+	checkResult(t, "Encode", order, err, buf[:n], b)
+
+	if _, err := Encode(buf[:len(b)-1], order, s1); err != errBufferTooSmall {
+		t.Errorf("Encode %v to short buffer: err = %v, want %v", order, err, errBufferTooSmall)
+	}
+
+	prefix := []byte("prefix")
+	buf, err = Append(prefix, order, s1)
+	checkResult(t, "Append", order, err, buf, append(prefix, b...))
+}
+
 func TestLittleEndianRead(t *testing.T)     { testRead(t, LittleEndian, little, s) }
 func TestLittleEndianWrite(t *testing.T)    { testWrite(t, LittleEndian, little, s) }
 func TestLittleEndianPtrWrite(t *testing.T) { testWrite(t, LittleEndian, little, &s) }
@@ -144,6 +173,111 @@ func TestBigEndianRead(t *testing.T)     { testRead(t, BigEndian, big, s) }
 func TestBigEndianWrite(t *testing.T)    { testWrite(t, BigEndian, big, s) }
 func TestBigEndianPtrWrite(t *testing.T) { testWrite(t, BigEndian, big, &s) }
 
+func TestLittleEndianDecode(t *testing.T)    { testDecode(t, LittleEndian, little, s) }
+func TestLittleEndianEncode(t *testing.T)    { testEncode(t, LittleEndian, little, s) }
+func TestLittleEndianPtrEncode(t *testing.T) { testEncode(t, LittleEndian, little, &s) }
+
+func TestBigEndianDecode(t *testing.T)    { testDecode(t, BigEndian, big, s) }
+func TestBigEndianEncode(t *testing.T)    { testEncode(t, BigEndian, big, s) }
+func TestBigEndianPtrEncode(t *testing.T) { testEncode(t, BigEndian, big, &s) }
+
+func TestDecodeSlice(t *testing.T) {
+	slice := make([]int32, 2)
+	_, err := Decode(src, BigEndian, slice)
+	checkResult(t, "DecodeSlice", BigEndian, err, slice, res)
+}
+
+func TestEncodeSlice(t *testing.T) {
+	buf, err := Append(nil, BigEndian, res)
+	checkResult(t, "AppendSlice", BigEndian, err, buf, src)
+
+	buf, err = Append(nil, BigEndian, []byte{1, 2})
+	checkResult(t, "AppendSlice", BigEndian, err, buf, []byte{1, 2})
+}
+
+type Tagged struct {
+	A uint16
+	B uint16 `binary:"little"`
+	C struct {
+		D uint16
+		E uint16 `binary:"big"`
+	} `binary:"little,pad=1"`
+	F uint32    `binary:"skip"`
+	G [2]uint16 `binary:"pad=2,little"`
+}
+
+var tagged = Tagged{
+	A: 0x0102,
+	B: 0x0304,
+	C: struct {
+		D uint16
+		E uint16 `binary:"big"`
+	}{0x0506, 0x0708},
+	F: 0x090a0b0c,
+	G: [2]uint16{0x0d0e, 0x0f10},
+}
+
+var taggedBytes = []byte{
+	0x01, 0x02,
+	0x04, 0x03,
+	0x06, 0x05, 0x07, 0x08,
+	0,          // pad
+	0, 0, 0, 0, // skip
+	0x0e, 0x0d, 0x10, 0x0f,
+	0, 0, // pad
+}
+
+func TestStructTags(t *testing.T) {
+	if n := Size(tagged); n != len(taggedBytes) {
+		t.Errorf("Size = %d, want %d", n, len(taggedBytes))
+	}
+	b, err := Append(nil, BigEndian, &tagged)
+	checkResult(t, "Append", BigEndian, err, b, taggedBytes)
+
+	var buf bytes.Buffer
+	err = Write(&buf, BigEndian, tagged)
+	checkResult(t, "Write", BigEndian, err, buf.Bytes(), taggedBytes)
+
+	var got Tagged
+	filled := slices.Clone(taggedBytes)
+	for i := range filled {
+		if filled[i] == 0 {
+			filled[i] = 0xff // padding is ignored
+		}
+	}
+	_, err = Decode(filled, BigEndian, &got)
+	want := tagged
+	want.F = 0
+	checkResult(t, "Decode", BigEndian, err, got, want)
+
+	// Unknown and malformed options are ignored.
+	for _, tag := range []string{"pad", "pad=-1", "pad=x", "middle", "big,", ",", "a_field"} {
+		typ := reflect.StructOf([]reflect.StructField{{
+			Name: "A",
+			Type: reflect.TypeFor[uint8](),
+			Tag:  reflect.StructTag(`binary:"` + tag + `"`),
+		}})
+		if n := Size(reflect.New(typ).Interface()); n != 1 {
+			t.Errorf("Size of struct with tag %q = %d, want 1", tag, n)
+		}
+	}
+	var other struct {
+		A uint16 `binary:"a_field"`
+		B uint32 `binary:"b_field,little"`
+	}
+	other.A, other.B = 0x0102, 0x03040506
+	enc, err := Append(nil, BigEndian, other)
+	checkResult(t, "Append with other tags", BigEndian, err, enc, []byte{1, 2, 6, 5, 4, 3})
+
+	typ := reflect.StructOf([]reflect.StructField{
+		{Name: "A", Type: reflect.TypeFor[uint8](), Tag: reflect.StructTag(`binary:"pad=` + strconv.Itoa(math.MaxInt-1) + `"`)},
+		{Name: "B", Type: reflect.TypeFor[uint8]()},
+	})
+	if n := Size(reflect.New(typ).Interface()); n != -1 {
+		t.Errorf("Size of struct with overflowing padding = %d, want -1", n)
+	}
+}
+
 func TestReadSlice(t *testing.T) {
 	slice := make([]int32, 2)
 	err := Read(bytes.NewReader(src), BigEndian, slice)
@@ -301,11 +435,11 @@ func TestBlankFields(t *testing.T) {
 
 func TestSizeStructCache(t *testing.T) {
 	// Reset the cache, otherwise multiple test runs fail.
-	structSize = sync.Map{}
+	structInfos = sync.Map{}
 
 	count := func() int {
 		var i int
-		structSize.Range(func(_, _ any) bool {
+		structInfos.Range(func(_, _ any) bool {
 			i++
 			return true
 		})
@@ -334,7 +468,7 @@ func TestSizeStructCache(t *testing.T) {
 		want int
 	}{
 		{new(foo), 1},
-		{new(bar), 1},
+		{new(bar), 2}, // bar and Struct
 		{new(bar), 0},
 		{new(struct{ A Struct }), 1},
 		{new(struct{ A Struct }), 0},
@@ -0,0 +1,426 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csv
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Marshal returns the CSV encoding of v, which must be a slice of structs
+// or of pointers to structs. The first record is a header holding the
+// column names, and each following record holds the fields of one
+// element of v, as written by [Encoder.Encode].
+func Marshal(v any) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("csv: Marshal of non-slice type %T", v)
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	enc := NewEncoder(w)
+	if err := enc.init(rv.Type().Elem()); err != nil {
+		return nil, err
+	}
+	for i := 0; i < rv.Len(); i++ {
+		if err := enc.encode(rv.Index(i)); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal parses the CSV-encoded data, which begins with a header
+// record, and appends one element per following record to the slice
+// pointed to by v, as read by [Decoder.Decode]. The elements of the
+// slice must be structs or pointers to structs.
+func Unmarshal(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("csv: Unmarshal of non-pointer-to-slice type %T", v)
+	}
+	slice := rv.Elem()
+	dec := NewDecoder(NewReader(bytes.NewReader(data)))
+	for {
+		elem := reflect.New(slice.Type().Elem()).Elem()
+		err := dec.decode(elem)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		slice.Set(reflect.Append(slice, elem))
+	}
+}
+
+// An Encoder writes structs as CSV records to a [Writer].
+//
+// Each exported field of a struct is a column, named by the field name
+// or by the name in its "csv" struct tag. A field with the tag "-" is
+// omitted. The fields of an embedded struct are promoted to the outer
+// struct. Fields may be strings, booleans, integers, floating-point
+// numbers, types implementing [encoding.TextMarshaler] and
+// [encoding.TextUnmarshaler], or pointers to those; a nil pointer is
+// written as an empty field.
+type Encoder struct {
+	w      *Writer
+	typ    reflect.Type
+	cols   []column
+	record []string // reused between records
+}
+
+// NewEncoder returns a new Encoder that writes to w.
+func NewEncoder(w *Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the struct, or pointer to struct, v as a record.
+// The first call writes a header record naming the columns first.
+// All calls must pass values of the same type.
+// Like [Writer.Write], Encode buffers its output.
+func (e *Encoder) Encode(v any) error {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return errors.New("csv: Encode of nil value")
+	}
+	if e.typ == nil {
+		if err := e.init(rv.Type()); err != nil {
+			return err
+		}
+	}
+	return e.encode(rv)
+}
+
+// init writes the header for elements of type t.
+func (e *Encoder) init(t reflect.Type) error {
+	cols, err := cachedColumns(t)
+	if err != nil {
+		return err
+	}
+	e.typ = t
+	e.cols = cols
+	e.record = make([]string, len(cols))
+	for i, c := range cols {
+		e.record[i] = c.name
+	}
+	return e.w.Write(e.record)
+}
+
+func (e *Encoder) encode(v reflect.Value) error {
+	if v.Type() != e.typ {
+		return fmt.Errorf("csv: Encode of %v after %v", v.Type(), e.typ)
+	}
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return fmt.Errorf("csv: Encode of nil %v", v.Type())
+		}
+		v = v.Elem()
+	}
+	for i, c := range e.cols {
+		s, err := formatField(fieldByIndex(v, c.index, false))
+		if err != nil {
+			return fmt.Errorf("csv: column %s: %w", c.name, err)
+		}
+		e.record[i] = s
+	}
+	return e.w.Write(e.record)
+}
+
+// A Decoder reads CSV records from a [Reader] into structs.
+// The first record read is a header naming the columns, which are
+// matched to struct fields as described for [Encoder].
+// Columns with no matching field are ignored, and fields with no
+// matching column are left unchanged. A UTF-8 byte order mark at the
+// start of the header is ignored.
+type Decoder struct {
+	r      *Reader
+	header []string
+	typ    reflect.Type
+	index  [][]int // field index of each column, or nil
+}
+
+// NewDecoder returns a new Decoder that reads from r.
+func NewDecoder(r *Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Header returns the header record, reading it if necessary.
+func (d *Decoder) Header() ([]string, error) {
+	if d.header == nil {
+		header, err := d.r.Read()
+		if err != nil {
+			return nil, err
+		}
+		if len(header) > 0 {
+			header[0] = strings.TrimPrefix(header[0], "\uFEFF")
+		}
+		d.header = append([]string(nil), header...)
+	}
+	return d.header, nil
+}
+
+// Decode reads the next record into the struct pointed to by v.
+// At the end of the input, it returns [io.EOF].
+// An error converting a field is reported as a [ParseError]
+// giving the position of the field.
+func (d *Decoder) Decode(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("csv: Decode of non-pointer type %T", v)
+	}
+	return d.decode(rv.Elem())
+}
+
+func (d *Decoder) decode(v reflect.Value) error {
+	if _, err := d.Header(); err != nil {
+		return err
+	}
+	if v.Type() != d.typ {
+		cols, err := cachedColumns(v.Type())
+		if err != nil {
+			return err
+		}
+		byName := make(map[string][]int, len(cols))
+		for _, c := range cols {
+			byName[c.name] = c.index
+		}
+		d.typ = v.Type()
+		d.index = make([][]int, len(d.header))
+		for i, name := range d.header {
+			d.index[i] = byName[name]
+		}
+	}
+
+	record, err := d.r.Read()
+	if err != nil {
+		return err
+	}
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	for i, field := range record {
+		if i >= len(d.index) || d.index[i] == nil {
+			continue
+		}
+		if err := parseField(fieldByIndex(v, d.index[i], true), field); err != nil {
+			line, col := d.r.FieldPos(i)
+			return &ParseError{StartLine: line, Line: line, Column: col, Err: fmt.Errorf("column %s: %w", d.header[i], err)}
+		}
+	}
+	return nil
+}
+
+// A column is a struct field mapped to a CSV column.
+type column struct {
+	name  string
+	index []int
+}
+
+var columnCache sync.Map // map[reflect.Type][]column
+
+// cachedColumns returns the columns for t, a struct type or a
+// pointer to one.
+func cachedColumns(t reflect.Type) ([]column, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("csv: unsupported type %v, want struct", t)
+	}
+	if cols, ok := columnCache.Load(t); ok {
+		return cols.([]column), nil
+	}
+	cols, err := typeColumns(t, nil, make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+	columnCache.Store(t, cols)
+	return cols, nil
+}
+
+func typeColumns(t reflect.Type, index []int, seen map[string]bool) ([]column, error) {
+	var cols []column
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("csv")
+		if tag == "-" {
+			continue
+		}
+		idx := append(index[:len(index):len(index)], i)
+		if sf.Anonymous && tag == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if !sf.IsExported() && ft != sf.Type {
+					// A nil pointer to an unexported type
+					// cannot be allocated when decoding.
+					continue
+				}
+				embedded, err := typeColumns(ft, idx, seen)
+				if err != nil {
+					return nil, err
+				}
+				cols = append(cols, embedded...)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if !supportedType(sf.Type) {
+			return nil, fmt.Errorf("csv: unsupported type %v for field %s", sf.Type, sf.Name)
+		}
+		name := tag
+		if name == "" {
+			name = sf.Name
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("csv: duplicate column name %q in %v", name, t)
+		}
+		seen[name] = true
+		cols = append(cols, column{name, idx})
+	}
+	return cols, nil
+}
+
+var (
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+func supportedType(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(textMarshalerType) && reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// fieldByIndex returns the field of the struct v with the given index
+// sequence. If alloc is set, nil embedded pointers are allocated;
+// otherwise the zero Value is returned on reaching one.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// formatField returns the text of the field v.
+func formatField(v reflect.Value) (string, error) {
+	if !v.IsValid() {
+		return "", nil
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	if reflect.PointerTo(v.Type()).Implements(textMarshalerType) {
+		if !v.CanAddr() {
+			p := reflect.New(v.Type())
+			p.Elem().Set(v)
+			v = p.Elem()
+		}
+		b, err := v.Addr().Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	}
+	panic("csv: unexpected field type " + v.Type().String()) // checked by supportedType
+}
+
+// parseField stores the text s in the field v.
+// An empty s sets a pointer field to nil.
+func parseField(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Pointer {
+		if s == "" {
+			v.SetZero()
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csv
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Base struct {
+	ID int `csv:"id"`
+}
+
+type Item struct {
+	Base
+	Name    string
+	Price   float64 `csv:"price"`
+	InStock bool    `csv:"in_stock"`
+	Count   *uint8  `csv:"count"`
+	Added   time.Time
+	Ignored string `csv:"-"`
+	hidden  string
+}
+
+var (
+	three = uint8(3)
+	added = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	items = []Item{
+		{Base: Base{1}, Name: "apple", Price: 0.5, InStock: true, Count: &three, Added: added},
+		{Base: Base{2}, Name: "pear, green", Price: 1e21, Added: added},
+	}
+	itemsCSV = "id,Name,price,in_stock,count,Added\n" +
+		"1,apple,0.5,true,3,2024-01-02T03:04:05Z\n" +
+		"2,\"pear, green\",1e+21,false,,2024-01-02T03:04:05Z\n"
+)
+
+func TestMarshal(t *testing.T) {
+	b, err := Marshal(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != itemsCSV {
+		t.Errorf("Marshal:\ngot  %q\nwant %q", b, itemsCSV)
+	}
+
+	ptrs := []*Item{&items[0], &items[1]}
+	b, err = Marshal(ptrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != itemsCSV {
+		t.Errorf("Marshal of pointers:\ngot  %q\nwant %q", b, itemsCSV)
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	var got []Item
+	if err := Unmarshal([]byte(itemsCSV), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, items) {
+		t.Errorf("Unmarshal:\ngot  %+v\nwant %+v", got, items)
+	}
+
+	// Columns are matched by name, in any order; unknown columns
+	// are ignored; a byte order mark is skipped.
+	var got2 []*Item
+	in := "\uFEFFextra,price,id\nx,2.5,7\n"
+	if err := Unmarshal([]byte(in), &got2); err != nil {
+		t.Fatal(err)
+	}
+	want := []*Item{{Base: Base{7}, Price: 2.5}}
+	if !reflect.DeepEqual(got2, want) {
+		t.Errorf("Unmarshal:\ngot  %+v\nwant %+v", got2, want)
+	}
+}
+
+func TestUnmarshalError(t *testing.T) {
+	var got []Item
+	err := Unmarshal([]byte("id,Name\n1,a\nx,b\n"), &got)
+	var perr *ParseError
+	if !errors.As(err, &perr) || perr.Line != 3 || perr.Column != 1 {
+		t.Fatalf("Unmarshal error = %v, want ParseError on line 3, column 1", err)
+	}
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("Unmarshal error = %v, want to wrap %v", err, strconv.ErrSyntax)
+	}
+
+	for _, v := range []any{
+		[]Item{},
+		new(Item),
+		new([]int),
+		new([]struct{ C chan int }),
+		new([]struct {
+			A int
+			B int `csv:"A"`
+		}),
+	} {
+		if err := Unmarshal([]byte("A\n1\n"), v); err == nil {
+			t.Errorf("Unmarshal into %T succeeded", v)
+		}
+	}
+}
+
+func TestEncoderDecoder(t *testing.T) {
+	var b strings.Builder
+	w := NewWriter(&b)
+	w.QuoteStyle = QuoteNonNumeric
+	enc := NewEncoder(w)
+	for _, it := range items {
+		if err := enc.Encode(it); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Encode(&items[0]); err == nil {
+		t.Error("Encode of a different type succeeded")
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		t.Fatal(err)
+	}
+	const want = `"id","Name","price","in_stock","count","Added"` + "\n" +
+		`1,"apple",0.5,"true",3,"2024-01-02T03:04:05Z"` + "\n" +
+		`2,"pear, green",1e+21,"false","","2024-01-02T03:04:05Z"` + "\n"
+	if b.String() != want {
+		t.Errorf("Encode:\ngot  %q\nwant %q", b.String(), want)
+	}
+
+	dec := NewDecoder(NewReader(strings.NewReader(b.String())))
+	header, err := dec.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"id", "Name", "price", "in_stock", "count", "Added"}; !reflect.DeepEqual(header, want) {
+		t.Errorf("Header = %q, want %q", header, want)
+	}
+	for i := 0; ; i++ {
+		var it Item
+		err := dec.Decode(&it)
+		if err == io.EOF {
+			if i != len(items) {
+				t.Errorf("Decode returned EOF after %d items, want %d", i, len(items))
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(it, items[i]) {
+			t.Errorf("Decode #%d:\ngot  %+v\nwant %+v", i, it, items[i])
+		}
+	}
+}
@@ -49,6 +49,10 @@
 //
 //	{`Multi-line
 //	field`, `comma is ,`}
+//
+// The quote character, and whether quotes are escaped by doubling them or
+// with an escape character, can be changed with the Quote and Escape fields
+// of [Reader] and [Writer].
 package csv
 
 import (
@@ -92,12 +96,34 @@ var (
 	ErrTrailingComma = errors.New("extra delimiter at end of line")
 )
 
-var errInvalidDelim = errors.New("csv: invalid field or comment delimiter")
+var (
+	errInvalidDelim = errors.New("csv: invalid field or comment delimiter")
+	errInvalidQuote = errors.New("csv: invalid quote or escape character")
+)
 
 func validDelim(r rune) bool {
 	return r != 0 && r != '"' && r != '\r' && r != '\n' && utf8.ValidRune(r) && r != utf8.RuneError
 }
 
+func validQuote(r rune) bool {
+	return r != '\r' && r != '\n' && utf8.ValidRune(r) && r != utf8.RuneError
+}
+
+// quoteRunes returns the quote and escape characters configured by the
+// Quote and Escape fields of a [Reader] or [Writer], applying their defaults.
+func quoteRunes(quote, escape, comma rune) (rune, rune, error) {
+	if quote == 0 {
+		quote = '"'
+	}
+	if escape == 0 {
+		escape = quote
+	}
+	if !validQuote(quote) || quote == comma || !validQuote(escape) || escape == comma {
+		return 0, 0, errInvalidQuote
+	}
+	return quote, escape, nil
+}
+
 // A Reader reads records from a CSV-encoded file.
 //
 // As returned by [NewReader], a Reader expects input conforming to RFC 4180.
@@ -131,6 +157,20 @@ type Reader struct {
 	// made and records may have a variable number of fields.
 	FieldsPerRecord int
 
+	// Quote is the quote character (set to '"' by NewReader).
+	// Quote must be a valid rune, must not be \r, \n,
+	// or the Unicode replacement character (0xFFFD),
+	// and must not be equal to Comma or Comment.
+	Quote rune
+
+	// Escape, if not 0, is the character that escapes a Quote character,
+	// or Escape itself, within a quoted field, as written by a [Writer]
+	// with the same Escape. An Escape followed by any other character
+	// is part of the field. If Escape is 0, a Quote character is escaped
+	// by doubling it, as specified by RFC 4180. The same rules as for
+	// Quote apply.
+	Escape rune
+
 	// If LazyQuotes is true, a quote may appear in an unquoted field and a
 	// non-doubled quote may appear in a quoted field.
 	LazyQuotes bool
@@ -180,6 +220,7 @@ type Reader struct {
 func NewReader(r io.Reader) *Reader {
 	return &Reader{
 		Comma: ',',
+		Quote: '"',
 		r:     bufio.NewReader(r),
 	}
 }
@@ -297,6 +338,17 @@ func (r *Reader) readRecord(dst []string) ([]string, error) {
 	if r.Comma == r.Comment || !validDelim(r.Comma) || (r.Comment != 0 && !validDelim(r.Comment)) {
 		return nil, errInvalidDelim
 	}
+	quote, escape, err := quoteRunes(r.Quote, r.Escape, r.Comma)
+	if err != nil {
+		return nil, err
+	}
+	if r.Comment != 0 && (quote == r.Comment || escape == r.Comment) {
+		return nil, errInvalidQuote
+	}
+	quotes := string(quote)
+	if escape != quote {
+		quotes += string(escape)
+	}
 
 	// Read line (automatically skipping past empty lines and any comments).
 	var line []byte
@@ -318,8 +370,8 @@ func (r *Reader) readRecord(dst []string) ([]string, error) {
 	}
 
 	// Parse each field in the record.
-	var err error
-	const quoteLen = len(`"`)
+	quoteLen := utf8.RuneLen(quote)
+	escapeLen := utf8.RuneLen(escape)
 	commaLen := utf8.RuneLen(r.Comma)
 	recLine := r.numLine // Starting line for record
 	r.recordBuffer = r.recordBuffer[:0]
@@ -339,7 +391,7 @@ parseField:
 			line = line[i:]
 			pos.col += i
 		}
-		if len(line) == 0 || line[0] != '"' {
+		if len(line) == 0 || nextRune(line) != quote {
 			// Non-quoted string field
 			i := bytes.IndexRune(line, r.Comma)
 			field := line
@@ -350,7 +402,7 @@ parseField:
 			}
 			// Check to make sure a quote does not appear in field.
 			if !r.LazyQuotes {
-				if j := bytes.IndexByte(field, '"'); j >= 0 {
+				if j := bytes.IndexRune(field, quote); j >= 0 {
 					col := pos.col + j
 					err = &ParseError{StartLine: recLine, Line: r.numLine, Column: col, Err: ErrBareQuote}
 					break parseField
@@ -371,16 +423,31 @@ parseField:
 			line = line[quoteLen:]
 			pos.col += quoteLen
 			for {
-				i := bytes.IndexByte(line, '"')
-				if i >= 0 {
+				i := bytes.IndexAny(line, quotes)
+				if i >= 0 && escape != quote && nextRune(line[i:]) == escape {
+					// Hit an escape character.
+					r.recordBuffer = append(r.recordBuffer, line[:i]...)
+					line = line[i+escapeLen:]
+					pos.col += i + escapeLen
+					if rn := nextRune(line); rn == quote || rn == escape {
+						// `\"` or `\\` sequence (append escaped character).
+						n := utf8.RuneLen(rn)
+						r.recordBuffer = append(r.recordBuffer, line[:n]...)
+						line = line[n:]
+						pos.col += n
+					} else {
+						// `\*` sequence (append escape character).
+						r.recordBuffer = utf8.AppendRune(r.recordBuffer, escape)
+					}
+				} else if i >= 0 {
 					// Hit next quote.
 					r.recordBuffer = append(r.recordBuffer, line[:i]...)
 					line = line[i+quoteLen:]
 					pos.col += i + quoteLen
 					switch rn := nextRune(line); {
-					case rn == '"':
+					case rn == quote && escape == quote:
 						// `""` sequence (append quote).
-						r.recordBuffer = append(r.recordBuffer, '"')
+						r.recordBuffer = utf8.AppendRune(r.recordBuffer, quote)
 						line = line[quoteLen:]
 						pos.col += quoteLen
 					case rn == r.Comma:
@@ -397,7 +464,7 @@ parseField:
 						break parseField
 					case r.LazyQuotes:
 						// `"` sequence (bare quote).
-						r.recordBuffer = append(r.recordBuffer, '"')
+						r.recordBuffer = utf8.AppendRune(r.recordBuffer, quote)
 					default:
 						// `"*` sequence (invalid non-escaped quote).
 						err = &ParseError{StartLine: recLine, Line: r.numLine, Column: pos.col - quoteLen, Err: ErrQuote}
@@ -24,6 +24,8 @@ type readTest struct {
 	// These fields are copied into the Reader
 	Comma              rune
 	Comment            rune
+	Quote              rune
+	Escape             rune
 	UseFieldsPerRecord bool // false (default) means FieldsPerRecord is -1
 	FieldsPerRecord    int
 	LazyQuotes         bool
@@ -401,6 +403,50 @@ field"`,
 	Comma:   'X',
 	Comment: 'X',
 	Errors:  []error{errInvalidDelim},
+}, {
+	Name:   "SingleQuote",
+	Input:  "§'a,b',§'c''d',§e\"f\n",
+	Output: [][]string{{"a,b", "c'd", `e"f`}},
+	Quote:  '\'',
+}, {
+	Name:   "MultiByteQuote",
+	Input:  "§«a,b«,§«c««d«\n",
+	Output: [][]string{{"a,b", "c«d"}},
+	Quote:  '«',
+}, {
+	Name:   "BareSingleQuote",
+	Input:  `§a∑'b`,
+	Errors: []error{&ParseError{Err: ErrBareQuote}},
+	Quote:  '\'',
+}, {
+	Name:   "Escape",
+	Input:  `§"a\"b",§"c\\d",§"e\f",§g\h` + "\n",
+	Output: [][]string{{`a"b`, `c\d`, `e\f`, `g\h`}},
+	Escape: '\\',
+}, {
+	Name:   "EscapeNoDoubledQuote",
+	Input:  `§"a∑""b"`,
+	Errors: []error{&ParseError{Err: ErrQuote}},
+	Escape: '\\',
+}, {
+	Name:   "EscapeMultiline",
+	Input:  "§'a\\\nb\\'c'\n",
+	Output: [][]string{{"a\\\nb'c"}},
+	Quote:  '\'',
+	Escape: '\\',
+}, {
+	Name:   "BadQuote",
+	Quote:  '\n',
+	Errors: []error{errInvalidQuote},
+}, {
+	Name:   "BadQuoteComma",
+	Quote:  ',',
+	Errors: []error{errInvalidQuote},
+}, {
+	Name:    "BadEscapeComment",
+	Comment: '#',
+	Escape:  '#',
+	Errors:  []error{errInvalidQuote},
 }}
 
 func TestRead(t *testing.T) {
@@ -412,6 +458,10 @@ func TestRead(t *testing.T) {
 			r.Comma = tt.Comma
 		}
 		r.Comment = tt.Comment
+		if tt.Quote != 0 {
+			r.Quote = tt.Quote
+		}
+		r.Escape = tt.Escape
 		if tt.UseFieldsPerRecord {
 			r.FieldsPerRecord = tt.FieldsPerRecord
 		} else {
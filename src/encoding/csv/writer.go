@@ -24,6 +24,13 @@ import (
 // If [Writer.UseCRLF] is true,
 // the Writer ends each output line with \r\n instead of \n.
 //
+// [Writer.Quote] is the quote character, and [Writer.Escape] the character
+// that escapes a quote character within a quoted field. [Writer.QuoteStyle]
+// selects which fields are quoted.
+//
+// If [Writer.UseBOM] is true, the Writer begins its output with a
+// UTF-8 byte order mark, as some spreadsheet programs expect.
+//
 // The writes of individual records are buffered.
 // After all data has been written, the client should call the
 // [Writer.Flush] method to guarantee all data has been forwarded to
@@ -32,17 +39,60 @@ import (
 type Writer struct {
 	Comma   rune // Field delimiter (set to ',' by NewWriter)
 	UseCRLF bool // True to use \r\n as the line terminator
-	w       *bufio.Writer
+
+	// Quote is the quote character (set to '"' by NewWriter).
+	// It must be a valid rune, must differ from Comma,
+	// and must not be \r, \n, or the Unicode replacement character.
+	Quote rune
+
+	// Escape, if not 0, is written before a Quote character that
+	// appears within a quoted field, and before Escape itself.
+	// If Escape is 0, a Quote character is escaped by doubling it,
+	// as specified by RFC 4180. The same rules as for Quote apply.
+	Escape rune
+
+	// QuoteStyle selects which fields are quoted.
+	QuoteStyle QuoteStyle
+
+	// UseBOM, if true, causes the first Write to begin
+	// with a UTF-8 byte order mark.
+	UseBOM bool
+
+	w        *bufio.Writer
+	wroteBOM bool
 }
 
+// A QuoteStyle selects which fields a [Writer] encloses in quotes.
+type QuoteStyle int
+
+const (
+	// QuoteMinimal quotes only the fields that need it: those that
+	// contain the delimiter, a quote or escape character, or a newline,
+	// and those that begin with a space.
+	QuoteMinimal QuoteStyle = iota
+
+	// QuoteAll quotes every field, including empty ones.
+	QuoteAll
+
+	// QuoteNonNumeric quotes every field that is not a decimal number,
+	// such as "-12" or "3.5e10", as well as those QuoteMinimal quotes.
+	QuoteNonNumeric
+)
+
 // NewWriter returns a new Writer that writes to w.
 func NewWriter(w io.Writer) *Writer {
 	return &Writer{
 		Comma: ',',
+		Quote: '"',
 		w:     bufio.NewWriter(w),
 	}
 }
 
+// quote returns the quote and escape characters.
+func (w *Writer) quote() (quote, escape rune, err error) {
+	return quoteRunes(w.Quote, w.Escape, w.Comma)
+}
+
 // Write writes a single CSV record to w along with any necessary quoting.
 // A record is a slice of strings with each string being one field.
 // Writes are buffered, so [Writer.Flush] must eventually be called to ensure
@@ -51,6 +101,21 @@ func (w *Writer) Write(record []string) error {
 	if !validDelim(w.Comma) {
 		return errInvalidDelim
 	}
+	quote, escape, err := w.quote()
+	if err != nil {
+		return err
+	}
+	specials := "\r\n" + string(quote)
+	if escape != quote {
+		specials += string(escape)
+	}
+
+	if w.UseBOM && !w.wroteBOM {
+		if _, err := w.w.WriteRune('\uFEFF'); err != nil {
+			return err
+		}
+		w.wroteBOM = true
+	}
 
 	for n, field := range record {
 		if n > 0 {
@@ -61,19 +126,19 @@ func (w *Writer) Write(record []string) error {
 
 		// If we don't have to have a quoted field then just
 		// write out the field and continue to the next field.
-		if !w.fieldNeedsQuotes(field) {
+		if !w.fieldNeedsQuotes(field, specials) {
 			if _, err := w.w.WriteString(field); err != nil {
 				return err
 			}
 			continue
 		}
 
-		if err := w.w.WriteByte('"'); err != nil {
+		if _, err := w.w.WriteRune(quote); err != nil {
 			return err
 		}
 		for len(field) > 0 {
 			// Search for special characters.
-			i := strings.IndexAny(field, "\"\r\n")
+			i := strings.IndexAny(field, specials)
 			if i < 0 {
 				i = len(field)
 			}
@@ -87,9 +152,12 @@ func (w *Writer) Write(record []string) error {
 			// Encode the special character.
 			if len(field) > 0 {
 				var err error
-				switch field[0] {
-				case '"':
-					_, err = w.w.WriteString(`""`)
+				r, size := utf8.DecodeRuneInString(field)
+				switch r {
+				case quote, escape:
+					if _, err = w.w.WriteRune(escape); err == nil {
+						_, err = w.w.WriteRune(r)
+					}
 				case '\r':
 					if !w.UseCRLF {
 						err = w.w.WriteByte('\r')
@@ -101,17 +169,16 @@ func (w *Writer) Write(record []string) error {
 						err = w.w.WriteByte('\n')
 					}
 				}
-				field = field[1:]
+				field = field[size:]
 				if err != nil {
 					return err
 				}
 			}
 		}
-		if err := w.w.WriteByte('"'); err != nil {
+		if _, err := w.w.WriteRune(quote); err != nil {
 			return err
 		}
 	}
-	var err error
 	if w.UseCRLF {
 		_, err = w.w.WriteString("\r\n")
 	} else {
@@ -146,8 +213,10 @@ func (w *Writer) WriteAll(records [][]string) error {
 }
 
 // fieldNeedsQuotes reports whether our field must be enclosed in quotes.
-// Fields with a Comma, fields with a quote or newline, and
-// fields which start with a space must be enclosed in quotes.
+// Fields with a Comma, fields with one of the specials (a quote, escape
+// or newline character), and fields which start with a space must be
+// enclosed in quotes, as must all fields or non-numeric fields if
+// required by w.QuoteStyle.
 // We used to quote empty strings, but we do not anymore (as of Go 1.4).
 // The two representations should be equivalent, but Postgres distinguishes
 // quoted vs non-quoted empty string during database imports, and it has
@@ -157,7 +226,15 @@ func (w *Writer) WriteAll(records [][]string) error {
 // Not quoting the empty string also makes this package match the behavior
 // of Microsoft Excel and Google Drive.
 // For Postgres, quote the data terminating string `\.`.
-func (w *Writer) fieldNeedsQuotes(field string) bool {
+func (w *Writer) fieldNeedsQuotes(field, specials string) bool {
+	switch w.QuoteStyle {
+	case QuoteAll:
+		return true
+	case QuoteNonNumeric:
+		if !isNumeric(field) {
+			return true
+		}
+	}
 	if field == "" {
 		return false
 	}
@@ -166,7 +243,7 @@ func (w *Writer) fieldNeedsQuotes(field string) bool {
 		return true
 	}
 
-	if w.Comma < utf8.RuneSelf {
+	if w.Comma < utf8.RuneSelf && specials == "\r\n\"" {
 		for i := 0; i < len(field); i++ {
 			c := field[i]
 			if c == '\n' || c == '\r' || c == '"' || c == byte(w.Comma) {
@@ -174,7 +251,7 @@ func (w *Writer) fieldNeedsQuotes(field string) bool {
 			}
 		}
 	} else {
-		if strings.ContainsRune(field, w.Comma) || strings.ContainsAny(field, "\"\r\n") {
+		if strings.ContainsRune(field, w.Comma) || strings.ContainsAny(field, specials) {
 			return true
 		}
 	}
@@ -182,3 +259,37 @@ func (w *Writer) fieldNeedsQuotes(field string) bool {
 	r1, _ := utf8.DecodeRuneInString(field)
 	return unicode.IsSpace(r1)
 }
+
+// isNumeric reports whether field is a decimal number: an optional sign,
+// digits with an optional decimal point, and an optional exponent.
+func isNumeric(field string) bool {
+	i := 0
+	if i < len(field) && (field[i] == '+' || field[i] == '-') {
+		i++
+	}
+	digits := 0
+	for ; i < len(field) && '0' <= field[i] && field[i] <= '9'; i++ {
+		digits++
+	}
+	if i < len(field) && field[i] == '.' {
+		i++
+		for ; i < len(field) && '0' <= field[i] && field[i] <= '9'; i++ {
+			digits++
+		}
+	}
+	if digits == 0 {
+		return false
+	}
+	if i < len(field) && (field[i] == 'e' || field[i] == 'E') {
+		i++
+		if i < len(field) && (field[i] == '+' || field[i] == '-') {
+			i++
+		}
+		if i == len(field) {
+			return false
+		}
+		for ; i < len(field) && '0' <= field[i] && field[i] <= '9'; i++ {
+		}
+	}
+	return i == len(field)
+}
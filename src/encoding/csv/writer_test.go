@@ -7,16 +7,21 @@ package csv
 import (
 	"bytes"
 	"errors"
+	"reflect"
 	"strings"
 	"testing"
 )
 
 var writeTests = []struct {
-	Input   [][]string
-	Output  string
-	Error   error
-	UseCRLF bool
-	Comma   rune
+	Input      [][]string
+	Output     string
+	Error      error
+	UseCRLF    bool
+	Comma      rune
+	Quote      rune
+	Escape     rune
+	QuoteStyle QuoteStyle
+	UseBOM     bool
 }{
 	{Input: [][]string{{"abc"}}, Output: "abc\n"},
 	{Input: [][]string{{"abc"}}, Output: "abc\r\n", UseCRLF: true},
@@ -47,6 +52,15 @@ var writeTests = []struct {
 	{Input: [][]string{{"a", "a", ""}}, Output: "a|a|\n", Comma: '|'},
 	{Input: [][]string{{",", ",", ""}}, Output: ",|,|\n", Comma: '|'},
 	{Input: [][]string{{"foo"}}, Comma: '"', Error: errInvalidDelim},
+	{Input: [][]string{{"a", "", "1"}}, Output: `"a","","1"` + "\n", QuoteStyle: QuoteAll},
+	{Input: [][]string{{"a", "", "1", "-2.5e3", "1e", ".", "+.5", "1,5"}}, Output: `"a","",1,-2.5e3,"1e",".",+.5,"1,5"` + "\n", QuoteStyle: QuoteNonNumeric},
+	{Input: [][]string{{`a'b`, `a"b`}}, Output: `'a''b',a"b` + "\n", Quote: '\''},
+	{Input: [][]string{{`a"b`, `a\b`, "ab"}}, Output: `"a\"b","a\\b",ab` + "\n", Escape: '\\'},
+	{Input: [][]string{{"a«b", "a»b"}}, Output: "«a\\«b«,a»b\n", Quote: '«', Escape: '\\'},
+	{Input: [][]string{{"a"}, {"b"}}, Output: "\uFEFFa\nb\n", UseBOM: true},
+	{Input: [][]string{{"foo"}}, Quote: '\n', Error: errInvalidQuote},
+	{Input: [][]string{{"foo"}}, Quote: ',', Error: errInvalidQuote},
+	{Input: [][]string{{"foo"}}, Escape: ',', Error: errInvalidQuote},
 }
 
 func TestWrite(t *testing.T) {
@@ -57,6 +71,12 @@ func TestWrite(t *testing.T) {
 		if tt.Comma != 0 {
 			f.Comma = tt.Comma
 		}
+		if tt.Quote != 0 {
+			f.Quote = tt.Quote
+		}
+		f.Escape = tt.Escape
+		f.QuoteStyle = tt.QuoteStyle
+		f.UseBOM = tt.UseBOM
 		err := f.WriteAll(tt.Input)
 		if err != tt.Error {
 			t.Errorf("Unexpected error:\ngot  %v\nwant %v", err, tt.Error)
@@ -68,6 +88,37 @@ func TestWrite(t *testing.T) {
 	}
 }
 
+func TestWriteReadQuote(t *testing.T) {
+	records := [][]string{
+		{`a"b`, `c\d`, "e'f", "g«h", "multi\nline", ""},
+		{" lead", "x,y", `\"`, `''`},
+	}
+	for _, tt := range []struct{ quote, escape rune }{
+		{0, 0},
+		{'\'', 0},
+		{'"', '\\'},
+		{'\'', '\\'},
+		{'«', '»'},
+	} {
+		var b strings.Builder
+		w := NewWriter(&b)
+		w.Quote, w.Escape = tt.quote, tt.escape
+		if err := w.WriteAll(records); err != nil {
+			t.Fatal(err)
+		}
+		r := NewReader(strings.NewReader(b.String()))
+		r.Quote, r.Escape = tt.quote, tt.escape
+		r.FieldsPerRecord = -1
+		got, err := r.ReadAll()
+		if err != nil {
+			t.Fatalf("Quote %q, Escape %q: reading %q: %v", tt.quote, tt.escape, b.String(), err)
+		}
+		if !reflect.DeepEqual(got, records) {
+			t.Errorf("Quote %q, Escape %q: read %q from %q, want %q", tt.quote, tt.escape, got, b.String(), records)
+		}
+	}
+}
+
 type errorWriter struct{}
 
 func (e errorWriter) Write(b []byte) (int, error) {
@@ -1171,9 +1171,30 @@ func (dec *Decoder) compileDec(remoteId typeId, ut *userTypeInfo) (engine *decEn
 		engine.instr[fieldnum] = decInstr{*op, fieldnum, localField.Index, ovfl}
 		engine.numInstr++
 	}
+	if dec.mismatch != nil && rt != emptyStructType {
+		dec.reportMismatches(wireStruct, srt)
+	}
 	return
 }
 
+// reportMismatches reports the fields of the wire struct and the local
+// struct that have no counterpart in the other.
+func (dec *Decoder) reportMismatches(wireStruct *structType, srt reflect.Type) {
+	sent := make(map[string]bool)
+	for _, wireField := range wireStruct.Field {
+		sent[wireField.Name] = true
+		if localField, present := srt.FieldByName(wireField.Name); !present || !isExported(localField.Name) {
+			dec.mismatch(FieldMismatch{Type: srt, WireType: wireStruct.Name, Field: wireField.Name})
+		}
+	}
+	for i := 0; i < srt.NumField(); i++ {
+		f := srt.Field(i)
+		if isSent(srt, &f) && !sent[f.Name] {
+			dec.mismatch(FieldMismatch{Type: srt, WireType: wireStruct.Name, Field: f.Name, Missing: true})
+		}
+	}
+}
+
 // getDecEnginePtr returns the engine for the specified type.
 func (dec *Decoder) getDecEnginePtr(remoteId typeId, ut *userTypeInfo) (enginePtr **decEngine, err error) {
 	rt := ut.user
@@ -34,6 +34,7 @@ type Decoder struct {
 	ignorerCache map[typeId]**decEngine                  // ditto for ignored objects
 	freeList     *decoderState                           // list of free decoderStates; avoids reallocation
 	countBuf     []byte                                  // used for decoding integers while parsing messages
+	mismatch     func(FieldMismatch)                     // reports fields that do not match; may be nil
 	err          error
 }
 
@@ -55,6 +56,33 @@ func NewDecoder(r io.Reader) *Decoder {
 	return dec
 }
 
+// A FieldMismatch describes a field of a struct type in the stream with
+// no counterpart in the struct type it is decoded into, or the reverse.
+// Such mismatches are allowed, so that types may evolve, but may show
+// that the encoder and decoder disagree about a type.
+type FieldMismatch struct {
+	Type     reflect.Type // the struct type being decoded into
+	WireType string       // the name of the struct type in the stream
+	Field    string       // the name of the field
+
+	// Missing reports that the field is in Type but not in the stream,
+	// so it is left unchanged by decoding. Otherwise the field is in
+	// the stream but not in Type, and its values are ignored.
+	Missing bool
+}
+
+// ReportFieldMismatches arranges for the decoder to call f for each
+// field that is in a struct type in the stream but not in the struct
+// type it is decoded into, or the reverse. The fields of each pair of
+// types are compared once, when a value of the stream's type is first
+// decoded into the local type. The function is called while the decoder
+// is in use, and must not call the decoder's methods.
+func (dec *Decoder) ReportFieldMismatches(f func(FieldMismatch)) {
+	dec.mutex.Lock()
+	defer dec.mutex.Unlock()
+	dec.mismatch = f
+}
+
 // recvType loads the definition of a type.
 func (dec *Decoder) recvType(id typeId) {
 	// Have we already seen this type? That's an error
@@ -1278,3 +1278,52 @@ func TestDecoderOverflow(t *testing.T) {
 		t.Fatalf("expected an error")
 	}
 }
+
+func TestReportFieldMismatches(t *testing.T) {
+	type V1 struct {
+		A, B int
+		Old  string
+		Sub  struct{ X, Y int }
+	}
+	type V2 struct {
+		A, B int
+		New  string
+		Sub  struct{ X, Z int }
+		priv int
+		Ch   chan int
+	}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for range 2 {
+		if err := enc.Encode(V1{A: 1, Old: "x"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dec := NewDecoder(&buf)
+	var got []string
+	dec.ReportFieldMismatches(func(m FieldMismatch) {
+		what := "ignored"
+		if m.Missing {
+			what = "missing"
+		}
+		got = append(got, m.WireType+"."+m.Field+" "+what+" in "+m.Type.String())
+	})
+	for range 2 {
+		var v V2
+		if err := dec.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+		if v.A != 1 {
+			t.Errorf("A = %d, want 1", v.A)
+		}
+	}
+	want := []string{
+		"struct { X int; Y int }.Y ignored in struct { X int; Z int }",
+		"struct { X int; Y int }.Z missing in struct { X int; Z int }",
+		"V1.Old ignored in gob.V2",
+		"V1.New missing in gob.V2",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mismatches:\ngot  %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,518 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gob
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A WireKind is the kind of a type as it is described in a gob stream.
+type WireKind uint8
+
+const (
+	WireBool WireKind = 1 + iota
+	WireInt
+	WireUint
+	WireFloat
+	WireBytes
+	WireString
+	WireComplex
+	WireInterface
+	WireArray
+	WireSlice
+	WireStruct
+	WireMap
+	WireGobEncoder
+	WireBinaryMarshaler
+	WireTextMarshaler
+)
+
+var wireKindNames = [...]string{
+	WireBool:            "bool",
+	WireInt:             "int",
+	WireUint:            "uint",
+	WireFloat:           "float",
+	WireBytes:           "bytes",
+	WireString:          "string",
+	WireComplex:         "complex",
+	WireInterface:       "interface",
+	WireArray:           "array",
+	WireSlice:           "slice",
+	WireStruct:          "struct",
+	WireMap:             "map",
+	WireGobEncoder:      "GobEncoder",
+	WireBinaryMarshaler: "BinaryMarshaler",
+	WireTextMarshaler:   "TextMarshaler",
+}
+
+func (k WireKind) String() string {
+	if int(k) < len(wireKindNames) && wireKindNames[k] != "" {
+		return wireKindNames[k]
+	}
+	return "WireKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// A WireType describes a type as it is defined in a gob stream,
+// independent of any Go type it may be decoded into.
+type WireType struct {
+	ID     int    // the type id used in the stream
+	Name   string // the name given by the encoder
+	Kind   WireKind
+	Elem   *WireType   // the element type of an array, slice or map
+	Key    *WireType   // the key type of a map
+	Len    int         // the length of an array
+	Fields []WireField // the fields of a struct
+}
+
+// A WireField is a field of a struct [WireType].
+type WireField struct {
+	Name string
+	Type *WireType
+}
+
+// String returns the name of the type.
+func (t *WireType) String() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return t.underlying()
+}
+
+// underlying returns a description of the structure of the type.
+func (t *WireType) underlying() string {
+	switch t.Kind {
+	case WireArray:
+		return "[" + strconv.Itoa(t.Len) + "]" + t.Elem.String()
+	case WireSlice:
+		return "[]" + t.Elem.String()
+	case WireMap:
+		return "map[" + t.Key.String() + "]" + t.Elem.String()
+	case WireStruct:
+		var b strings.Builder
+		b.WriteString("struct {")
+		for i, f := range t.Fields {
+			if i > 0 {
+				b.WriteByte(';')
+			}
+			b.WriteString(" " + f.Name + " " + f.Type.String())
+		}
+		b.WriteString(" }")
+		return b.String()
+	}
+	return t.Kind.String()
+}
+
+// builtinWireTypes describes the types predefined by the gob format.
+var builtinWireTypes = map[typeId]*WireType{
+	tBool:      {ID: int(tBool), Name: "bool", Kind: WireBool},
+	tInt:       {ID: int(tInt), Name: "int", Kind: WireInt},
+	tUint:      {ID: int(tUint), Name: "uint", Kind: WireUint},
+	tFloat:     {ID: int(tFloat), Name: "float", Kind: WireFloat},
+	tBytes:     {ID: int(tBytes), Name: "bytes", Kind: WireBytes},
+	tString:    {ID: int(tString), Name: "string", Kind: WireString},
+	tComplex:   {ID: int(tComplex), Name: "complex", Kind: WireComplex},
+	tInterface: {ID: int(tInterface), Name: "interface", Kind: WireInterface},
+}
+
+// A Field is a field of a struct value read by an [Inspector].
+type Field struct {
+	Name  string
+	Value any
+}
+
+// A MapEntry is an entry of a map value read by an [Inspector].
+type MapEntry struct {
+	Key, Value any
+}
+
+// An Interface is a non-nil interface value read by an [Inspector].
+type Interface struct {
+	Name  string // the name under which the concrete type was registered
+	Type  *WireType
+	Value any
+}
+
+// An Inspector reads a gob stream without knowing the Go types of the
+// values in it, reporting the types the stream defines and decoding
+// values into a generic representation.
+//
+// Values of each [WireKind] are represented as follows:
+//
+//	WireBool            bool
+//	WireInt             int64
+//	WireUint            uint64
+//	WireFloat           float64
+//	WireComplex         complex128
+//	WireString          string
+//	WireBytes           []byte
+//	WireInterface       nil or Interface
+//	WireArray           []any
+//	WireSlice           []any
+//	WireMap             []MapEntry
+//	WireStruct          []Field
+//	WireGobEncoder,
+//	WireBinaryMarshaler,
+//	WireTextMarshaler   []byte, holding the encoded value
+//
+// As the encoder does not send zero values, the []Field for a struct
+// holds only the fields that are not zero, in order.
+type Inspector struct {
+	dec   *Decoder
+	types map[typeId]*WireType
+	added []typeId // ids added to types by the current call to Next
+}
+
+// NewInspector returns a new Inspector that reads from r.
+func NewInspector(r io.Reader) *Inspector {
+	return &Inspector{dec: NewDecoder(r), types: make(map[typeId]*WireType)}
+}
+
+// Next reads the next value from the stream, with the definitions of any
+// types that precede it, and returns its type and value.
+// At the end of the stream, Next returns [io.EOF].
+func (in *Inspector) Next() (t *WireType, v any, err error) {
+	dec := in.dec
+	dec.buf.Reset()
+	dec.err = nil
+	id := dec.decodeTypeSequence(false)
+	if dec.err != nil {
+		return nil, nil, dec.err
+	}
+	in.added = in.added[:0]
+	defer func() {
+		if err != nil {
+			// Drop the types described so far, some of which may have
+			// been left incomplete by the error.
+			for _, id := range in.added {
+				delete(in.types, id)
+			}
+		}
+	}()
+	defer catchError(&err)
+	state := dec.newDecoderState(&dec.buf)
+	defer dec.freeDecoderState(state)
+	t = in.wireType(id)
+	v = in.topValue(state, t, 0)
+	for id := range dec.wireType {
+		in.wireType(id)
+	}
+	return t, v, nil
+}
+
+// Types returns the types defined by the stream so far, in order of id.
+func (in *Inspector) Types() []*WireType {
+	types := make([]*WireType, 0, len(in.types))
+	for _, t := range in.types {
+		types = append(types, t)
+	}
+	slices.SortFunc(types, func(a, b *WireType) int { return a.ID - b.ID })
+	return types
+}
+
+// wireType returns the description of the type with the given id.
+func (in *Inspector) wireType(id typeId) *WireType {
+	if t := builtinWireTypes[id]; t != nil {
+		return t
+	}
+	if t := in.types[id]; t != nil {
+		return t
+	}
+	w := in.dec.wireType[id]
+	if w == nil {
+		errorf("undefined type id %d", id)
+	}
+	// Record the type before its components, which may refer to it.
+	t := &WireType{ID: int(id)}
+	in.types[id] = t
+	in.added = append(in.added, id)
+	switch {
+	case w.ArrayT != nil:
+		t.Name, t.Kind, t.Len = w.ArrayT.Name, WireArray, w.ArrayT.Len
+		t.Elem = in.wireType(w.ArrayT.Elem)
+	case w.SliceT != nil:
+		t.Name, t.Kind = w.SliceT.Name, WireSlice
+		t.Elem = in.wireType(w.SliceT.Elem)
+	case w.StructT != nil:
+		t.Name, t.Kind = w.StructT.Name, WireStruct
+		for _, f := range w.StructT.Field {
+			t.Fields = append(t.Fields, WireField{f.Name, in.wireType(f.Id)})
+		}
+	case w.MapT != nil:
+		t.Name, t.Kind = w.MapT.Name, WireMap
+		t.Key = in.wireType(w.MapT.Key)
+		t.Elem = in.wireType(w.MapT.Elem)
+	case w.GobEncoderT != nil:
+		t.Name, t.Kind = w.GobEncoderT.Name, WireGobEncoder
+	case w.BinaryMarshalerT != nil:
+		t.Name, t.Kind = w.BinaryMarshalerT.Name, WireBinaryMarshaler
+	case w.TextMarshalerT != nil:
+		t.Name, t.Kind = w.TextMarshalerT.Name, WireTextMarshaler
+	default:
+		errorf("empty wire type for id %d", id)
+	}
+	return t
+}
+
+// topValue reads a value sent at the top level of a message:
+// a struct, or a singleton field.
+func (in *Inspector) topValue(state *decoderState, t *WireType, depth int) any {
+	if t.Kind == WireStruct {
+		return in.structValue(state, t, depth)
+	}
+	if state.decodeUint() != 0 {
+		errorf("decode: corrupted data: non-zero delta for singleton")
+	}
+	return in.value(state, t, depth)
+}
+
+func (in *Inspector) value(state *decoderState, t *WireType, depth int) any {
+	if depth > maxIgnoreNestingDepth {
+		error_(errors.New("invalid nesting depth"))
+	}
+	switch t.Kind {
+	case WireBool:
+		return state.decodeUint() != 0
+	case WireInt:
+		return state.decodeInt()
+	case WireUint:
+		return state.decodeUint()
+	case WireFloat:
+		return float64FromBits(state.decodeUint())
+	case WireComplex:
+		re := float64FromBits(state.decodeUint())
+		im := float64FromBits(state.decodeUint())
+		return complex(re, im)
+	case WireString:
+		return string(in.bytes(state))
+	case WireBytes, WireGobEncoder, WireBinaryMarshaler, WireTextMarshaler:
+		return in.bytes(state)
+	case WireArray, WireSlice:
+		// Every element takes at least one byte.
+		n, ok := state.getLength()
+		if !ok {
+			errorf("%s: length exceeds input size", t.Kind)
+		}
+		if t.Kind == WireArray && n != t.Len {
+			errorf("length mismatch in array: got %d, want %d", n, t.Len)
+		}
+		elems := make([]any, n)
+		for i := range elems {
+			elems[i] = in.value(state, t.Elem, depth+1)
+		}
+		return elems
+	case WireMap:
+		n, ok := state.getLength()
+		if !ok {
+			errorf("map: length exceeds input size")
+		}
+		entries := make([]MapEntry, n)
+		for i := range entries {
+			entries[i].Key = in.value(state, t.Key, depth+1)
+			entries[i].Value = in.value(state, t.Elem, depth+1)
+		}
+		return entries
+	case WireStruct:
+		return in.structValue(state, t, depth)
+	case WireInterface:
+		return in.interfaceValue(state, depth)
+	}
+	errorf("invalid wire type %s", t)
+	return nil
+}
+
+func (in *Inspector) bytes(state *decoderState) []byte {
+	n, ok := state.getLength()
+	if !ok {
+		errorf("bytes or string length exceeds input size")
+	}
+	b := make([]byte, n)
+	state.b.Read(b)
+	return b
+}
+
+func (in *Inspector) structValue(state *decoderState, t *WireType, depth int) []Field {
+	var fields []Field
+	fieldnum := -1
+	for state.b.Len() > 0 {
+		delta := state.decodeUint()
+		if delta == 0 { // struct terminator is zero delta fieldnum
+			break
+		}
+		if delta >= uint64(len(t.Fields)-fieldnum) {
+			error_(errRange)
+		}
+		fieldnum += int(delta)
+		f := t.Fields[fieldnum]
+		fields = append(fields, Field{f.Name, in.value(state, f.Type, depth+1)})
+	}
+	return fields
+}
+
+func (in *Inspector) interfaceValue(state *decoderState, depth int) any {
+	n, ok := state.getLength()
+	if !ok {
+		errorf("bad interface encoding: name too large for buffer")
+	}
+	if n == 0 {
+		return nil
+	}
+	name := string(state.b.Bytes()[:n])
+	state.b.Drop(n)
+	id := in.dec.decodeTypeSequence(true)
+	if id < 0 {
+		error_(in.dec.err)
+	}
+	// Byte count of value is next; the value follows.
+	state.decodeUint()
+	t := in.wireType(id)
+	return Interface{Name: name, Type: t, Value: in.topValue(state, t, depth+1)}
+}
+
+// Dump reads the gob stream from r and writes a description of the
+// types and values in it to w, one per line, until the end of the
+// stream or the first error.
+//
+// Each type is described before the first value that uses it, as
+//
+//	type id name definition
+//
+// where the definition is omitted if it is the same as the name,
+// and each value is written in a syntax resembling a Go composite
+// literal. The format is meant for people and may change.
+func Dump(w io.Writer, r io.Reader) error {
+	in := NewInspector(r)
+	bw := bufio.NewWriter(w)
+	seen := make(map[int]bool)
+	var err error
+	for {
+		var t *WireType
+		var v any
+		if t, v, err = in.Next(); err != nil {
+			break
+		}
+		for _, t := range in.Types() {
+			if !seen[t.ID] {
+				seen[t.ID] = true
+				fmt.Fprintf(bw, "type %d %s", t.ID, t)
+				if u := t.underlying(); u != t.String() {
+					bw.WriteString(" " + u)
+				}
+				bw.WriteByte('\n')
+			}
+		}
+		if err = formatValue(bw, t, v); err != nil {
+			break
+		}
+		bw.WriteByte('\n')
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	if ferr := bw.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// formatValue writes a value of type t read by an Inspector to w.
+func formatValue(w *bufio.Writer, t *WireType, v any) error {
+	switch t.Kind {
+	case WireBool, WireInt, WireUint, WireFloat, WireComplex:
+		fmt.Fprint(w, v)
+		return nil
+	case WireString:
+		if s, ok := v.(string); ok {
+			w.WriteString(strconv.Quote(s))
+			return nil
+		}
+	case WireBytes:
+		if b, ok := v.([]byte); ok {
+			fmt.Fprintf(w, "%q", b)
+			return nil
+		}
+	case WireGobEncoder, WireBinaryMarshaler, WireTextMarshaler:
+		if b, ok := v.([]byte); ok {
+			fmt.Fprintf(w, "%s(%x)", t, b)
+			return nil
+		}
+	case WireInterface:
+		switch v := v.(type) {
+		case nil:
+			w.WriteString("nil")
+			return nil
+		case Interface:
+			w.WriteString(v.Name + "(")
+			if err := formatValue(w, v.Type, v.Value); err != nil {
+				return err
+			}
+			w.WriteByte(')')
+			return nil
+		}
+	case WireArray, WireSlice:
+		if elems, ok := v.([]any); ok {
+			w.WriteString(t.String() + "{")
+			for i, e := range elems {
+				if i > 0 {
+					w.WriteString(", ")
+				}
+				if err := formatValue(w, t.Elem, e); err != nil {
+					return err
+				}
+			}
+			w.WriteByte('}')
+			return nil
+		}
+	case WireMap:
+		if entries, ok := v.([]MapEntry); ok {
+			w.WriteString(t.String() + "{")
+			for i, e := range entries {
+				if i > 0 {
+					w.WriteString(", ")
+				}
+				if err := formatValue(w, t.Key, e.Key); err != nil {
+					return err
+				}
+				w.WriteString(": ")
+				if err := formatValue(w, t.Elem, e.Value); err != nil {
+					return err
+				}
+			}
+			w.WriteByte('}')
+			return nil
+		}
+	case WireStruct:
+		if fields, ok := v.([]Field); ok {
+			w.WriteString(t.String() + "{")
+			// The fields are in the order of the struct type, which
+			// might have more than one field of the same name.
+			j := 0
+			for i, f := range fields {
+				for j < len(t.Fields) && t.Fields[j].Name != f.Name {
+					j++
+				}
+				if j == len(t.Fields) {
+					return fmt.Errorf("gob: no field %s in type %s", f.Name, t)
+				}
+				if i > 0 {
+					w.WriteString(", ")
+				}
+				w.WriteString(f.Name + ": ")
+				if err := formatValue(w, t.Fields[j].Type, f.Value); err != nil {
+					return err
+				}
+				j++
+			}
+			w.WriteByte('}')
+			return nil
+		}
+	}
+	return fmt.Errorf("gob: cannot format %T value of type %s", v, t)
+}
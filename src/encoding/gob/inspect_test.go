@@ -0,0 +1,175 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gob
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type InspectPoint struct {
+	X, Y int
+}
+
+type InspectT struct {
+	Name   string
+	Tags   []string
+	Counts map[string]uint
+	Pos    [2]float64
+	Next   *InspectT
+	Any    any
+	When   time.Time
+	Zero   int
+}
+
+func init() {
+	Register(InspectPoint{})
+}
+
+func TestInspector(t *testing.T) {
+	when := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	whenBytes, err := when.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for _, v := range []any{
+		InspectT{
+			Name:   "a",
+			Tags:   []string{"x", "y"},
+			Counts: map[string]uint{"n": 3},
+			Pos:    [2]float64{1.5, 0},
+			Next:   &InspectT{Name: "b"},
+			Any:    InspectPoint{1, 2},
+			When:   when,
+		},
+		-7,
+		[]byte("raw"),
+	} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	in := NewInspector(&buf)
+	typ, v, err := in.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ.Kind != WireStruct || typ.Name != "InspectT" {
+		t.Fatalf("type = %v %v, want struct InspectT", typ.Kind, typ)
+	}
+	var names []string
+	for _, f := range typ.Fields {
+		names = append(names, f.Name+" "+f.Type.String())
+	}
+	wantNames := []string{"Name string", "Tags []string", "Counts map[string]uint", "Pos [2]float64",
+		"Next InspectT", "Any interface", "When Time", "Zero int"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("fields = %q, want %q", names, wantNames)
+	}
+	if typ.Fields[4].Type != typ {
+		t.Errorf("recursive field type is not the struct type")
+	}
+	if k := typ.Fields[6].Type.Kind; k != WireGobEncoder {
+		t.Errorf("time.Time kind = %v, want %v", k, WireGobEncoder)
+	}
+	pointType := in.Types()[len(in.Types())-1]
+	want := []Field{
+		{"Name", "a"},
+		{"Tags", []any{"x", "y"}},
+		{"Counts", []MapEntry{{"n", uint64(3)}}},
+		{"Pos", []any{1.5, 0.0}},
+		{"Next", []Field{{"Name", "b"}, {"Pos", []any{0.0, 0.0}}}},
+		{"Any", Interface{Name: "encoding/gob.InspectPoint", Type: pointType, Value: []Field{{"X", int64(1)}, {"Y", int64(2)}}}},
+		{"When", whenBytes},
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("value:\ngot  %#v\nwant %#v", v, want)
+	}
+
+	typ, v, err = in.Next()
+	if err != nil || typ.Kind != WireInt || v != int64(-7) {
+		t.Errorf("Next = %v, %#v, %v; want int -7", typ, v, err)
+	}
+	typ, v, err = in.Next()
+	if err != nil || typ.Kind != WireBytes || !bytes.Equal(v.([]byte), []byte("raw")) {
+		t.Errorf("Next = %v, %#v, %v; want bytes raw", typ, v, err)
+	}
+	if _, _, err := in.Next(); err != io.EOF {
+		t.Errorf("Next at end = %v, want EOF", err)
+	}
+}
+
+func TestDump(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.Encode(InspectPoint{1, 2})
+	enc.Encode(map[string][]InspectPoint{"a": {{3, 0}}})
+	var out strings.Builder
+	if err := Dump(&out, &buf); err != nil {
+		t.Fatal(err)
+	}
+	// Type ids depend on the order in which the tests register types.
+	got := regexp.MustCompile(`type [0-9]+`).ReplaceAllString(out.String(), "type N")
+	const want = `type N InspectPoint struct { X int; Y int }
+InspectPoint{X: 1, Y: 2}
+type N []InspectPoint
+type N map[string][]InspectPoint
+map[string][]InspectPoint{"a": []InspectPoint{InspectPoint{X: 3}}}
+`
+	if got != want {
+		t.Errorf("Dump:\ngot\n%s\nwant\n%s", got, want)
+	}
+
+	if err := Dump(io.Discard, strings.NewReader("\x05\xff\x81\x01\x02")); err == nil {
+		t.Error("Dump of corrupt input succeeded")
+	}
+}
+
+func FuzzDump(f *testing.F) {
+	for _, v := range []any{
+		InspectT{Name: "a", Tags: []string{"x"}, Counts: map[string]uint{"n": 1}, Any: InspectPoint{1, 2}},
+		map[string][]InspectPoint{"a": {{3, 0}}},
+		[3]complex128{1i},
+		[]byte("raw"),
+		true,
+	} {
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Encode(v); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buf.Bytes())
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		Dump(io.Discard, bytes.NewReader(b))
+
+		in := NewInspector(bytes.NewReader(b))
+		for {
+			if _, _, err := in.Next(); err != nil {
+				break
+			}
+		}
+		for _, typ := range in.Types() {
+			incomplete := typ.Kind == 0
+			switch typ.Kind {
+			case WireArray, WireSlice:
+				incomplete = typ.Elem == nil
+			case WireMap:
+				incomplete = typ.Key == nil || typ.Elem == nil
+			}
+			if incomplete {
+				t.Errorf("incomplete type %d after reading the stream", typ.ID)
+			}
+		}
+	})
+}
@@ -42,9 +42,13 @@ import (
 //
 // To unmarshal JSON into a struct, Unmarshal matches incoming object
 // keys to the keys used by [Marshal] (either the struct field name or its tag),
-// preferring an exact match but also accepting a case-insensitive match. By
+// preferring an exact match but also accepting a case-insensitive match
+// (see [Decoder.UseCaseSensitiveNames] for an alternative). By
 // default, object keys which don't have a corresponding struct field are
-// ignored (see [Decoder.DisallowUnknownFields] for an alternative).
+// ignored (see [Decoder.DisallowUnknownFields] for an alternative), unless
+// the struct has a map field with the "unknown" tag option, in which case
+// they are stored in that map. If a key appears more than once, the last
+// value is used (see [Decoder.DisallowDuplicateNames] for an alternative).
 //
 // To unmarshal JSON into an interface value,
 // Unmarshal stores one of these in the interface value:
@@ -217,6 +221,8 @@ type decodeState struct {
 	savedError            error
 	useNumber             bool
 	disallowUnknownFields bool
+	caseSensitive         bool
+	disallowDuplicates    bool
 }
 
 // readIndex returns the position of the last byte read.
@@ -269,6 +275,9 @@ func (d *decodeState) skip() {
 		op := s.step(s, data[i])
 		i++
 		if len(s.parseState) < depth {
+			if d.disallowDuplicates {
+				d.checkSkipped(d.readIndex(), i)
+			}
 			d.off = i
 			d.opcode = op
 			return
@@ -276,6 +285,20 @@ func (d *decodeState) skip() {
 	}
 }
 
+// checkSkipped saves an error if the skipped value d.data[start:end]
+// contains an object with duplicate member names.
+func (d *decodeState) checkSkipped(start, end int) {
+	var sd decodeState
+	sd.init(d.data[start:end])
+	sd.disallowDuplicates = true
+	sd.scan.reset()
+	sd.scanWhile(scanSkipSpace)
+	sd.valueInterface()
+	if err, ok := sd.savedError.(*SyntaxError); ok {
+		d.saveError(&SyntaxError{err.msg, err.Offset + int64(start)})
+	}
+}
+
 // scanNext processes the byte at d.data[d.off].
 func (d *decodeState) scanNext() {
 	if d.off < len(d.data) {
@@ -656,6 +679,7 @@ func (d *decodeState) object(v reflect.Value) error {
 	if d.errorContext != nil {
 		origErrorContext = *d.errorContext
 	}
+	var seen map[string]bool
 
 	for {
 		// Read opening " of string key or closing }.
@@ -676,10 +700,14 @@ func (d *decodeState) object(v reflect.Value) error {
 		if !ok {
 			panic(phasePanicMsg)
 		}
+		if d.disallowDuplicates {
+			seen = d.checkDuplicate(seen, key, start)
+		}
 
 		// Figure out field corresponding to key.
 		var subv reflect.Value
-		destring := false // whether the value is wrapped in a string to be decoded first
+		var unknown reflect.Value // map holding an unknown member
+		destring := false         // whether the value is wrapped in a string to be decoded first
 
 		if v.Kind() == reflect.Map {
 			elemType := t.Elem()
@@ -691,39 +719,25 @@ func (d *decodeState) object(v reflect.Value) error {
 			subv = mapElem
 		} else {
 			f := fields.byExactName[string(key)]
-			if f == nil {
+			if f == nil && !d.caseSensitive {
 				f = fields.byFoldedName[string(foldName(key))]
 			}
 			if f != nil {
-				subv = v
-				destring = f.quoted
-				for _, i := range f.index {
-					if subv.Kind() == reflect.Pointer {
-						if subv.IsNil() {
-							// If a struct embeds a pointer to an unexported type,
-							// it is not possible to set a newly allocated value
-							// since the field is unexported.
-							//
-							// See https://golang.org/issue/21357
-							if !subv.CanSet() {
-								d.saveError(fmt.Errorf("json: cannot set embedded pointer to unexported struct: %v", subv.Type().Elem()))
-								// Invalidate subv to ensure d.value(subv) skips over
-								// the JSON value without assigning it to subv.
-								subv = reflect.Value{}
-								destring = false
-								break
-							}
-							subv.Set(reflect.New(subv.Type().Elem()))
-						}
-						subv = subv.Elem()
-					}
-					subv = subv.Field(i)
-				}
+				subv = d.fieldByIndex(v, f.index)
+				destring = f.quoted && subv.IsValid()
 				if d.errorContext == nil {
 					d.errorContext = new(errorContext)
 				}
 				d.errorContext.FieldStack = append(d.errorContext.FieldStack, f.name)
 				d.errorContext.Struct = t
+			} else if fields.unknown != nil {
+				unknown = d.fieldByIndex(v, fields.unknown.index)
+				if unknown.IsValid() {
+					if unknown.IsNil() {
+						unknown.Set(reflect.MakeMap(unknown.Type()))
+					}
+					subv = reflect.New(unknown.Type().Elem()).Elem()
+				}
 			} else if d.disallowUnknownFields {
 				d.saveError(fmt.Errorf("json: unknown field %q", key))
 			}
@@ -759,6 +773,11 @@ func (d *decodeState) object(v reflect.Value) error {
 
 		// Write value back to map;
 		// if using struct, subv points into struct already.
+		if unknown.IsValid() {
+			kv := reflect.New(unknown.Type().Key()).Elem()
+			kv.SetString(string(key))
+			unknown.SetMapIndex(kv, subv)
+		}
 		if v.Kind() == reflect.Map {
 			kt := t.Key()
 			var kv reflect.Value
@@ -821,6 +840,48 @@ func (d *decodeState) object(v reflect.Value) error {
 	return nil
 }
 
+// fieldByIndex returns the field of the struct v with the given index
+// sequence, allocating any nil embedded pointers on the way. If a pointer
+// cannot be allocated, it saves an error and returns the zero Value.
+func (d *decodeState) fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				// If a struct embeds a pointer to an unexported type,
+				// it is not possible to set a newly allocated value
+				// since the field is unexported.
+				//
+				// See https://golang.org/issue/21357
+				if !v.CanSet() {
+					d.saveError(fmt.Errorf("json: cannot set embedded pointer to unexported struct: %v", v.Type().Elem()))
+					// Return an invalid Value to ensure d.value skips over
+					// the JSON value without assigning it.
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
+}
+
+// checkDuplicate saves an error if key, which starts at offset start,
+// is in the set of object member names seen. It returns seen with key added,
+// allocating the set if necessary.
+func (d *decodeState) checkDuplicate(seen map[string]bool, key []byte, start int) map[string]bool {
+	if seen[string(key)] {
+		d.saveError(&SyntaxError{"duplicate object member name " + strconv.Quote(string(key)), int64(start)})
+		return seen
+	}
+	if seen == nil {
+		seen = make(map[string]bool)
+	}
+	seen[string(key)] = true
+	return seen
+}
+
 // convertNumber converts the number literal s to a float64 or a Number
 // depending on the setting of d.useNumber.
 func (d *decodeState) convertNumber(s string) (any, error) {
@@ -1065,6 +1126,7 @@ func (d *decodeState) arrayInterface() []any {
 // objectInterface is like object but returns map[string]interface{}.
 func (d *decodeState) objectInterface() map[string]any {
 	m := make(map[string]any)
+	var seen map[string]bool
 	for {
 		// Read opening " of string key or closing }.
 		d.scanWhile(scanSkipSpace)
@@ -1084,6 +1146,9 @@ func (d *decodeState) objectInterface() map[string]any {
 		if !ok {
 			panic(phasePanicMsg)
 		}
+		if d.disallowDuplicates {
+			seen = d.checkDuplicate(seen, []byte(key), start)
+		}
 
 		// Read : before value.
 		if d.opcode == scanSkipSpace {
@@ -74,6 +74,17 @@ import (
 // false, 0, a nil pointer, a nil interface value, and any empty array,
 // slice, map, or string.
 //
+// The "omitzero" option specifies that the field should be omitted
+// from the encoding if the field has a zero value, according to rules:
+//
+// 1) If the field type has an "IsZero() bool" method, that will be used to
+// determine whether the value is zero.
+//
+// 2) Otherwise, the value is zero if it is the zero value for its type.
+//
+// If both "omitempty" and "omitzero" are specified, the field will be omitted
+// if the value is either empty or zero (or both).
+//
 // As a special case, if the field tag is "-", the field is always omitted.
 // Note that a field with name "-" can still be generated using the tag "-,".
 //
@@ -109,6 +120,23 @@ import (
 // only Unicode letters, digits, and ASCII punctuation except quotation
 // marks, backslash, and comma.
 //
+// The "inline" option on a field of struct type, or pointer to struct type,
+// marshals the field's fields as if they were fields in the outer struct,
+// as for an embedded struct field. Any name in the tag is ignored.
+//
+// The "unknown" option on a field of map type with string keys marshals the
+// map's entries as members of the outer object, after the struct's other
+// fields, and makes [Unmarshal] store in the map any object members that do
+// not match another field. It is an error for a key in the map to match
+// the name of another field. The "inline" option on such a field has the same
+// effect. If there are multiple such fields at the least nested level, all
+// are ignored. For example:
+//
+//	type Config struct {
+//		Name  string
+//		Extra map[string]any `json:",unknown"`
+//	}
+//
 // Embedded struct fields are usually marshaled as if their inner exported fields
 // were fields in the outer struct, subject to the usual Go visibility rules amended
 // as described in the next paragraph.
@@ -531,13 +559,21 @@ func (bits floatEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
 		e.error(&UnsupportedValueError{v, strconv.FormatFloat(f, 'g', -1, int(bits))})
 	}
 
+	b := e.AvailableBuffer()
+	b = mayAppendQuote(b, opts.quoted)
+	b = appendFloat(b, f, int(bits))
+	b = mayAppendQuote(b, opts.quoted)
+	e.Write(b)
+}
+
+// appendFloat appends the JSON encoding of the finite number f,
+// which has the given bit size, to b.
+func appendFloat(b []byte, f float64, bits int) []byte {
 	// Convert as if by ES6 number to string conversion.
 	// This matches most other JSON generators.
 	// See golang.org/issue/6384 and golang.org/issue/14135.
 	// Like fmt %g, but the exponent cutoffs are different
 	// and exponents themselves are not padded to two digits.
-	b := e.AvailableBuffer()
-	b = mayAppendQuote(b, opts.quoted)
 	abs := math.Abs(f)
 	fmt := byte('f')
 	// Note: Must use float32 comparisons for underlying float32 value to get precise cutoffs right.
@@ -546,7 +582,7 @@ func (bits floatEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
 			fmt = 'e'
 		}
 	}
-	b = strconv.AppendFloat(b, f, fmt, -1, int(bits))
+	b = strconv.AppendFloat(b, f, fmt, -1, bits)
 	if fmt == 'e' {
 		// clean up e-09 to e-9
 		n := len(b)
@@ -555,8 +591,7 @@ func (bits floatEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
 			b = b[:n-1]
 		}
 	}
-	b = mayAppendQuote(b, opts.quoted)
-	e.Write(b)
+	return b
 }
 
 var (
@@ -670,6 +705,7 @@ type structFields struct {
 	list         []field
 	byExactName  map[string]*field
 	byFoldedName map[string]*field
+	unknown      *field // map field holding unknown members, or nil
 }
 
 func (se structEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
@@ -690,7 +726,8 @@ FieldLoop:
 			fv = fv.Field(i)
 		}
 
-		if f.omitEmpty && isEmptyValue(fv) {
+		if (f.omitEmpty && isEmptyValue(fv)) ||
+			(f.omitZero && (f.isZero == nil && fv.IsZero() || f.isZero != nil && f.isZero(fv))) {
 			continue
 		}
 		e.WriteByte(next)
@@ -703,6 +740,9 @@ FieldLoop:
 		opts.quoted = f.quoted
 		f.encoder(e, fv, opts)
 	}
+	if f := se.fields.unknown; f != nil {
+		next = se.encodeUnknown(e, v, f, next, opts)
+	}
 	if next == '{' {
 		e.WriteString("{}")
 	} else {
@@ -710,6 +750,41 @@ FieldLoop:
 	}
 }
 
+// encodeUnknown encodes the entries of the map field f of v as object
+// members, starting with the byte next, and returns the next byte to use.
+func (se structEncoder) encodeUnknown(e *encodeState, v reflect.Value, f *field, next byte, opts encOpts) byte {
+	mv := v
+	for _, i := range f.index {
+		if mv.Kind() == reflect.Pointer {
+			if mv.IsNil() {
+				return next
+			}
+			mv = mv.Elem()
+		}
+		mv = mv.Field(i)
+	}
+	if mv.Len() == 0 {
+		return next
+	}
+	keys := mv.MapKeys()
+	slices.SortFunc(keys, func(a, b reflect.Value) int {
+		return strings.Compare(a.String(), b.String())
+	})
+	opts.quoted = false
+	for _, k := range keys {
+		name := k.String()
+		if se.fields.byExactName[name] != nil {
+			e.error(&UnsupportedValueError{v, "key " + strconv.Quote(name) + " of field " + f.name + " conflicts with a struct field"})
+		}
+		e.WriteByte(next)
+		next = ','
+		e.Write(appendString(e.AvailableBuffer(), name, opts.escapeHTML))
+		e.WriteByte(':')
+		f.encoder(e, mv.MapIndex(k), opts)
+	}
+	return next
+}
+
 func newStructEncoder(t reflect.Type) encoderFunc {
 	se := structEncoder{fields: cachedTypeFields(t)}
 	return se.encode
@@ -1037,11 +1112,57 @@ type field struct {
 	index     []int
 	typ       reflect.Type
 	omitEmpty bool
+	omitZero  bool
+	isZero    func(reflect.Value) bool
 	quoted    bool
 
 	encoder encoderFunc
 }
 
+type isZeroer interface {
+	IsZero() bool
+}
+
+var isZeroerType = reflect.TypeFor[isZeroer]()
+
+// zeroFunc returns the function reporting whether a value of type t
+// is zero according to its IsZero method, or nil if t has none.
+func zeroFunc(t reflect.Type) func(reflect.Value) bool {
+	switch {
+	case t.Kind() == reflect.Interface && t.Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			// Avoid panics calling IsZero on a nil interface or
+			// non-nil interface with nil pointer.
+			return v.IsNil() ||
+				(v.Elem().Kind() == reflect.Pointer && v.Elem().IsNil()) ||
+				v.Interface().(isZeroer).IsZero()
+		}
+	case t.Kind() == reflect.Pointer && t.Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			if v.IsNil() {
+				// Avoid panics calling IsZero on a nil pointer.
+				return true
+			}
+			return v.Interface().(isZeroer).IsZero()
+		}
+	case t.Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			return v.Interface().(isZeroer).IsZero()
+		}
+	case reflect.PointerTo(t).Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			if !v.CanAddr() {
+				// Temporarily box v so we can take the address.
+				v2 := reflect.New(v.Type()).Elem()
+				v2.Set(v)
+				v = v2
+			}
+			return v.Addr().Interface().(isZeroer).IsZero()
+		}
+	}
+	return nil
+}
+
 // typeFields returns a list of fields that JSON should recognize for the given type.
 // The algorithm is breadth-first search over the set of structs to include - the top struct
 // and then any reachable anonymous structs.
@@ -1059,6 +1180,9 @@ func typeFields(t reflect.Type) structFields {
 	// Fields found.
 	var fields []field
 
+	// Fields for unknown members found.
+	var unknowns []field
+
 	// Buffer to run appendHTMLEscape on field names.
 	var nameEscBuf []byte
 
@@ -1121,8 +1245,21 @@ func typeFields(t reflect.Type) structFields {
 					}
 				}
 
+				// Record map field for unknown members.
+				inline := opts.Contains("inline")
+				if (inline || opts.Contains("unknown")) &&
+					sf.Type.Kind() == reflect.Map && sf.Type.Key().Kind() == reflect.String {
+					unknowns = append(unknowns, field{
+						name:    sf.Name,
+						index:   index,
+						typ:     sf.Type,
+						encoder: typeEncoder(sf.Type.Elem()),
+					})
+					continue
+				}
+
 				// Record found field and index sequence.
-				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+				if !(inline || name == "" && sf.Anonymous) || ft.Kind() != reflect.Struct {
 					tagged := name != ""
 					if name == "" {
 						name = sf.Name
@@ -1133,8 +1270,12 @@ func typeFields(t reflect.Type) structFields {
 						index:     index,
 						typ:       ft,
 						omitEmpty: opts.Contains("omitempty"),
+						omitZero:  opts.Contains("omitzero"),
 						quoted:    quoted,
 					}
+					if field.omitZero {
+						field.isZero = zeroFunc(sf.Type)
+					}
 					field.nameBytes = []byte(field.name)
 
 					// Build nameEscHTML and nameNonEsc ahead of time.
@@ -1227,7 +1368,18 @@ func typeFields(t reflect.Type) structFields {
 			foldedNameIndex[string(foldName(field.nameBytes))] = &fields[i]
 		}
 	}
-	return structFields{fields, exactNameIndex, foldedNameIndex}
+
+	// The least nested unknown field is used, unless it is ambiguous.
+	var unknown *field
+	if len(unknowns) > 0 {
+		slices.SortStableFunc(unknowns, func(a, b field) int {
+			return cmp.Compare(len(a.index), len(b.index))
+		})
+		if len(unknowns) == 1 || len(unknowns[0].index) < len(unknowns[1].index) {
+			unknown = &unknowns[0]
+		}
+	}
+	return structFields{fields, exactNameIndex, foldedNameIndex, unknown}
 }
 
 // dominantField looks through the fields, all of which are known to
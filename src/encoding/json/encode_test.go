@@ -14,7 +14,9 @@ import (
 	"regexp"
 	"runtime/debug"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 type Optionals struct {
@@ -70,6 +72,144 @@ func TestOmitEmpty(t *testing.T) {
 	}
 }
 
+type NonZeroStruct struct{}
+
+func (nzs NonZeroStruct) IsZero() bool {
+	return false
+}
+
+type NoPanicStruct struct {
+	Int int `json:"int,omitzero"`
+}
+
+func (nps *NoPanicStruct) IsZero() bool {
+	return nps.Int != 0
+}
+
+type OptionalsZero struct {
+	Sr string `json:"sr"`
+	So string `json:"so,omitzero"`
+
+	Mr map[string]any `json:"mr"`
+	Mo map[string]any `json:",omitzero"`
+
+	Tr time.Time `json:"tr"`
+	To time.Time `json:"to,omitzero"`
+
+	Nzs NonZeroStruct `json:"nzs,omitzero"`
+
+	NoPanicStruct0 isZeroer       `json:"nps0,omitzero"`
+	NoPanicStruct1 isZeroer       `json:"nps1,omitzero"`
+	NoPanicStruct2 *NoPanicStruct `json:"nps2,omitzero"`
+	NoPanicStruct3 *NoPanicStruct `json:"nps3,omitzero"`
+	NoPanicStruct4 NoPanicStruct  `json:"nps4,omitzero"`
+
+	Both int `json:"both,omitempty,omitzero"`
+}
+
+func TestOmitZero(t *testing.T) {
+	const want = `{
+ "sr": "",
+ "mr": {},
+ "tr": "0001-01-01T00:00:00Z",
+ "nzs": {},
+ "nps1": {},
+ "nps3": {},
+ "nps4": {}
+}`
+	var o OptionalsZero
+	o.Mr = map[string]any{}
+	o.NoPanicStruct0 = (*NoPanicStruct)(nil)
+	o.NoPanicStruct1 = &NoPanicStruct{}
+	o.NoPanicStruct3 = &NoPanicStruct{}
+
+	got, err := MarshalIndent(&o, "", " ")
+	if err != nil {
+		t.Fatalf("MarshalIndent error: %v", err)
+	}
+	if got := string(got); got != want {
+		t.Errorf("MarshalIndent:\n\tgot:  %s\n\twant: %s\n", indentNewlines(got), indentNewlines(want))
+	}
+
+	// An addressable value calls IsZero through a pointer receiver
+	// without copying.
+	got, err = Marshal(struct {
+		V NoPanicStruct `json:",omitzero"`
+	}{NoPanicStruct{Int: 1}})
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+	if string(got) != "{}" {
+		t.Errorf("Marshal = %s, want {}", got)
+	}
+}
+
+type InlineInner struct {
+	B int
+	C int `json:"c"`
+}
+
+type InlineOuter struct {
+	A     int
+	Inner InlineInner    `json:"ignored,inline"`
+	Ptr   *InlineInner   `json:",inline"`
+	Extra map[string]any `json:",unknown"`
+}
+
+func TestInlineAndUnknown(t *testing.T) {
+	v := InlineOuter{
+		A:     1,
+		Inner: InlineInner{B: 2, C: 3},
+		Extra: map[string]any{"z": true, "y": []any{"s"}},
+	}
+	got, err := Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+	// Fields of Inner and Ptr conflict and are dropped, as for embedded
+	// structs at the same depth.
+	const want = `{"A":1,"y":["s"],"z":true}`
+	if string(got) != want {
+		t.Errorf("Marshal:\n\tgot:  %s\n\twant: %s", got, want)
+	}
+
+	var v2 InlineOuter
+	if err := Unmarshal([]byte(`{"A":1,"b":2,"y":["s"],"z":true}`), &v2); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	if v2.A != 1 || !reflect.DeepEqual(v2.Extra, map[string]any{"b": 2.0, "z": true, "y": []any{"s"}}) {
+		t.Errorf("Unmarshal = %+v", v2)
+	}
+
+	type Inlined struct {
+		A     int
+		Inner InlineInner    `json:",inline"`
+		Rest  map[string]int `json:",inline"`
+	}
+	got, err = Marshal(Inlined{A: 1, Inner: InlineInner{B: 2, C: 3}, Rest: map[string]int{"d": 4}})
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+	if want := `{"A":1,"B":2,"c":3,"d":4}`; string(got) != want {
+		t.Errorf("Marshal:\n\tgot:  %s\n\twant: %s", got, want)
+	}
+	var v3 Inlined
+	dec := NewDecoder(strings.NewReader(`{"A":1,"B":2,"c":3,"d":4,"e":5}`))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&v3); err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if want := (Inlined{A: 1, Inner: InlineInner{B: 2, C: 3}, Rest: map[string]int{"d": 4, "e": 5}}); !reflect.DeepEqual(v3, want) {
+		t.Errorf("Decode = %+v, want %+v", v3, want)
+	}
+
+	// A map key that matches a field is an error.
+	_, err = Marshal(Inlined{Rest: map[string]int{"A": 1}})
+	if _, ok := err.(*UnsupportedValueError); !ok {
+		t.Errorf("Marshal with conflicting key: error = %v, want UnsupportedValueError", err)
+	}
+}
+
 type StringTag struct {
 	BoolStr    bool    `json:",string"`
 	IntStr     int64   `json:",string"`
@@ -124,11 +124,16 @@ func Indent(dst *bytes.Buffer, src []byte, prefix, indent string) error {
 }
 
 func appendIndent(dst, src []byte, prefix, indent string) ([]byte, error) {
+	return appendIndentDepth(dst, src, prefix, indent, 0)
+}
+
+// appendIndentDepth is like appendIndent, but indents src as if it were
+// nested within depth objects or arrays.
+func appendIndentDepth(dst, src []byte, prefix, indent string, depth int) ([]byte, error) {
 	origLen := len(dst)
 	scan := newScanner()
 	defer freeScanner(scan)
 	needIndent := false
-	depth := 0
 	for _, c := range src {
 		scan.bytes++
 		v := scan.step(scan, c)
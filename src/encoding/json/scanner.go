@@ -79,6 +79,14 @@ type scanner struct {
 	// Error that happened, if any.
 	err error
 
+	// A non-space byte seen after the top-level value, and the offset
+	// at which it was seen. It is reported as an error on the next call,
+	// and the error is only made then, since a Decoder stops reading
+	// at the end of each value.
+	afterTop      bool
+	afterTopByte  byte
+	afterTopBytes int64
+
 	// total bytes consumed, updated by decoder.Decode (and deliberately
 	// not set to zero by scan.reset)
 	bytes int64
@@ -152,11 +160,15 @@ func (s *scanner) reset() {
 	s.parseState = s.parseState[0:0]
 	s.err = nil
 	s.endTop = false
+	s.afterTop = false
 }
 
 // eof tells the scanner that the end of input has been reached.
 // It returns a scan status just as s.step does.
 func (s *scanner) eof() int {
+	if s.afterTop {
+		return s.afterTopError()
+	}
 	if s.err != nil {
 		return scanError
 	}
@@ -328,11 +340,27 @@ func stateEndValue(s *scanner, c byte) int {
 func stateEndTop(s *scanner, c byte) int {
 	if !isSpace(c) {
 		// Complain about non-space byte on next call.
-		s.error(c, "after top-level value")
+		s.step = stateAfterTop
+		s.afterTop, s.afterTopByte, s.afterTopBytes = true, c, s.bytes
 	}
 	return scanEnd
 }
 
+// stateAfterTop is the state after a non-space byte following
+// the top-level value.
+func stateAfterTop(s *scanner, c byte) int {
+	return s.afterTopError()
+}
+
+// afterTopError records and returns the error for a non-space byte
+// following the top-level value.
+func (s *scanner) afterTopError() int {
+	s.afterTop = false
+	s.step = stateError
+	s.err = &SyntaxError{"invalid character " + quoteChar(s.afterTopByte) + " after top-level value", s.afterTopBytes}
+	return scanError
+}
+
 // stateInString is the state after reading `"`.
 func stateInString(s *scanner, c byte) int {
 	if c == '"' {
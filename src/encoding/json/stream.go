@@ -41,6 +41,15 @@ func (dec *Decoder) UseNumber() { dec.d.useNumber = true }
 // non-ignored, exported fields in the destination.
 func (dec *Decoder) DisallowUnknownFields() { dec.d.disallowUnknownFields = true }
 
+// UseCaseSensitiveNames causes the Decoder to match object keys to struct
+// field names or tags exactly, rather than preferring an exact match
+// but also accepting a case-insensitive one.
+func (dec *Decoder) UseCaseSensitiveNames() { dec.d.caseSensitive = true }
+
+// DisallowDuplicateNames causes the Decoder to return a [SyntaxError]
+// when an object in the input contains the same key more than once.
+func (dec *Decoder) DisallowDuplicateNames() { dec.d.disallowDuplicates = true }
+
 // Decode reads the next JSON-encoded value from its
 // input and stores it in the value pointed to by v.
 //
@@ -186,6 +195,16 @@ type Encoder struct {
 	indentBuf    []byte
 	indentPrefix string
 	indentValue  string
+
+	// State of WriteToken and WriteValue.
+	tokenBuf   []byte        // output not yet written to w
+	tokenStack []encodeFrame // open objects and arrays
+}
+
+// An encodeFrame is an object or array opened by Encoder.WriteToken.
+type encodeFrame struct {
+	kind TokenKind // '{' or '['
+	n    int       // number of names and values written so far
 }
 
 // NewEncoder returns a new encoder that writes to w.
@@ -196,6 +215,9 @@ func NewEncoder(w io.Writer) *Encoder {
 // Encode writes the JSON encoding of v to the stream,
 // followed by a newline character.
 //
+// Within an object or array opened by [Encoder.WriteToken], Encode
+// writes v as the next name or value in it, with no newline.
+//
 // See the documentation for [Marshal] for details about the
 // conversion of Go values to JSON.
 func (enc *Encoder) Encode(v any) error {
@@ -211,6 +233,11 @@ func (enc *Encoder) Encode(v any) error {
 		return err
 	}
 
+	if len(enc.tokenStack) > 0 {
+		// Inside an object or array opened by WriteToken.
+		return enc.writeValue(e.Bytes())
+	}
+
 	// Terminate each value with a newline.
 	// This makes the output look a little nicer
 	// when debugging, and some kind of space
@@ -233,6 +260,144 @@ func (enc *Encoder) Encode(v any) error {
 	return err
 }
 
+// WriteToken writes the next token of a JSON value to the stream,
+// adding commas and colons between tokens as needed. Each top-level
+// value is followed by a newline character, as with [Encoder.Encode].
+//
+// WriteToken reports an error, and writes nothing, if t would not
+// produce valid JSON: for example, if t ends an array when an object
+// is open, or if t is not a string and an object member name is
+// expected. It does not allocate once its buffer has grown.
+//
+// Output is buffered while an object or array is open, and written
+// to the underlying writer when a top-level value is complete or the
+// buffer is large.
+func (enc *Encoder) WriteToken(t RawToken) error {
+	if enc.err != nil {
+		return enc.err
+	}
+	switch k := t.Kind(); k {
+	case '}', ']':
+		begin := TokenKind('[')
+		if k == '}' {
+			begin = '{'
+		}
+		if len(enc.tokenStack) == 0 {
+			return errors.New("json: unexpected " + string(rune(k)) + " outside of any object or array")
+		}
+		f := enc.tokenStack[len(enc.tokenStack)-1]
+		if f.kind != begin || k == '}' && f.n%2 == 1 {
+			return errors.New("json: unexpected " + string(rune(k)))
+		}
+		enc.tokenStack = enc.tokenStack[:len(enc.tokenStack)-1]
+		if f.n > 0 && enc.indenting() {
+			enc.tokenBuf = appendNewline(enc.tokenBuf, enc.indentPrefix, enc.indentValue, len(enc.tokenStack))
+		}
+		enc.tokenBuf = append(enc.tokenBuf, byte(k))
+		return enc.tokenValueEnd()
+
+	case '{', '[':
+		if err := enc.tokenBeforeValue(false); err != nil {
+			return err
+		}
+		enc.tokenBuf = append(enc.tokenBuf, byte(k))
+		enc.tokenStack = append(enc.tokenStack, encodeFrame{kind: k})
+		return nil
+
+	case 'n', 'f', 't', '"', '0':
+		if err := t.checkSupported(); err != nil {
+			return err
+		}
+		if err := enc.tokenBeforeValue(k == '"'); err != nil {
+			return err
+		}
+		enc.tokenBuf = t.appendToken(enc.tokenBuf, enc.escapeHTML)
+		return enc.tokenValueEnd()
+	}
+	return errors.New("json: invalid token")
+}
+
+// WriteValue writes the next complete JSON value to the stream,
+// as [Encoder.WriteToken] does for the tokens making it up.
+// It reports an error, and writes nothing, if v is not valid JSON.
+func (enc *Encoder) WriteValue(v RawMessage) error {
+	if enc.err != nil {
+		return enc.err
+	}
+	b, err := appendCompact(enc.indentBuf[:0], v, enc.escapeHTML)
+	if err != nil {
+		return err
+	}
+	enc.indentBuf = b
+	return enc.writeValue(b)
+}
+
+// writeValue writes the compact, valid JSON value b.
+// It must not refer to enc.tokenBuf.
+func (enc *Encoder) writeValue(b []byte) error {
+	if err := enc.tokenBeforeValue(b[0] == '"'); err != nil {
+		return err
+	}
+	if enc.indenting() {
+		// b is valid, so appendIndent cannot fail.
+		enc.tokenBuf, _ = appendIndentDepth(enc.tokenBuf, b, enc.indentPrefix, enc.indentValue, len(enc.tokenStack))
+	} else {
+		enc.tokenBuf = append(enc.tokenBuf, b...)
+	}
+	return enc.tokenValueEnd()
+}
+
+func (enc *Encoder) indenting() bool {
+	return enc.indentPrefix != "" || enc.indentValue != ""
+}
+
+// tokenBeforeValue checks that a value may be written next,
+// and writes any separator needed before it.
+func (enc *Encoder) tokenBeforeValue(isString bool) error {
+	if len(enc.tokenStack) == 0 {
+		return nil
+	}
+	f := &enc.tokenStack[len(enc.tokenStack)-1]
+	switch {
+	case f.kind == '{' && f.n%2 == 0 && !isString:
+		return errors.New("json: object member name must be a string")
+	case f.kind == '{' && f.n%2 == 1:
+		enc.tokenBuf = append(enc.tokenBuf, ':')
+		if enc.indenting() {
+			enc.tokenBuf = append(enc.tokenBuf, ' ')
+		}
+	default:
+		if f.n > 0 {
+			enc.tokenBuf = append(enc.tokenBuf, ',')
+		}
+		if enc.indenting() {
+			enc.tokenBuf = appendNewline(enc.tokenBuf, enc.indentPrefix, enc.indentValue, len(enc.tokenStack))
+		}
+	}
+	f.n++
+	return nil
+}
+
+// tokenFlushSize is the size at which buffered output
+// is written before the top-level value is complete.
+const tokenFlushSize = 4 << 10
+
+// tokenValueEnd completes a value, terminating a top-level value with
+// a newline, and writes the buffered output if appropriate.
+func (enc *Encoder) tokenValueEnd() error {
+	if len(enc.tokenStack) == 0 {
+		enc.tokenBuf = append(enc.tokenBuf, '\n')
+	} else if len(enc.tokenBuf) < tokenFlushSize {
+		return nil
+	}
+	_, err := enc.w.Write(enc.tokenBuf)
+	enc.tokenBuf = enc.tokenBuf[:0]
+	if err != nil {
+		enc.err = err
+	}
+	return err
+}
+
 // SetIndent instructs the encoder to format each subsequent encoded
 // value as if indented by the package-level function Indent(dst, src, prefix, indent).
 // Calling SetIndent("", "") disables indentation.
@@ -365,53 +530,87 @@ func (d Delim) String() string {
 // to mark the start and end of arrays and objects.
 // Commas and colons are elided.
 func (dec *Decoder) Token() (Token, error) {
+	c, isName, err := dec.nextToken()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c == '[' || c == ']' || c == '{' || c == '}':
+		return Delim(c), nil
+
+	case isName:
+		var x string
+		old := dec.tokenState
+		dec.tokenState = tokenTopValue
+		err := dec.Decode(&x)
+		dec.tokenState = old
+		if err != nil {
+			return nil, err
+		}
+		dec.tokenState = tokenObjectColon
+		return x, nil
+
+	default:
+		var x any
+		if err := dec.Decode(&x); err != nil {
+			return nil, err
+		}
+		return x, nil
+	}
+}
+
+// nextToken skips any commas and colons, and returns the first byte
+// of the next token. If the token is a delimiter, nextToken consumes
+// it. Otherwise it leaves the token in the input, and reports whether
+// it is the name of an object member.
+func (dec *Decoder) nextToken() (c byte, isName bool, err error) {
 	for {
 		c, err := dec.peek()
 		if err != nil {
-			return nil, err
+			return 0, false, err
 		}
 		switch c {
 		case '[':
 			if !dec.tokenValueAllowed() {
-				return dec.tokenError(c)
+				return dec.nextTokenError(c)
 			}
 			dec.scanp++
 			dec.tokenStack = append(dec.tokenStack, dec.tokenState)
 			dec.tokenState = tokenArrayStart
-			return Delim('['), nil
+			return c, false, nil
 
 		case ']':
 			if dec.tokenState != tokenArrayStart && dec.tokenState != tokenArrayComma {
-				return dec.tokenError(c)
+				return dec.nextTokenError(c)
 			}
 			dec.scanp++
 			dec.tokenState = dec.tokenStack[len(dec.tokenStack)-1]
 			dec.tokenStack = dec.tokenStack[:len(dec.tokenStack)-1]
 			dec.tokenValueEnd()
-			return Delim(']'), nil
+			return c, false, nil
 
 		case '{':
 			if !dec.tokenValueAllowed() {
-				return dec.tokenError(c)
+				return dec.nextTokenError(c)
 			}
 			dec.scanp++
 			dec.tokenStack = append(dec.tokenStack, dec.tokenState)
 			dec.tokenState = tokenObjectStart
-			return Delim('{'), nil
+			return c, false, nil
 
 		case '}':
 			if dec.tokenState != tokenObjectStart && dec.tokenState != tokenObjectComma {
-				return dec.tokenError(c)
+				return dec.nextTokenError(c)
 			}
 			dec.scanp++
 			dec.tokenState = dec.tokenStack[len(dec.tokenStack)-1]
 			dec.tokenStack = dec.tokenStack[:len(dec.tokenStack)-1]
 			dec.tokenValueEnd()
-			return Delim('}'), nil
+			return c, false, nil
 
 		case ':':
 			if dec.tokenState != tokenObjectColon {
-				return dec.tokenError(c)
+				return dec.nextTokenError(c)
 			}
 			dec.scanp++
 			dec.tokenState = tokenObjectValue
@@ -428,36 +627,106 @@ func (dec *Decoder) Token() (Token, error) {
 				dec.tokenState = tokenObjectKey
 				continue
 			}
-			return dec.tokenError(c)
+			return dec.nextTokenError(c)
 
 		case '"':
 			if dec.tokenState == tokenObjectStart || dec.tokenState == tokenObjectKey {
-				var x string
-				old := dec.tokenState
-				dec.tokenState = tokenTopValue
-				err := dec.Decode(&x)
-				dec.tokenState = old
-				if err != nil {
-					return nil, err
-				}
-				dec.tokenState = tokenObjectColon
-				return x, nil
+				return c, true, nil
 			}
 			fallthrough
 
 		default:
 			if !dec.tokenValueAllowed() {
-				return dec.tokenError(c)
-			}
-			var x any
-			if err := dec.Decode(&x); err != nil {
-				return nil, err
+				return dec.nextTokenError(c)
 			}
-			return x, nil
+			return c, false, nil
 		}
 	}
 }
 
+func (dec *Decoder) nextTokenError(c byte) (byte, bool, error) {
+	_, err := dec.tokenError(c)
+	return 0, false, err
+}
+
+// ReadToken returns the next JSON token in the input stream, like
+// [Decoder.Token], but as a [RawToken] rather than a [Token].
+// At the end of the input stream, ReadToken returns an invalid
+// token and [io.EOF].
+//
+// The token refers to the Decoder's internal buffer, so it is valid
+// only until the next call to a Decoder method. Use [RawToken.String]
+// or [RawToken.AppendString] to retain the value of a string.
+//
+// ReadToken and Token may be mixed with each other and with
+// [Decoder.Decode] and [Decoder.ReadValue], which read the next
+// complete value.
+func (dec *Decoder) ReadToken() (RawToken, error) {
+	c, isName, err := dec.nextToken()
+	if err != nil {
+		return RawToken{}, err
+	}
+	switch c {
+	case '[', ']', '{', '}':
+		return RawToken{kind: TokenKind(c)}, nil
+	}
+
+	// Read the literal, number or string as if it were a top-level value.
+	old := dec.tokenState
+	dec.tokenState = tokenTopValue
+	n, err := dec.readValue()
+	dec.tokenState = old
+	if err != nil {
+		return RawToken{}, err
+	}
+	t := RawToken{raw: dec.buf[dec.scanp : dec.scanp+n]}
+	dec.scanp += n
+	if isName {
+		dec.tokenState = tokenObjectColon
+	} else {
+		dec.tokenValueEnd()
+	}
+	switch c {
+	case 'n', 'f', 't':
+		t.kind, t.raw = TokenKind(c), nil
+	case '"':
+		t.kind = '"'
+	default:
+		t.kind = '0'
+	}
+	return t, nil
+}
+
+// ReadValue returns the next complete JSON value in the input stream,
+// without decoding it. At the end of the input stream, ReadValue
+// returns nil, [io.EOF].
+//
+// The value refers to the Decoder's internal buffer, so it is valid
+// only until the next call to a Decoder method. It has no leading
+// or trailing white space.
+func (dec *Decoder) ReadValue() (RawMessage, error) {
+	if dec.err != nil {
+		return nil, dec.err
+	}
+	if err := dec.tokenPrepareForDecode(); err != nil {
+		return nil, err
+	}
+	if !dec.tokenValueAllowed() {
+		return nil, &SyntaxError{msg: "not at beginning of value", Offset: dec.InputOffset()}
+	}
+	if _, err := dec.peek(); err != nil {
+		return nil, err
+	}
+	n, err := dec.readValue()
+	if err != nil {
+		return nil, err
+	}
+	v := RawMessage(dec.buf[dec.scanp : dec.scanp+n])
+	dec.scanp += n
+	dec.tokenValueEnd()
+	return v, nil
+}
+
 func (dec *Decoder) tokenError(c byte) (Token, error) {
 	var context string
 	switch dec.tokenState {
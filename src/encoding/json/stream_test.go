@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net"
 	"net/http"
 	"net/http/httptest"
@@ -520,3 +521,342 @@ func TestHTTPDecoding(t *testing.T) {
 		t.Errorf("Decode error:\n\tgot:  %v\n\twant: io.EOF", err)
 	}
 }
+
+func TestReadToken(t *testing.T) {
+	tests := []struct {
+		CaseName
+		json string
+		want []string // Kind and String of each token
+	}{
+		{Name(""), `10 "x" null true false`, []string{`0 10`, `" x`, `n null`, `t true`, `f false`}},
+		{Name(""), ` [10, -1.5e3 , "bé\n"] `, []string{`[ [`, `0 10`, `0 -1.5e3`, "\" bé\n", `] ]`}},
+		{Name(""), `{"a": {"b":[]}, "c" :{}}`, []string{`{ {`, `" a`, `{ {`, `" b`, `[ [`, `] ]`, `} }`, `" c`, `{ {`, `} }`, `} }`}},
+		{Name(""), `{"` + strings.Repeat("a", 600) + `": 1}`, []string{`{ {`, `" ` + strings.Repeat("a", 600), `0 1`, `} }`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(tt.json))
+			var got []string
+			for {
+				tok, err := dec.ReadToken()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("%s: ReadToken error: %v", tt.Where, err)
+				}
+				got = append(got, string(rune(tok.Kind()))+" "+tok.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: tokens:\n\tgot:  %q\n\twant: %q", tt.Where, got, tt.want)
+			}
+		})
+	}
+
+	// Syntax errors are reported as by Token.
+	for _, in := range []string{`[1 2]`, `{"a" 1}`, `{1: 2}`, `]`, `[}`, `"abc`, `tru`} {
+		_, wantErr := readAllTokens(NewDecoder(strings.NewReader(in)), (*Decoder).Token)
+		_, err := readAllTokens(NewDecoder(strings.NewReader(in)), func(dec *Decoder) (Token, error) {
+			tok, err := dec.ReadToken()
+			return tok, err
+		})
+		if err == nil || !reflect.DeepEqual(err, wantErr) {
+			t.Errorf("ReadToken of %#q: %v, want %v", in, err, wantErr)
+		}
+	}
+}
+
+func readAllTokens(dec *Decoder, next func(*Decoder) (Token, error)) ([]Token, error) {
+	var toks []Token
+	for {
+		tok, err := next(dec)
+		if err == io.EOF {
+			return toks, nil
+		}
+		if err != nil {
+			return toks, err
+		}
+		toks = append(toks, tok)
+	}
+}
+
+func TestRawTokenValues(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`[12, -3, 1e3, 18446744073709551615, 0.5, "s", true]`))
+	var toks []RawToken
+	for {
+		tok, err := dec.ReadToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tok.Kind() == ']' {
+			break
+		}
+		if tok.Kind() != '[' {
+			toks = append(toks, tok)
+		}
+	}
+
+	if n, err := toks[0].Int(); n != 12 || err != nil {
+		t.Errorf("Int of 12 = %d, %v", n, err)
+	}
+	if n, err := toks[1].Int(); n != -3 || err != nil {
+		t.Errorf("Int of -3 = %d, %v", n, err)
+	}
+	if _, err := toks[1].Uint(); err == nil {
+		t.Errorf("Uint of -3 succeeded")
+	}
+	if _, err := toks[2].Int(); err == nil {
+		t.Errorf("Int of 1e3 succeeded")
+	}
+	if f, err := toks[2].Float(); f != 1000 || err != nil {
+		t.Errorf("Float of 1e3 = %v, %v", f, err)
+	}
+	if n, err := toks[3].Uint(); n != 1<<64-1 || err != nil {
+		t.Errorf("Uint of 18446744073709551615 = %d, %v", n, err)
+	}
+	if f, err := toks[4].Float(); f != 0.5 || err != nil {
+		t.Errorf("Float of 0.5 = %v, %v", f, err)
+	}
+	if _, err := toks[5].Int(); err == nil {
+		t.Errorf("Int of string succeeded")
+	}
+	if !toks[6].Bool() {
+		t.Errorf("Bool of true = false")
+	}
+
+	for _, tt := range []struct {
+		tok  RawToken
+		want string
+	}{
+		{IntToken(-5), "-5"},
+		{UintToken(7), "7"},
+		{FloatToken(1e21), "1e+21"},
+		{FloatToken(0.25), "0.25"},
+		{StringToken("a\"b"), "a\"b"},
+		{BoolToken(false), "false"},
+		{NullToken, "null"},
+		{BeginObjectToken, "{"},
+	} {
+		if got := tt.tok.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+	if f, err := IntToken(-5).Float(); f != -5 || err != nil {
+		t.Errorf("Float of IntToken(-5) = %v, %v", f, err)
+	}
+	if n, err := FloatToken(3).Int(); n != 3 || err != nil {
+		t.Errorf("Int of FloatToken(3) = %v, %v", n, err)
+	}
+}
+
+func TestReadValue(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(` {"a": [1, 2], "b" : { "c": null }} 5 `))
+	if tok, err := dec.ReadToken(); err != nil || tok.Kind() != '{' {
+		t.Fatalf("ReadToken = %v, %v", tok, err)
+	}
+	var got []string
+	for dec.More() {
+		tok, err := dec.ReadToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := dec.ReadValue()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, tok.String()+"="+string(v))
+	}
+	want := []string{`a=[1, 2]`, `b={ "c": null }`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("values:\n\tgot:  %q\n\twant: %q", got, want)
+	}
+	if tok, err := dec.ReadToken(); err != nil || tok.Kind() != '}' {
+		t.Fatalf("ReadToken = %v, %v", tok, err)
+	}
+	if v, err := dec.ReadValue(); string(v) != "5" || err != nil {
+		t.Errorf("ReadValue = %q, %v; want %q", v, err, "5")
+	}
+	if _, err := dec.ReadValue(); err != io.EOF {
+		t.Errorf("ReadValue at end = %v, want io.EOF", err)
+	}
+}
+
+func TestWriteToken(t *testing.T) {
+	var buf strings.Builder
+	enc := NewEncoder(&buf)
+	write := func(toks ...RawToken) {
+		t.Helper()
+		for _, tok := range toks {
+			if err := enc.WriteToken(tok); err != nil {
+				t.Fatalf("WriteToken(%v): %v", tok, err)
+			}
+		}
+	}
+	write(BeginObjectToken, StringToken("a"), IntToken(1), StringToken("b<"), BeginArrayToken, TrueToken, NullToken, FloatToken(0.5), EndArrayToken)
+	if err := enc.WriteValue(RawMessage(` { "x" : [ ] } `)); err == nil {
+		t.Error("WriteValue of object as member name succeeded")
+	}
+	if err := enc.WriteToken(IntToken(2)); err == nil {
+		t.Error("WriteToken of number as member name succeeded")
+	}
+	if err := enc.WriteToken(EndArrayToken); err == nil {
+		t.Error("WriteToken of mismatched ] succeeded")
+	}
+	write(StringToken("c"))
+	if err := enc.WriteToken(EndObjectToken); err == nil {
+		t.Error("WriteToken of } after member name succeeded")
+	}
+	if err := enc.WriteToken(FloatToken(math.NaN())); err == nil {
+		t.Error("WriteToken of NaN succeeded")
+	}
+	if err := enc.WriteValue(RawMessage(`{"x":`)); err == nil {
+		t.Error("WriteValue of invalid JSON succeeded")
+	}
+	if err := enc.WriteValue(RawMessage(` { "x" : [ ] } `)); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("output written before the top-level value is complete: %q", buf.String())
+	}
+	if err := enc.Encode(map[string]int{"d": 4}); err == nil {
+		t.Error("Encode of map as member name succeeded")
+	}
+	if err := enc.Encode("d"); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode([]int{5}); err != nil {
+		t.Fatal(err)
+	}
+	write(EndObjectToken, StringToken("next"))
+	if err := enc.Encode(6); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"a":1,"b\u003c":[true,null,0.5],"c":{"x":[]},"d":[5]}
+"next"
+6
+`
+	if got := buf.String(); got != want {
+		t.Errorf("output:\n\tgot:  %s\n\twant: %s", got, want)
+	}
+}
+
+func TestWriteTokenIndent(t *testing.T) {
+	const input = `{"a":1,"b":[true,{},[],{"c":"d"}],"e":{"f":[1,2]}} [] "x"`
+	var want bytes.Buffer
+	dec := NewDecoder(strings.NewReader(input))
+	enc := NewEncoder(&want)
+	enc.SetIndent(">", "  ")
+	for {
+		var v any
+		if err := dec.Decode(&v); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		enc.Encode(v)
+	}
+
+	for _, useValue := range []bool{false, true} {
+		var buf bytes.Buffer
+		dec := NewDecoder(strings.NewReader(input))
+		enc := NewEncoder(&buf)
+		enc.SetIndent(">", "  ")
+		for {
+			tok, err := dec.ReadToken()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if useValue && tok.Kind() == '"' && tok.String() == "e" {
+				// Write the rest of this member as a whole value.
+				enc.WriteToken(tok)
+				v, err := dec.ReadValue()
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = enc.WriteValue(v)
+			} else {
+				err = enc.WriteToken(tok)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if buf.String() != want.String() {
+			t.Errorf("indented output (useValue=%v):\n\tgot:\n%s\n\twant:\n%s", useValue, buf.String(), want.String())
+		}
+	}
+}
+
+func TestTokenAllocs(t *testing.T) {
+	if testing.CoverMode() != "" {
+		t.Skip("coverage instrumentation allocates")
+	}
+	input := strings.Repeat(`{"name":"value","list":[1,-2.5,true,false,null,"a\nb"]}`, 1000)
+	dec := NewDecoder(strings.NewReader(input))
+	enc := NewEncoder(io.Discard)
+	copyToken := func() {
+		tok, err := dec.ReadToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.WriteToken(tok); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for range 1000 {
+		copyToken()
+	}
+	if allocs := testing.AllocsPerRun(1000, copyToken); allocs != 0 {
+		t.Errorf("copying a token allocates %v times, want 0", allocs)
+	}
+}
+
+func TestDecoderCaseSensitiveNames(t *testing.T) {
+	type T struct {
+		Name  string
+		Other string `json:"other"`
+	}
+	for _, sensitive := range []bool{false, true} {
+		var v T
+		dec := NewDecoder(strings.NewReader(`{"name":"a","OTHER":"b"}`))
+		if sensitive {
+			dec.UseCaseSensitiveNames()
+		}
+		if err := dec.Decode(&v); err != nil {
+			t.Fatalf("Decode error: %v", err)
+		}
+		want := T{"a", "b"}
+		if sensitive {
+			want = T{}
+		}
+		if v != want {
+			t.Errorf("Decode with case sensitivity %v = %+v, want %+v", sensitive, v, want)
+		}
+	}
+}
+
+func TestDecoderDisallowDuplicateNames(t *testing.T) {
+	tests := []struct {
+		in  string
+		err bool
+	}{
+		{`{"a":1,"b":2}`, false},
+		{`{"a":1,"a":2}`, true},
+		{`{"a":{"a":1},"b":{"a":1}}`, false},
+		{`{"b":[{"a":1},{"a":1,"a":2}]}`, true},
+		{`{"a\u0062":1,"ab":2}`, true},
+	}
+	for _, tt := range tests {
+		for _, v := range []any{new(any), new(map[string]any), new(struct{ A any })} {
+			dec := NewDecoder(strings.NewReader(tt.in))
+			dec.DisallowDuplicateNames()
+			err := dec.Decode(v)
+			if _, ok := err.(*SyntaxError); ok != tt.err {
+				t.Errorf("Decode(%s) into %T: error = %v, want error %v", tt.in, v, err, tt.err)
+			}
+		}
+	}
+}
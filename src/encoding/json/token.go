@@ -0,0 +1,241 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// A TokenKind is the kind of a [RawToken]. It is the first byte of the
+// token's JSON encoding, except that all numbers have the kind '0':
+//
+//   - 'n': null
+//   - 'f': false
+//   - 't': true
+//   - '"': string
+//   - '0': number
+//   - '{': beginning of an object
+//   - '}': end of an object
+//   - '[': beginning of an array
+//   - ']': end of an array
+//
+// The zero TokenKind is invalid.
+type TokenKind byte
+
+func (k TokenKind) String() string {
+	switch k {
+	case 'n':
+		return "null"
+	case 'f':
+		return "false"
+	case 't':
+		return "true"
+	case '"':
+		return "string"
+	case '0':
+		return "number"
+	case '{', '}', '[', ']':
+		return string(rune(k))
+	}
+	return "invalid"
+}
+
+// A RawToken is a single JSON token: a delimiter, or a string, number,
+// boolean or null. Unlike [Token], a RawToken is a plain value, so
+// reading and writing tokens with [Decoder.ReadToken] and
+// [Encoder.WriteToken] does not allocate.
+//
+// Tokens are made with [StringToken], [IntToken], [UintToken],
+// [FloatToken] and [BoolToken], or are one of [NullToken], [FalseToken],
+// [TrueToken], [BeginObjectToken], [EndObjectToken], [BeginArrayToken]
+// and [EndArrayToken]. The zero RawToken is invalid.
+type RawToken struct {
+	kind TokenKind
+	num  byte   // 'i', 'u' or 'f' for a number made by IntToken, UintToken or FloatToken
+	raw  []byte // encoding of a string or number read by a Decoder
+	str  string // value of a string made by StringToken
+	bits uint64 // value of a number made by IntToken, UintToken or FloatToken
+}
+
+// Tokens for literals and delimiters.
+var (
+	NullToken  = RawToken{kind: 'n'}
+	FalseToken = RawToken{kind: 'f'}
+	TrueToken  = RawToken{kind: 't'}
+
+	BeginObjectToken = RawToken{kind: '{'}
+	EndObjectToken   = RawToken{kind: '}'}
+	BeginArrayToken  = RawToken{kind: '['}
+	EndArrayToken    = RawToken{kind: ']'}
+)
+
+// BoolToken returns a token for the boolean b.
+func BoolToken(b bool) RawToken {
+	if b {
+		return TrueToken
+	}
+	return FalseToken
+}
+
+// StringToken returns a token for the string s.
+func StringToken(s string) RawToken {
+	return RawToken{kind: '"', str: s}
+}
+
+// IntToken returns a token for the number n.
+func IntToken(n int64) RawToken {
+	return RawToken{kind: '0', num: 'i', bits: uint64(n)}
+}
+
+// UintToken returns a token for the number n.
+func UintToken(n uint64) RawToken {
+	return RawToken{kind: '0', num: 'u', bits: n}
+}
+
+// FloatToken returns a token for the number f.
+// Writing a token for a NaN or infinity reports an [UnsupportedValueError].
+func FloatToken(f float64) RawToken {
+	return RawToken{kind: '0', num: 'f', bits: math.Float64bits(f)}
+}
+
+// Kind returns the kind of t.
+func (t RawToken) Kind() TokenKind {
+	return t.kind
+}
+
+// String returns the value of a string token, or the JSON encoding
+// of any other token.
+func (t RawToken) String() string {
+	if t.kind == '"' && t.raw == nil {
+		return t.str
+	}
+	var buf [32]byte
+	return string(t.AppendString(buf[:0]))
+}
+
+// AppendString appends the value of a string token, or the JSON
+// encoding of any other token, to b and returns the extended buffer.
+func (t RawToken) AppendString(b []byte) []byte {
+	switch t.kind {
+	case 'n', 'f', 't':
+		return append(b, t.kind.String()...)
+	case '{', '}', '[', ']':
+		return append(b, byte(t.kind))
+	case '"':
+		if t.raw == nil {
+			return append(b, t.str...)
+		}
+		s, _ := unquoteBytes(t.raw)
+		return append(b, s...)
+	case '0':
+		return t.appendNumber(b)
+	}
+	return append(b, "<invalid json.RawToken>"...)
+}
+
+// appendNumber appends the JSON encoding of a number token to b.
+func (t RawToken) appendNumber(b []byte) []byte {
+	switch t.num {
+	case 'i':
+		return strconv.AppendInt(b, int64(t.bits), 10)
+	case 'u':
+		return strconv.AppendUint(b, t.bits, 10)
+	case 'f':
+		return appendFloat(b, math.Float64frombits(t.bits), 64)
+	}
+	return append(b, t.raw...)
+}
+
+// Bool returns the value of a boolean token.
+// It panics if t is not true or false.
+func (t RawToken) Bool() bool {
+	switch t.kind {
+	case 't':
+		return true
+	case 'f':
+		return false
+	}
+	panic("json: Bool of non-boolean " + t.kind.String() + " token")
+}
+
+// Int returns the value of a number token as an int64.
+// It reports an [UnmarshalTypeError] if t is not a number, and
+// a [strconv.NumError] if the number is not an integer or is out of range.
+func (t RawToken) Int() (int64, error) {
+	if t.kind != '0' {
+		return 0, t.typeError(reflect.TypeFor[int64]())
+	}
+	if t.num == 'i' {
+		return int64(t.bits), nil
+	}
+	var buf [32]byte
+	return strconv.ParseInt(string(t.appendNumber(buf[:0])), 10, 64)
+}
+
+// Uint returns the value of a number token as a uint64.
+// It reports an [UnmarshalTypeError] if t is not a number, and
+// a [strconv.NumError] if the number is not an integer or is out of range.
+func (t RawToken) Uint() (uint64, error) {
+	if t.kind != '0' {
+		return 0, t.typeError(reflect.TypeFor[uint64]())
+	}
+	if t.num == 'u' {
+		return t.bits, nil
+	}
+	var buf [32]byte
+	return strconv.ParseUint(string(t.appendNumber(buf[:0])), 10, 64)
+}
+
+// Float returns the value of a number token as a float64.
+// It reports an [UnmarshalTypeError] if t is not a number, and
+// a [strconv.NumError] if the number is out of range.
+func (t RawToken) Float() (float64, error) {
+	if t.kind != '0' {
+		return 0, t.typeError(reflect.TypeFor[float64]())
+	}
+	switch t.num {
+	case 'i':
+		return float64(int64(t.bits)), nil
+	case 'u':
+		return float64(t.bits), nil
+	case 'f':
+		return math.Float64frombits(t.bits), nil
+	}
+	return strconv.ParseFloat(string(t.raw), 64)
+}
+
+func (t RawToken) typeError(typ reflect.Type) error {
+	return &UnmarshalTypeError{Value: t.kind.String(), Type: typ}
+}
+
+// checkSupported reports an error if t is a NaN or infinity,
+// which cannot be encoded.
+func (t RawToken) checkSupported() error {
+	if t.num == 'f' {
+		f := math.Float64frombits(t.bits)
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return &UnsupportedValueError{reflect.ValueOf(f), strconv.FormatFloat(f, 'g', -1, 64)}
+		}
+	}
+	return nil
+}
+
+// appendToken appends the JSON encoding of t to b.
+func (t RawToken) appendToken(b []byte, escapeHTML bool) []byte {
+	switch t.kind {
+	case '"':
+		if t.raw == nil {
+			return appendString(b, t.str, escapeHTML)
+		}
+		// t.raw was read by a Decoder, so it is valid.
+		b, _ = appendCompact(b, t.raw, escapeHTML)
+		return b
+	case '0':
+		return t.appendNumber(b)
+	}
+	return t.AppendString(b)
+}
@@ -8,6 +8,7 @@
 package pem
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/base64"
 	"errors"
@@ -314,3 +315,60 @@ func EncodeToMemory(b *Block) []byte {
 	}
 	return buf.Bytes()
 }
+
+// A Reader reads successive PEM blocks from an input stream, such as a
+// bundle of certificates, without reading all of it into memory first.
+type Reader struct {
+	r    *bufio.Reader
+	line []byte // the current line
+	buf  []byte // the lines of the block being read
+	err  error
+}
+
+// NewReader returns a new Reader reading from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next PEM block in the input. As with [Decode], any
+// text before the block, and any malformed block, is skipped. At the
+// end of the input, Next returns nil and [io.EOF].
+func (r *Reader) Next() (*Block, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	for {
+		line, err := r.readLine()
+		switch {
+		case bytes.HasPrefix(line, pemStart[1:]):
+			// A BEGIN line within a block makes the block malformed,
+			// so it is where the next block might start.
+			r.buf = append(r.buf[:0], line...)
+		case len(r.buf) > 0:
+			r.buf = append(r.buf, line...)
+			if bytes.HasPrefix(line, pemEnd[1:]) {
+				p, _ := Decode(r.buf)
+				r.buf = r.buf[:0]
+				if p != nil {
+					return p, nil
+				}
+			}
+		}
+		if err != nil {
+			r.err = err
+			return nil, err
+		}
+	}
+}
+
+// readLine returns the next line of the input, including its newline.
+func (r *Reader) readLine() ([]byte, error) {
+	r.line = r.line[:0]
+	for {
+		b, err := r.r.ReadSlice('\n')
+		r.line = append(r.line, b...)
+		if err != bufio.ErrBufferFull {
+			return r.line, err
+		}
+	}
+}
@@ -6,10 +6,12 @@ package pem
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"reflect"
 	"strings"
 	"testing"
+	"testing/iotest"
 	"testing/quick"
 )
 
@@ -168,6 +170,55 @@ func TestCVE202224675(t *testing.T) {
 	}
 }
 
+func TestReader(t *testing.T) {
+	inputs := []string{
+		pemData,
+		pemPrivateKey2,
+		"-----BEGIN A-----\nAAAA\n-----END B-----\n-----BEGIN C-----\n-----END C-----\n",
+		"-----BEGIN A-----\n-----BEGIN B-----\nAAAA\n-----END B-----\n-----END A-----\n",
+		"-----BEGIN A-----\r\nk: v\r\n\r\nAAAA\r\n-----END A-----",
+		"x\n-----BEGIN A-----\n" + strings.Repeat("A", 10000) + "\n-----END A-----\n",
+	}
+	for _, test := range badPEMTests {
+		inputs = append(inputs, test.input)
+	}
+	for _, in := range inputs {
+		var want []*Block
+		for rest := []byte(in); ; {
+			var p *Block
+			if p, rest = Decode(rest); p == nil {
+				break
+			}
+			want = append(want, p)
+		}
+
+		var got []*Block
+		r := NewReader(iotest.OneByteReader(strings.NewReader(in)))
+		for {
+			p, err := r.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Next: %v", err)
+			}
+			got = append(got, p)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Reader of %.40q:\ngot  %#v\nwant %#v", in, got, want)
+		}
+	}
+
+	errRead := errors.New("read error")
+	r := NewReader(io.MultiReader(strings.NewReader(pemPrivateKey2), iotest.ErrReader(errRead)))
+	if p, err := r.Next(); err != nil || !reflect.DeepEqual(p, privateKey2) {
+		t.Errorf("Next = %#v, %v; want %#v", p, err, privateKey2)
+	}
+	if _, err := r.Next(); err != errRead {
+		t.Errorf("Next error = %v, want %v", err, errRead)
+	}
+}
+
 func TestEncode(t *testing.T) {
 	r := EncodeToMemory(privateKey2)
 	if string(r) != pemPrivateKey2 {
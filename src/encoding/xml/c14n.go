@@ -0,0 +1,422 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+)
+
+// CanonicalOptions configures a [CanonicalWriter].
+type CanonicalOptions struct {
+	// Exclusive selects Exclusive XML Canonicalization, in which an
+	// element carries only the name space declarations it visibly
+	// uses, rather than Canonical XML, in which it carries every
+	// declaration in scope that its parent did not.
+	Exclusive bool
+
+	// WithComments keeps comments in the output.
+	WithComments bool
+
+	// InclusivePrefixes lists the prefixes that Exclusive
+	// canonicalization treats as in Canonical XML: the
+	// InclusiveNamespaces PrefixList. The prefix "#default"
+	// stands for the default name space.
+	InclusivePrefixes []string
+
+	// Namespaces maps prefixes to the name spaces bound to them
+	// where the input appears, as when canonicalizing an element
+	// taken from a larger document. The prefix "" stands for the
+	// default name space.
+	Namespaces map[string]string
+}
+
+// A CanonicalWriter writes XML tokens in canonical form, as specified
+// by Canonical XML 1.0 (https://www.w3.org/TR/xml-c14n) and Exclusive
+// XML Canonicalization 1.0 (https://www.w3.org/TR/xml-exc-c14n/), for
+// computing and verifying XML signatures.
+//
+// The tokens are those returned by [Decoder.RawToken]: names have
+// their prefix, not their name space URL, in Space, and name space
+// declarations are attributes. A document type declaration and
+// the attribute defaults and entities it might define are ignored.
+//
+// Attribute values are written as they are in the tokens. XML requires
+// tabs, newlines and carriage returns written literally in attribute
+// values, unlike character references to them, to be read as spaces,
+// which [Canonicalize] does but [Decoder] does not.
+type CanonicalWriter struct {
+	w         *bufio.Writer
+	opts      CanonicalOptions
+	scope     []nsBinding // name spaces declared in scope, innermost last
+	rendered  []nsBinding // name spaces declared in the output, innermost last
+	marks     []int       // len(scope), len(rendered) at each open element
+	tags      []Name
+	seenRoot  bool
+	inclusive map[string]bool
+	err       error
+}
+
+// NewCanonicalWriter returns a new CanonicalWriter writing to w.
+// If opts is nil, it uses Canonical XML without comments.
+func NewCanonicalWriter(w io.Writer, opts *CanonicalOptions) *CanonicalWriter {
+	c := &CanonicalWriter{w: bufio.NewWriter(w)}
+	if opts != nil {
+		c.opts = *opts
+	}
+	for prefix, url := range c.opts.Namespaces {
+		c.scope = append(c.scope, nsBinding{prefix: prefix, url: url})
+	}
+	if c.opts.Exclusive {
+		c.inclusive = make(map[string]bool)
+		for _, prefix := range c.opts.InclusivePrefixes {
+			if prefix == "#default" {
+				prefix = ""
+			}
+			c.inclusive[prefix] = true
+		}
+	}
+	return c
+}
+
+// Canonicalize reads the XML document from r and writes its
+// canonical form to w.
+func Canonicalize(w io.Writer, r io.Reader, opts *CanonicalOptions) error {
+	d := NewDecoder(r)
+	d.normalizeAttrs = true
+	c := NewCanonicalWriter(w, opts)
+	for {
+		t, err := d.RawToken()
+		if err == io.EOF {
+			return c.Close()
+		}
+		if err != nil {
+			return err
+		}
+		if err := c.WriteToken(t); err != nil {
+			return err
+		}
+	}
+}
+
+// WriteToken writes the canonical form of t.
+// Start and end elements must match, and text outside the
+// outermost element must be white space.
+func (c *CanonicalWriter) WriteToken(t Token) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.err = c.writeToken(t)
+	return c.err
+}
+
+func (c *CanonicalWriter) writeToken(t Token) error {
+	w := c.w
+	switch t := t.(type) {
+	case StartElement:
+		if len(c.tags) == 0 && c.seenRoot {
+			return errors.New("xml: canonical document has more than one root element")
+		}
+		return c.writeStart(&t)
+	case EndElement:
+		if len(c.tags) == 0 {
+			return errors.New("xml: end tag </" + t.Name.Local + "> without start tag")
+		}
+		start := c.tags[len(c.tags)-1]
+		if start != t.Name {
+			if start.Local != t.Name.Local {
+				return errors.New("xml: end tag </" + t.Name.Local + "> does not match start tag <" + start.Local + ">")
+			}
+			return errors.New("xml: end tag </" + t.Name.Local + "> in namespace " + t.Name.Space + " does not match start tag <" + start.Local + "> in namespace " + start.Space)
+		}
+		w.WriteString("</")
+		writeQName(w, t.Name)
+		w.WriteByte('>')
+		c.tags = c.tags[:len(c.tags)-1]
+		m := len(c.marks) - 2
+		c.scope, c.rendered = c.scope[:c.marks[m]], c.rendered[:c.marks[m+1]]
+		c.marks = c.marks[:m]
+	case CharData:
+		if len(c.tags) == 0 {
+			if len(bytes.Trim(t, " \t\r\n")) > 0 {
+				return errors.New("xml: canonical document has text outside the root element")
+			}
+			return nil
+		}
+		escapeCanonical(w, t, false)
+	case Comment:
+		if !c.opts.WithComments {
+			return nil
+		}
+		if bytes.Contains(t, endComment) {
+			return errors.New("xml: Comment containing --> marker")
+		}
+		c.writeOutside(func() {
+			w.WriteString("<!--")
+			w.Write(t)
+			w.WriteString("-->")
+		})
+	case ProcInst:
+		if t.Target == "xml" {
+			// The XML declaration is not part of the canonical form.
+			return nil
+		}
+		if !isNameString(t.Target) {
+			return errors.New("xml: ProcInst with invalid Target")
+		}
+		if bytes.Contains(t.Inst, endProcInst) {
+			return errors.New("xml: ProcInst containing ?> marker")
+		}
+		c.writeOutside(func() {
+			w.WriteString("<?")
+			w.WriteString(t.Target)
+			if len(t.Inst) > 0 {
+				w.WriteByte(' ')
+				w.Write(t.Inst)
+			}
+			w.WriteString("?>")
+		})
+	case Directive:
+		if len(c.tags) > 0 {
+			return errors.New("xml: directive inside an element")
+		}
+	default:
+		return errors.New("xml: invalid token type")
+	}
+	return nil
+}
+
+// writeOutside calls write, separating a node outside the root
+// element from the root with a newline.
+func (c *CanonicalWriter) writeOutside(write func()) {
+	if len(c.tags) > 0 {
+		write()
+		return
+	}
+	if c.seenRoot {
+		c.w.WriteByte('\n')
+	}
+	write()
+	if !c.seenRoot {
+		c.w.WriteByte('\n')
+	}
+}
+
+// writeStart writes start with its name space declarations and
+// attributes in canonical order.
+func (c *CanonicalWriter) writeStart(start *StartElement) error {
+	if start.Name.Local == "" {
+		return errors.New("xml: start tag with no name")
+	}
+	c.seenRoot = true
+	c.marks = append(c.marks, len(c.scope), len(c.rendered))
+	c.tags = append(c.tags, start.Name)
+
+	var attrs []Attr
+	for _, attr := range start.Attr {
+		switch {
+		case attr.Name.Space == xmlnsPrefix:
+			if attr.Name.Local != xmlPrefix && attr.Value != "" {
+				c.scope = append(c.scope, nsBinding{prefix: attr.Name.Local, url: attr.Value})
+			}
+		case attr.Name.Space == "" && attr.Name.Local == xmlnsPrefix:
+			c.scope = append(c.scope, nsBinding{url: attr.Value})
+		default:
+			attrs = append(attrs, attr)
+		}
+	}
+
+	if p := start.Name.Space; p != "" && p != xmlPrefix {
+		if _, ok := lookupBinding(c.scope, p); !ok {
+			return errors.New("xml: name space prefix " + p + " is not declared")
+		}
+	}
+
+	// Find the prefixes whose declarations this element needs.
+	var prefixes []string
+	if c.opts.Exclusive {
+		prefixes = append(prefixes, start.Name.Space)
+		for _, attr := range attrs {
+			if attr.Name.Space != "" {
+				prefixes = append(prefixes, attr.Name.Space)
+			}
+		}
+		for prefix := range c.inclusive {
+			prefixes = append(prefixes, prefix)
+		}
+	} else {
+		for _, b := range c.scope {
+			prefixes = append(prefixes, b.prefix)
+		}
+		if len(c.rendered) > 0 {
+			prefixes = append(prefixes, "")
+		}
+	}
+	slices.Sort(prefixes)
+	prefixes = slices.Compact(prefixes)
+
+	var decls []nsBinding
+	for _, prefix := range prefixes {
+		if prefix == xmlPrefix {
+			continue
+		}
+		url, ok := lookupBinding(c.scope, prefix)
+		if !ok && prefix != "" {
+			if c.opts.Exclusive && c.inclusive[prefix] {
+				continue
+			}
+			return errors.New("xml: name space prefix " + prefix + " is not declared")
+		}
+		if have, _ := lookupBinding(c.rendered, prefix); have == url && (ok || prefix == "") {
+			// Already rendered by an ancestor, or an empty default
+			// that no ancestor overrode.
+			continue
+		}
+		decls = append(decls, nsBinding{prefix: prefix, url: url})
+	}
+	c.rendered = append(c.rendered, decls...)
+
+	// Attributes are sorted by name space URL, then local name.
+	type sortAttr struct {
+		url string
+		Attr
+	}
+	sorted := make([]sortAttr, len(attrs))
+	for i, attr := range attrs {
+		url := ""
+		switch attr.Name.Space {
+		case "":
+		case xmlPrefix:
+			url = xmlURL
+		default:
+			var ok bool
+			if url, ok = lookupBinding(c.scope, attr.Name.Space); !ok {
+				return errors.New("xml: name space prefix " + attr.Name.Space + " is not declared")
+			}
+		}
+		sorted[i] = sortAttr{url, attr}
+	}
+	slices.SortFunc(sorted, func(a, b sortAttr) int {
+		if c := strings.Compare(a.url, b.url); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name.Local, b.Name.Local)
+	})
+
+	w := c.w
+	w.WriteByte('<')
+	writeQName(w, start.Name)
+	for _, d := range decls {
+		w.WriteString(" xmlns")
+		if d.prefix != "" {
+			w.WriteByte(':')
+			w.WriteString(d.prefix)
+		}
+		w.WriteString(`="`)
+		escapeCanonical(w, []byte(d.url), true)
+		w.WriteByte('"')
+	}
+	for _, attr := range sorted {
+		w.WriteByte(' ')
+		writeQName(w, attr.Name)
+		w.WriteString(`="`)
+		escapeCanonical(w, []byte(attr.Value), true)
+		w.WriteByte('"')
+	}
+	w.WriteByte('>')
+	return nil
+}
+
+// lookupBinding returns the name space bound to prefix by the
+// innermost of bindings.
+func lookupBinding(bindings []nsBinding, prefix string) (string, bool) {
+	for i := len(bindings) - 1; i >= 0; i-- {
+		if bindings[i].prefix == prefix {
+			return bindings[i].url, true
+		}
+	}
+	return "", false
+}
+
+func writeQName(w *bufio.Writer, name Name) {
+	if name.Space != "" {
+		w.WriteString(name.Space)
+		w.WriteByte(':')
+	}
+	w.WriteString(name.Local)
+}
+
+// escapeCanonical writes s to w with the characters that canonical
+// XML escapes in text, or in attribute values if attr is set, replaced.
+func escapeCanonical(w *bufio.Writer, s []byte, attr bool) {
+	last := 0
+	for i, b := range s {
+		var esc string
+		switch b {
+		case '&':
+			esc = "&amp;"
+		case '<':
+			esc = "&lt;"
+		case '>':
+			if attr {
+				continue
+			}
+			esc = "&gt;"
+		case '"':
+			if !attr {
+				continue
+			}
+			esc = "&quot;"
+		case '\t':
+			if !attr {
+				continue
+			}
+			esc = "&#x9;"
+		case '\n':
+			if !attr {
+				continue
+			}
+			esc = "&#xA;"
+		case '\r':
+			esc = "&#xD;"
+		default:
+			continue
+		}
+		w.Write(s[last:i])
+		w.WriteString(esc)
+		last = i + 1
+	}
+	w.Write(s[last:])
+}
+
+// Flush flushes any buffered output to the underlying writer.
+func (c *CanonicalWriter) Flush() error {
+	if c.err != nil {
+		return c.err
+	}
+	c.err = c.w.Flush()
+	return c.err
+}
+
+// Close flushes the output and reports an error if
+// any elements are still open.
+func (c *CanonicalWriter) Close() error {
+	if err := c.Flush(); err != nil {
+		return err
+	}
+	if len(c.tags) > 0 {
+		c.err = errors.New("xml: unclosed tag <" + c.tags[len(c.tags)-1].Local + ">")
+		return c.err
+	}
+	if !c.seenRoot {
+		c.err = errors.New("xml: canonical document has no root element")
+		return c.err
+	}
+	return nil
+}
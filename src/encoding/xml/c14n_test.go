@@ -0,0 +1,168 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"strings"
+	"testing"
+)
+
+var canonicalTests = []struct {
+	desc string
+	opts *CanonicalOptions
+	in   string
+	want string
+}{{
+	// Canonical XML 1.0, section 3.1.
+	desc: "PIs, comments, and outside of document element",
+	in: `<?xml version="1.0"?>
+
+<?xml-stylesheet   href="doc.xsl"
+   type="text/xsl"   ?>
+
+<!DOCTYPE doc SYSTEM "doc.dtd">
+
+<doc>Hello, world!<!-- Comment 1 --></doc>
+
+<?pi-without-data     ?>
+
+<!-- Comment 2 -->
+
+<!-- Comment 3 -->
+`,
+	want: `<?xml-stylesheet href="doc.xsl"
+   type="text/xsl"   ?>
+<doc>Hello, world!</doc>
+<?pi-without-data?>`,
+}, {
+	desc: "PIs, comments, and outside of document element, with comments",
+	opts: &CanonicalOptions{WithComments: true},
+	in: `<?xml version="1.0"?>
+
+<?xml-stylesheet   href="doc.xsl"
+   type="text/xsl"   ?>
+
+<!DOCTYPE doc SYSTEM "doc.dtd">
+
+<doc>Hello, world!<!-- Comment 1 --></doc>
+
+<?pi-without-data     ?>
+
+<!-- Comment 2 -->
+
+<!-- Comment 3 -->
+`,
+	want: `<?xml-stylesheet href="doc.xsl"
+   type="text/xsl"   ?>
+<doc>Hello, world!<!-- Comment 1 --></doc>
+<?pi-without-data?>
+<!-- Comment 2 -->
+<!-- Comment 3 -->`,
+}, {
+	// Canonical XML 1.0, section 3.3, without the DTD.
+	desc: "start and end tags",
+	in: `<doc>
+   <e1   />
+   <e2   ></e2>
+   <e3   name = "elem3"   id="elem3"   />
+   <e4   name="elem4"   id="elem4"   ></e4>
+   <e5 a:attr="out" b:attr="sorted" attr2="all" attr="I'm"
+      xmlns:b="http://www.ietf.org"
+      xmlns:a="http://www.w3.org"
+      xmlns="http://example.org"/>
+   <e6 xmlns="" xmlns:a="http://www.w3.org">
+      <e7 xmlns="http://www.ietf.org">
+         <e8 xmlns="" xmlns:a="http://www.w3.org">
+            <e9 xmlns="" xmlns:a="http://www.ietf.org"/>
+         </e8>
+      </e7>
+   </e6>
+</doc>`,
+	want: `<doc>
+   <e1></e1>
+   <e2></e2>
+   <e3 id="elem3" name="elem3"></e3>
+   <e4 id="elem4" name="elem4"></e4>
+   <e5 xmlns="http://example.org" xmlns:a="http://www.w3.org" xmlns:b="http://www.ietf.org" attr="I'm" attr2="all" b:attr="sorted" a:attr="out"></e5>
+   <e6 xmlns:a="http://www.w3.org">
+      <e7 xmlns="http://www.ietf.org">
+         <e8 xmlns="">
+            <e9 xmlns:a="http://www.ietf.org"></e9>
+         </e8>
+      </e7>
+   </e6>
+</doc>`,
+}, {
+	desc: "character escapes",
+	in:   `<doc a="x&#9;y&#10;&quot;&lt;>" b='"'><![CDATA[&<>]]>"'&#xD;</doc>`,
+	want: `<doc a="x&#x9;y&#xA;&quot;&lt;>" b="&quot;">&amp;&lt;&gt;"'&#xD;</doc>`,
+}, {
+	// Exclusive XML Canonicalization 1.0, section 2.2.
+	desc: "inclusive subtree",
+	opts: &CanonicalOptions{Namespaces: map[string]string{"n0": "foo:bar", "n3": "ftp://example.org"}},
+	in: `<n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+    <n3:stuff xmlns:n3="ftp://example.org"/>
+  </n1:elem2>`,
+	want: `<n1:elem2 xmlns:n0="foo:bar" xmlns:n1="http://example.net" xmlns:n3="ftp://example.org" xml:lang="en">
+    <n3:stuff></n3:stuff>
+  </n1:elem2>`,
+}, {
+	desc: "attribute value normalization",
+	in:   "<a b=\"x\ny\tz\" c=\"1\r\n2\r3\" d='&quot;&lt;&gt;&amp;'/>",
+	want: `<a b="x y z" c="1 2 3" d="&quot;&lt;>&amp;"></a>`,
+}, {
+	desc: "attribute value normalization",
+	in:   "<a b=\"x\ny\tz\" c=\"1\r\n2\r3\" d='&#9;&#10;&#13;'/>",
+	want: `<a b="x y z" c="1 2 3" d="&#x9;&#xA;&#xD;"></a>`,
+}, {
+	desc: "exclusive subtree",
+	opts: &CanonicalOptions{Exclusive: true, Namespaces: map[string]string{"n0": "foo:bar", "n3": "ftp://example.org"}},
+	in: `<n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+    <n3:stuff xmlns:n3="ftp://example.org"/>
+  </n1:elem2>`,
+	want: `<n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+    <n3:stuff xmlns:n3="ftp://example.org"></n3:stuff>
+  </n1:elem2>`,
+}, {
+	desc: "exclusive with inclusive prefixes",
+	opts: &CanonicalOptions{Exclusive: true, InclusivePrefixes: []string{"#default", "n0"}, Namespaces: map[string]string{"n0": "foo:bar"}},
+	in:   `<n1:a xmlns:n1="urn:a" xmlns="urn:d" xmlns:unused="urn:u"><n1:b xmlns=""/></n1:a>`,
+	want: `<n1:a xmlns="urn:d" xmlns:n0="foo:bar" xmlns:n1="urn:a"><n1:b xmlns=""></n1:b></n1:a>`,
+}, {
+	desc: "exclusive default name space",
+	opts: &CanonicalOptions{Exclusive: true},
+	in:   `<a xmlns="urn:d" xmlns:x="urn:x"><x:b><c xmlns=""/><d/></x:b></a>`,
+	want: `<a xmlns="urn:d"><x:b xmlns:x="urn:x"><c xmlns=""></c><d></d></x:b></a>`,
+}}
+
+func TestCanonicalize(t *testing.T) {
+	for _, tt := range canonicalTests {
+		t.Run(tt.desc, func(t *testing.T) {
+			var out strings.Builder
+			if err := Canonicalize(&out, strings.NewReader(tt.in), tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("\nhave %s\nwant %s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeErrors(t *testing.T) {
+	for _, in := range []string{
+		``,
+		`<a>`,
+		`<a></b>`,
+		`<a></a><b></b>`,
+		`text<a></a>`,
+		`<x:a></x:a>`,
+		`<a x:b="1"></a>`,
+	} {
+		if err := Canonicalize(new(strings.Builder), strings.NewReader(in), nil); err == nil {
+			t.Errorf("Canonicalize(%q) succeeded", in)
+		}
+	}
+}
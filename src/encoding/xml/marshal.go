@@ -160,6 +160,39 @@ func (enc *Encoder) Indent(prefix, indent string) {
 	enc.p.indent = indent
 }
 
+// DeclarePrefix declares prefix as the name space prefix for url in the
+// next start element written, and so within that element.
+//
+// Elements in the name space url are written using a declared prefix,
+// rather than with a default name space declaration, and attributes in
+// it use a declared prefix rather than one chosen by the Encoder.
+// Name space declarations in the attributes of a [StartElement], such
+// as those returned by [Decoder.Token], are also declared prefixes.
+// If url is already the default name space, declared by an attribute
+// of an enclosing element, elements in it are written without a prefix.
+func (enc *Encoder) DeclarePrefix(prefix, url string) error {
+	if err := checkPrefix(prefix, url); err != nil {
+		return err
+	}
+	if url == "" {
+		return fmt.Errorf("xml: DeclarePrefix of %s with empty name space", prefix)
+	}
+	enc.p.pending = append(enc.p.pending, nsBinding{prefix, url, true})
+	return nil
+}
+
+// checkPrefix reports an error if prefix may not be declared
+// for the name space url.
+func checkPrefix(prefix, url string) error {
+	if !isNameString(prefix) || strings.Contains(prefix, ":") {
+		return fmt.Errorf("xml: invalid name space prefix %q", prefix)
+	}
+	if prefix == xmlnsPrefix || prefix == xmlPrefix && url != xmlURL || prefix != xmlPrefix && url == xmlURL {
+		return fmt.Errorf("xml: name space prefix %s cannot be bound to %s", prefix, url)
+	}
+	return nil
+}
+
 // Encode writes the XML encoding of v to the stream.
 //
 // See the documentation for [Marshal] for details about the conversion
@@ -324,18 +357,60 @@ type printer struct {
 	depth      int
 	indentedIn bool
 	putNewline bool
-	attrNS     map[string]string // map prefix -> name space
-	attrPrefix map[string]string // map name space -> prefix
-	prefixes   []string
+	ns         []nsBinding // name spaces in scope, innermost last
+	nsMarks    []int       // len(ns) at the start of each open element
+	pending    []nsBinding // declared for the next start element
 	tags       []Name
+	tagPrefix  []string // prefix written for each open element
 	closed     bool
 	err        error
 }
 
+// An nsBinding binds a prefix to a name space.
+type nsBinding struct {
+	prefix   string // or "" for the default name space
+	url      string
+	declared bool // by Encoder.DeclarePrefix or an attribute
+}
+
+// lookupNS returns the innermost binding of prefix in scope.
+func (p *printer) lookupNS(prefix string) nsBinding {
+	for i := len(p.ns) - 1; i >= 0; i-- {
+		if p.ns[i].prefix == prefix {
+			return p.ns[i]
+		}
+	}
+	return nsBinding{}
+}
+
+// lookupPrefix returns the innermost prefix in scope bound to url,
+// considering only declared prefixes if declared is set.
+func (p *printer) lookupPrefix(url string, declared bool) string {
+	for i := len(p.ns) - 1; i >= 0; i-- {
+		b := p.ns[i]
+		if b.prefix != "" && b.url == url && (b.declared || !declared) && p.lookupNS(b.prefix).url == url {
+			return b.prefix
+		}
+	}
+	return ""
+}
+
+// declaredPrefix returns the prefix declared by the attribute name,
+// written by the Decoder as xmlns:prefix, if it is such a declaration.
+func declaredPrefix(name Name) (string, bool) {
+	if name.Space == xmlnsPrefix {
+		return name.Local, true
+	}
+	if name.Space == "" {
+		return strings.CutPrefix(name.Local, xmlnsPrefix+":")
+	}
+	return "", false
+}
+
 // createAttrPrefix finds the name space prefix attribute to use for the given name space,
 // defining a new prefix if necessary. It returns the prefix.
 func (p *printer) createAttrPrefix(url string) string {
-	if prefix := p.attrPrefix[url]; prefix != "" {
+	if prefix := p.lookupPrefix(url, false); prefix != "" {
 		return prefix
 	}
 
@@ -348,11 +423,20 @@ func (p *printer) createAttrPrefix(url string) string {
 	}
 
 	// Need to define a new name space.
-	if p.attrPrefix == nil {
-		p.attrPrefix = make(map[string]string)
-		p.attrNS = make(map[string]string)
-	}
+	prefix := p.newPrefix(url)
+	p.ns = append(p.ns, nsBinding{prefix: prefix, url: url})
 
+	p.WriteString(`xmlns:`)
+	p.WriteString(prefix)
+	p.WriteString(`="`)
+	EscapeText(p, []byte(url))
+	p.WriteString(`" `)
+
+	return prefix
+}
+
+// newPrefix returns a prefix for url that is not bound in scope.
+func (p *printer) newPrefix(url string) string {
 	// Pick a name. We try to use the final element of the path
 	// but fall back to _.
 	prefix := strings.TrimRight(url, "/")
@@ -369,48 +453,26 @@ func (p *printer) createAttrPrefix(url string) string {
 	if len(prefix) >= 3 && strings.EqualFold(prefix[:3], "xml") {
 		prefix = "_" + prefix
 	}
-	if p.attrNS[prefix] != "" {
+	if p.lookupNS(prefix).url != "" {
 		// Name is taken. Find a better one.
 		for p.seq++; ; p.seq++ {
-			if id := prefix + "_" + strconv.Itoa(p.seq); p.attrNS[id] == "" {
+			if id := prefix + "_" + strconv.Itoa(p.seq); p.lookupNS(id).url == "" {
 				prefix = id
 				break
 			}
 		}
 	}
-
-	p.attrPrefix[url] = prefix
-	p.attrNS[prefix] = url
-
-	p.WriteString(`xmlns:`)
-	p.WriteString(prefix)
-	p.WriteString(`="`)
-	EscapeText(p, []byte(url))
-	p.WriteString(`" `)
-
-	p.prefixes = append(p.prefixes, prefix)
-
 	return prefix
 }
 
-// deleteAttrPrefix removes an attribute name space prefix.
-func (p *printer) deleteAttrPrefix(prefix string) {
-	delete(p.attrPrefix, p.attrNS[prefix])
-	delete(p.attrNS, prefix)
-}
-
 func (p *printer) markPrefix() {
-	p.prefixes = append(p.prefixes, "")
+	p.nsMarks = append(p.nsMarks, len(p.ns))
 }
 
 func (p *printer) popPrefix() {
-	for len(p.prefixes) > 0 {
-		prefix := p.prefixes[len(p.prefixes)-1]
-		p.prefixes = p.prefixes[:len(p.prefixes)-1]
-		if prefix == "" {
-			break
-		}
-		p.deleteAttrPrefix(prefix)
+	if n := len(p.nsMarks); n > 0 {
+		p.ns = p.ns[:p.nsMarks[n-1]]
+		p.nsMarks = p.nsMarks[:n-1]
 	}
 }
 
@@ -721,18 +783,79 @@ func (p *printer) writeStart(start *StartElement) error {
 		return fmt.Errorf("xml: start tag with no name")
 	}
 
-	p.tags = append(p.tags, start.Name)
+	// Bind the name spaces declared for the element first,
+	// as they apply to its own name and attributes.
 	p.markPrefix()
+	decls := p.pending
+	p.pending = nil
+	for i := 0; i < len(decls); i++ {
+		if b := p.lookupNS(decls[i].prefix); b.declared && b.url == decls[i].url || decls[i].prefix == xmlPrefix {
+			// Already in scope.
+			decls = append(decls[:i], decls[i+1:]...)
+			i--
+			continue
+		}
+		p.ns = append(p.ns, decls[i])
+	}
+	ownDefault := false
+	for _, attr := range start.Attr {
+		if prefix, ok := declaredPrefix(attr.Name); ok && attr.Value != "" {
+			if err := checkPrefix(prefix, attr.Value); err != nil {
+				p.popPrefix()
+				return err
+			}
+			p.ns = append(p.ns, nsBinding{prefix, attr.Value, true})
+		} else if attr.Name.Space == "" && attr.Name.Local == xmlnsPrefix {
+			p.ns = append(p.ns, nsBinding{"", attr.Value, true})
+			ownDefault = true
+		}
+	}
+
+	// Choose how to write the element's name space: by the default
+	// name space already declared, by a declared prefix, or by a new
+	// default name space declaration. If the element declares another
+	// default name space in its attributes, a new prefix is needed.
+	var prefix string
+	writeDefault := false
+	if space := start.Name.Space; space != "" {
+		if def := p.lookupNS(""); def.declared && def.url == space {
+			// Nothing to write.
+		} else if prefix = p.lookupPrefix(space, true); prefix != "" {
+			// Use the declared prefix.
+		} else if ownDefault {
+			prefix = p.newPrefix(space)
+			b := nsBinding{prefix: prefix, url: space}
+			p.ns = append(p.ns, b)
+			decls = append(decls, b)
+		} else {
+			writeDefault = true
+			p.ns = append(p.ns, nsBinding{url: space})
+		}
+	}
+
+	p.tags = append(p.tags, start.Name)
+	p.tagPrefix = append(p.tagPrefix, prefix)
 
 	p.writeIndent(1)
 	p.WriteByte('<')
+	if prefix != "" {
+		p.WriteString(prefix)
+		p.WriteByte(':')
+	}
 	p.WriteString(start.Name.Local)
 
-	if start.Name.Space != "" {
+	if writeDefault {
 		p.WriteString(` xmlns="`)
 		p.EscapeString(start.Name.Space)
 		p.WriteByte('"')
 	}
+	for _, b := range decls {
+		p.WriteString(` xmlns:`)
+		p.WriteString(b.prefix)
+		p.WriteString(`="`)
+		p.EscapeString(b.url)
+		p.WriteByte('"')
+	}
 
 	// Attributes
 	for _, attr := range start.Attr {
@@ -740,6 +863,21 @@ func (p *printer) writeStart(start *StartElement) error {
 		if name.Local == "" {
 			continue
 		}
+		if prefix, ok := declaredPrefix(name); ok {
+			if attr.Value == "" || prefix == xmlPrefix {
+				// Undeclaring a prefix is not allowed, and
+				// the xml prefix is always declared.
+				continue
+			}
+			p.WriteByte(' ')
+			p.WriteString(xmlnsPrefix)
+			p.WriteByte(':')
+			p.WriteString(prefix)
+			p.WriteString(`="`)
+			p.EscapeString(attr.Value)
+			p.WriteByte('"')
+			continue
+		}
 		p.WriteByte(' ')
 		if name.Space != "" {
 			p.WriteString(p.createAttrPrefix(name.Space))
@@ -768,10 +906,16 @@ func (p *printer) writeEnd(name Name) error {
 		return fmt.Errorf("xml: end tag </%s> in namespace %s does not match start tag <%s> in namespace %s", name.Local, name.Space, top.Local, top.Space)
 	}
 	p.tags = p.tags[:len(p.tags)-1]
+	prefix := p.tagPrefix[len(p.tagPrefix)-1]
+	p.tagPrefix = p.tagPrefix[:len(p.tagPrefix)-1]
 
 	p.writeIndent(-1)
 	p.WriteByte('<')
 	p.WriteByte('/')
+	if prefix != "" {
+		p.WriteString(prefix)
+		p.WriteByte(':')
+	}
 	p.WriteString(name.Local)
 	p.WriteByte('>')
 	p.popPrefix()
@@ -0,0 +1,287 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"errors"
+	"io"
+	"iter"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A Path is a compiled path expression selecting elements, written in
+// a subset of XPath 1.0 that can be evaluated while reading a document
+// once from start to end.
+//
+// A path is a sequence of steps separated by / or //, optionally with
+// a leading / or //. A step separated by / selects the children of the
+// elements selected by the previous step, and a step separated by //
+// selects their descendants. A step is a name test, which is a local
+// name or * to match any element, followed by zero or more predicates:
+//
+//	[n]           the nth element, counting from 1, among the elements
+//	              with the same parent that pass the test so far
+//	[@attr]       elements with the attribute attr
+//	[@attr='v']   elements with the attribute attr equal to v
+//	[@attr!='v']  elements without the attribute attr equal to v
+//
+// Values may be quoted with ' or ". Names match the local part of
+// element and attribute names in any name space. For example,
+//
+//	/feed/entry[@type='post']//link[1]
+//
+// selects the first link in each element that contains links, within
+// each entry element of type post in the feed.
+type Path struct {
+	expr   string
+	steps  []pathStep
+	counts int // number of positional predicates
+}
+
+type pathStep struct {
+	descendant bool
+	local      string // or "*"
+	preds      []pathPred
+}
+
+type pathPred struct {
+	pos   int    // position, if a positional predicate
+	slot  int    // index of the position count in a pathFrame
+	attr  string // attribute name, if an attribute predicate
+	op    string // "", "=" or "!="
+	value string
+}
+
+// CompilePath parses a path expression and returns, if successful,
+// a [Path] that can be used to select elements from a [Decoder].
+func CompilePath(expr string) (*Path, error) {
+	p := &Path{expr: expr}
+	s := expr
+	for i := 0; ; i++ {
+		var step pathStep
+		switch {
+		case strings.HasPrefix(s, "//"):
+			step.descendant = true
+			s = s[2:]
+		case strings.HasPrefix(s, "/"):
+			s = s[1:]
+		case i > 0:
+			return nil, p.error(s, "expected / or //")
+		}
+		step.local, s = pathName(s)
+		if step.local == "" && strings.HasPrefix(s, "*") {
+			step.local, s = "*", s[1:]
+		}
+		if step.local == "" {
+			return nil, p.error(s, "expected name or *")
+		}
+		for strings.HasPrefix(s, "[") {
+			var pred pathPred
+			var err error
+			if pred, s, err = p.parsePred(s[1:]); err != nil {
+				return nil, err
+			}
+			step.preds = append(step.preds, pred)
+		}
+		p.steps = append(p.steps, step)
+		if s == "" {
+			return p, nil
+		}
+	}
+}
+
+// MustCompilePath is like [CompilePath] but panics if the expression
+// cannot be parsed.
+func MustCompilePath(expr string) *Path {
+	p, err := CompilePath(expr)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String returns the source text used to compile the path.
+func (p *Path) String() string {
+	return p.expr
+}
+
+// parsePred parses the predicate at the start of s, after its [,
+// and returns it and the rest of s.
+func (p *Path) parsePred(s string) (pathPred, string, error) {
+	var pred pathPred
+	if strings.HasPrefix(s, "@") {
+		pred.attr, s = pathName(s[1:])
+		if pred.attr == "" {
+			return pred, s, p.error(s, "expected attribute name")
+		}
+		if strings.HasPrefix(s, "=") || strings.HasPrefix(s, "!=") {
+			pred.op, s, _ = strings.Cut(s, "=")
+			pred.op += "="
+			if s == "" || s[0] != '\'' && s[0] != '"' {
+				return pred, s, p.error(s, "expected quoted value")
+			}
+			end := strings.IndexByte(s[1:], s[0])
+			if end < 0 {
+				return pred, s, p.error(s, "unterminated value")
+			}
+			pred.value, s = s[1:1+end], s[2+end:]
+		}
+	} else {
+		n := 0
+		for n < len(s) && '0' <= s[n] && s[n] <= '9' {
+			n++
+		}
+		pos, err := strconv.Atoi(s[:n])
+		if err != nil || pos < 1 {
+			return pred, s, p.error(s, "expected @attribute or position")
+		}
+		pred.pos, pred.slot, s = pos, p.counts, s[n:]
+		p.counts++
+	}
+	if !strings.HasPrefix(s, "]") {
+		return pred, s, p.error(s, "expected ]")
+	}
+	return pred, s[1:], nil
+}
+
+// pathName returns the name at the start of s and the rest of s.
+func pathName(s string) (string, string) {
+	n := 0
+	for n < len(s) && (isNameByte(s[n]) || s[n] >= 0x80) && s[n] != ':' {
+		n++
+	}
+	if n == 0 || !isNameString(s[:n]) {
+		return "", s
+	}
+	return s[:n], s[n:]
+}
+
+func (p *Path) error(rest, msg string) error {
+	return errors.New("xml: invalid path " + strconv.Quote(p.expr) + " at offset " +
+		strconv.Itoa(len(p.expr)-len(rest)) + ": " + msg)
+}
+
+// match reports whether start passes the test of the step, counting
+// positions in counts.
+func (step *pathStep) match(start *StartElement, counts []int) bool {
+	if step.local != "*" && step.local != start.Name.Local {
+		return false
+	}
+	for _, pred := range step.preds {
+		if pred.pos > 0 {
+			counts[pred.slot]++
+			if counts[pred.slot] != pred.pos {
+				return false
+			}
+			continue
+		}
+		i := slices.IndexFunc(start.Attr, func(a Attr) bool { return a.Name.Local == pred.attr })
+		switch pred.op {
+		case "":
+			if i < 0 {
+				return false
+			}
+		case "=":
+			if i < 0 || start.Attr[i].Value != pred.value {
+				return false
+			}
+		case "!=":
+			if i >= 0 && start.Attr[i].Value == pred.value {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// A pathFrame records the evaluation of a path within an open element.
+type pathFrame struct {
+	active []int // indexes of the steps to test the children against
+	counts []int // position counts of the children, by predicate slot
+}
+
+// Select returns an iterator over the elements matching p, read with
+// [Decoder.Token] from the decoder's current position. The path is
+// relative to that position: at the start of the input, the path
+// /feed/entry selects entry elements within a feed root element.
+// Select reads up to the end of the input, or through the end of
+// the element containing the decoder's position.
+//
+// Within the body of the loop, the caller may consume a selected
+// element, for example by passing it to [Decoder.DecodeElement] or
+// by calling [Decoder.Skip], or leave it to Select, which then also
+// selects matching elements within it. Subtrees that cannot contain
+// a match are skipped without being examined further.
+//
+// If reading fails, the iterator yields the error and stops.
+func (d *Decoder) Select(p *Path) iter.Seq2[StartElement, error] {
+	return func(yield func(StartElement, error) bool) {
+		frames := []pathFrame{{active: []int{0}, counts: make([]int, p.counts)}}
+		for {
+			tok, err := d.Token()
+			if err != nil {
+				if err != io.EOF {
+					yield(StartElement{}, err)
+				}
+				return
+			}
+			switch tok := tok.(type) {
+			case EndElement:
+				if len(frames) == 1 {
+					return
+				}
+				frames = frames[:len(frames)-1]
+			case StartElement:
+				parent := &frames[len(frames)-1]
+				var active []int
+				matched := false
+				for _, i := range parent.active {
+					step := &p.steps[i]
+					if step.descendant && !slices.Contains(active, i) {
+						active = append(active, i)
+					}
+					if step.match(&tok, parent.counts) {
+						if i == len(p.steps)-1 {
+							matched = true
+						} else if !slices.Contains(active, i+1) {
+							active = append(active, i+1)
+						}
+					}
+				}
+				if matched {
+					depth := d.depth()
+					if !yield(tok, nil) {
+						return
+					}
+					if d.depth() < depth {
+						// The caller consumed the element.
+						continue
+					}
+				}
+				if len(active) == 0 {
+					if err := d.Skip(); err != nil {
+						yield(StartElement{}, err)
+						return
+					}
+					continue
+				}
+				frames = append(frames, pathFrame{active: active, counts: make([]int, p.counts)})
+			}
+		}
+	}
+}
+
+// depth returns the number of elements open in the decoder.
+func (d *Decoder) depth() int {
+	n := 0
+	for s := d.stk; s != nil; s = s.next {
+		if s.kind == stkStart {
+			n++
+		}
+	}
+	return n
+}
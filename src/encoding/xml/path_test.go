@@ -0,0 +1,153 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const pathFeed = `<?xml version="1.0"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Feed</title>
+  <entry type="post" id="1">
+    <title>One</title>
+    <link href="a"/>
+    <content><link href="b"/><link href="c"/></content>
+  </entry>
+  <entry type="page" id="2">
+    <title>Two</title>
+    <link href="d"/>
+  </entry>
+  <entry id="3">
+    <title>Three</title>
+    <entry id="3.1"><title>Nested</title></entry>
+  </entry>
+</feed>`
+
+var selectTests = []struct {
+	path string
+	want []string // id, href or title attribute of each match
+}{
+	{"/feed/entry", []string{"1", "2", "3"}},
+	{"feed/entry", []string{"1", "2", "3"}},
+	{"//entry", []string{"1", "2", "3", "3.1"}},
+	{"/feed/entry[2]", []string{"2"}},
+	{"//entry[1]", []string{"1", "3.1"}},
+	{"/feed/entry[@type]", []string{"1", "2"}},
+	{"/feed/entry[@type='page']", []string{"2"}},
+	{`/feed/entry[@type!="page"]`, []string{"1", "3"}},
+	{"/feed/entry[@type][2]", []string{"2"}},
+	{"/feed/entry[2][@type='post']", nil},
+	{"/feed/*/link", []string{"a", "d"}},
+	{"/feed/entry//link", []string{"a", "b", "c", "d"}},
+	{"//content/link[2]", []string{"c"}},
+	{"//link[1]", []string{"a", "b", "d"}},
+	{"/entry", nil},
+	{"/feed/entry/entry", []string{"3.1"}},
+	{"//entry//entry", []string{"3.1"}},
+}
+
+func TestSelect(t *testing.T) {
+	for _, tt := range selectTests {
+		p, err := CompilePath(tt.path)
+		if err != nil {
+			t.Errorf("CompilePath(%q): %v", tt.path, err)
+			continue
+		}
+		var got []string
+		for start, err := range NewDecoder(strings.NewReader(pathFeed)).Select(p) {
+			if err != nil {
+				t.Fatalf("%s: %v", tt.path, err)
+			}
+			for _, a := range start.Attr {
+				if a.Name.Local == "id" || a.Name.Local == "href" {
+					got = append(got, a.Value)
+				}
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Select(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSelectDecodeElement(t *testing.T) {
+	type Entry struct {
+		ID    string `xml:"id,attr"`
+		Title string `xml:"title"`
+	}
+	d := NewDecoder(strings.NewReader(pathFeed))
+	var got []Entry
+	for start, err := range d.Select(MustCompilePath("//entry")) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		var e Entry
+		if err := d.DecodeElement(&e, &start); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, e)
+	}
+	// The nested entry was consumed with its parent.
+	want := []Entry{{"1", "One"}, {"2", "Two"}, {"3", "Three"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Select within the current element stops at its end.
+	d = NewDecoder(strings.NewReader(`<a><b><c/></b><c/></a>`))
+	d.Token()
+	d.Token()
+	n := 0
+	for _, err := range d.Select(MustCompilePath("c")) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != 1 {
+		t.Errorf("Select within <b> matched %d elements, want 1", n)
+	}
+	if tok, err := d.Token(); err != nil || tok.(StartElement).Name.Local != "c" {
+		t.Errorf("Token after Select = %v, %v, want <c>", tok, err)
+	}
+}
+
+func TestSelectError(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`<a><b></a>`))
+	var err error
+	for _, err = range d.Select(MustCompilePath("//x")) {
+	}
+	if _, ok := err.(*SyntaxError); !ok {
+		t.Errorf("Select error = %v, want *SyntaxError", err)
+	}
+}
+
+func TestCompilePathError(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"/",
+		"a/",
+		"a//",
+		"///a",
+		"a[",
+		"a[]",
+		"a[0]",
+		"a[x]",
+		"a[@]",
+		"a[@x=1]",
+		"a[@x='1]",
+		"a[@x='1'",
+		"a b",
+		"x:a",
+		"a/@b",
+	} {
+		if _, err := CompilePath(expr); err == nil {
+			t.Errorf("CompilePath(%q) succeeded", expr)
+		}
+	}
+}
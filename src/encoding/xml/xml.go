@@ -213,6 +213,12 @@ type Decoder struct {
 	linestart      int64
 	offset         int64
 	unmarshalDepth int
+
+	// normalizeAttrs causes white space characters in attribute values,
+	// other than character references, to be replaced with spaces,
+	// as XML requires. It is only set by Canonicalize, since the
+	// Decoder has always returned attribute values as written.
+	normalizeAttrs bool
 }
 
 // NewDecoder creates a new XML parser reading from r.
@@ -1118,11 +1124,14 @@ Input:
 			return nil
 		}
 
-		// We must rewrite unescaped \r and \r\n into \n.
-		if b == '\r' {
-			d.buf.WriteByte('\n')
-		} else if b1 == '\r' && b == '\n' {
+		// We must rewrite unescaped \r and \r\n into \n, or into a
+		// space in attribute values that are normalized.
+		if b1 == '\r' && b == '\n' {
 			// Skip \r\n--we already wrote \n.
+		} else if quote >= 0 && d.normalizeAttrs && (b == '\t' || b == '\n' || b == '\r') {
+			d.buf.WriteByte(' ')
+		} else if b == '\r' {
+			d.buf.WriteByte('\n')
 		} else {
 			d.buf.WriteByte(b)
 		}
@@ -255,7 +255,7 @@ var depsRules = `
 	# compression
 	FMT, encoding/binary, hash/adler32, hash/crc32
 	< compress/bzip2, compress/flate, compress/lzw, internal/zstd
-	< archive/zip, compress/gzip, compress/zlib;
+	< compress/gzip, compress/zlib, compress/zstd;
 
 	# templates
 	FMT
@@ -362,7 +362,12 @@ var depsRules = `
 	< plugin;
 
 	CGO, FMT
-	< os/user
+	< os/user;
+
+	FMT
+	< archive/internal/extract;
+
+	os/user, encoding/binary, archive/internal/extract
 	< archive/tar;
 
 	sync
@@ -461,11 +466,14 @@ var depsRules = `
 	crypto/boring, crypto/internal/edwards25519/field
 	< crypto/ecdh;
 
+	crypto/hmac < internal/pbkdf2;
+
 	crypto/aes,
 	crypto/des,
 	crypto/ecdh,
 	crypto/hmac,
 	crypto/internal/edwards25519,
+	internal/pbkdf2,
 	crypto/md5,
 	crypto/rc4,
 	crypto/sha1,
@@ -480,7 +488,8 @@ var depsRules = `
 	CRYPTO, FMT, math/big
 	< crypto/internal/boring/bbig
 	< crypto/rand
-	< crypto/internal/mlkem768
+	< crypto/internal/mlkem
+	< crypto/mlkem
 	< crypto/ed25519
 	< encoding/asn1
 	< golang.org/x/crypto/cryptobyte/asn1
@@ -492,6 +501,11 @@ var depsRules = `
 
 	CGO, net !< CRYPTO-MATH;
 
+	# archive/zip uses crypto for WinZip AES encryption.
+	crypto/rand, internal/pbkdf2,
+	archive/internal/extract, compress/flate, hash/crc32
+	< archive/zip;
+
 	# TLS, Prince of Dependencies.
 	CRYPTO-MATH, NET, container/list, encoding/hex, encoding/pem
 	< golang.org/x/crypto/internal/alias
@@ -500,6 +514,7 @@ var depsRules = `
 	< golang.org/x/crypto/internal/poly1305
 	< golang.org/x/crypto/chacha20poly1305
 	< golang.org/x/crypto/hkdf
+	< crypto/hpke
 	< crypto/x509/internal/macos
 	< crypto/x509/pkix;
 
@@ -541,6 +556,7 @@ var depsRules = `
 	< net/http/httptrace;
 
 	compress/gzip,
+	compress/zstd,
 	golang.org/x/net/http/httpguts,
 	golang.org/x/net/http/httpproxy,
 	golang.org/x/net/http2/hpack,
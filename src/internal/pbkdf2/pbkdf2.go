@@ -0,0 +1,39 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package pbkdf2 implements the PBKDF2 key derivation function of RFC 8018,
+// for the packages of the standard library that need it.
+package pbkdf2
+
+import (
+	"crypto/hmac"
+	"crypto/subtle"
+	"hash"
+	"internal/byteorder"
+)
+
+// Key derives a key of length keyLen from password and salt, with iter
+// iterations of HMAC with the hash function h.
+//
+// The caller is responsible for bounding iter when it comes from untrusted
+// input, since the cost of Key is proportional to it.
+func Key(h func() hash.Hash, password, salt []byte, iter, keyLen int) []byte {
+	prf := hmac.New(h, password)
+	var dk, u, t []byte
+	for block := uint32(1); len(dk) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(byteorder.BeAppendUint32(nil, block))
+		u = prf.Sum(u[:0])
+		t = append(t[:0], u...)
+		for i := 1; i < iter; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			subtle.XORBytes(t, t, u)
+		}
+		dk = append(dk, t...)
+	}
+	return dk[:keyLen]
+}
@@ -0,0 +1,60 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unix
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// Flistxattr lists the names of the extended attributes of the file fd
+// into dest, as a sequence of NUL-terminated strings.
+// If dest is empty, it returns the size needed.
+func Flistxattr(fd int, dest []byte) (int, error) {
+	var p unsafe.Pointer
+	if len(dest) > 0 {
+		p = unsafe.Pointer(&dest[0])
+	}
+	r1, _, errno := syscall.Syscall(syscall.SYS_FLISTXATTR, uintptr(fd), uintptr(p), uintptr(len(dest)))
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(r1), nil
+}
+
+// Fgetxattr reads the value of the extended attribute attr of the file fd
+// into dest. If dest is empty, it returns the size needed.
+func Fgetxattr(fd int, attr string, dest []byte) (int, error) {
+	a, err := syscall.BytePtrFromString(attr)
+	if err != nil {
+		return 0, err
+	}
+	var p unsafe.Pointer
+	if len(dest) > 0 {
+		p = unsafe.Pointer(&dest[0])
+	}
+	r1, _, errno := syscall.Syscall6(syscall.SYS_FGETXATTR, uintptr(fd), uintptr(unsafe.Pointer(a)), uintptr(p), uintptr(len(dest)), 0, 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(r1), nil
+}
+
+// Fsetxattr sets the extended attribute attr of the file fd to data.
+func Fsetxattr(fd int, attr string, data []byte, flags int) error {
+	a, err := syscall.BytePtrFromString(attr)
+	if err != nil {
+		return err
+	}
+	var p unsafe.Pointer
+	if len(data) > 0 {
+		p = unsafe.Pointer(&data[0])
+	}
+	_, _, errno := syscall.Syscall6(syscall.SYS_FSETXATTR, uintptr(fd), uintptr(unsafe.Pointer(a)), uintptr(p), uintptr(len(data)), uintptr(flags), 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
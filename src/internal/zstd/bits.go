@@ -128,3 +128,34 @@ func (rbr *reverseBitReader) fetch(b uint8) bool {
 func (rbr *reverseBitReader) makeError(msg string) error {
 	return rbr.r.makeError(int(rbr.off), msg)
 }
+
+// bitWriter writes a bit stream that is read by a reverseBitReader.
+// Values are read back in the reverse of the order they were written.
+type bitWriter struct {
+	out  []byte // the completed bytes
+	bits uint64 // bits not yet written to out
+	cnt  uint   // number of valid bits in the bits field
+}
+
+// add writes the low b bits of v.
+func (bw *bitWriter) add(v uint32, b uint8) {
+	bw.bits |= (uint64(v) & (1<<b - 1)) << bw.cnt
+	bw.cnt += uint(b)
+	for bw.cnt >= 8 {
+		bw.out = append(bw.out, byte(bw.bits))
+		bw.bits >>= 8
+		bw.cnt -= 8
+	}
+}
+
+// close writes the final 1 bit that marks the start of the stream
+// for a reverseBitReader, and returns the completed stream.
+func (bw *bitWriter) close() []byte {
+	bw.add(1, 1)
+	if bw.cnt > 0 {
+		bw.out = append(bw.out, byte(bw.bits))
+	}
+	bw.bits = 0
+	bw.cnt = 0
+	return bw.out
+}
@@ -0,0 +1,145 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// dictMagic is the magic number at the start of a formatted dictionary.
+// RFC 5.
+const dictMagic = 0xec30a437
+
+// A Dict is a zstd dictionary, described in RFC 8878 section 5.
+// A Dict may be shared by any number of Readers and Writers.
+type Dict struct {
+	// The Dictionary_ID, or 0 for a raw content dictionary.
+	id uint32
+
+	// The Huffman table for literals, if any.
+	huffmanTable     []uint16
+	huffmanTableBits int
+
+	// The FSE tables for sequences, if any.
+	seqTables    [3][]fseBaselineEntry
+	seqTableBits [3]uint8
+
+	// The initial repeated offsets.
+	repeatedOffsets [3]uint32
+
+	// The content used as history before the first block of a frame.
+	content []byte
+}
+
+// ParseDict parses a zstd dictionary.
+// If data does not start with the dictionary magic number,
+// it is treated as a raw content dictionary with an ID of 0.
+// The Dict refers to data, which must not be modified
+// while the Dict is in use.
+func ParseDict(data []byte) (*Dict, error) {
+	d := &Dict{
+		repeatedOffsets: [3]uint32{1, 4, 8},
+	}
+	if len(data) < 4 || binary.LittleEndian.Uint32(data) != dictMagic {
+		d.content = data
+		return d, nil
+	}
+	if len(data) < 8 {
+		return nil, errors.New("zstd: dictionary too short")
+	}
+	d.id = binary.LittleEndian.Uint32(data[4:])
+	if d.id == 0 {
+		return nil, errors.New("zstd: invalid zero dictionary ID")
+	}
+
+	// Use a Reader for the table parsing code and its
+	// error reporting, which will record offsets into data.
+	var r Reader
+
+	// Entropy_Tables. RFC 5.
+	off := 8
+	d.huffmanTable = make([]uint16, 1<<maxHuffmanBits)
+	tableBits, off, err := r.readHuff(block(data), off, d.huffmanTable)
+	if err != nil {
+		return nil, err
+	}
+	d.huffmanTableBits = tableBits
+
+	// The FSE tables appear in the order offsets,
+	// match lengths, literal lengths.
+	for _, kind := range [...]seqCode{seqOffset, seqMatch, seqLiteral} {
+		info := &seqCodeInfo[kind]
+		fseTable := make([]fseEntry, 1<<info.maxBits)
+		tableBits, roff, err := r.readFSE(block(data), off, info.maxSym, info.maxBits, fseTable)
+		if err != nil {
+			return nil, err
+		}
+		fseTable = fseTable[:1<<tableBits]
+		baselineTable := make([]fseBaselineEntry, len(fseTable))
+		if err := info.toBaseline(&r, roff, fseTable, baselineTable); err != nil {
+			return nil, err
+		}
+		d.seqTables[kind] = baselineTable
+		d.seqTableBits[kind] = uint8(tableBits)
+		off = roff
+	}
+
+	if len(data)-off < 12 {
+		return nil, r.makeEOFError(off)
+	}
+	for i := range d.repeatedOffsets {
+		v := binary.LittleEndian.Uint32(data[off:])
+		if v == 0 {
+			return nil, r.makeError(off, "invalid zero repeat offset in dictionary")
+		}
+		d.repeatedOffsets[i] = v
+		off += 4
+	}
+
+	d.content = data[off:]
+	return d, nil
+}
+
+// ID returns the dictionary ID. This is 0 for a raw content dictionary.
+func (d *Dict) ID() uint32 {
+	return d.id
+}
+
+// SetDict sets the dictionary to use when decompressing frames.
+// The dictionary is used for every subsequent frame,
+// and is retained across calls to Reset.
+// A nil d removes any dictionary.
+func (r *Reader) SetDict(d *Dict) {
+	r.dict = d
+}
+
+// loadDict prepares the Reader to decompress a frame using r.dict.
+// This is called after the frame header has been read.
+func (r *Reader) loadDict(windowSize int) {
+	d := r.dict
+
+	// The dictionary content is always available for backreferences,
+	// regardless of the window size.
+	r.window.reset(windowSize + len(d.content))
+	r.window.save(d.content)
+
+	r.repeatedOffset1 = d.repeatedOffsets[0]
+	r.repeatedOffset2 = d.repeatedOffsets[1]
+	r.repeatedOffset3 = d.repeatedOffsets[2]
+
+	if d.huffmanTableBits > 0 {
+		// Copy the table, since a Compressed_Literals_Block
+		// will overwrite r.huffmanTable.
+		if len(r.huffmanTable) < 1<<maxHuffmanBits {
+			r.huffmanTable = make([]uint16, 1<<maxHuffmanBits)
+		}
+		copy(r.huffmanTable, d.huffmanTable)
+		r.huffmanTableBits = d.huffmanTableBits
+	}
+
+	r.seqTables = d.seqTables
+	r.seqTableBits = d.seqTableBits
+}
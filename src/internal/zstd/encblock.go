@@ -0,0 +1,530 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// This file contains the code to encode the contents of a compressed block.
+// We never use Repeat_Mode for tables, so each block can be decoded
+// without reference to the tables of earlier blocks.
+
+// A sequence is a single literal length, match offset, match length triple.
+// The offset is stored as an Offset_Value, so it may refer to one of
+// the repeated offsets. RFC 3.1.1.3.2.
+type sequence struct {
+	litLen   uint32
+	offset   uint32
+	matchLen uint32
+}
+
+// literalLengthCode returns the code, extra value, and number of
+// extra bits used to encode a literal length. RFC 3.1.1.3.2.1.1.
+func literalLengthCode(litLen uint32) (uint8, uint32, uint8) {
+	if litLen < literalLengthOffset {
+		return uint8(litLen), 0, 0
+	}
+	i := len(literalLengthBase) - 1
+	for literalLengthBase[i]&0xffffff > litLen {
+		i--
+	}
+	base := literalLengthBase[i]
+	return uint8(i + literalLengthOffset), litLen - base&0xffffff, uint8(base >> 24)
+}
+
+// matchLengthCode returns the code, extra value, and number of
+// extra bits used to encode a match length. RFC 3.1.1.3.2.1.1.
+func matchLengthCode(matchLen uint32) (uint8, uint32, uint8) {
+	if matchLen-3 < matchLengthOffset {
+		return uint8(matchLen - 3), 0, 0
+	}
+	i := len(matchLengthBase) - 1
+	for matchLengthBase[i]&0xffffff > matchLen {
+		i--
+	}
+	base := matchLengthBase[i]
+	return uint8(i + matchLengthOffset), matchLen - base&0xffffff, uint8(base >> 24)
+}
+
+// offsetCode returns the code and extra value used to encode an
+// Offset_Value. The number of extra bits is the same as the code.
+// RFC 3.1.1.3.2.1.1.
+func offsetCode(value uint32) (uint8, uint32) {
+	code := uint8(bits.Len32(value) - 1)
+	return code, value - 1<<code
+}
+
+// blockEncoder holds the scratch space used to encode a block.
+type blockEncoder struct {
+	seqs []sequence
+	lits []byte
+
+	codes []uint8 // literal length, offset, match length codes per sequence
+
+	seqTables [3]fseEncTable // tables for FSE_Compressed_Mode or RLE_Mode
+	norm      [256]int16     // scratch space for a distribution
+
+	huffCode [256]uint16
+	huffBits [256]uint8
+
+	bw [4]bitWriter
+}
+
+// encode appends the compressed block for the current
+// sequences and literals to dst, and returns the result.
+// RFC 3.1.1.3.
+func (e *blockEncoder) encode(dst []byte) []byte {
+	dst = e.encodeLiterals(dst)
+	return e.encodeSequences(dst)
+}
+
+// encodeLiterals appends the Literals_Section to dst.
+// RFC 3.1.1.3.1.
+func (e *blockEncoder) encodeLiterals(dst []byte) []byte {
+	lits := e.lits
+	if len(lits) == 0 {
+		return append(dst, 0)
+	}
+
+	var counts [256]uint32
+	for _, c := range lits {
+		counts[c]++
+	}
+	if counts[lits[0]] == uint32(len(lits)) {
+		dst = appendRawRLELiteralsHeader(dst, 1, len(lits))
+		return append(dst, lits[0])
+	}
+
+	// Compressing small literal sections doesn't pay for the table.
+	if len(lits) >= 64 {
+		start := len(dst)
+		if out, ok := e.appendHuffLiterals(dst, &counts); ok && len(out)-start < len(lits) {
+			return out
+		}
+		dst = dst[:start]
+	}
+
+	dst = appendRawRLELiteralsHeader(dst, 0, len(lits))
+	return append(dst, lits...)
+}
+
+// appendRawRLELiteralsHeader appends the header of a Raw_Literals_Block
+// (typ 0) or a RLE_Literals_Block (typ 1). RFC 3.1.1.3.1.1.
+func appendRawRLELiteralsHeader(dst []byte, typ byte, size int) []byte {
+	switch {
+	case size < 1<<5:
+		return append(dst, typ|byte(size)<<3)
+	case size < 1<<12:
+		return append(dst, typ|1<<2|byte(size)<<4, byte(size>>4))
+	default:
+		return append(dst, typ|3<<2|byte(size)<<4, byte(size>>4), byte(size>>12))
+	}
+}
+
+// appendHuffLiterals appends a Compressed_Literals_Block.
+// It reports false if the literals can't be encoded this way,
+// in which case the contents of dst beyond its length are undefined.
+// RFC 3.1.1.3.1.4.
+func (e *blockEncoder) appendHuffLiterals(dst []byte, counts *[256]uint32) ([]byte, bool) {
+	maxSym := 255
+	for counts[maxSym] == 0 {
+		maxSym--
+	}
+	// We only write the weights directly, which supports
+	// at most 128 weights, plus the implied final one.
+	if maxSym > 128 {
+		return dst, false
+	}
+
+	tableBits := huffmanLengths(counts[:maxSym+1], maxHuffmanBits, e.huffBits[:maxSym+1])
+
+	// Convert lengths to weights, and assign codes in the
+	// same order as readHuff. RFC 4.2.1.3.
+	var weights [256]uint8
+	var rank [maxHuffmanBits + 2]uint32
+	for sym, nb := range e.huffBits[:maxSym+1] {
+		if nb > 0 {
+			w := uint8(tableBits) + 1 - nb
+			weights[sym] = w
+			rank[w]++
+		}
+	}
+	next := uint32(0)
+	for w := 1; w <= tableBits; w++ {
+		cur := next
+		next += rank[w] << (w - 1)
+		rank[w] = cur
+	}
+	for sym, w := range weights[:maxSym+1] {
+		if w > 0 {
+			e.huffCode[sym] = uint16(rank[w] >> (w - 1))
+			rank[w] += 1 << (w - 1)
+		}
+	}
+
+	// Reserve space for the largest header; we move the data
+	// down later if the header turns out to be smaller.
+	const maxHeaderSize = 5
+	hdrPos := len(dst)
+	dst = append(dst, make([]byte, maxHeaderSize)...)
+	dataPos := len(dst)
+
+	// Huffman_Tree_Description using direct representation.
+	// The weight of the last symbol is implied. RFC 4.2.1.1.
+	dst = append(dst, byte(127+maxSym))
+	for i := 0; i < maxSym; i += 2 {
+		b := weights[i] << 4
+		if i+1 < maxSym {
+			b |= weights[i+1]
+		}
+		dst = append(dst, b)
+	}
+
+	lits := e.lits
+	streams := 1
+	if len(lits) > 1023 {
+		streams = 4
+	}
+	if streams == 1 {
+		dst = append(dst, e.huffStream(0, lits)...)
+	} else {
+		// RFC 3.1.1.3.1.6.
+		segment := (len(lits) + 3) / 4
+		jump := len(dst)
+		dst = append(dst, 0, 0, 0, 0, 0, 0)
+		for i := 0; i < 4; i++ {
+			end := (i + 1) * segment
+			if i == 3 {
+				end = len(lits)
+			}
+			stream := e.huffStream(i, lits[i*segment:end])
+			if i < 3 {
+				if len(stream) > 0xffff {
+					return dst, false
+				}
+				binary.LittleEndian.PutUint16(dst[jump+2*i:], uint16(len(stream)))
+			}
+			dst = append(dst, stream...)
+		}
+	}
+
+	size := len(lits)
+	compressedSize := len(dst) - dataPos
+	var hdr [maxHeaderSize]byte
+	var hdrLen int
+	switch {
+	case size < 1<<10 && compressedSize < 1<<10:
+		sf := byte(0)
+		if streams == 4 {
+			sf = 1
+		}
+		hdr[0] = 2 | sf<<2 | byte(size)<<4
+		hdr[1] = byte(size>>4)&0x3f | byte(compressedSize)<<6
+		hdr[2] = byte(compressedSize >> 2)
+		hdrLen = 3
+	case streams == 1:
+		return dst, false
+	case size < 1<<14 && compressedSize < 1<<14:
+		hdr[0] = 2 | 2<<2 | byte(size)<<4
+		hdr[1] = byte(size >> 4)
+		hdr[2] = byte(size>>12)&3 | byte(compressedSize)<<2
+		hdr[3] = byte(compressedSize >> 6)
+		hdrLen = 4
+	case size < 1<<18 && compressedSize < 1<<18:
+		hdr[0] = 2 | 3<<2 | byte(size)<<4
+		hdr[1] = byte(size >> 4)
+		hdr[2] = byte(size>>12)&0x3f | byte(compressedSize)<<6
+		hdr[3] = byte(compressedSize >> 2)
+		hdr[4] = byte(compressedSize >> 10)
+		hdrLen = 5
+	default:
+		return dst, false
+	}
+
+	copy(dst[hdrPos:], hdr[:hdrLen])
+	n := copy(dst[hdrPos+hdrLen:], dst[dataPos:])
+	return dst[:hdrPos+hdrLen+n], true
+}
+
+// huffStream returns a single Huffman-coded literals stream,
+// using e.bw[i] as scratch space.
+func (e *blockEncoder) huffStream(i int, lits []byte) []byte {
+	bw := &e.bw[i]
+	bw.out = bw.out[:0]
+	// The decoder reads the stream backward,
+	// so write the literals in reverse order.
+	for j := len(lits) - 1; j >= 0; j-- {
+		c := lits[j]
+		bw.add(uint32(e.huffCode[c]), e.huffBits[c])
+	}
+	return bw.close()
+}
+
+// huffmanLengths sets lengths[sym] to the length of the Huffman code
+// for each symbol with a non-zero count, limited to maxBits.
+// There must be at least two such symbols.
+// It returns the length of the longest code.
+func huffmanLengths(counts []uint32, maxBits int, lengths []uint8) int {
+	// leaves holds the symbols sorted by increasing count.
+	type node struct {
+		count  uint32
+		parent int32
+	}
+	var leaves []int
+	for sym, c := range counts {
+		lengths[sym] = 0
+		if c > 0 {
+			leaves = append(leaves, sym)
+		}
+	}
+	sort.SliceStable(leaves, func(i, j int) bool {
+		return counts[leaves[i]] < counts[leaves[j]]
+	})
+
+	// Build the tree using two queues: the sorted leaves at
+	// nodes[:n], and the internal nodes in order of creation
+	// at nodes[n:]. The root is the last node.
+	n := len(leaves)
+	nodes := make([]node, 2*n-1)
+	for i, sym := range leaves {
+		nodes[i].count = counts[sym]
+	}
+	nextLeaf, nextInternal, end := 0, n, n
+	pick := func() int {
+		if nextLeaf < n && (nextInternal == end || nodes[nextLeaf].count <= nodes[nextInternal].count) {
+			nextLeaf++
+			return nextLeaf - 1
+		}
+		nextInternal++
+		return nextInternal - 1
+	}
+	for end < len(nodes) {
+		a, b := pick(), pick()
+		nodes[end].count = nodes[a].count + nodes[b].count
+		nodes[a].parent = int32(end)
+		nodes[b].parent = int32(end)
+		end++
+	}
+
+	// Parents always follow their children, so we can compute
+	// depths walking backward from the root.
+	depth := make([]uint8, len(nodes))
+	maxLen := 0
+	for i := len(nodes) - 2; i >= 0; i-- {
+		depth[i] = depth[nodes[i].parent] + 1
+		if i < n && int(depth[i]) > maxLen {
+			maxLen = int(depth[i])
+		}
+	}
+	for i, sym := range leaves {
+		lengths[sym] = depth[i]
+	}
+	if maxLen <= maxBits {
+		return maxLen
+	}
+
+	// Limit the code lengths. Clamping the long codes leaves
+	// the Kraft sum too large, so lengthen the codes of the least
+	// frequent symbols until it fits, and then shorten the codes
+	// of the most frequent symbols while there is room.
+	// Each length only changes by one step at a time,
+	// so we always reach a complete code.
+	target := uint32(1) << maxBits
+	kraft := uint32(0)
+	for _, sym := range leaves {
+		if int(lengths[sym]) > maxBits {
+			lengths[sym] = uint8(maxBits)
+		}
+		kraft += 1 << (maxBits - int(lengths[sym]))
+	}
+	for kraft > target {
+		for _, sym := range leaves {
+			if int(lengths[sym]) < maxBits {
+				lengths[sym]++
+				kraft -= 1 << (maxBits - int(lengths[sym]))
+				if kraft <= target {
+					break
+				}
+			}
+		}
+	}
+	for kraft < target {
+		for i := n - 1; i >= 0; i-- {
+			sym := leaves[i]
+			add := uint32(1) << (maxBits - int(lengths[sym]))
+			if lengths[sym] > 1 && kraft+add <= target {
+				lengths[sym]--
+				kraft += add
+			}
+		}
+	}
+
+	maxLen = 0
+	for _, sym := range leaves {
+		if int(lengths[sym]) > maxLen {
+			maxLen = int(lengths[sym])
+		}
+	}
+	return maxLen
+}
+
+// encodeSequences appends the Sequences_Section to dst.
+// RFC 3.1.1.3.2.
+func (e *blockEncoder) encodeSequences(dst []byte) []byte {
+	seqs := e.seqs
+	n := len(seqs)
+	switch {
+	case n < 128:
+		dst = append(dst, byte(n))
+	case n < 0x7f00:
+		dst = append(dst, byte(n>>8)+128, byte(n))
+	default:
+		dst = append(dst, 255, byte(n-0x7f00), byte((n-0x7f00)>>8))
+	}
+	if n == 0 {
+		return dst
+	}
+
+	// Compute the codes for each sequence.
+	if cap(e.codes) < 3*n {
+		e.codes = make([]uint8, 3*n)
+	}
+	codes := e.codes[:3*n]
+	var counts [3][maxSeqSym + 1]uint32
+	for i := range seqs {
+		s := &seqs[i]
+		llCode, _, _ := literalLengthCode(s.litLen)
+		ofCode, _ := offsetCode(s.offset)
+		mlCode, _, _ := matchLengthCode(s.matchLen)
+		codes[3*i+int(seqLiteral)] = llCode
+		codes[3*i+int(seqOffset)] = ofCode
+		codes[3*i+int(seqMatch)] = mlCode
+		counts[seqLiteral][llCode]++
+		counts[seqOffset][ofCode]++
+		counts[seqMatch][mlCode]++
+	}
+
+	// Choose the table to use for each kind of code,
+	// and append the Symbol_Compression_Modes and the tables.
+	predefinedEncOnce.Do(initPredefinedEncTables)
+	modesPos := len(dst)
+	dst = append(dst, 0)
+	var tables [3]*fseEncTable
+	for _, kind := range [...]seqCode{seqLiteral, seqOffset, seqMatch} {
+		var mode byte
+		mode, tables[kind], dst = e.chooseSeqTable(dst, kind, counts[kind][:seqCodeInfo[kind].maxSym+1], n)
+		dst[modesPos] |= mode << (6 - 2*kind)
+	}
+
+	bw := &e.bw[0]
+	bw.out = dst
+
+	// update moves from the state for the next sequence to a state
+	// that decodes code, writing the bits the decoder needs to get back.
+	update := func(t *fseEncTable, state uint16, code uint8) uint16 {
+		s := t.state[code][state]
+		ent := &t.dec[s]
+		bw.add(uint32(state)-uint32(ent.base), ent.bits)
+		return s
+	}
+
+	// The decoder reads the sequences in order, so we encode
+	// them backward. The FSE states after the last sequence
+	// are arbitrary.
+	llTable, ofTable, mlTable := tables[seqLiteral], tables[seqOffset], tables[seqMatch]
+	var llState, ofState, mlState uint16
+	for i := n - 1; i >= 0; i-- {
+		s := &seqs[i]
+		llCode, llExtra, llBits := literalLengthCode(s.litLen)
+		mlCode, mlExtra, mlBits := matchLengthCode(s.matchLen)
+		ofCode, ofExtra := offsetCode(s.offset)
+
+		if i == n-1 {
+			llState = llTable.firstState(llCode, false)
+			ofState = ofTable.firstState(ofCode, false)
+			mlState = mlTable.firstState(mlCode, false)
+		} else {
+			// Reverse of the order in which execSeqs
+			// updates the states.
+			ofState = update(ofTable, ofState, ofCode)
+			mlState = update(mlTable, mlState, mlCode)
+			llState = update(llTable, llState, llCode)
+		}
+
+		// Reverse of the order in which execSeqs
+		// reads the extra bits.
+		bw.add(llExtra, llBits)
+		bw.add(mlExtra, mlBits)
+		bw.add(ofExtra, ofCode)
+	}
+
+	// Reverse of the order in which execSeqs reads the initial states.
+	bw.add(uint32(mlState), mlTable.tableBits)
+	bw.add(uint32(ofState), ofTable.tableBits)
+	bw.add(uint32(llState), llTable.tableBits)
+
+	dst = bw.close()
+	bw.out = nil
+	return dst
+}
+
+// maxSeqSym is the largest literal length, offset, or match length code.
+const maxSeqSym = 52
+
+// chooseSeqTable picks the cheapest way to encode the codes of one kind,
+// whose counts are in counts, and appends the table description, if any,
+// to dst. It returns the Compression_Mode and the table to use.
+// RFC 3.1.1.3.2.1.
+func (e *blockEncoder) chooseSeqTable(dst []byte, kind seqCode, counts []uint32, total int) (byte, *fseEncTable, []byte) {
+	info := &seqCodeInfo[kind]
+	t := &e.seqTables[kind]
+
+	distinct, last := 0, 0
+	for sym, c := range counts {
+		if c > 0 {
+			distinct++
+			last = sym
+		}
+	}
+	if distinct == 1 {
+		// RLE_Mode.
+		norm := e.norm[:last+1]
+		for i := range norm {
+			norm[i] = 0
+		}
+		norm[last] = 1
+		if err := t.init(norm, 0); err != nil {
+			panic("zstd: internal error: " + err.Error())
+		}
+		return 1, t, append(dst, byte(last))
+	}
+
+	predef := predefinedEncTables[kind]
+	predefCost := predef.cost(counts)
+
+	// Building a table for a few sequences is rarely worthwhile.
+	if total < 16 && predefCost != math.MaxInt {
+		return 0, predef, dst
+	}
+
+	tableBits := chooseTableBits(total, distinct, 5, info.maxBits)
+	norm := e.norm[:last+1]
+	normalizeCounts(counts[:last+1], tableBits, norm)
+	if err := t.init(norm, tableBits); err != nil {
+		panic("zstd: internal error: " + err.Error())
+	}
+	start := len(dst)
+	dst = appendFSETable(dst, norm, tableBits)
+	customCost := t.cost(counts) + 8*(len(dst)-start)
+	if predefCost <= customCost {
+		return 0, predef, dst[:start]
+	}
+	// FSE_Compressed_Mode.
+	return 2, t, dst
+}
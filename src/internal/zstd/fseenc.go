@@ -0,0 +1,276 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+)
+
+// fseEncTable is an FSE table used for encoding.
+type fseEncTable struct {
+	tableBits uint8
+	dec       []fseEntry // the decoding table
+
+	// state[sym][next] is a state that decodes to sym and
+	// then transitions to state next.
+	// It is nil for symbols that do not appear in the table.
+	state [][]uint16
+
+	// Backing store for state.
+	stateBuf []uint16
+}
+
+// init builds an encoding table from a distribution,
+// as produced by normalizeCounts or from the RFC.
+// The table is constructed the same way as the decoder does in buildFSE,
+// and reports an error in the same cases.
+func (t *fseEncTable) init(norm []int16, tableBits int) error {
+	tableSize := 1 << tableBits
+	if cap(t.dec) < tableSize {
+		t.dec = make([]fseEntry, tableSize)
+	}
+	t.dec = t.dec[:tableSize]
+	var r Reader
+	if err := r.buildFSE(0, norm, t.dec, tableBits); err != nil {
+		return err
+	}
+	t.tableBits = uint8(tableBits)
+
+	present := 0
+	for _, n := range norm {
+		if n != 0 {
+			present++
+		}
+	}
+	if cap(t.stateBuf) < present*tableSize {
+		t.stateBuf = make([]uint16, present*tableSize)
+	}
+	if cap(t.state) < len(norm) {
+		t.state = make([][]uint16, len(norm))
+	}
+	t.state = t.state[:len(norm)]
+	buf := t.stateBuf[:present*tableSize]
+	for sym, n := range norm {
+		if n == 0 {
+			t.state[sym] = nil
+			continue
+		}
+		t.state[sym] = buf[:tableSize:tableSize]
+		buf = buf[tableSize:]
+	}
+
+	for s, e := range t.dec {
+		next := t.state[e.sym][e.base : int(e.base)+1<<e.bits]
+		for i := range next {
+			next[i] = uint16(s)
+		}
+	}
+	return nil
+}
+
+// firstState returns a state from which to start encoding sym,
+// that is, the state that the decoder will be in when it
+// decodes the last symbol of the stream.
+// If needBits is true, the state is one that requires
+// at least one bit to move to the next state.
+func (t *fseEncTable) firstState(sym uint8, needBits bool) uint16 {
+	if needBits {
+		for s, e := range t.dec {
+			if e.sym == sym && e.bits > 0 {
+				return uint16(s)
+			}
+		}
+	}
+	return t.state[sym][0]
+}
+
+// cost returns an estimate of the number of bits needed to encode
+// symbols with the given counts using t.
+// It returns math.MaxInt if there is a count for a symbol not in t.
+func (t *fseEncTable) cost(counts []uint32) int {
+	var sum float64
+	for sym, c := range counts {
+		if c == 0 {
+			continue
+		}
+		if sym >= len(t.state) || t.state[sym] == nil {
+			return math.MaxInt
+		}
+		// The number of states for sym determines its probability.
+		n := 0
+		for _, e := range t.dec {
+			if e.sym == uint8(sym) {
+				n++
+			}
+		}
+		sum += float64(c) * (float64(t.tableBits) - math.Log2(float64(n)))
+	}
+	return int(sum)
+}
+
+var (
+	predefinedEncOnce   sync.Once
+	predefinedEncTables [3]*fseEncTable
+)
+
+// initPredefinedEncTables builds the encoding tables for the
+// predefined literal length, offset, and match length distributions.
+func initPredefinedEncTables() {
+	for kind, norm := range [3][]int16{
+		seqLiteral: literalPredefinedDistribution,
+		seqOffset:  offsetPredefinedDistribution,
+		seqMatch:   matchPredefinedDistribution,
+	} {
+		t := new(fseEncTable)
+		if err := t.init(norm, seqCodeInfo[kind].predefTableBits); err != nil {
+			panic("zstd: bad predefined distribution")
+		}
+		predefinedEncTables[kind] = t
+	}
+}
+
+// normalizeCounts converts symbol counts into a distribution
+// whose values sum to 1<<tableBits, storing it in norm.
+// Every symbol with a non-zero count gets a value of at least 1.
+// The number of symbols with non-zero counts must be less than 1<<tableBits.
+func normalizeCounts(counts []uint32, tableBits int, norm []int16) {
+	total := uint64(0)
+	for _, c := range counts {
+		total += uint64(c)
+	}
+	tableSize := 1 << tableBits
+	sum := 0
+	largest := 0
+	for sym, c := range counts {
+		if c == 0 {
+			norm[sym] = 0
+			continue
+		}
+		n := int((uint64(c)*uint64(tableSize) + total/2) / total)
+		if n < 1 {
+			n = 1
+		}
+		norm[sym] = int16(n)
+		sum += n
+		if c > counts[largest] {
+			largest = sym
+		}
+	}
+
+	// Give any excess to, or take any shortfall from,
+	// the most probable symbols.
+	if sum < tableSize {
+		norm[largest] += int16(tableSize - sum)
+	}
+	for sum > tableSize {
+		big := largest
+		for sym, n := range norm[:len(counts)] {
+			if n > norm[big] {
+				big = sym
+			}
+		}
+		take := sum - tableSize
+		if take > int(norm[big])/2 {
+			take = int(norm[big]) / 2
+		}
+		if take == 0 {
+			take = 1
+		}
+		norm[big] -= int16(take)
+		sum -= take
+	}
+}
+
+// chooseTableBits returns the accuracy log to use for an FSE table
+// that encodes total symbols, of which distinct are different,
+// subject to the limits on the table size.
+func chooseTableBits(total, distinct, minBits, maxBits int) int {
+	// There is no point in a table much larger than the input.
+	tableBits := bits.Len(uint(total))
+	if tableBits > maxBits {
+		tableBits = maxBits
+	}
+	for tableBits < maxBits && 1<<tableBits <= distinct {
+		tableBits++
+	}
+	if tableBits < minBits {
+		tableBits = minBits
+	}
+	return tableBits
+}
+
+// appendFSETable appends the description of the distribution norm
+// to dst, in the format read by readFSE. RFC 4.1.1.
+func appendFSETable(dst []byte, norm []int16, tableBits int) []byte {
+	var acc uint32
+	var cnt uint
+	put := func(v uint32, b uint) {
+		acc |= v << cnt
+		cnt += b
+		for cnt >= 8 {
+			dst = append(dst, byte(acc))
+			acc >>= 8
+			cnt -= 8
+		}
+	}
+
+	put(uint32(tableBits-5), 4)
+
+	remaining := (1 << tableBits) + 1
+	threshold := 1 << tableBits
+	bitsNeeded := tableBits + 1
+
+	// Trailing zero probabilities are implied.
+	last := len(norm) - 1
+	for last > 0 && norm[last] == 0 {
+		last--
+	}
+
+	prev0 := false
+	for sym := 0; remaining > 1 && sym <= last; {
+		if prev0 {
+			start := sym
+			for norm[sym] == 0 {
+				sym++
+			}
+			for sym >= start+3 {
+				start += 3
+				put(3, 2)
+			}
+			put(uint32(sym-start), 2)
+		}
+
+		count := int(norm[sym])
+		sym++
+		max := (2*threshold - 1) - remaining
+		if count < 0 {
+			remaining += count
+		} else {
+			remaining -= count
+		}
+		count++
+		if count >= threshold {
+			count += max
+		}
+		if count < max {
+			put(uint32(count), uint(bitsNeeded-1))
+		} else {
+			put(uint32(count), uint(bitsNeeded))
+		}
+		prev0 = count == 1
+
+		for remaining < threshold {
+			bitsNeeded--
+			threshold >>= 1
+		}
+	}
+
+	if cnt > 0 {
+		dst = append(dst, byte(acc))
+	}
+	return dst
+}
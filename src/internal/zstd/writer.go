@@ -0,0 +1,607 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/bits"
+)
+
+// maxBlockSize is the largest amount of data in a single block.
+// RFC 3.1.1.2.3.
+const maxBlockSize = 128 << 10
+
+// minMatch is the shortest match that we look for.
+const minMatch = 4
+
+// levelParams are the parameters of the match finder
+// for a compression level.
+type levelParams struct {
+	windowLog uint8 // log2 of the window size
+	hashLog   uint8 // log2 of the size of the hash table
+	depth     int   // number of candidates to check for each position
+	lazy      bool  // whether to look for a better match at the next position
+	goodLen   int   // stop looking for candidates after a match this long
+}
+
+// levels holds the parameters for each compression level.
+// Level 0 stores the data without compression.
+var levels = [...]levelParams{
+	1: {windowLog: 18, hashLog: 15, depth: 1, goodLen: 32},
+	2: {windowLog: 18, hashLog: 16, depth: 4, goodLen: 32},
+	3: {windowLog: 19, hashLog: 16, depth: 8, goodLen: 32},
+	4: {windowLog: 19, hashLog: 16, depth: 8, lazy: true, goodLen: 32},
+	5: {windowLog: 20, hashLog: 17, depth: 16, lazy: true, goodLen: 64},
+	6: {windowLog: 20, hashLog: 17, depth: 32, lazy: true, goodLen: 128},
+	7: {windowLog: 20, hashLog: 17, depth: 64, lazy: true, goodLen: 256},
+	8: {windowLog: 21, hashLog: 18, depth: 128, lazy: true, goodLen: 512},
+	9: {windowLog: 21, hashLog: 18, depth: 256, lazy: true, goodLen: 1024},
+}
+
+// MaxLevel is the largest supported compression level.
+const MaxLevel = len(levels) - 1
+
+var errWriterClosed = errors.New("zstd: write to closed Writer")
+
+// Writer implements [io.WriteCloser] to write a zstd compressed stream.
+// All data written to a Writer is written as a single frame.
+type Writer struct {
+	// The underlying Writer.
+	w io.Writer
+
+	// The compression level and its parameters.
+	level  int
+	params levelParams
+
+	// The dictionary, if any.
+	dict *Dict
+
+	// Whether to write a checksum at the end of the frame.
+	checksum bool
+
+	// Whether we have written the frame header.
+	wroteHeader bool
+
+	// Whether Close has been called.
+	closed bool
+
+	// The first error seen, returned by all later calls.
+	err error
+
+	// hist holds the window of previously compressed data,
+	// followed by the pending data that has not yet been compressed.
+	// The dictionary content, if any, is at the start.
+	hist []byte
+
+	// The offset in hist of the first pending byte.
+	pending int
+
+	// The offset in hist of the first byte that is part of the frame,
+	// rather than the dictionary.
+	frameStart int
+
+	// head maps the hash of 4 bytes to 1 + the last offset
+	// in hist where those bytes occurred.
+	head []int32
+
+	// chain[i] is 1 + the previous offset in hist with
+	// the same hash as offset i.
+	chain []int32
+
+	// The next offset in hist to add to head and chain.
+	nextInsert int
+
+	// The repeated offsets, as the decoder will see them
+	// at the start of the next sequence. RFC 3.1.1.5.
+	reps [3]uint32
+
+	// Scratch space to encode a block.
+	enc blockEncoder
+
+	// The output buffer.
+	out []byte
+
+	// For checksum computation.
+	xxh xxhash64
+}
+
+// NewWriter creates a new Writer that compresses data to w
+// at the given level, which must be between 0 and [MaxLevel].
+// It is the caller's responsibility to call Close on the Writer when done.
+func NewWriter(w io.Writer, level int) *Writer {
+	if level < 0 || level > MaxLevel {
+		panic("zstd: invalid compression level")
+	}
+	zw := &Writer{
+		level:    level,
+		params:   levels[level],
+		checksum: true,
+	}
+	zw.Reset(w)
+	return zw
+}
+
+// SetDict sets the dictionary to use when compressing.
+// It must be called before the first call to Write, Flush, or Close.
+// A nil d removes any dictionary.
+// The dictionary is retained across calls to Reset.
+func (w *Writer) SetDict(d *Dict) {
+	w.dict = d
+	w.Reset(w.w)
+}
+
+// SetChecksum sets whether to write a content checksum
+// at the end of the frame. The default is true.
+// It must be called before the first call to Write, Flush, or Close.
+func (w *Writer) SetChecksum(checksum bool) {
+	w.checksum = checksum
+}
+
+// Reset discards the Writer's state and makes it equivalent to the
+// result of NewWriter with the same level, but writing to dst instead.
+// The dictionary and checksum settings are retained.
+func (w *Writer) Reset(dst io.Writer) {
+	w.w = dst
+	w.wroteHeader = false
+	w.closed = false
+	w.err = nil
+	w.hist = w.hist[:0]
+	w.pending = 0
+	w.frameStart = 0
+	w.nextInsert = 0
+	w.reps = [3]uint32{1, 4, 8}
+	w.out = w.out[:0]
+	w.xxh.reset()
+
+	if w.level > 0 {
+		windowSize := 1 << w.params.windowLog
+		// Keep room for two windows, so that we only have
+		// to slide the history after every window's worth of data.
+		histCap := 2*windowSize + maxBlockSize
+		if cap(w.hist) < histCap {
+			w.hist = make([]byte, 0, histCap)
+			w.chain = make([]int32, histCap)
+		}
+		if len(w.head) == 0 {
+			w.head = make([]int32, 1<<w.params.hashLog)
+		} else {
+			for i := range w.head {
+				w.head[i] = 0
+			}
+		}
+	}
+
+	if w.dict != nil && w.level > 0 {
+		content := w.dict.content
+		windowSize := 1 << w.params.windowLog
+		if len(content) > windowSize {
+			content = content[len(content)-windowSize:]
+		}
+		w.reps = w.dict.repeatedOffsets
+		w.hist = append(w.hist, content...)
+		w.pending = len(w.hist)
+		w.frameStart = len(w.hist)
+		w.insertUpTo(len(w.hist))
+	}
+}
+
+// Write implements [io.Writer].
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.closed {
+		return 0, errWriterClosed
+	}
+	n := 0
+	for len(p) > 0 {
+		room := maxBlockSize - (len(w.hist) - w.pending)
+		chunk := p
+		if len(chunk) > room {
+			chunk = chunk[:room]
+		}
+		w.hist = append(w.hist, chunk...)
+		n += len(chunk)
+		p = p[len(chunk):]
+
+		// Keep a full block pending, since we don't know
+		// whether the next call will be Close.
+		if len(p) > 0 {
+			if err := w.writeBlock(false); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Flush writes any pending data to the underlying writer.
+// The data written so far can be decompressed by a Reader,
+// although the frame is not complete until Close is called.
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.closed {
+		return nil
+	}
+	if !w.wroteHeader {
+		w.writeHeader(false, 0)
+	}
+	if len(w.hist) > w.pending {
+		return w.writeBlock(false)
+	}
+	return w.flushOut()
+}
+
+// Close writes any pending data and completes the frame.
+// It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.closed {
+		return nil
+	}
+	if err := w.writeBlock(true); err != nil {
+		return err
+	}
+	w.closed = true
+	if w.checksum {
+		w.out = binary.LittleEndian.AppendUint32(w.out, uint32(w.xxh.digest()))
+	}
+	return w.flushOut()
+}
+
+// flushOut writes w.out to the underlying writer.
+func (w *Writer) flushOut() error {
+	if len(w.out) > 0 {
+		if _, err := w.w.Write(w.out); err != nil {
+			w.err = err
+			return err
+		}
+		w.out = w.out[:0]
+	}
+	return nil
+}
+
+// writeHeader appends the frame header to w.out.
+// If singleSegment is true, the frame holds exactly size bytes.
+// RFC 3.1.1.1.
+func (w *Writer) writeHeader(singleSegment bool, size int) {
+	w.wroteHeader = true
+	w.out = binary.LittleEndian.AppendUint32(w.out, 0xfd2fb528)
+
+	var descriptor byte
+	if w.checksum {
+		descriptor |= 1 << 2
+	}
+	var dictID uint32
+	if w.dict != nil && w.level > 0 {
+		dictID = w.dict.id
+	}
+	if dictID != 0 {
+		descriptor |= 3
+	}
+	var fcs []byte
+	if singleSegment {
+		descriptor |= 1 << 5
+		switch {
+		case size < 256:
+			fcs = []byte{byte(size)}
+		case size < 256+1<<16:
+			descriptor |= 1 << 6
+			fcs = binary.LittleEndian.AppendUint16(nil, uint16(size-256))
+		default:
+			descriptor |= 2 << 6
+			fcs = binary.LittleEndian.AppendUint32(nil, uint32(size))
+		}
+	}
+	w.out = append(w.out, descriptor)
+
+	if !singleSegment {
+		// Window_Descriptor. RFC 3.1.1.1.2.
+		windowLog := w.params.windowLog
+		if w.level == 0 {
+			windowLog = 17
+		}
+		w.out = append(w.out, (windowLog-10)<<3)
+	}
+	if dictID != 0 {
+		w.out = binary.LittleEndian.AppendUint32(w.out, dictID)
+	}
+	w.out = append(w.out, fcs...)
+}
+
+// writeBlock compresses the pending data as a single block.
+func (w *Writer) writeBlock(last bool) error {
+	src := w.hist[w.pending:]
+	if !w.wroteHeader {
+		// If this is the only block, we know the content size,
+		// which lets the decoder use a smaller window.
+		// We don't do this when using a dictionary,
+		// as the window must then include the dictionary.
+		single := last && w.frameStart == 0
+		w.writeHeader(single, len(src))
+	}
+	if w.checksum {
+		w.xxh.update(src)
+	}
+
+	// Block_Header. RFC 3.1.1.2.
+	hdr := uint32(0)
+	if last {
+		hdr = 1
+	}
+
+	switch {
+	case len(src) == 0 || w.level == 0:
+		w.out = appendBlockHeader(w.out, hdr|0<<1|uint32(len(src))<<3)
+		w.out = append(w.out, src...)
+	case isRLE(src):
+		w.out = appendBlockHeader(w.out, hdr|1<<1|uint32(len(src))<<3)
+		w.out = append(w.out, src[0])
+	default:
+		reps := w.reps
+		w.findSequences()
+		start := len(w.out)
+		w.out = appendBlockHeader(w.out, 0)
+		w.out = w.enc.encode(w.out)
+		size := len(w.out) - start - 3
+		if size < len(src) {
+			b := hdr | 2<<1 | uint32(size)<<3
+			w.out[start] = byte(b)
+			w.out[start+1] = byte(b >> 8)
+			w.out[start+2] = byte(b >> 16)
+		} else {
+			// The decoder won't see the sequences,
+			// so it won't update the repeated offsets.
+			w.reps = reps
+			w.out = appendBlockHeader(w.out[:start], hdr|0<<1|uint32(len(src))<<3)
+			w.out = append(w.out, src...)
+		}
+	}
+
+	w.pending = len(w.hist)
+	if w.level > 0 {
+		w.slide()
+	} else {
+		w.hist = w.hist[:0]
+		w.pending = 0
+	}
+	return w.flushOut()
+}
+
+// appendBlockHeader appends a 3 byte block header.
+func appendBlockHeader(dst []byte, hdr uint32) []byte {
+	return append(dst, byte(hdr), byte(hdr>>8), byte(hdr>>16))
+}
+
+// isRLE reports whether all the bytes of b are the same.
+func isRLE(b []byte) bool {
+	for _, c := range b[1:] {
+		if c != b[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// slide discards old history if there isn't room for another block.
+func (w *Writer) slide() {
+	windowSize := 1 << w.params.windowLog
+	if len(w.hist)+maxBlockSize <= cap(w.hist) {
+		return
+	}
+	delta := len(w.hist) - windowSize
+	copy(w.hist, w.hist[delta:])
+	w.hist = w.hist[:windowSize]
+	w.pending -= delta
+	w.nextInsert -= delta
+	if w.nextInsert < 0 {
+		w.nextInsert = 0
+	}
+	w.frameStart -= delta
+	if w.frameStart < 0 {
+		w.frameStart = 0
+	}
+
+	adjust := func(v int32) int32 {
+		if v <= int32(delta) {
+			return 0
+		}
+		return v - int32(delta)
+	}
+	for i, v := range w.head {
+		w.head[i] = adjust(v)
+	}
+	copy(w.chain, w.chain[delta:delta+windowSize])
+	for i, v := range w.chain[:windowSize] {
+		w.chain[i] = adjust(v)
+	}
+}
+
+// hash4 returns the hash of the 4 bytes at the start of b.
+func (w *Writer) hash4(b []byte) uint32 {
+	return (binary.LittleEndian.Uint32(b) * 0x9e3779b1) >> (32 - w.params.hashLog)
+}
+
+// insertUpTo adds the offsets in hist before end to the hash chains.
+func (w *Writer) insertUpTo(end int) {
+	if limit := len(w.hist) - minMatch + 1; end > limit {
+		end = limit
+	}
+	for i := w.nextInsert; i < end; i++ {
+		h := w.hash4(w.hist[i:])
+		w.chain[i] = w.head[h]
+		w.head[h] = int32(i + 1)
+	}
+	if end > w.nextInsert {
+		w.nextInsert = end
+	}
+}
+
+// A match is a candidate match for the data at some position.
+type match struct {
+	length int
+	offset int    // distance back to the start of the match
+	value  uint32 // the Offset_Value used to encode offset
+	gain   int    // estimated benefit of using the match
+}
+
+// matchGain estimates the number of bits saved by a match,
+// in units of a quarter of a literal byte.
+// Long offset values take more bits to encode.
+func matchGain(length int, value uint32) int {
+	return 4*length - bits.Len32(value)
+}
+
+// offsetValue returns the Offset_Value used to encode offset
+// following a literal length of litLen. RFC 3.1.1.5.
+func (w *Writer) offsetValue(offset, litLen int) uint32 {
+	o := uint32(offset)
+	if litLen > 0 {
+		switch o {
+		case w.reps[0]:
+			return 1
+		case w.reps[1]:
+			return 2
+		case w.reps[2]:
+			return 3
+		}
+	} else {
+		// With no literals, the repeated offsets are shifted.
+		// We don't use the Offset_Value 3, which means reps[0]-1.
+		switch o {
+		case w.reps[1]:
+			return 1
+		case w.reps[2]:
+			return 2
+		}
+	}
+	return o + 3
+}
+
+// updateReps updates the repeated offsets after a sequence
+// with the given Offset_Value and literal length,
+// just as the decoder does.
+func (w *Writer) updateReps(value uint32, litLen int) {
+	if value > 3 {
+		w.reps = [3]uint32{value - 3, w.reps[0], w.reps[1]}
+		return
+	}
+	if litLen == 0 {
+		value++
+	}
+	switch value {
+	case 2:
+		w.reps[0], w.reps[1] = w.reps[1], w.reps[0]
+	case 3:
+		w.reps = [3]uint32{w.reps[2], w.reps[0], w.reps[1]}
+	}
+}
+
+// matchLen returns the length of the common prefix of a and b.
+func matchLen(a, b []byte) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// bestMatch returns the most profitable match for the data
+// at hist[pos:end], following a literal length of litLen.
+// The returned match has zero length if there is none.
+func (w *Writer) bestMatch(pos, end, litLen int) match {
+	minPos := pos - 1<<w.params.windowLog
+	if minPos < 0 {
+		minPos = 0
+	}
+	hist := w.hist[:end]
+	cur := hist[pos:]
+
+	var best match
+	try := func(offset, length int) {
+		if length < minMatch {
+			return
+		}
+		value := w.offsetValue(offset, litLen)
+		if gain := matchGain(length, value); gain > best.gain {
+			best = match{length: length, offset: offset, value: value, gain: gain}
+		}
+	}
+
+	// The repeated offsets are cheap to encode, so try them first.
+	for _, rep := range w.reps {
+		if cand := pos - int(rep); cand >= minPos {
+			try(int(rep), matchLen(hist[cand:], cur))
+		}
+	}
+
+	// A match of minMatch bytes must be close enough to be
+	// worth more than the literals it replaces.
+	if minGain := 4*minMatch - 14; best.gain < minGain {
+		best.gain = minGain
+	}
+
+	cand := int(w.head[w.hash4(cur)]) - 1
+	for depth := w.params.depth; depth > 0 && cand >= minPos; depth-- {
+		if cand >= pos {
+			// Can't happen unless the chain is stale.
+			break
+		}
+		if best.length < len(cur) && hist[cand+best.length] == cur[best.length] {
+			try(pos-cand, matchLen(hist[cand:], cur))
+			if best.length >= w.params.goodLen || best.length == len(cur) {
+				break
+			}
+		}
+		cand = int(w.chain[cand]) - 1
+	}
+	return best
+}
+
+// findSequences fills in w.enc with the sequences and literals
+// for the pending data.
+func (w *Writer) findSequences() {
+	w.enc.seqs = w.enc.seqs[:0]
+	w.enc.lits = w.enc.lits[:0]
+
+	end := len(w.hist)
+	litStart := w.pending
+	pos := w.pending
+	for pos+minMatch <= end {
+		w.insertUpTo(pos)
+		m := w.bestMatch(pos, end, pos-litStart)
+		if m.length == 0 {
+			pos++
+			continue
+		}
+		if w.params.lazy && pos+1+minMatch <= end {
+			// Delaying the match costs a literal byte.
+			w.insertUpTo(pos + 1)
+			if m2 := w.bestMatch(pos+1, end, pos+1-litStart); m2.gain > m.gain+4 {
+				pos++
+				m = m2
+			}
+		}
+
+		litLen := pos - litStart
+		w.enc.seqs = append(w.enc.seqs, sequence{
+			litLen:   uint32(litLen),
+			offset:   m.value,
+			matchLen: uint32(m.length),
+		})
+		w.updateReps(m.value, litLen)
+		w.enc.lits = append(w.enc.lits, w.hist[litStart:pos]...)
+		pos += m.length
+		litStart = pos
+	}
+	w.insertUpTo(end)
+	w.enc.lits = append(w.enc.lits, w.hist[litStart:end]...)
+}
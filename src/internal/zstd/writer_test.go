@@ -0,0 +1,324 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// writerInputs returns a set of inputs for testing the Writer.
+func writerInputs(t testing.TB) map[string][]byte {
+	rnd := rand.New(rand.NewSource(1))
+	random := make([]byte, 300<<10)
+	rnd.Read(random)
+
+	// lowBits has a small alphabet, so it exercises
+	// Huffman coding of literals.
+	lowBits := make([]byte, 200<<10)
+	for i := range lowBits {
+		lowBits[i] = byte(rnd.Intn(16)) + 'a'
+	}
+
+	var seq bytes.Buffer
+	for i := 0; i < 50000; i++ {
+		fmt.Fprintf(&seq, "%d\n", i)
+	}
+
+	inputs := map[string][]byte{
+		"empty":    nil,
+		"one":      []byte("x"),
+		"hello":    []byte("hello, world\n"),
+		"rle":      bytes.Repeat([]byte{'z'}, 300<<10),
+		"repeat":   bytes.Repeat([]byte("abcdefghijklmnop"), 20000),
+		"random":   random,
+		"lowbits":  lowBits,
+		"sequence": seq.Bytes(),
+	}
+	for _, test := range tests {
+		inputs["test-"+test.name] = []byte(test.uncompressed)
+	}
+	if !testing.Short() {
+		inputs["big"] = bigData(t)
+	}
+	return inputs
+}
+
+func compress(t testing.TB, data []byte, level int, d *Dict) []byte {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, level)
+	if d != nil {
+		w.SetDict(d)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	for name, data := range writerInputs(t) {
+		for level := 0; level <= MaxLevel; level++ {
+			if testing.Short() && level != 1 && level != 5 {
+				continue
+			}
+			t.Run(fmt.Sprintf("%s/%d", name, level), func(t *testing.T) {
+				compressed := compress(t, data, level, nil)
+				got, err := io.ReadAll(NewReader(bytes.NewReader(compressed)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, data) {
+					showDiffs(t, got, data)
+				}
+				if level > 0 && len(data) > 1<<10 && name != "random" && len(compressed) >= len(data)*3/5 {
+					t.Errorf("compressed %d bytes to %d", len(data), len(compressed))
+				}
+			})
+		}
+	}
+}
+
+func TestWriterFlush(t *testing.T) {
+	data := bigData(t)[:1<<20]
+	var buf bytes.Buffer
+	w := NewWriter(&buf, 5)
+	r := NewReader(&buf)
+	for i := 0; i < len(data); i += 100 << 10 {
+		chunk := data[i:min(i+100<<10, len(data))]
+		if _, err := w.Write(chunk); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		// Everything written so far must be readable.
+		got := make([]byte, len(chunk))
+		if _, err := io.ReadFull(r, got); err != nil {
+			t.Fatalf("reading after flush at %d: %v", i, err)
+		}
+		if !bytes.Equal(got, chunk) {
+			t.Fatalf("mismatch after flush at %d", i)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := io.Copy(io.Discard, r); n != 0 || err != nil {
+		t.Errorf("read after close = %d, %v; want 0, nil", n, err)
+	}
+}
+
+func TestWriterReset(t *testing.T) {
+	data := []byte("hello, hello, hello, world\n")
+	var buf1, buf2 bytes.Buffer
+	w := NewWriter(&buf1, 5)
+	w.Write(data)
+	w.Close()
+	w.Reset(&buf2)
+	w.Write(data)
+	w.Close()
+	if !bytes.Equal(buf1.Bytes(), buf2.Bytes()) {
+		t.Errorf("output after Reset differs:\n%q\n%q", buf1.Bytes(), buf2.Bytes())
+	}
+	if _, err := w.Write(data); err == nil {
+		t.Error("Write after Close succeeded")
+	}
+}
+
+func TestChecksumError(t *testing.T) {
+	compressed := compress(t, []byte("hello, world\n"), 5, nil)
+	compressed[len(compressed)-1] ^= 0xff
+	_, err := io.ReadAll(NewReader(bytes.NewReader(compressed)))
+	if !errors.Is(err, ErrChecksum) {
+		t.Errorf("got error %v, want %v", err, ErrChecksum)
+	}
+}
+
+func TestHuffmanLengths(t *testing.T) {
+	// Fibonacci counts produce the deepest possible tree,
+	// which must be limited.
+	counts := make([]uint32, 30)
+	a, b := uint32(1), uint32(1)
+	for i := range counts {
+		counts[i] = a
+		a, b = b, a+b
+	}
+	lengths := make([]uint8, len(counts))
+	maxLen := huffmanLengths(counts, maxHuffmanBits, lengths)
+	if maxLen != maxHuffmanBits {
+		t.Errorf("got max length %d, want %d", maxLen, maxHuffmanBits)
+	}
+	kraft := 0
+	for _, l := range lengths {
+		if l == 0 || int(l) > maxLen {
+			t.Fatalf("bad length %d", l)
+		}
+		kraft += 1 << (maxLen - int(l))
+	}
+	if kraft != 1<<maxLen {
+		t.Errorf("Kraft sum %d, want %d", kraft, 1<<maxLen)
+	}
+}
+
+// writeTemp writes data to a temporary file and returns its name.
+func writeTemp(t *testing.T, name string, data []byte) string {
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, data, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// Test that the zstd program can decompress what we compress.
+func TestWriterZstd(t *testing.T) {
+	zstd := findZstd(t)
+	for name, data := range writerInputs(t) {
+		t.Run(name, func(t *testing.T) {
+			compressed := compress(t, data, 5, nil)
+			cmd := exec.Command(zstd, "-d")
+			cmd.Stdin = bytes.NewReader(compressed)
+			got, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("zstd -d failed: %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				showDiffs(t, got, data)
+			}
+		})
+	}
+}
+
+func TestDictRoundTrip(t *testing.T) {
+	dict := []byte("The quick brown fox jumps over the lazy dog. ")
+	data := []byte("The quick brown fox jumps over the lazy cat. The lazy dog sleeps.")
+
+	d, err := ParseDict(dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed := compress(t, data, 5, d)
+	plain := compress(t, data, 5, nil)
+	if len(compressed) >= len(plain) {
+		t.Errorf("dictionary did not help: %d >= %d", len(compressed), len(plain))
+	}
+
+	r := NewReader(bytes.NewReader(compressed))
+	r.SetDict(d)
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		showDiffs(t, got, data)
+	}
+
+	if zstd, err := exec.LookPath("zstd"); err == nil {
+		cmd := exec.Command(zstd, "-d", "-D", writeTemp(t, "dict", dict))
+		cmd.Stdin = bytes.NewReader(compressed)
+		got, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("zstd -d failed: %v", err)
+		}
+		if !bytes.Equal(got, data) {
+			showDiffs(t, got, data)
+		}
+	}
+}
+
+// Test that we can use formatted dictionaries trained by the zstd program.
+func TestTrainedDict(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping dictionary training in short mode")
+	}
+	zstd := findZstd(t)
+
+	dir := t.TempDir()
+	var samples []string
+	for i := 0; i < 200; i++ {
+		sample := fmt.Sprintf(`{"id": %d, "name": "user%d", "email": "user%d@example.com", "active": %t, "score": %d}`, i, i*7, i*13, i%3 == 0, i*i%1000)
+		file := filepath.Join(dir, fmt.Sprintf("sample%d.json", i))
+		if err := os.WriteFile(file, []byte(sample), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		samples = append(samples, file)
+	}
+	dictFile := filepath.Join(dir, "dict")
+	args := append([]string{"--train", "-q", "--maxdict=4096", "-o", dictFile}, samples...)
+	if out, err := exec.Command(zstd, args...).CombinedOutput(); err != nil {
+		t.Skipf("zstd --train failed: %v\n%s", err, out)
+	}
+	dict, err := os.ReadFile(dictFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := ParseDict(dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.ID() == 0 {
+		t.Error("trained dictionary has zero ID")
+	}
+
+	data := []byte(`{"id": 1000, "name": "user7000", "email": "user13000@example.com", "active": false, "score": 0}`)
+
+	// Decompress data compressed by zstd with the dictionary.
+	cmd := exec.Command(zstd, "-c", "-D", dictFile)
+	cmd.Stdin = bytes.NewReader(data)
+	compressed, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewReader(bytes.NewReader(compressed))
+	if _, err := io.ReadAll(r); err == nil {
+		t.Error("reading without dictionary succeeded")
+	}
+	r.Reset(bytes.NewReader(compressed))
+	r.SetDict(d)
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		showDiffs(t, got, data)
+	}
+
+	// Have zstd decompress data we compressed with the dictionary.
+	compressed = compress(t, data, 5, d)
+	cmd = exec.Command(zstd, "-d", "-D", dictFile)
+	cmd.Stdin = bytes.NewReader(compressed)
+	got, err = cmd.Output()
+	if err != nil {
+		t.Fatalf("zstd -d failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		showDiffs(t, got, data)
+	}
+}
+
+func BenchmarkWriter(b *testing.B) {
+	data := bigData(b)[:4<<20]
+	for _, level := range []int{1, 5, 9} {
+		b.Run(fmt.Sprint(level), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			w := NewWriter(io.Discard, level)
+			for i := 0; i < b.N; i++ {
+				w.Reset(io.Discard)
+				w.Write(data)
+				w.Close()
+			}
+		})
+	}
+}
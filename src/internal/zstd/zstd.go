@@ -2,8 +2,8 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// Package zstd provides a decompressor for zstd streams,
-// described in RFC 8878. It does not support dictionaries.
+// Package zstd provides a decompressor and a compressor for zstd streams,
+// described in RFC 8878.
 package zstd
 
 import (
@@ -13,6 +13,10 @@ import (
 	"io"
 )
 
+// ErrChecksum is returned when reading a frame whose
+// content checksum does not match the decompressed data.
+var ErrChecksum = errors.New("invalid checksum")
+
 // fuzzing is a fuzzer hook set to true when fuzzing.
 // This is used to reject cases where we don't match zstd.
 var fuzzing = false
@@ -82,6 +86,9 @@ type Reader struct {
 
 	// For checksum computation.
 	checksum xxhash64
+
+	// The dictionary to use for each frame, if any.
+	dict *Dict
 }
 
 // NewReader creates a new Reader that decompresses data from the given reader.
@@ -119,6 +126,7 @@ func (r *Reader) Reset(input io.Reader) {
 	// seqTableBuffers
 	// scratch
 	// fseScratch
+	// dict
 }
 
 // Read implements [io.Reader].
@@ -256,11 +264,18 @@ retry:
 
 	// Dictionary_ID. RFC 3.1.1.1.3.
 	if dictionaryIdSize != 0 {
-		dictionaryId := r.scratch[windowDescriptorSize : windowDescriptorSize+dictionaryIdSize]
-		// Allow only zero Dictionary ID.
-		for _, b := range dictionaryId {
-			if b != 0 {
-				return r.makeError(relativeOffset, "dictionaries are not supported")
+		var dictionaryId uint32
+		for i, b := range r.scratch[windowDescriptorSize : windowDescriptorSize+dictionaryIdSize] {
+			dictionaryId |= uint32(b) << (8 * i)
+		}
+		// A zero Dictionary ID means that no particular
+		// dictionary is required.
+		if dictionaryId != 0 {
+			if r.dict == nil {
+				return r.makeError(relativeOffset, "frame requires a dictionary")
+			}
+			if r.dict.id != dictionaryId {
+				return r.makeError(relativeOffset, "frame dictionary ID does not match")
 			}
 		}
 	}
@@ -313,6 +328,10 @@ retry:
 	r.seqTables[1] = nil
 	r.seqTables[2] = nil
 
+	if r.dict != nil {
+		r.loadDict(int(windowSize))
+	}
+
 	return nil
 }
 
@@ -465,7 +484,7 @@ func (r *Reader) readBlock() error {
 			inputChecksum := binary.LittleEndian.Uint32(r.scratch[:4])
 			dataChecksum := uint32(r.checksum.digest())
 			if inputChecksum != dataChecksum {
-				return r.wrapError(0, fmt.Errorf("invalid checksum: got %#x want %#x", dataChecksum, inputChecksum))
+				return r.wrapError(0, fmt.Errorf("%w: got %#x want %#x", ErrChecksum, dataChecksum, inputChecksum))
 			}
 
 			r.blockOffset += 4
@@ -0,0 +1,307 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"compress/gzip"
+	"compress/zstd"
+	"io"
+	"net/http/internal/ascii"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CompressHandler returns a handler that compresses the responses of h
+// using a content coding accepted by the client, as indicated by the
+// request's "Accept-Encoding" header. The supported codings are
+// "zstd" and "gzip"; when the client accepts both equally,
+// zstd is preferred.
+//
+// CompressHandler always adds "Accept-Encoding" to the response's
+// "Vary" header, since the response depends on it. It leaves the
+// response uncompressed if h sets a "Content-Encoding" or
+// "Content-Range" header, if the response has no body, or if
+// the "Content-Type" indicates data that is already compressed,
+// such as images, audio, video, and archives.
+//
+// When it compresses a response, CompressHandler removes any
+// "Content-Length" and "Accept-Ranges" headers, which describe the
+// uncompressed data, and changes a strong "ETag" into a weak one.
+// If h does not set a "Content-Type", it is detected from the first
+// data written, using [DetectContentType].
+func CompressHandler(h Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		coding := negotiateContentEncoding(r.Header["Accept-Encoding"])
+		if coding == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressResponseWriter{
+			rw:     w,
+			coding: coding,
+			isHead: r.Method == "HEAD",
+		}
+		defer cw.close()
+		h.ServeHTTP(cw, r)
+	})
+}
+
+// compressEncodings are the content codings supported by
+// CompressHandler, in order of preference.
+var compressEncodings = []string{"zstd", "gzip"}
+
+// negotiateContentEncoding returns the content coding to use for a
+// response to a request with the given Accept-Encoding header values,
+// or "" if the response should not be compressed. RFC 9110, 12.5.3.
+func negotiateContentEncoding(accept []string) string {
+	best, bestQ := "", 0.0
+	wildcardQ := -1.0
+	q := make(map[string]float64)
+	for _, v := range accept {
+		for _, elem := range strings.Split(v, ",") {
+			coding, params, _ := strings.Cut(elem, ";")
+			coding, ok := ascii.ToLower(textproto.TrimString(coding))
+			if !ok {
+				continue
+			}
+			weight := 1.0
+			for _, p := range strings.Split(params, ";") {
+				k, v, ok := strings.Cut(p, "=")
+				if !ok || !ascii.EqualFold(textproto.TrimString(k), "q") {
+					continue
+				}
+				f, err := strconv.ParseFloat(textproto.TrimString(v), 64)
+				if err != nil || f < 0 || f > 1 {
+					f = 0
+				}
+				weight = f
+			}
+			if coding == "*" {
+				wildcardQ = weight
+			} else {
+				q[coding] = weight
+			}
+		}
+	}
+	for _, coding := range compressEncodings {
+		weight, ok := q[coding]
+		if !ok {
+			weight = wildcardQ
+		}
+		if weight > bestQ {
+			best, bestQ = coding, weight
+		}
+	}
+	return best
+}
+
+// compressedContentTypes are the media type prefixes
+// of data that is not worth compressing again.
+var compressedContentTypes = []string{
+	"image/",
+	"audio/",
+	"video/",
+	"font/woff",
+	"application/gzip",
+	"application/x-gzip",
+	"application/zip",
+	"application/zstd",
+	"application/x-bzip2",
+	"application/x-xz",
+	"application/x-7z-compressed",
+	"application/wasm",
+}
+
+// isCompressedContentType reports whether a response with
+// the given Content-Type is already compressed.
+func isCompressedContentType(ct string) bool {
+	ct, ok := ascii.ToLower(ct)
+	if !ok {
+		return false
+	}
+	if strings.HasPrefix(ct, "image/svg") {
+		return false
+	}
+	for _, prefix := range compressedContentTypes {
+		if strings.HasPrefix(ct, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	gzipWriterPool sync.Pool // of *gzip.Writer
+	zstdWriterPool sync.Pool // of *zstd.Writer
+)
+
+// compressResponseWriter is the ResponseWriter used by CompressHandler.
+// It decides whether to compress the response when the header is written.
+// If the handler calls WriteHeader without setting a Content-Type, the
+// decision and the header are deferred until the first data is written,
+// so that the type can be detected from it.
+type compressResponseWriter struct {
+	rw     ResponseWriter
+	coding string // content coding to use if compressing
+	isHead bool
+
+	code        int // status code of a deferred header, or 0
+	wroteHeader bool
+	zw          io.WriteCloser // compressor, or nil if not compressing
+}
+
+func (cw *compressResponseWriter) Header() Header {
+	return cw.rw.Header()
+}
+
+func (cw *compressResponseWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		cw.rw.WriteHeader(code)
+		return
+	}
+	if code >= 100 && code <= 199 && code != StatusSwitchingProtocols {
+		// Informational headers are passed through
+		// and may be followed by the final header.
+		cw.rw.WriteHeader(code)
+		return
+	}
+	if cw.code != 0 {
+		// The header is already deferred. Like the server,
+		// ignore superfluous calls.
+		return
+	}
+	if _, ok := cw.rw.Header()["Content-Type"]; !ok && bodyAllowedForStatus(code) {
+		cw.code = code
+		return
+	}
+	cw.writeHeader(code, nil)
+}
+
+// status returns the status code to write with a deferred header.
+func (cw *compressResponseWriter) status() int {
+	if cw.code != 0 {
+		return cw.code
+	}
+	return StatusOK
+}
+
+// writeHeader decides whether to compress the response and
+// writes the header with the given status code.
+// p holds the first data written to the body, if any.
+func (cw *compressResponseWriter) writeHeader(code int, p []byte) {
+	cw.wroteHeader = true
+	h := cw.rw.Header()
+	if cw.shouldCompress(code, h, p) {
+		h.Set("Content-Encoding", cw.coding)
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges")
+		if etag := h.Get("Etag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("Etag", "W/"+etag)
+		}
+		if !cw.isHead {
+			cw.zw = newCompressWriter(cw.coding, cw.rw)
+		}
+	}
+	cw.rw.WriteHeader(code)
+}
+
+// shouldCompress reports whether to compress a response with
+// the given status code and header.
+func (cw *compressResponseWriter) shouldCompress(code int, h Header, p []byte) bool {
+	if !bodyAllowedForStatus(code) || code == StatusPartialContent {
+		return false
+	}
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+	if _, ok := h["Content-Type"]; !ok && p != nil {
+		// Detect the content type of the uncompressed data,
+		// since the server would otherwise see compressed data.
+		h.Set("Content-Type", DetectContentType(p))
+	}
+	return !isCompressedContentType(h.Get("Content-Type"))
+}
+
+func (cw *compressResponseWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.writeHeader(cw.status(), p)
+	}
+	if cw.zw == nil {
+		return cw.rw.Write(p)
+	}
+	return cw.zw.Write(p)
+}
+
+// Flush flushes any buffered compressed data to the client.
+func (cw *compressResponseWriter) Flush() {
+	cw.FlushError()
+}
+
+// FlushError is like Flush, but returns any error encountered.
+// It is used by [ResponseController].
+func (cw *compressResponseWriter) FlushError() error {
+	if !cw.wroteHeader {
+		cw.writeHeader(cw.status(), nil)
+	}
+	if f, ok := cw.zw.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			return err
+		}
+	}
+	return NewResponseController(cw.rw).Flush()
+}
+
+// Unwrap returns the underlying ResponseWriter,
+// for use by [ResponseController].
+func (cw *compressResponseWriter) Unwrap() ResponseWriter {
+	return cw.rw
+}
+
+// close writes a deferred header, finishes the compressed stream,
+// if any, and returns the compressor to its pool.
+func (cw *compressResponseWriter) close() {
+	if !cw.wroteHeader && cw.code != 0 {
+		// No data was written, so there is nothing to compress.
+		cw.wroteHeader = true
+		cw.rw.WriteHeader(cw.code)
+	}
+	if cw.zw == nil {
+		return
+	}
+	cw.zw.Close()
+	switch zw := cw.zw.(type) {
+	case *gzip.Writer:
+		zw.Reset(io.Discard)
+		gzipWriterPool.Put(zw)
+	case *zstd.Writer:
+		zw.Reset(io.Discard)
+		zstdWriterPool.Put(zw)
+	}
+	cw.zw = nil
+}
+
+// newCompressWriter returns a compressor for the given
+// content coding that writes to w.
+func newCompressWriter(coding string, w io.Writer) io.WriteCloser {
+	switch coding {
+	case "gzip":
+		if zw, ok := gzipWriterPool.Get().(*gzip.Writer); ok {
+			zw.Reset(w)
+			return zw
+		}
+		return gzip.NewWriter(w)
+	case "zstd":
+		if zw, ok := zstdWriterPool.Get().(*zstd.Writer); ok {
+			zw.Reset(w)
+			return zw
+		}
+		zw, _ := zstd.NewWriterLevel(w, zstd.BestSpeed)
+		return zw
+	}
+	panic("http: unsupported content coding " + coding)
+}
@@ -0,0 +1,226 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http_test
+
+import (
+	"compress/gzip"
+	"compress/zstd"
+	"io"
+	. "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var compressTestBody = strings.Repeat("Hello, compressed world! ", 100)
+
+func TestCompressHandlerNegotiation(t *testing.T) {
+	h := CompressHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, compressTestBody)
+	}))
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"zstd", "zstd"},
+		{"gzip, zstd", "zstd"},
+		{"GZIP, deflate", "gzip"},
+		{"gzip;q=1.0, zstd;q=0.5", "gzip"},
+		{"gzip; q=0.5, zstd;q=0", "gzip"},
+		{"zstd;q=0, gzip;q=0", ""},
+		{"*", "zstd"},
+		{"*;q=0.1, gzip", "gzip"},
+		{"br, *;q=0", ""},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if test.accept != "" {
+			req.Header.Set("Accept-Encoding", test.accept)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		res := rec.Result()
+		if g := res.Header.Get("Content-Encoding"); g != test.want {
+			t.Errorf("Accept-Encoding %q: Content-Encoding = %q, want %q", test.accept, g, test.want)
+		}
+		if g := res.Header.Get("Vary"); g != "Accept-Encoding" {
+			t.Errorf("Accept-Encoding %q: Vary = %q, want %q", test.accept, g, "Accept-Encoding")
+		}
+		if g := res.Header.Get("Content-Type"); !strings.HasPrefix(g, "text/plain") {
+			t.Errorf("Accept-Encoding %q: Content-Type = %q, want text/plain", test.accept, g)
+		}
+
+		var body io.Reader = res.Body
+		switch test.want {
+		case "gzip":
+			zr, err := gzip.NewReader(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body = zr
+		case "zstd":
+			body = zstd.NewReader(res.Body)
+		}
+		got, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatalf("Accept-Encoding %q: %v", test.accept, err)
+		}
+		if string(got) != compressTestBody {
+			t.Errorf("Accept-Encoding %q: body = %q, want %q", test.accept, got, compressTestBody)
+		}
+	}
+}
+
+func TestCompressHandlerHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   map[string]string
+		code     int
+		compress bool
+	}{
+		{"plain", nil, StatusOK, true},
+		{"length", map[string]string{"Content-Length": "2500"}, StatusOK, true},
+		{"encoded", map[string]string{"Content-Encoding": "br"}, StatusOK, false},
+		{"range", map[string]string{"Content-Range": "bytes 0-9/100"}, StatusPartialContent, false},
+		{"image", map[string]string{"Content-Type": "image/png"}, StatusOK, false},
+		{"svg", map[string]string{"Content-Type": "image/svg+xml"}, StatusOK, true},
+		{"not modified", nil, StatusNotModified, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := CompressHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+				for k, v := range test.header {
+					w.Header().Set(k, v)
+				}
+				w.Header().Set("ETag", `"v1"`)
+				w.Header().Set("Accept-Ranges", "bytes")
+				w.WriteHeader(test.code)
+				if test.code != StatusNotModified {
+					io.WriteString(w, compressTestBody)
+				}
+			}))
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			res := rec.Result()
+
+			if g := res.Header.Get("Content-Encoding") == "gzip"; g != test.compress {
+				t.Fatalf("compressed = %v, want %v", g, test.compress)
+			}
+			wantETag, wantRanges := `"v1"`, "bytes"
+			if test.compress {
+				wantETag, wantRanges = `W/"v1"`, ""
+				if g := res.Header.Get("Content-Length"); g != "" {
+					t.Errorf("Content-Length = %q, want none", g)
+				}
+			}
+			if g := res.Header.Get("ETag"); g != wantETag {
+				t.Errorf("ETag = %q, want %q", g, wantETag)
+			}
+			if g := res.Header.Get("Accept-Ranges"); g != wantRanges {
+				t.Errorf("Accept-Ranges = %q, want %q", g, wantRanges)
+			}
+		})
+	}
+}
+
+func TestCompressHandlerWriteHeader(t *testing.T) {
+	png := "\x89PNG\x0D\x0A\x1A\x0A" + compressTestBody
+	tests := []struct {
+		name     string
+		code     int
+		body     string
+		wantType string
+		compress bool
+	}{
+		{"text", StatusOK, compressTestBody, "text/plain; charset=utf-8", true},
+		{"created", StatusCreated, compressTestBody, "text/plain; charset=utf-8", true},
+		{"image", StatusOK, png, "image/png", false},
+		{"empty", StatusAccepted, "", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := CompressHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+				w.WriteHeader(test.code)
+				if test.body != "" {
+					io.WriteString(w, test.body)
+				}
+			}))
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			res := rec.Result()
+
+			if res.StatusCode != test.code {
+				t.Errorf("status = %d, want %d", res.StatusCode, test.code)
+			}
+			if g := res.Header.Get("Content-Type"); g != test.wantType {
+				t.Errorf("Content-Type = %q, want %q", g, test.wantType)
+			}
+			if g := res.Header.Get("Content-Encoding") == "gzip"; g != test.compress {
+				t.Fatalf("compressed = %v, want %v", g, test.compress)
+			}
+			var body io.Reader = res.Body
+			if test.compress {
+				zr, err := gzip.NewReader(res.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = zr
+			}
+			got, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.body {
+				t.Errorf("body = %q, want %q", got, test.body)
+			}
+		})
+	}
+}
+
+func TestCompressHandlerFlush(t *testing.T) { run(t, testCompressHandlerFlush) }
+func testCompressHandlerFlush(t *testing.T, mode testMode) {
+	proceed := make(chan struct{})
+	cst := newClientServerTest(t, mode, CompressHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, "first part\n")
+		if err := NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Flush: %v", err)
+		}
+		<-proceed
+		io.WriteString(w, "second part\n")
+	})))
+	cst.tr.ContentEncodings = []string{"zstd"}
+
+	res, err := cst.c.Get(cst.ts.URL)
+	if err != nil {
+		close(proceed)
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if !res.Uncompressed {
+		t.Errorf("response was not compressed")
+	}
+
+	// The first part must be readable before the handler finishes.
+	buf := make([]byte, len("first part\n"))
+	_, err = io.ReadFull(res.Body, buf)
+	close(proceed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rest, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := string(buf)+string(rest), "first part\nsecond part\n"; g != w {
+		t.Errorf("body = %q, want %q", g, w)
+	}
+}
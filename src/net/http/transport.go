@@ -12,6 +12,7 @@ package http
 import (
 	"bufio"
 	"compress/gzip"
+	"compress/zstd"
 	"container/list"
 	"context"
 	"crypto/tls"
@@ -26,6 +27,7 @@ import (
 	"net/textproto"
 	"net/url"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -200,6 +202,15 @@ type Transport struct {
 	// uncompressed.
 	DisableCompression bool
 
+	// ContentEncodings lists the content codings, in order of
+	// preference, that the Transport requests in the
+	// "Accept-Encoding" header when DisableCompression is false.
+	// Responses using any of these codings are transparently
+	// decoded, as described for DisableCompression.
+	// The supported codings are "gzip" and "zstd"; others are ignored.
+	// If ContentEncodings is empty, the Transport requests only gzip.
+	ContentEncodings []string
+
 	// MaxIdleConns controls the maximum number of idle (keep-alive)
 	// connections across all hosts. Zero means no limit.
 	MaxIdleConns int
@@ -328,6 +339,7 @@ func (t *Transport) Clone() *Transport {
 		TLSHandshakeTimeout:    t.TLSHandshakeTimeout,
 		DisableKeepAlives:      t.DisableKeepAlives,
 		DisableCompression:     t.DisableCompression,
+		ContentEncodings:       append([]string(nil), t.ContentEncodings...),
 		MaxIdleConns:           t.MaxIdleConns,
 		MaxIdleConnsPerHost:    t.MaxIdleConnsPerHost,
 		MaxConnsPerHost:        t.MaxConnsPerHost,
@@ -565,7 +577,7 @@ func (t *Transport) roundTrip(req *Request) (*Response, error) {
 	req = setupRewindBody(req)
 
 	if altRT := t.alternateRoundTripper(req); altRT != nil {
-		if resp, err := altRT.RoundTrip(req); err != ErrSkipAltProtocol {
+		if resp, err := t.roundTripAlt(altRT, req); err != ErrSkipAltProtocol {
 			return resp, err
 		}
 		var err error
@@ -618,7 +630,7 @@ func (t *Transport) roundTrip(req *Request) (*Response, error) {
 		if pconn.alt != nil {
 			// HTTP/2 path.
 			t.setReqCanceler(cancelKey, nil) // not cancelable with CancelRequest
-			resp, err = pconn.alt.RoundTrip(req)
+			resp, err = t.roundTripAlt(pconn.alt, req)
 		} else {
 			resp, err = pconn.roundTrip(treq)
 		}
@@ -2304,8 +2316,8 @@ func (pc *persistConn) readLoop() {
 		}
 
 		resp.Body = body
-		if rc.addedGzip && ascii.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
-			resp.Body = &gzipReader{body: body}
+		if zr := decodedBody(body, resp.Header.Get("Content-Encoding"), rc.addedAcceptEncoding); zr != nil {
+			resp.Body = zr
 			resp.Header.Del("Content-Encoding")
 			resp.Header.Del("Content-Length")
 			resp.ContentLength = -1
@@ -2592,10 +2604,10 @@ type requestAndChan struct {
 	cancelKey cancelKey
 	ch        chan responseAndError // unbuffered; always send in select on callerGone
 
-	// whether the Transport (as opposed to the user client code)
-	// added the Accept-Encoding gzip header. If the Transport
-	// set it, only then do we transparently decode the gzip.
-	addedGzip bool
+	// the Accept-Encoding header added by the Transport (as
+	// opposed to the user client code), if any. If the Transport
+	// set it, only then do we transparently decode the response.
+	addedAcceptEncoding string
 
 	// Optional blocking chan for Expect: 100-continue (for send).
 	// If the request has an "Expect: 100-continue" header and
@@ -2669,9 +2681,9 @@ func (pc *persistConn) roundTrip(req *transportRequest) (resp *Response, err err
 
 	// Ask for a compressed version if the caller didn't set their
 	// own value for Accept-Encoding. We only attempt to
-	// uncompress the gzip stream if we were the layer that
+	// uncompress the response if we were the layer that
 	// requested it.
-	requestedGzip := false
+	acceptEncoding := ""
 	if !pc.t.DisableCompression &&
 		req.Header.Get("Accept-Encoding") == "" &&
 		req.Header.Get("Range") == "" &&
@@ -2688,8 +2700,8 @@ func (pc *persistConn) roundTrip(req *transportRequest) (resp *Response, err err
 		// We don't request gzip if the request is for a range, since
 		// auto-decoding a portion of a gzipped document will just fail
 		// anyway. See https://golang.org/issue/8923
-		requestedGzip = true
-		req.extraHeaders().Set("Accept-Encoding", "gzip")
+		acceptEncoding = pc.t.acceptEncoding()
+		req.extraHeaders().Set("Accept-Encoding", acceptEncoding)
 	}
 
 	var continueCh chan struct{}
@@ -2726,9 +2738,10 @@ func (pc *persistConn) roundTrip(req *transportRequest) (resp *Response, err err
 		req:        req.Request,
 		cancelKey:  req.cancelKey,
 		ch:         resc,
-		addedGzip:  requestedGzip,
 		continueCh: continueCh,
 		callerGone: gone,
+
+		addedAcceptEncoding: acceptEncoding,
 	}
 
 	var respHeaderTimer <-chan time.Time
@@ -2968,6 +2981,134 @@ func (gz *gzipReader) Close() error {
 	return gz.body.Close()
 }
 
+// zstdReader wraps a response body so it can lazily
+// call zstd.NewReader on the first call to Read.
+type zstdReader struct {
+	_    incomparable
+	body *bodyEOFSignal // underlying HTTP/1 response body framing
+	zr   *zstd.Reader   // lazily-initialized zstd reader
+}
+
+func (zr *zstdReader) Read(p []byte) (n int, err error) {
+	if zr.zr == nil {
+		zr.zr = zstd.NewReader(zr.body)
+	}
+
+	zr.body.mu.Lock()
+	if zr.body.closed {
+		err = errReadOnClosedResBody
+	}
+	zr.body.mu.Unlock()
+
+	if err != nil {
+		return 0, err
+	}
+	return zr.zr.Read(p)
+}
+
+func (zr *zstdReader) Close() error {
+	return zr.body.Close()
+}
+
+// acceptEncoding returns the Accept-Encoding header value
+// the Transport sends when it requests compression.
+func (t *Transport) acceptEncoding() string {
+	var codings []string
+	for _, c := range t.ContentEncodings {
+		for _, supported := range [...]string{"gzip", "zstd"} {
+			if ascii.EqualFold(c, supported) && !slices.Contains(codings, supported) {
+				codings = append(codings, supported)
+			}
+		}
+	}
+	if len(codings) == 0 {
+		return "gzip"
+	}
+	return strings.Join(codings, ", ")
+}
+
+// decodedBody returns a reader that decodes body, whose
+// Content-Encoding is coding, if coding is one of the codings
+// listed in acceptEncoding. Otherwise it returns nil.
+func decodedBody(body *bodyEOFSignal, coding, acceptEncoding string) io.ReadCloser {
+	if acceptEncoding == "" || !httpguts.HeaderValuesContainsToken([]string{acceptEncoding}, coding) {
+		return nil
+	}
+	switch {
+	case ascii.EqualFold(coding, "gzip"):
+		return &gzipReader{body: body}
+	case ascii.EqualFold(coding, "zstd"):
+		return &zstdReader{body: body}
+	}
+	return nil
+}
+
+// roundTripAlt sends req with the alternate RoundTripper rt, such as
+// the HTTP/2 Transport.
+//
+// The HTTP/2 Transport only requests and decodes gzip by itself. If
+// ContentEncodings enables other codings, roundTripAlt sets the
+// Accept-Encoding header instead, and decodes the response.
+func (t *Transport) roundTripAlt(rt RoundTripper, req *Request) (*Response, error) {
+	acceptEncoding := t.acceptEncoding()
+	if t.DisableCompression || acceptEncoding == "gzip" ||
+		req.Header.Get("Accept-Encoding") != "" ||
+		req.Header.Get("Range") != "" ||
+		req.Method == "HEAD" {
+		return rt.RoundTrip(req)
+	}
+	// Don't modify the caller's headers. The body, if any, is shared
+	// with req, so that it can still be rewound on ErrSkipAltProtocol.
+	r := *req
+	r.Header = req.Header.Clone()
+	r.Header.Set("Accept-Encoding", acceptEncoding)
+	resp, err := rt.RoundTrip(&r)
+	if err != nil {
+		return resp, err
+	}
+	resp.Request = req
+	coding := resp.Header.Get("Content-Encoding")
+	if !httpguts.HeaderValuesContainsToken([]string{acceptEncoding}, coding) {
+		return resp, nil
+	}
+	resp.Body = &decodingReader{body: resp.Body, coding: coding}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// decodingReader wraps a response body that was not read by the
+// Transport itself, such as an HTTP/2 response body, so it can lazily
+// create a gzip or zstd reader on the first call to Read.
+type decodingReader struct {
+	_      incomparable
+	body   io.ReadCloser // underlying Response.Body
+	coding string        // "gzip" or "zstd", in any case
+	r      io.Reader     // lazily-initialized decompressor
+	err    error         // any error from gzip.NewReader, or errReadOnClosedResBody; sticky
+}
+
+func (dr *decodingReader) Read(p []byte) (n int, err error) {
+	if dr.err != nil {
+		return 0, dr.err
+	}
+	if dr.r == nil {
+		if ascii.EqualFold(dr.coding, "zstd") {
+			dr.r = zstd.NewReader(dr.body)
+		} else if dr.r, dr.err = gzip.NewReader(dr.body); dr.err != nil {
+			return 0, dr.err
+		}
+	}
+	return dr.r.Read(p)
+}
+
+func (dr *decodingReader) Close() error {
+	dr.err = errReadOnClosedResBody
+	return dr.body.Close()
+}
+
 type tlsHandshakeTimeoutError struct{}
 
 func (tlsHandshakeTimeoutError) Timeout() bool   { return true }
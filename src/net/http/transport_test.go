@@ -13,6 +13,7 @@ import (
 	"bufio"
 	"bytes"
 	"compress/gzip"
+	"compress/zstd"
 	"context"
 	"crypto/rand"
 	"crypto/tls"
@@ -1089,6 +1090,69 @@ func testRoundTripGzip(t *testing.T, mode testMode) {
 
 }
 
+func TestTransportContentEncodings(t *testing.T) { run(t, testTransportContentEncodings) }
+func testTransportContentEncodings(t *testing.T, mode testMode) {
+	const testString = "The test string aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	ts := newClientServerTest(t, mode, HandlerFunc(func(rw ResponseWriter, req *Request) {
+		if g, e := req.Header.Get("Accept-Encoding"), req.FormValue("expect_accept"); g != e {
+			t.Errorf("Accept-Encoding = %q, want %q", g, e)
+		}
+		coding := req.FormValue("coding")
+		rw.Header().Set("Content-Encoding", coding)
+		var w io.WriteCloser
+		switch coding {
+		case "gzip":
+			w = gzip.NewWriter(rw)
+		case "zstd":
+			w = zstd.NewWriter(rw)
+		}
+		io.WriteString(w, testString)
+		w.Close()
+	})).ts
+	tr := ts.Client().Transport.(*Transport)
+
+	tests := []struct {
+		encodings []string
+		coding    string
+		accept    string
+		decoded   bool
+	}{
+		{nil, "gzip", "gzip", true},
+		{nil, "zstd", "gzip", false},
+		{[]string{"zstd", "gzip"}, "zstd", "zstd, gzip", true},
+		{[]string{"zstd", "gzip"}, "gzip", "zstd, gzip", true},
+		{[]string{"ZSTD", "br"}, "zstd", "zstd", true},
+		{[]string{"zstd"}, "gzip", "zstd", false},
+		{[]string{"br"}, "gzip", "gzip", true},
+	}
+	for _, test := range tests {
+		tr.ContentEncodings = test.encodings
+		res, err := ts.Client().Get(ts.URL + "?coding=" + test.coding + "&expect_accept=" + url.QueryEscape(test.accept))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Uncompressed != test.decoded {
+			t.Errorf("%v, %s: Uncompressed = %v, want %v", test.encodings, test.coding, res.Uncompressed, test.decoded)
+			continue
+		}
+		if test.decoded {
+			if string(body) != testString {
+				t.Errorf("%v, %s: body = %q, want %q", test.encodings, test.coding, body, testString)
+			}
+			if g := res.Header.Get("Content-Encoding"); g != "" {
+				t.Errorf("%v, %s: Content-Encoding = %q, want none", test.encodings, test.coding, g)
+			}
+		} else if g := res.Header.Get("Content-Encoding"); g != test.coding {
+			t.Errorf("%v, %s: Content-Encoding = %q, want %q", test.encodings, test.coding, g, test.coding)
+		}
+	}
+}
+
 func TestTransportGzip(t *testing.T) { run(t, testTransportGzip) }
 func testTransportGzip(t *testing.T, mode testMode) {
 	if mode == http2Mode {
@@ -6273,6 +6337,7 @@ func TestTransportClone(t *testing.T) {
 		TLSHandshakeTimeout:    time.Second,
 		DisableKeepAlives:      true,
 		DisableCompression:     true,
+		ContentEncodings:       []string{"zstd"},
 		MaxIdleConns:           1,
 		MaxIdleConnsPerHost:    1,
 		MaxConnsPerHost:        1,